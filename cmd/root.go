@@ -3,7 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +18,7 @@ import (
 	"github.com/opencode-ai/opencode/internal/format"
 	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/pubsub"
 	"github.com/opencode-ai/opencode/internal/tui"
 	"github.com/opencode-ai/opencode/internal/version"
@@ -45,7 +49,24 @@ to assist developers in writing, debugging, and understanding code directly from
 
   # Run a single non-interactive prompt with JSON output format
   opencode -p "Explain the use of context in Go" -f json
+
+  # Run a single non-interactive prompt with a user-defined agent
+  opencode -p "Review the changes of the last commit" --agent reviewer
+
+  # Stream the progress of a non-interactive prompt as JSON events
+  opencode -p "Fix the failing tests" -f stream-json
+
+  # Continue the last session with another non-interactive prompt
+  opencode -p "Now add a test for it" --continue
+
+  # Read the prompt from stdin, the arguments come before it
+  git diff | opencode -p - "review this"
+
+  # Attach images to a non-interactive prompt
+  opencode -p "Implement this mockup" --attach mockup.png
   `,
+	// The arguments are the instructions that come before a prompt read from stdin
+	Args: cobra.ArbitraryArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// If the help flag is set, show the help message
 		if cmd.Flag("help").Changed {
@@ -57,49 +78,53 @@ to assist developers in writing, debugging, and understanding code directly from
 			return nil
 		}
 
-		// Load the config
-		debug, _ := cmd.Flags().GetBool("debug")
-		cwd, _ := cmd.Flags().GetString("cwd")
 		prompt, _ := cmd.Flags().GetString("prompt")
 		outputFormat, _ := cmd.Flags().GetString("output-format")
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		agentName, _ := cmd.Flags().GetString("agent")
+		sessionID, _ := cmd.Flags().GetString("session")
+		continueLast, _ := cmd.Flags().GetBool("continue")
+		attachPaths, _ := cmd.Flags().GetStringArray("attach")
 
 		// Validate format option
 		if !format.IsValid(outputFormat) {
 			return fmt.Errorf("invalid format option: %s\n%s", outputFormat, format.GetHelpText())
 		}
-
-		if cwd != "" {
-			err := os.Chdir(cwd)
-			if err != nil {
-				return fmt.Errorf("failed to change directory: %v", err)
-			}
+		if sessionID != "" && continueLast {
+			return fmt.Errorf("--session and --continue can't be used together")
+		}
+		if prompt == "" && (sessionID != "" || continueLast || len(attachPaths) > 0) {
+			return fmt.Errorf("--session, --continue and --attach need a prompt")
 		}
-		if cwd == "" {
-			c, err := os.Getwd()
+		if prompt == "-" {
+			stdinPrompt, err := readPrompt(os.Stdin, args)
 			if err != nil {
-				return fmt.Errorf("failed to get current working directory: %v", err)
+				return err
 			}
-			cwd = c
+			prompt = stdinPrompt
+		} else if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments %q, only -p - takes arguments", args)
 		}
-		_, err := config.Load(cwd, debug)
+		// Paths are relative to where opencode was started, before --cwd
+		attachments, err := readAttachments(attachPaths)
 		if err != nil {
 			return err
 		}
-
-		// Connect DB, this will also run migrations
-		conn, err := db.Connect()
-		if err != nil {
-			return err
+		opts := app.NonInteractiveOptions{
+			OutputFormat: outputFormat,
+			Quiet:        quiet,
+			Agent:        config.AgentName(agentName),
+			SessionID:    sessionID,
+			Continue:     continueLast,
+			Attachments:  attachments,
 		}
 
 		// Create main context for the application
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
-		app, err := app.New(ctx, conn)
+		app, err := setupApp(ctx, cmd)
 		if err != nil {
-			logging.Error("Failed to create app: %v", err)
 			return err
 		}
 		// Defer shutdown here so it runs for both interactive and non-interactive modes
@@ -111,7 +136,7 @@ to assist developers in writing, debugging, and understanding code directly from
 		// Non-interactive mode
 		if prompt != "" {
 			// Run non-interactive flow using the App method
-			return app.RunNonInteractive(ctx, prompt, outputFormat, quiet)
+			return app.RunNonInteractive(ctx, prompt, opts)
 		}
 
 		// Interactive mode
@@ -183,6 +208,86 @@ to assist developers in writing, debugging, and understanding code directly from
 	},
 }
 
+// readPrompt reads a prompt from stdin. The arguments are instructions about
+// the input, they come before it.
+func readPrompt(stdin io.Reader, args []string) (string, error) {
+	input, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the prompt from stdin: %w", err)
+	}
+	parts := []string{}
+	if instructions := strings.TrimSpace(strings.Join(args, " ")); instructions != "" {
+		parts = append(parts, instructions)
+	}
+	if text := strings.TrimRight(string(input), "\n"); strings.TrimSpace(text) != "" {
+		parts = append(parts, text)
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty prompt on stdin")
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// readAttachments reads the images given with --attach, the MIME type is
+// detected like in the file picker of the TUI
+func readAttachments(paths []string) ([]message.Attachment, error) {
+	var attachments []message.Attachment
+	for _, path := range paths {
+		attachment, err := message.ReadAttachment(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach %s: %w", path, err)
+		}
+		switch attachment.MimeType {
+		case "image/jpeg", "image/png", "image/webp":
+		default:
+			return nil, fmt.Errorf("failed to attach %s: %s is not a supported image type", path, attachment.MimeType)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			attachment.FilePath = abs
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// setupApp changes into the requested working directory, loads the config,
+// connects the database and creates the app shared by all commands.
+func setupApp(ctx context.Context, cmd *cobra.Command) (*app.App, error) {
+	debug, _ := cmd.Flags().GetBool("debug")
+	cwd, _ := cmd.Flags().GetString("cwd")
+
+	if cwd != "" {
+		err := os.Chdir(cwd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to change directory: %v", err)
+		}
+	}
+	if cwd == "" {
+		c, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current working directory: %v", err)
+		}
+		cwd = c
+	}
+	_, err := config.Load(cwd, debug)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect DB, this will also run migrations
+	conn, err := db.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := app.New(ctx, conn)
+	if err != nil {
+		logging.Error("Failed to create app: %v", err)
+		return nil, err
+	}
+	return app, nil
+}
+
 // attemptTUIRecovery tries to recover the TUI after a panic
 func attemptTUIRecovery(program *tea.Program) {
 	logging.Info("Attempting to recover TUI after panic")
@@ -257,6 +362,8 @@ func setupSubscriptions(app *app.App, parentCtx context.Context) (chan tea.Msg,
 	setupSubscriber(ctx, &wg, "messages", app.Messages.Subscribe, ch)
 	setupSubscriber(ctx, &wg, "permissions", app.Permissions.Subscribe, ch)
 	setupSubscriber(ctx, &wg, "coderAgent", app.CoderAgent.Subscribe, ch)
+	setupSubscriber(ctx, &wg, "jobs", app.Jobs.Subscribe, ch)
+	setupSubscriber(ctx, &wg, "lsp", app.SubscribeLSP, ch)
 
 	cleanupFunc := func() {
 		logging.Info("Cancelling all subscriptions")
@@ -291,17 +398,27 @@ func Execute() {
 func init() {
 	rootCmd.Flags().BoolP("help", "h", false, "Help")
 	rootCmd.Flags().BoolP("version", "v", false, "Version")
-	rootCmd.Flags().BoolP("debug", "d", false, "Debug")
-	rootCmd.Flags().StringP("cwd", "c", "", "Current working directory")
-	rootCmd.Flags().StringP("prompt", "p", "", "Prompt to run in non-interactive mode")
+	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+	rootCmd.PersistentFlags().StringP("cwd", "c", "", "Current working directory")
+	rootCmd.Flags().StringP("prompt", "p", "", "Prompt to run in non-interactive mode, - reads it from stdin")
 
 	// Add format flag with validation logic
 	rootCmd.Flags().StringP("output-format", "f", format.Text.String(),
-		"Output format for non-interactive mode (text, json)")
+		"Output format for non-interactive mode (text, json, stream-json)")
 
 	// Add quiet flag to hide spinner in non-interactive mode
 	rootCmd.Flags().BoolP("quiet", "q", false, "Hide spinner in non-interactive mode")
 
+	// Add agent flag to answer with a user-defined agent in non-interactive mode
+	rootCmd.Flags().String("agent", "", "Agent to run the prompt with in non-interactive mode (defaults to coder)")
+
+	// Add session flags to keep a conversation going across non-interactive runs
+	rootCmd.Flags().String("session", "", "Session to continue in non-interactive mode")
+	rootCmd.Flags().Bool("continue", false, "Continue the last session in non-interactive mode")
+
+	// Add attach flag to send images with the prompt in non-interactive mode
+	rootCmd.Flags().StringArray("attach", nil, "Image to attach to the prompt in non-interactive mode, can be repeated")
+
 	// Register custom validation for the format flag
 	rootCmd.RegisterFlagCompletionFunc("output-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return format.SupportedFormats, cobra.ShellCompDirectiveNoFileComp
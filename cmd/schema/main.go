@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 
 	"github.com/opencode-ai/opencode/internal/config"
@@ -77,6 +78,246 @@ func generateSchema() map[string]any {
 		"default":     false,
 	}
 
+	schema["properties"].(map[string]any)["lspDiscovery"] = map[string]any{
+		"type":        "boolean",
+		"description": "Start the language servers found on the PATH for the languages of the project that have none configured",
+		"default":     true,
+	}
+
+	schema["properties"].(map[string]any)["compaction"] = map[string]any{
+		"type":        "object",
+		"description": "How sessions are summarized when they approach the context window",
+		"properties": map[string]any{
+			"threshold": map[string]any{
+				"type":             "number",
+				"description":      "Fraction of the context window at which the session is summarized automatically",
+				"default":          0.95,
+				"exclusiveMinimum": 0,
+				"maximum":          1,
+			},
+			"keepTurns": map[string]any{
+				"type":        "integer",
+				"description": "Number of recent turns kept verbatim, 0 summarizes the whole conversation",
+				"default":     2,
+				"minimum":     0,
+			},
+			"maxToolOutput": map[string]any{
+				"type":        "integer",
+				"description": "Number of characters above which old tool outputs are truncated, before being summarized and when sent to the model",
+				"default":     2000,
+				"minimum":     0,
+			},
+		},
+	}
+
+	schema["properties"].(map[string]any)["maxParallelTools"] = map[string]any{
+		"type":        "integer",
+		"description": "Maximum number of read-only tool calls executed concurrently",
+		"default":     4,
+		"minimum":     1,
+	}
+
+	budget := func(description string) map[string]any {
+		return map[string]any{
+			"type":        "object",
+			"description": description,
+			"properties": map[string]any{
+				"cost": map[string]any{
+					"type":        "number",
+					"description": "Maximum cost in dollars",
+					"minimum":     0,
+				},
+				"tokens": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of tokens sent to and received from the models",
+					"minimum":     0,
+				},
+			},
+		}
+	}
+	schema["properties"].(map[string]any)["budgets"] = map[string]any{
+		"type":        "object",
+		"description": "Spending limits that stop the agents",
+		"properties": map[string]any{
+			"session":  budget("Limit of a session, including its sub-agents"),
+			"daily":    budget("Limit of all the sessions of a day"),
+			"subAgent": budget("Limit of each sub-agent run"),
+			"warnAt": map[string]any{
+				"type":             "number",
+				"description":      "Fraction of a limit at which a warning is shown",
+				"default":          0.8,
+				"exclusiveMinimum": 0,
+				"maximum":          1,
+			},
+		},
+	}
+
+	schema["properties"].(map[string]any)["cassette"] = map[string]any{
+		"type":        "object",
+		"description": "Record the responses of the models to a file, or replay them from it without calling the providers",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Path of the cassette file",
+			},
+			"mode": map[string]any{
+				"type":        "string",
+				"description": "Whether to record or replay the responses",
+				"enum":        []string{"record", "replay"},
+			},
+		},
+	}
+
+	schema["properties"].(map[string]any)["permissions"] = map[string]any{
+		"type":        "array",
+		"description": "Rules allowing, denying or always asking for the requests of the tools, deny winning over ask and ask over allow",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"tool": map[string]any{
+					"type":        "string",
+					"description": "Tool name or glob pattern, every tool when empty",
+				},
+				"action": map[string]any{
+					"type":        "string",
+					"description": "What to do with the matching requests",
+					"enum":        []string{"allow", "deny", "ask"},
+				},
+				"command": map[string]any{
+					"type":        "string",
+					"description": "Glob matching the commands run by bash, like \"go test *\"",
+				},
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Glob matching the files changed by the editing tools, relative to the working directory, like \"migrations/**\"",
+				},
+				"agents": map[string]any{
+					"type":        "array",
+					"description": "Agents the rule applies to, all when empty",
+					"items":       map[string]any{"type": "string"},
+				},
+				"sessions": map[string]any{
+					"type":        "array",
+					"description": "IDs of the sessions the rule applies to, all when empty",
+					"items":       map[string]any{"type": "string"},
+				},
+				"exact": map[string]any{
+					"type":        "boolean",
+					"description": "Match the command as the whole command line, without wildcards or arguments",
+					"default":     false,
+				},
+			},
+			"required": []string{"action"},
+		},
+	}
+
+	schema["properties"].(map[string]any)["sandbox"] = map[string]any{
+		"type":        "object",
+		"description": "Sandbox of the bash commands on Linux, where only the working directory, the temporary directory and the writable paths can be written to",
+		"properties": map[string]any{
+			"enabled": map[string]any{
+				"type":        "boolean",
+				"description": "Run the bash commands in the sandbox",
+				"default":     false,
+			},
+			"writablePaths": map[string]any{
+				"type":        "array",
+				"description": "Extra paths the commands can write to",
+				"items":       map[string]any{"type": "string"},
+			},
+			"network": map[string]any{
+				"type":        "boolean",
+				"description": "Share the network of the host, the commands only have a loopback interface otherwise",
+				"default":     false,
+			},
+			"allowedPorts": map[string]any{
+				"type":        "array",
+				"description": "TCP ports the commands can connect to when the network is shared, all when empty",
+				"items":       map[string]any{"type": "integer", "minimum": 1, "maximum": 65535},
+			},
+			"autoApprove": map[string]any{
+				"type":        "boolean",
+				"description": "Run the sandboxed commands without asking, unless a permission rule denies or asks for them",
+				"default":     false,
+			},
+		},
+	}
+
+	schema["properties"].(map[string]any)["format"] = map[string]any{
+		"type":        "object",
+		"description": "Formatting of the files written by the edit, write and patch tools",
+		"properties": map[string]any{
+			"onWrite": map[string]any{
+				"type":        "boolean",
+				"description": "Format the files once they are written, with the formatter of their extension or else their language server",
+				"default":     false,
+			},
+			"formatters": map[string]any{
+				"type":        "array",
+				"description": "Commands formatting files in place, they take precedence over the language servers",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"extensions": map[string]any{
+							"type":        "array",
+							"description": "Extensions of the files, like .go",
+							"items":       map[string]any{"type": "string", "pattern": "^\\."},
+						},
+						"command": map[string]any{
+							"type":        "string",
+							"description": "Shell command formatting the file in $FILE",
+						},
+					},
+					"required": []string{"extensions", "command"},
+				},
+			},
+		},
+	}
+
+	hookSchema := map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{
+					"type":        "string",
+					"description": "Shell command receiving the event as JSON on stdin",
+				},
+				"tools": map[string]any{
+					"type":        "array",
+					"description": "Glob patterns of the tools the hook runs for, all when empty",
+					"items":       map[string]any{"type": "string"},
+				},
+				"timeout": map[string]any{
+					"type":        "integer",
+					"description": "Timeout of the hook in seconds",
+					"default":     60,
+					"minimum":     0,
+				},
+			},
+			"required": []string{"command"},
+		},
+	}
+	hookEvents := map[string]string{
+		"preToolUse":       "Hooks run before a tool, exiting with code 2 blocks it",
+		"postToolUse":      "Hooks run after a tool, they can add feedback to its result",
+		"userPromptSubmit": "Hooks run when a prompt is submitted, exiting with code 2 blocks it",
+		"stop":             "Hooks run when the agent finished responding",
+		"sessionStart":     "Hooks run when a session first runs",
+		"sessionEnd":       "Hooks run for the sessions that ran when OpenCode exits",
+	}
+	hookProperties := map[string]any{}
+	for event, description := range hookEvents {
+		property := maps.Clone(hookSchema)
+		property["description"] = description
+		hookProperties[event] = property
+	}
+	schema["properties"].(map[string]any)["hooks"] = map[string]any{
+		"type":        "object",
+		"description": "Shell commands run at events of the lifecycle of the agent",
+		"properties":  hookProperties,
+	}
+
 	schema["properties"].(map[string]any)["contextPaths"] = map[string]any{
 		"type":        "array",
 		"description": "Context paths for the application",
@@ -232,8 +473,36 @@ func generateSchema() map[string]any {
 					"description": "Reasoning effort for models that support it (OpenAI, Anthropic)",
 					"enum":        []string{"low", "medium", "high"},
 				},
+				"description": map[string]any{
+					"type":        "string",
+					"description": "Short description of a user-defined agent, shown when switching agents",
+				},
+				"prompt": map[string]any{
+					"type":        "string",
+					"description": "File with the system prompt of the agent, relative to the working directory",
+				},
+				"tools": map[string]any{
+					"type":        "array",
+					"description": "Tools the agent can use, as names or glob patterns (all tools when empty)",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"disabledTools": map[string]any{
+					"type":        "array",
+					"description": "Tools the agent can't use, as names or glob patterns",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"fallbacks": map[string]any{
+					"type":        "array",
+					"description": "Models tried in order when the model is overloaded, rate limited or the conversation exceeds its context window",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
 			},
-			"required": []string{"model"},
 		},
 	}
 
@@ -243,6 +512,7 @@ func generateSchema() map[string]any {
 		modelEnum = append(modelEnum, string(modelID))
 	}
 	agentSchema["additionalProperties"].(map[string]any)["properties"].(map[string]any)["model"].(map[string]any)["enum"] = modelEnum
+	agentSchema["additionalProperties"].(map[string]any)["properties"].(map[string]any)["fallbacks"].(map[string]any)["items"].(map[string]any)["enum"] = modelEnum
 
 	// Add specific agent properties
 	agentProperties := map[string]any{}
@@ -281,7 +551,7 @@ func generateSchema() map[string]any {
 			"properties": map[string]any{
 				"disabled": map[string]any{
 					"type":        "boolean",
-					"description": "Whether the LSP is disabled",
+					"description": "Whether the LSP is disabled, also keeps a discovered server of the language from starting",
 					"default":     false,
 				},
 				"command": map[string]any{
@@ -300,7 +570,6 @@ func generateSchema() map[string]any {
 					"description": "Additional options for the LSP server",
 				},
 			},
-			"required": []string{"command"},
 		},
 	}
 
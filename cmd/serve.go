@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/opencode-ai/opencode/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run OpenCode headless and expose it over a local HTTP API",
+	Long: `Start OpenCode without the terminal UI and serve sessions, messages,
+permission requests and agent events over HTTP. Events are streamed with
+Server-Sent Events on /events.
+
+Every request must send the token printed at startup in an
+"Authorization: Bearer <token>" header, and a JSON body with the
+"Content-Type: application/json" header. Only local clients are served,
+requests naming another host or sent by a web page are rejected.`,
+	Example: `
+  # Serve on the default address (127.0.0.1:4096)
+  opencode serve
+
+  # Serve a specific project on another port
+  opencode serve -c /path/to/project --port 8080
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetInt("port")
+
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		initMCPTools(ctx, app)
+
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		srv, err := server.New(app, addr)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("OpenCode server listening on http://%s\n", addr)
+		fmt.Printf("Token: %s\n", srv.Token())
+		return srv.Run(ctx)
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("host", server.DefaultHost, "Host to listen on")
+	serveCmd.Flags().Int("port", server.DefaultPort, "Port to listen on")
+
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,608 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/transcript"
+	"github.com/spf13/cobra"
+)
+
+var sessionCmd = &cobra.Command{
+	Use:     "sessions",
+	Aliases: []string{"session"},
+	Short:   "Manage the sessions of a project",
+	Long: `List, show, export, import and delete the sessions stored in the database
+of a project. Sub-agent sessions are handled with the session that started them.`,
+}
+
+var sessionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions, most recent first",
+	Example: `
+  # Sessions of the last week that cost more than a dollar
+  opencode sessions list --since 7d --min-cost 1
+
+  # Sessions about the parser, as JSON
+  opencode sessions list --title parser --json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		filter, err := sessionFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		sessions, err := filteredSessions(ctx, app, filter)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(sessions)
+		}
+		printSessions(os.Stdout, sessions)
+		return nil
+	},
+}
+
+var sessionShowCmd = &cobra.Command{
+	Use:   "show <session-id>",
+	Short: "Print the transcript of a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		sess, err := app.Sessions.Get(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("session %s not found: %w", args[0], err)
+		}
+		msgs, err := app.Messages.List(ctx, sess.ID)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(struct {
+				Session  session.Session   `json:"session"`
+				Messages []message.Message `json:"messages"`
+			}{sess, msgs})
+		}
+		printTranscript(os.Stdout, sess, msgs)
+		return nil
+	},
+}
+
+var sessionSearchCmd = &cobra.Command{
+	Use:   "search <query>...",
+	Short: "Search the messages of every session",
+	Long: `Search the prompts, answers, reasoning, tool inputs and tool results of every
+session for messages containing all the words of the query, the best matches
+first. Words are matched with their variants, a trailing * matches a prefix.`,
+	Example: `
+  # Find the session where the migration bug was fixed
+  opencode sessions search migration bug
+
+  # Sessions that ran a command, as JSON
+  opencode sessions search "goose*" --json | jq -r '.[].session_id' | sort -u
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		results, err := app.Messages.Search(ctx, strings.Join(args, " "), limit)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			if results == nil {
+				results = []message.SearchResult{}
+			}
+			return printJSON(results)
+		}
+		if len(results) == 0 {
+			fmt.Println("No messages found")
+			return nil
+		}
+		printSearchResults(os.Stdout, results)
+		return nil
+	},
+}
+
+var sessionExportCmd = &cobra.Command{
+	Use:   "export <session-id>",
+	Short: "Export a session as a JSON bundle, a Markdown or an HTML transcript",
+	Long: `Export a session, its messages, the versions of the files it changed, its
+checkpoints and the sessions of its sub-agents as a JSON bundle that opencode
+sessions import reads back. Paths inside the working directory are stored
+relative to it.
+
+The markdown and html formats render a readable transcript instead, with the
+prompts, the answers, the collapsed tool calls and the diff of the files each
+prompt changed. The HTML page is standalone and highlighted with the colors of
+the current theme.`,
+	Example: `
+  # Archive a session
+  opencode sessions export <session-id> -o parser-fix.json
+
+  # Attach a transcript to a pull request
+  opencode sessions export <session-id> --format markdown -o transcript.md
+
+  # Share a transcript with the reasoning of the model
+  opencode sessions export <session-id> --format html --reasoning -o transcript.html
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		format, _ := cmd.Flags().GetString("format")
+		reasoning, _ := cmd.Flags().GetBool("reasoning")
+		if format != "json" && !slices.Contains(transcript.SupportedFormats, format) {
+			return fmt.Errorf("invalid format %q, expected json, %s", format, strings.Join(transcript.SupportedFormats, ", "))
+		}
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		bundle, err := app.ExportSession(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if format == "json" {
+			data, err := json.MarshalIndent(bundle, "", "  ")
+			if err != nil {
+				return err
+			}
+			buf.Write(append(data, '\n'))
+		} else {
+			err := transcript.Render(&buf, transcript.Transcript{
+				Session:     bundle.Session,
+				Messages:    bundle.Messages,
+				Files:       bundle.Files,
+				Checkpoints: bundle.Checkpoints,
+			}, transcript.Format(format), transcript.Options{Reasoning: reasoning})
+			if err != nil {
+				return err
+			}
+		}
+		if output == "" || output == "-" {
+			_, err = os.Stdout.Write(buf.Bytes())
+			return err
+		}
+		return os.WriteFile(output, buf.Bytes(), 0o644)
+	},
+}
+
+var sessionImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a session exported with opencode sessions export",
+	Long: `Create a new session from an exported bundle, - reads it from stdin. The
+imported session gets new IDs, so the same bundle can be imported several times.
+The versions of files outside the working directory are skipped.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		var bundle app.SessionBundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return fmt.Errorf("invalid session bundle: %w", err)
+		}
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		sess, err := app.ImportSession(ctx, bundle)
+		if err != nil {
+			return err
+		}
+		fmt.Println(sess.ID)
+		return nil
+	},
+}
+
+var sessionDeleteCmd = &cobra.Command{
+	Use:   "delete <session-id>...",
+	Short: "Delete sessions with their messages and file versions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		var sessions []session.Session
+		for _, id := range args {
+			sess, err := app.Sessions.Get(ctx, id)
+			if err != nil {
+				return fmt.Errorf("session %s not found: %w", id, err)
+			}
+			sessions = append(sessions, sess)
+		}
+		return deleteSessions(ctx, app, sessions, yes)
+	},
+}
+
+var sessionPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete every session matching the filters",
+	Example: `
+  # Delete the sessions that weren't used for a month
+  opencode sessions prune --before 30d
+
+  # See which non-interactive sessions would be deleted
+  opencode sessions prune --title "Non-interactive:" --dry-run
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		filter, err := sessionFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if filter.isZero() {
+			return fmt.Errorf("prune needs at least one filter, use delete to remove given sessions")
+		}
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		sessions, err := filteredSessions(ctx, app, filter)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			printSessions(os.Stdout, sessions)
+			return nil
+		}
+		return deleteSessions(ctx, app, sessions, yes)
+	},
+}
+
+var sessionRevertCmd = &cobra.Command{
+	Use:   "revert <session-id>",
+	Short: "Restore the files of a session to a checkpoint",
+	Long: `Restore every file changed by the agent to its state when a prompt was sent,
+and mark that prompt and every message after it as reverted. A summary of the
+changes is printed and confirmed before anything is written.
+
+Without --to the last prompt of the session is reverted, undoing the last turn.`,
+	Example: `
+  # Undo the last turn of a session
+  opencode sessions revert <session-id>
+
+  # Go back to the state before a given prompt without asking
+  opencode sessions revert <session-id> --to <message-id> --yes
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		yes, _ := cmd.Flags().GetBool("yes")
+		showDiff, _ := cmd.Flags().GetBool("diff")
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		plan, err := app.PlanRevert(ctx, args[0], to)
+		if err != nil {
+			return err
+		}
+		fmt.Print(plan.Summary())
+		if showDiff {
+			for _, change := range plan.Changes {
+				fmt.Print(change.Diff)
+			}
+		}
+
+		if !yes && !confirm("Apply?") {
+			fmt.Println("Aborted")
+			return nil
+		}
+
+		if err := app.Revert(ctx, plan); err != nil {
+			return err
+		}
+		fmt.Println("Reverted")
+		return nil
+	},
+}
+
+// sessionFilter selects sessions by last activity, cost and title
+type sessionFilter struct {
+	since, before    time.Time
+	minCost, maxCost float64
+	title            string
+}
+
+func (f sessionFilter) isZero() bool {
+	return f == sessionFilter{}
+}
+
+func (f sessionFilter) match(sess session.Session) bool {
+	updated := time.Unix(sess.UpdatedAt, 0)
+	switch {
+	case !f.since.IsZero() && updated.Before(f.since):
+		return false
+	case !f.before.IsZero() && !updated.Before(f.before):
+		return false
+	case f.minCost > 0 && sess.Cost < f.minCost:
+		return false
+	case f.maxCost > 0 && sess.Cost > f.maxCost:
+		return false
+	case f.title != "" && !strings.Contains(strings.ToLower(sess.Title), strings.ToLower(f.title)):
+		return false
+	}
+	return true
+}
+
+func addSessionFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("since", "", "Only sessions used since a date (2006-01-02) or a duration ago (7d, 12h)")
+	cmd.Flags().String("before", "", "Only sessions not used since a date (2006-01-02) or a duration ago (30d)")
+	cmd.Flags().Float64("min-cost", 0, "Only sessions that cost at least this many dollars")
+	cmd.Flags().Float64("max-cost", 0, "Only sessions that cost at most this many dollars")
+	cmd.Flags().String("title", "", "Only sessions whose title contains this text, ignoring case")
+}
+
+func sessionFilterFromFlags(cmd *cobra.Command) (sessionFilter, error) {
+	var filter sessionFilter
+	var err error
+	since, _ := cmd.Flags().GetString("since")
+	if filter.since, err = parseSince(since, time.Now()); err != nil {
+		return sessionFilter{}, fmt.Errorf("invalid --since: %w", err)
+	}
+	before, _ := cmd.Flags().GetString("before")
+	if filter.before, err = parseSince(before, time.Now()); err != nil {
+		return sessionFilter{}, fmt.Errorf("invalid --before: %w", err)
+	}
+	filter.minCost, _ = cmd.Flags().GetFloat64("min-cost")
+	filter.maxCost, _ = cmd.Flags().GetFloat64("max-cost")
+	filter.title, _ = cmd.Flags().GetString("title")
+	return filter, nil
+}
+
+// parseSince parses a local date, or a duration before now that also
+// accepts days
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is neither a date nor a duration", value)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date nor a duration", value)
+	}
+	return now.Add(-d), nil
+}
+
+func filteredSessions(ctx context.Context, app *app.App, filter sessionFilter) ([]session.Session, error) {
+	sessions, err := app.Sessions.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(sessions, func(sess session.Session) bool {
+		return !filter.match(sess)
+	}), nil
+}
+
+func deleteSessions(ctx context.Context, app *app.App, sessions []session.Session, yes bool) error {
+	if len(sessions) == 0 {
+		fmt.Println("No sessions to delete")
+		return nil
+	}
+	printSessions(os.Stdout, sessions)
+	if !yes && !confirm(fmt.Sprintf("Delete %d sessions?", len(sessions))) {
+		fmt.Println("Aborted")
+		return nil
+	}
+	for _, sess := range sessions {
+		if err := app.Sessions.Delete(ctx, sess.ID); err != nil {
+			return fmt.Errorf("failed to delete session %s: %w", sess.ID, err)
+		}
+	}
+	fmt.Printf("Deleted %d sessions\n", len(sessions))
+	return nil
+}
+
+func printSessions(w io.Writer, sessions []session.Session) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUPDATED\tCOST\tMESSAGES\tTITLE")
+	for _, sess := range sessions {
+		fmt.Fprintf(tw, "%s\t%s\t$%.2f\t%d\t%s\n",
+			sess.ID,
+			time.Unix(sess.UpdatedAt, 0).Format("2006-01-02 15:04"),
+			sess.Cost,
+			sess.MessageCount,
+			sess.Title,
+		)
+	}
+	tw.Flush()
+}
+
+// printTranscript prints the conversation of a session, with the tool calls
+// and a preview of their results
+func printTranscript(w io.Writer, sess session.Session, msgs []message.Message) {
+	const maxResultLines = 10
+	fmt.Fprintf(w, "%s\n", sess.Title)
+	fmt.Fprintf(w, "Session %s, $%.2f, %d prompt and %d completion tokens\n",
+		sess.ID, sess.Cost, sess.PromptTokens, sess.CompletionTokens)
+	for _, msg := range msgs {
+		header := string(msg.Role)
+		if msg.Role == message.Assistant && msg.Model != "" {
+			header += " (" + string(msg.Model) + ")"
+		}
+		if msg.RevertedAt != 0 {
+			header += " [reverted]"
+		}
+		fmt.Fprintf(w, "\n── %s ── %s\n", header, time.Unix(msg.CreatedAt, 0).Format("2006-01-02 15:04:05"))
+		if text := strings.TrimSpace(msg.Content().String()); text != "" {
+			fmt.Fprintln(w, text)
+		}
+		for _, call := range msg.ToolCalls() {
+			fmt.Fprintf(w, "→ %s %s\n", call.Name, call.Input)
+		}
+		for _, result := range msg.ToolResults() {
+			lines := strings.Split(strings.TrimRight(result.Content, "\n"), "\n")
+			prefix := "←"
+			if result.IsError {
+				prefix = "← error:"
+			}
+			if len(lines) > maxResultLines {
+				lines = append(lines[:maxResultLines], fmt.Sprintf("… %d more lines", len(lines)-maxResultLines))
+			}
+			fmt.Fprintf(w, "%s %s\n", prefix, strings.Join(lines, "\n  "))
+		}
+	}
+}
+
+// printSearchResults prints the matching messages under their session, the
+// matched terms in bold
+func printSearchResults(w io.Writer, results []message.SearchResult) {
+	bold := lipgloss.NewStyle().Bold(true)
+	muted := lipgloss.NewStyle().Faint(true)
+	for i, result := range results {
+		if i == 0 || results[i-1].SessionID != result.SessionID {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s %s\n", bold.Render(result.SessionTitle), muted.Render(result.SessionID))
+		}
+		snippet := strings.Join(strings.Fields(result.Snippet), " ")
+		var highlighted strings.Builder
+		for {
+			start := strings.Index(snippet, message.MatchStart)
+			end := strings.Index(snippet, message.MatchEnd)
+			if start == -1 || end < start {
+				break
+			}
+			highlighted.WriteString(snippet[:start])
+			highlighted.WriteString(bold.Render(snippet[start+len(message.MatchStart) : end]))
+			snippet = snippet[end+len(message.MatchEnd):]
+		}
+		highlighted.WriteString(snippet)
+		fmt.Fprintf(w, "  %s %s %s\n    %s\n",
+			muted.Render(time.Unix(result.CreatedAt, 0).Format("2006-01-02 15:04")),
+			result.Role,
+			muted.Render(result.MessageID),
+			highlighted.String(),
+		)
+	}
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// confirm asks a yes or no question on the terminal, no being the default
+func confirm(question string) bool {
+	fmt.Print(question + " [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "y")
+}
+
+func init() {
+	sessionRevertCmd.Flags().String("to", "", "ID of the prompt to revert to (defaults to the last prompt)")
+	sessionRevertCmd.Flags().BoolP("yes", "y", false, "Apply the rollback without asking for confirmation")
+	sessionRevertCmd.Flags().Bool("diff", false, "Print the full diff of every restored file")
+
+	addSessionFilterFlags(sessionListCmd)
+	sessionListCmd.Flags().Bool("json", false, "Print the sessions as JSON")
+	sessionShowCmd.Flags().Bool("json", false, "Print the session and its messages as JSON")
+	sessionSearchCmd.Flags().IntP("limit", "n", 20, "Maximum number of messages to show")
+	sessionSearchCmd.Flags().Bool("json", false, "Print the results as JSON")
+	sessionExportCmd.Flags().StringP("output", "o", "", "File to write the export to (defaults to stdout)")
+	sessionExportCmd.Flags().StringP("format", "f", "json", "Export format (json, markdown, html)")
+	sessionExportCmd.Flags().Bool("reasoning", false, "Include the reasoning of the models in transcripts")
+	sessionDeleteCmd.Flags().BoolP("yes", "y", false, "Delete without asking for confirmation")
+	addSessionFilterFlags(sessionPruneCmd)
+	sessionPruneCmd.Flags().BoolP("yes", "y", false, "Delete without asking for confirmation")
+	sessionPruneCmd.Flags().Bool("dry-run", false, "Only list the sessions that would be deleted")
+
+	sessionCmd.AddCommand(
+		sessionListCmd,
+		sessionShowCmd,
+		sessionSearchCmd,
+		sessionExportCmd,
+		sessionImportCmd,
+		sessionDeleteCmd,
+		sessionPruneCmd,
+		sessionRevertCmd,
+	)
+	rootCmd.AddCommand(sessionCmd)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the tokens and cost of the requests sent to the models",
+	Long: `Report the usage recorded for every request sent to the models, totaled by
+day, model, session or agent. The usage of deleted and summarized sessions is
+still reported.`,
+	Example: `
+  # Cost per day over the last 30 days
+  opencode usage
+
+  # Cost per model this month, as CSV
+  opencode usage --by model --since 2025-06-01 --format csv
+
+  # The most expensive sessions of the week
+  opencode usage --by session --since 7d
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		by, _ := cmd.Flags().GetString("by")
+		format, _ := cmd.Flags().GetString("format")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		beforeFlag, _ := cmd.Flags().GetString("before")
+		if !slices.Contains(session.UsageGroupings, session.UsageGrouping(by)) {
+			return fmt.Errorf("unknown grouping %q, expected one of %v", by, session.UsageGroupings)
+		}
+		if !slices.Contains([]string{"table", "csv", "json"}, format) {
+			return fmt.Errorf("unknown format %q, expected table, csv or json", format)
+		}
+		now := time.Now()
+		since, err := parseSince(sinceFlag, now)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		before := now
+		if beforeFlag != "" {
+			if before, err = parseSince(beforeFlag, now); err != nil {
+				return fmt.Errorf("invalid --before: %w", err)
+			}
+		}
+
+		ctx := context.Background()
+		app, err := setupApp(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		// The ledger has a resolution of a second, the current one is included
+		usage, err := app.Sessions.ListUsage(ctx, since, before.Add(time.Second))
+		if err != nil {
+			return err
+		}
+		totals, err := session.TotalUsage(usage, session.UsageGrouping(by))
+		if err != nil {
+			return err
+		}
+		switch format {
+		case "json":
+			return printJSON(totals)
+		case "csv":
+			return printUsageCSV(os.Stdout, by, totals)
+		}
+		printUsage(os.Stdout, by, totals)
+		return nil
+	},
+}
+
+func printUsage(w io.Writer, by string, totals []session.UsageTotal) {
+	bySession := by == string(session.UsageBySession)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := strings.ToUpper(by) + "\tREQUESTS\tINPUT\tOUTPUT\tCACHE READ\tCACHE WRITE\tLATENCY\tCOST"
+	if bySession {
+		header += "\tTITLE"
+	}
+	fmt.Fprintln(tw, header)
+	sum := session.UsageTotal{Key: "TOTAL"}
+	for _, total := range totals {
+		row := usageRow(total)
+		if bySession {
+			row += "\t" + total.SessionTitle
+		}
+		fmt.Fprintln(tw, row)
+		sum.Requests += total.Requests
+		sum.InputTokens += total.InputTokens
+		sum.OutputTokens += total.OutputTokens
+		sum.CacheReadTokens += total.CacheReadTokens
+		sum.CacheWriteTokens += total.CacheWriteTokens
+		sum.Cost += total.Cost
+		sum.AverageLatencyMs += total.AverageLatencyMs * total.Requests
+	}
+	if sum.Requests > 0 {
+		sum.AverageLatencyMs /= sum.Requests
+	}
+	fmt.Fprintln(tw, usageRow(sum))
+	tw.Flush()
+}
+
+func usageRow(total session.UsageTotal) string {
+	return fmt.Sprintf("%s\t%d\t%d\t%d\t%d\t%d\t%s\t$%.2f",
+		total.Key,
+		total.Requests,
+		total.InputTokens,
+		total.OutputTokens,
+		total.CacheReadTokens,
+		total.CacheWriteTokens,
+		(time.Duration(total.AverageLatencyMs) * time.Millisecond).Round(100*time.Millisecond),
+		total.Cost,
+	)
+}
+
+func printUsageCSV(w io.Writer, by string, totals []session.UsageTotal) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{by, "session_title", "requests", "input_tokens", "output_tokens",
+		"cache_read_tokens", "cache_write_tokens", "average_latency_ms", "cost"})
+	for _, total := range totals {
+		cw.Write([]string{
+			total.Key,
+			total.SessionTitle,
+			strconv.FormatInt(total.Requests, 10),
+			strconv.FormatInt(total.InputTokens, 10),
+			strconv.FormatInt(total.OutputTokens, 10),
+			strconv.FormatInt(total.CacheReadTokens, 10),
+			strconv.FormatInt(total.CacheWriteTokens, 10),
+			strconv.FormatInt(total.AverageLatencyMs, 10),
+			strconv.FormatFloat(total.Cost, 'f', 6, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func init() {
+	usageCmd.Flags().String("by", "day", "Total the usage by day, model, session or agent")
+	usageCmd.Flags().StringP("format", "f", "table", "Output format (table, csv, json)")
+	usageCmd.Flags().String("since", "30d", "Only requests since a date (2006-01-02) or a duration ago (7d, 12h)")
+	usageCmd.Flags().String("before", "", "Only requests before a date (2006-01-02) or a duration ago (1d)")
+	rootCmd.AddCommand(usageCmd)
+}
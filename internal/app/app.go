@@ -1,11 +1,13 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"maps"
+	"os"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,11 +15,14 @@ import (
 	"github.com/opencode-ai/opencode/internal/db"
 	"github.com/opencode-ai/opencode/internal/format"
 	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/jobs"
 	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/llm/models"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/lsp"
 	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
 	"github.com/opencode-ai/opencode/internal/session"
 	"github.com/opencode-ai/opencode/internal/tui/theme"
 )
@@ -27,21 +32,28 @@ type App struct {
 	Messages    message.Service
 	History     history.Service
 	Permissions permission.Service
+	Jobs        jobs.Service
 
 	CoderAgent agent.Service
 
-	LSPClients map[string]*lsp.Client
+	// LSPClients are replaced when the servers restart
+	LSPClients *lsp.Clients
 
-	clientsMutex sync.RWMutex
+	// The language servers are supervised until lspStop is closed, and
+	// restarted on their lspRestarts channel
+	lspRestarts map[string]chan struct{}
+	lspStop     chan struct{}
+	lspEvents   *pubsub.Broker[LSPEvent]
+	watcherWG   sync.WaitGroup
 
-	watcherCancelFuncs []context.CancelFunc
-	cancelFuncsMutex   sync.Mutex
-	watcherWG          sync.WaitGroup
+	// shutdownOnce keeps Shutdown idempotent, it is called both on exit
+	// and by the deferred cleanup
+	shutdownOnce sync.Once
 }
 
 func New(ctx context.Context, conn *sql.DB) (*App, error) {
 	q := db.New(conn)
-	sessions := session.NewService(q)
+	sessions := session.NewService(q, conn)
 	messages := message.NewService(q)
 	files := history.NewService(q, conn)
 
@@ -49,26 +61,38 @@ func New(ctx context.Context, conn *sql.DB) (*App, error) {
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
-		Permissions: permission.NewPermissionService(),
-		LSPClients:  make(map[string]*lsp.Client),
+		Permissions: permission.NewPermissionService(config.Get().Permissions, permission.RulesFile()),
+		Jobs:        jobs.NewService(),
+		LSPClients:  lsp.NewClients(),
+		lspRestarts: make(map[string]chan struct{}),
+		lspStop:     make(chan struct{}),
+		lspEvents:   pubsub.NewBroker[LSPEvent](),
 	}
 
 	// Initialize theme based on configuration
 	app.initTheme()
 
-	// Initialize LSP clients in the background
-	go app.initLSPClients(ctx)
+	// Initialize LSP clients in the background, the servers are discovered
+	// first so that the agents get the LSP tools
+	app.initLSPClients(ctx)
 
 	var err error
 	app.CoderAgent, err = agent.NewAgent(
 		config.AgentCoder,
 		app.Sessions,
 		app.Messages,
+		app.History,
 		agent.CoderAgentTools(
 			app.Permissions,
 			app.Sessions,
 			app.Messages,
 			app.History,
+			app.Jobs,
+			app.LSPClients,
+		),
+		agent.PlanAgentTools(
+			app.Sessions,
+			app.Messages,
 			app.LSPClients,
 		),
 	)
@@ -96,44 +120,95 @@ func (app *App) initTheme() {
 	}
 }
 
+// NonInteractiveOptions are the options of a non-interactive run
+type NonInteractiveOptions struct {
+	OutputFormat string
+	Quiet        bool
+	// Agent runs the prompt with a user-defined agent, the coder when empty
+	Agent config.AgentName
+	// SessionID continues an existing session instead of creating one
+	SessionID string
+	// Continue continues the session that was updated last
+	Continue bool
+	// Attachments are sent with the prompt, the model must support them
+	Attachments []message.Attachment
+}
+
 // RunNonInteractive handles the execution flow when a prompt is provided via CLI flag.
-func (a *App) RunNonInteractive(ctx context.Context, prompt string, outputFormat string, quiet bool) error {
+func (a *App) RunNonInteractive(ctx context.Context, prompt string, opts NonInteractiveOptions) error {
 	logging.Info("Running in non-interactive mode")
+	outputFormat, _ := format.Parse(opts.OutputFormat)
+	streaming := outputFormat == format.StreamJSON
 
-	// Start spinner if not in quiet mode
+	// Start spinner if not in quiet mode, it would break the streamed events
 	var spinner *format.Spinner
-	if !quiet {
+	if !opts.Quiet && !streaming {
 		spinner = format.NewSpinner("Thinking...")
 		spinner.Start()
 		defer spinner.Stop()
 	}
 
-	const maxPromptLengthForTitle = 100
-	titlePrefix := "Non-interactive: "
-	var titleSuffix string
+	sess, created, err := a.nonInteractiveSession(ctx, prompt, opts)
+	if err != nil {
+		return err
+	}
 
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
+	agentName := config.AgentName(sess.Agent)
+	if opts.Agent != "" {
+		agentName = opts.Agent
+	}
+	if agentName == "" {
+		agentName = config.AgentCoder
+	}
+	model := models.SupportedModels[config.Get().Agents[agentName].Model]
+	if len(opts.Attachments) > 0 && !model.SupportsAttachments {
+		if created {
+			a.Sessions.Delete(ctx, sess.ID)
+		}
+		return fmt.Errorf("model %s doesn't support attachments", model.Name)
 	}
-	title := titlePrefix + titleSuffix
 
-	sess, err := a.Sessions.Create(ctx, title)
-	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	if opts.Agent != "" {
+		if err := a.CoderAgent.SetAgent(ctx, sess.ID, opts.Agent); err != nil {
+			if created {
+				a.Sessions.Delete(ctx, sess.ID)
+			}
+			return err
+		}
 	}
-	logging.Info("Created session for non-interactive run", "session_id", sess.ID)
 
 	// Automatically approve all permission requests for this non-interactive session
 	a.Permissions.AutoApproveSession(sess.ID)
 
-	done, err := a.CoderAgent.Run(ctx, sess.ID, prompt)
+	var stream *streamWriter
+	var streamDone <-chan struct{}
+	streamCtx, stopStream := context.WithCancel(ctx)
+	defer stopStream()
+	if streaming {
+		stream = newStreamWriter(os.Stdout, sess.ID)
+		if msgs, err := a.Messages.List(ctx, sess.ID); err == nil {
+			stream.skip(msgs)
+		}
+		stream.write(streamEvent{
+			Type:      streamEventStart,
+			SessionID: sess.ID,
+			Agent:     string(agentName),
+			Model:     string(model.ID),
+		})
+		streamDone = stream.follow(streamCtx, a)
+	}
+
+	done, err := a.CoderAgent.Run(ctx, sess.ID, prompt, opts.Attachments...)
 	if err != nil {
 		return fmt.Errorf("failed to start agent processing stream: %w", err)
 	}
 
 	result := <-done
+	if stream != nil {
+		stopStream()
+		<-streamDone
+		stream.finish(ctx, a, result)
+	}
 	if result.Error != nil {
 		if errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled) {
 			logging.Info("Agent processing cancelled", "session_id", sess.ID)
@@ -143,40 +218,96 @@ func (a *App) RunNonInteractive(ctx context.Context, prompt string, outputFormat
 	}
 
 	// Stop spinner before printing output
-	if !quiet && spinner != nil {
+	if spinner != nil {
 		spinner.Stop()
 	}
 
-	// Get the text content from the response
-	content := "No content available"
-	if result.Message.Content().String() != "" {
-		content = result.Message.Content().String()
+	if !streaming {
+		// Get the text content from the response
+		content := "No content available"
+		if result.Message.Content().String() != "" {
+			content = result.Message.Content().String()
+		}
+
+		fmt.Println(format.FormatOutput(content, opts.OutputFormat))
 	}
 
-	fmt.Println(format.FormatOutput(content, outputFormat))
+	// Scripts looping over -p need to know the run stopped half way
+	if result.Message.FinishReason() == message.FinishReasonBudgetExceeded && result.Budget != nil {
+		return fmt.Errorf("%w, %s", agent.ErrBudgetExceeded, result.Budget)
+	}
 
 	logging.Info("Non-interactive run completed", "session_id", sess.ID)
 
 	return nil
 }
 
-// Shutdown performs a clean shutdown of the application
-func (app *App) Shutdown() {
-	// Cancel all watcher goroutines
-	app.cancelFuncsMutex.Lock()
-	for _, cancel := range app.watcherCancelFuncs {
-		cancel()
+// nonInteractiveSession returns the session a non-interactive prompt runs in,
+// and whether it was created for it.
+func (a *App) nonInteractiveSession(ctx context.Context, prompt string, opts NonInteractiveOptions) (session.Session, bool, error) {
+	switch {
+	case opts.SessionID != "":
+		sess, err := a.Sessions.Get(ctx, opts.SessionID)
+		if err != nil {
+			return session.Session{}, false, fmt.Errorf("session %s not found: %w", opts.SessionID, err)
+		}
+		logging.Info("Continuing session in non-interactive mode", "session_id", sess.ID)
+		return sess, false, nil
+	case opts.Continue:
+		sessions, err := a.Sessions.List(ctx)
+		if err != nil {
+			return session.Session{}, false, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if len(sessions) == 0 {
+			return session.Session{}, false, fmt.Errorf("no session to continue")
+		}
+		sess := slices.MaxFunc(sessions, func(a, b session.Session) int {
+			return cmp.Compare(a.UpdatedAt, b.UpdatedAt)
+		})
+		logging.Info("Continuing session in non-interactive mode", "session_id", sess.ID)
+		return sess, false, nil
+	}
+
+	const maxPromptLengthForTitle = 100
+	titlePrefix := "Non-interactive: "
+	var titleSuffix string
+
+	if len(prompt) > maxPromptLengthForTitle {
+		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
+	} else {
+		titleSuffix = prompt
 	}
-	app.cancelFuncsMutex.Unlock()
+	title := titlePrefix + titleSuffix
+
+	sess, err := a.Sessions.Create(ctx, title)
+	if err != nil {
+		return session.Session{}, false, fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	}
+	logging.Info("Created session for non-interactive run", "session_id", sess.ID)
+	return sess, true, nil
+}
+
+// Shutdown performs a clean shutdown of the application, it can be called
+// more than once
+func (app *App) Shutdown() {
+	app.shutdownOnce.Do(app.shutdown)
+}
+
+func (app *App) shutdown() {
+	// Let the hooks know the sessions ended
+	endCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	app.CoderAgent.EndSessions(endCtx)
+	cancel()
+
+	// Kill the background jobs started by the agents
+	app.Jobs.Shutdown()
+
+	// Stop restarting the language servers and cancel all watcher goroutines
+	close(app.lspStop)
 	app.watcherWG.Wait()
 
 	// Perform additional cleanup for LSP clients
-	app.clientsMutex.RLock()
-	clients := make(map[string]*lsp.Client, len(app.LSPClients))
-	maps.Copy(clients, app.LSPClients)
-	app.clientsMutex.RUnlock()
-
-	for name, client := range clients {
+	for name, client := range app.LSPClients.All() {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		if err := client.Shutdown(shutdownCtx); err != nil {
 			logging.Error("Failed to shutdown LSP client", "name", name, "error", err)
@@ -0,0 +1,228 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// SessionBundleVersion is the version of the format of exported sessions
+const SessionBundleVersion = 1
+
+// SessionBundle is a portable copy of a session with its messages, the
+// versions of the files it changed and the sessions of its sub-agents. The
+// paths inside the working directory are relative, so the bundle can be
+// imported in another copy of a project.
+type SessionBundle struct {
+	Version     int                  `json:"version"`
+	Session     session.Session      `json:"session"`
+	Messages    []message.Message    `json:"messages"`
+	Files       []history.File       `json:"files"`
+	Checkpoints []history.Checkpoint `json:"checkpoints,omitempty"`
+	Children    []SessionBundle      `json:"children,omitempty"`
+}
+
+// ExportSession bundles a session to archive or share it
+func (a *App) ExportSession(ctx context.Context, sessionID string) (SessionBundle, error) {
+	sess, err := a.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return SessionBundle{}, fmt.Errorf("session %s not found: %w", sessionID, err)
+	}
+	msgs, err := a.Messages.List(ctx, sessionID)
+	if err != nil {
+		return SessionBundle{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	files, err := a.History.ListBySession(ctx, sessionID)
+	if err != nil {
+		return SessionBundle{}, fmt.Errorf("failed to list files: %w", err)
+	}
+	checkpoints, err := a.History.ListCheckpoints(ctx, sessionID)
+	if err != nil {
+		return SessionBundle{}, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	for i, file := range files {
+		files[i].Path = portablePath(file.Path)
+	}
+	for i, checkpoint := range checkpoints {
+		versions := make(map[string]string, len(checkpoint.Files))
+		for path, id := range checkpoint.Files {
+			versions[portablePath(path)] = id
+		}
+		checkpoints[i].Files = versions
+	}
+	children, err := a.Sessions.ListChildren(ctx, sessionID)
+	if err != nil {
+		return SessionBundle{}, fmt.Errorf("failed to list child sessions: %w", err)
+	}
+	bundles := make([]SessionBundle, 0, len(children))
+	for _, child := range children {
+		bundle, err := a.ExportSession(ctx, child.ID)
+		if err != nil {
+			return SessionBundle{}, err
+		}
+		bundles = append(bundles, bundle)
+	}
+	return SessionBundle{
+		Version:     SessionBundleVersion,
+		Session:     sess,
+		Messages:    msgs,
+		Files:       files,
+		Checkpoints: checkpoints,
+		Children:    bundles,
+	}, nil
+}
+
+// ImportSession creates a new session from a bundle. Everything gets new IDs,
+// so a bundle can be imported several times, and the relative paths are
+// resolved from the working directory. The versions of files outside of it
+// are skipped, restoring a checkpoint must not write anywhere else.
+func (a *App) ImportSession(ctx context.Context, bundle SessionBundle) (session.Session, error) {
+	if bundle.Version != SessionBundleVersion {
+		return session.Session{}, fmt.Errorf("unsupported session bundle version %d", bundle.Version)
+	}
+	var created []string
+	imported, err := a.importSession(ctx, bundle, "", &created)
+	if err != nil {
+		// Don't leave a half imported session behind
+		for _, id := range created {
+			a.Sessions.Delete(ctx, id)
+		}
+		return session.Session{}, err
+	}
+	return imported, nil
+}
+
+// importSession imports a session under a parent, then its children, and
+// records the IDs of the sessions it created
+func (a *App) importSession(ctx context.Context, bundle SessionBundle, parentID string, created *[]string) (session.Session, error) {
+	bundle.Session.ParentSessionID = parentID
+	sess, err := a.Sessions.Import(ctx, bundle.Session)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	*created = append(*created, sess.ID)
+
+	// Task sessions are named after the tool call that started them
+	childIDs := make(map[string]string, len(bundle.Children))
+	for _, child := range bundle.Children {
+		imported, err := a.importSession(ctx, child, sess.ID, created)
+		if err != nil {
+			return session.Session{}, err
+		}
+		childIDs[child.Session.ID] = imported.ID
+	}
+	return a.importSessionContent(ctx, sess, bundle, childIDs)
+}
+
+func (a *App) importSessionContent(ctx context.Context, sess session.Session, bundle SessionBundle, childIDs map[string]string) (session.Session, error) {
+	messageIDs := make(map[string]string, len(bundle.Messages))
+	for _, msg := range bundle.Messages {
+		imported, err := a.Messages.Import(ctx, sess.ID, withToolCallIDs(msg, childIDs))
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to import message %s: %w", msg.ID, err)
+		}
+		messageIDs[msg.ID] = imported.ID
+	}
+	fileIDs := make(map[string]string, len(bundle.Files))
+	for _, file := range bundle.Files {
+		path, err := localPath(file.Path)
+		if err != nil {
+			logging.Warn("Skipping file of the session bundle", "path", file.Path, "error", err)
+			continue
+		}
+		file.Path = path
+		imported, err := a.History.Import(ctx, sess.ID, file)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to import file %s: %w", file.Path, err)
+		}
+		fileIDs[file.ID] = imported.ID
+	}
+	for _, checkpoint := range bundle.Checkpoints {
+		messageID, ok := messageIDs[checkpoint.MessageID]
+		if !ok {
+			continue
+		}
+		versions := make(map[string]string, len(checkpoint.Files))
+		for path, id := range checkpoint.Files {
+			fileID, ok := fileIDs[id]
+			if !ok {
+				continue
+			}
+			if path, err := localPath(path); err == nil {
+				versions[path] = fileID
+			}
+		}
+		checkpoint.MessageID = messageID
+		checkpoint.Files = versions
+		if _, err := a.History.ImportCheckpoint(ctx, sess.ID, checkpoint); err != nil {
+			return session.Session{}, fmt.Errorf("failed to import checkpoint: %w", err)
+		}
+	}
+
+	// The message count was kept up to date while importing the messages
+	sess, err := a.Sessions.Get(ctx, sess.ID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	if summaryID, ok := messageIDs[bundle.Session.SummaryMessageID]; ok {
+		sess.SummaryMessageID = summaryID
+		return a.Sessions.Save(ctx, sess)
+	}
+	return sess, nil
+}
+
+// withToolCallIDs renames the tool calls of a message, and their results,
+// that started the child sessions given by their old and new IDs
+func withToolCallIDs(msg message.Message, ids map[string]string) message.Message {
+	if len(ids) == 0 {
+		return msg
+	}
+	parts := slices.Clone(msg.Parts)
+	for i, part := range parts {
+		switch part := part.(type) {
+		case message.ToolCall:
+			if id, ok := ids[part.ID]; ok {
+				part.ID = id
+				parts[i] = part
+			}
+		case message.ToolResult:
+			if id, ok := ids[part.ToolCallID]; ok {
+				part.ToolCallID = id
+				parts[i] = part
+			}
+		}
+	}
+	msg.Parts = parts
+	return msg
+}
+
+// portablePath makes the paths inside the working directory relative
+func portablePath(path string) string {
+	rel, err := filepath.Rel(config.WorkingDirectory(), path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// localPath resolves a path of a bundle from the working directory, it fails
+// for the paths outside of it
+func localPath(path string) (string, error) {
+	local := filepath.FromSlash(path)
+	if !filepath.IsAbs(local) {
+		local = filepath.Join(config.WorkingDirectory(), local)
+	}
+	if filepath.IsAbs(portablePath(local)) {
+		return "", fmt.Errorf("path %s is outside the working directory", path)
+	}
+	return local, nil
+}
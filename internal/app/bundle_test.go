@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBundleApp returns an app on a new database, in a new working directory
+func newBundleApp(t *testing.T) *App {
+	t.Helper()
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = dir
+	config.Get().WorkingDir = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	return &App{
+		Sessions: session.NewService(q, conn),
+		Messages: message.NewService(q),
+		History:  history.NewService(q, conn),
+	}
+}
+
+func TestSessionBundleRoundTrip(t *testing.T) {
+	a := newBundleApp(t)
+	ctx := context.Background()
+
+	sess, err := a.Sessions.Create(ctx, "Fix the parser")
+	require.NoError(t, err)
+	prompt, err := a.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "fix it"}},
+	})
+	require.NoError(t, err)
+	path := filepath.Join(config.WorkingDirectory(), "parser.go")
+	_, err = a.History.Create(ctx, sess.ID, path, "package old")
+	require.NoError(t, err)
+	_, err = a.History.CreateCheckpoint(ctx, sess.ID, prompt.ID)
+	require.NoError(t, err)
+
+	bundle, err := a.ExportSession(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, bundle.Files, 1)
+	assert.Equal(t, "parser.go", bundle.Files[0].Path)
+
+	// Bundles go through JSON
+	data, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	var decoded SessionBundle
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	for range 2 {
+		imported, err := a.ImportSession(ctx, decoded)
+		require.NoError(t, err)
+		assert.NotEqual(t, sess.ID, imported.ID)
+		assert.Equal(t, sess.Title, imported.Title)
+		assert.EqualValues(t, 1, imported.MessageCount)
+
+		msgs, err := a.Messages.List(ctx, imported.ID)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "fix it", msgs[0].Content().String())
+
+		checkpoint, err := a.History.GetCheckpoint(ctx, msgs[0].ID)
+		require.NoError(t, err)
+		file, err := a.History.Get(ctx, checkpoint.Files[path])
+		require.NoError(t, err)
+		assert.Equal(t, imported.ID, file.SessionID)
+		assert.Equal(t, "package old", file.Content)
+	}
+
+	_, err = a.ImportSession(ctx, SessionBundle{Version: SessionBundleVersion + 1})
+	assert.Error(t, err)
+}
+
+func TestSessionBundleChildren(t *testing.T) {
+	a := newBundleApp(t)
+	ctx := context.Background()
+
+	sess, err := a.Sessions.Create(ctx, "Explore")
+	require.NoError(t, err)
+	call := message.ToolCall{ID: "call-1", Name: "agent", Input: `{}`, Type: "tool_use", Finished: true}
+	_, err = a.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{call},
+	})
+	require.NoError(t, err)
+	_, err = a.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{ToolCallID: call.ID, Content: "found it"}},
+	})
+	require.NoError(t, err)
+	task, err := a.Sessions.CreateTaskSession(ctx, call.ID, sess.ID, "Find the parser")
+	require.NoError(t, err)
+	_, err = a.Messages.Create(ctx, task.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "find the parser"}},
+	})
+	require.NoError(t, err)
+
+	bundle, err := a.ExportSession(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, bundle.Children, 1)
+	assert.Equal(t, task.ID, bundle.Children[0].Session.ID)
+
+	imported, err := a.ImportSession(ctx, bundle)
+	require.NoError(t, err)
+	children, err := a.Sessions.ListChildren(ctx, imported.ID)
+	require.NoError(t, err)
+	require.Len(t, children, 1)
+	child := children[0]
+	assert.NotEqual(t, task.ID, child.ID)
+	assert.Equal(t, "Find the parser", child.Title)
+
+	// The tool call still leads to the messages of the task
+	msgs, err := a.Messages.List(ctx, imported.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, child.ID, msgs[0].ToolCalls()[0].ID)
+	assert.Equal(t, child.ID, msgs[1].ToolResults()[0].ToolCallID)
+	taskMsgs, err := a.Messages.List(ctx, child.ID)
+	require.NoError(t, err)
+	require.Len(t, taskMsgs, 1)
+	assert.Equal(t, "find the parser", taskMsgs[0].Content().String())
+}
+
+func TestSessionBundleOutsidePaths(t *testing.T) {
+	a := newBundleApp(t)
+	ctx := context.Background()
+	wd := config.WorkingDirectory()
+
+	sess, err := a.Sessions.Create(ctx, "Outside")
+	require.NoError(t, err)
+	prompt, err := a.Messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "change the files"}},
+	})
+	require.NoError(t, err)
+	inside := filepath.Join(wd, "inside.go")
+	_, err = a.History.Create(ctx, sess.ID, inside, "package inside")
+	require.NoError(t, err)
+	_, err = a.History.Create(ctx, sess.ID, filepath.Join(filepath.Dir(wd), "outside.go"), "package outside")
+	require.NoError(t, err)
+	_, err = a.History.CreateCheckpoint(ctx, sess.ID, prompt.ID)
+	require.NoError(t, err)
+
+	bundle, err := a.ExportSession(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, bundle.Files, 2)
+	// A relative path can escape the working directory too
+	bundle.Files = append(bundle.Files, history.File{ID: "escape", Path: "../escape.go", Content: "package escape"})
+
+	imported, err := a.ImportSession(ctx, bundle)
+	require.NoError(t, err)
+	files, err := a.History.ListBySession(ctx, imported.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, inside, files[0].Path)
+	msgs, err := a.Messages.List(ctx, imported.ID)
+	require.NoError(t, err)
+	checkpoint, err := a.History.GetCheckpoint(ctx, msgs[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, checkpoint.Files, 1)
+	assert.Contains(t, checkpoint.Files, inside)
+}
@@ -2,84 +2,230 @@ package app
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/lsp"
 	"github.com/opencode-ai/opencode/internal/lsp/watcher"
+	"github.com/opencode-ai/opencode/internal/pubsub"
 )
 
+const (
+	// lspRestartDelay is the delay before restarting a crashed server, it
+	// doubles with each crash in a row up to lspMaxRestartDelay
+	lspRestartDelay    = time.Second
+	lspMaxRestartDelay = time.Minute
+	// lspStableTime is how long a server must run for its earlier crashes to
+	// be forgotten
+	lspStableTime = 5 * time.Minute
+	// lspMaxCrashes is the number of crashes in a row after which a server is
+	// left crashed until it is restarted by hand
+	lspMaxCrashes = 5
+)
+
+// LSPEvent is published when the state of a language server changes
+type LSPEvent struct {
+	Name  string          `json:"name"`
+	State lsp.ServerState `json:"state"`
+}
+
+// initLSPClients starts the configured language servers, and the ones found
+// for the languages of the project when discovery is on. The servers start in
+// the background and are restarted when they crash.
 func (app *App) initLSPClients(ctx context.Context) {
 	cfg := config.Get()
 
-	// Initialize LSP clients
+	// The discovered servers are added to the config, for the tools
+	if cfg.LSPDiscovery {
+		for name, clientConfig := range lsp.DiscoverServers(config.WorkingDirectory(), cfg.LSP) {
+			logging.Info("Discovered LSP server", "name", name, "command", clientConfig.Command)
+			cfg.LSP[name] = clientConfig
+		}
+	}
+
 	for name, clientConfig := range cfg.LSP {
-		// Start each client initialization in its own goroutine
-		go app.createAndStartLSPClient(ctx, name, clientConfig.Command, clientConfig.Args...)
+		if clientConfig.Disabled {
+			continue
+		}
+		restart := make(chan struct{}, 1)
+		app.lspRestarts[name] = restart
+		go app.superviseLSPClient(ctx, name, clientConfig, restart)
 	}
 	logging.Info("LSP clients initialization started in background")
 }
 
-// createAndStartLSPClient creates a new LSP client, initializes it, and starts its workspace watcher
-func (app *App) createAndStartLSPClient(ctx context.Context, name string, command string, args ...string) {
+// SubscribeLSP returns the events of the changes of the state of the
+// language servers
+func (app *App) SubscribeLSP(ctx context.Context) <-chan pubsub.Event[LSPEvent] {
+	return app.lspEvents.Subscribe(ctx)
+}
+
+// RestartLSPClients restarts the language servers, including the ones left
+// crashed, and returns their names
+func (app *App) RestartLSPClients() []string {
+	names := slices.Sorted(maps.Keys(app.lspRestarts))
+	for _, name := range names {
+		app.restartLSPClient(name)
+	}
+	return names
+}
+
+// restartLSPClient asks the supervisor of a server to restart it
+func (app *App) restartLSPClient(name string) {
+	select {
+	case app.lspRestarts[name] <- struct{}{}:
+	default:
+	}
+}
+
+// superviseLSPClient runs a language server until ctx is done or the app
+// shuts down, restarting it with a growing delay when it crashes and when a
+// restart is asked
+func (app *App) superviseLSPClient(ctx context.Context, name string, clientConfig config.LSPConfig, restart <-chan struct{}) {
+	crashes := 0
+	for {
+		started := time.Now()
+		client, err := app.createAndStartLSPClient(ctx, name, clientConfig.Command, clientConfig.Args...)
+		if err == nil {
+			watchCtx, cancelWatch := context.WithCancel(ctx)
+			app.startWorkspaceWatcher(watchCtx, name, client)
+
+			select {
+			case <-ctx.Done():
+				cancelWatch()
+				return
+			case <-app.lspStop:
+				cancelWatch()
+				return
+			case <-restart:
+				cancelWatch()
+				logging.Info("Restarting LSP client", "name", name)
+				if err := client.Close(); err != nil {
+					logging.Debug("Failed to stop LSP client", "name", name, "error", err)
+				}
+				crashes = 0
+				continue
+			case <-client.Done():
+				cancelWatch()
+				err = client.ExitError()
+				if err == nil {
+					err = fmt.Errorf("server exited")
+				}
+			}
+		}
+		if app.lspStopped(ctx) {
+			return
+		}
+
+		if time.Since(started) > lspStableTime {
+			crashes = 0
+		}
+		crashes++
+		if client != nil {
+			app.setLSPState(name, client, lsp.StateCrashed)
+		}
+		if crashes >= lspMaxCrashes {
+			logging.ErrorPersist(fmt.Sprintf("LSP server %s crashed %d times in a row, restart it from the command palette: %v", name, crashes, err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-app.lspStop:
+				return
+			case <-restart:
+				crashes = 0
+			}
+			continue
+		}
+		delay := min(lspRestartDelay<<(crashes-1), lspMaxRestartDelay)
+		logging.Warn("LSP server crashed, restarting it", "name", name, "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.lspStop:
+			return
+		case <-restart:
+			crashes = 0
+		case <-time.After(delay):
+		}
+	}
+}
+
+// lspStopped reports whether the servers are no longer supervised
+func (app *App) lspStopped(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-app.lspStop:
+		return true
+	default:
+		return false
+	}
+}
+
+// createAndStartLSPClient creates a new LSP client, adds it to the clients
+// and initializes it
+func (app *App) createAndStartLSPClient(ctx context.Context, name string, command string, args ...string) (*lsp.Client, error) {
 	// Create a specific context for initialization with a timeout
 	logging.Info("Creating LSP client", "name", name, "command", command, "args", args)
-	
+
 	// Create the LSP client
 	lspClient, err := lsp.NewClient(ctx, command, args...)
 	if err != nil {
 		logging.Error("Failed to create LSP client for", name, err)
-		return
+		return nil, err
 	}
 
+	// Replace the client of the server, the starting servers are shown
+	app.LSPClients.Set(name, lspClient)
+	app.setLSPState(name, lspClient, lsp.StateStarting)
+
 	// Create a longer timeout for initialization (some servers take time to start)
 	initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	
+
 	// Initialize with the initialization context
 	_, err = lspClient.InitializeLSPClient(initCtx, config.WorkingDirectory())
 	if err != nil {
 		logging.Error("Initialize failed", "name", name, "error", err)
 		// Clean up the client to prevent resource leaks
 		lspClient.Close()
-		return
+		return lspClient, err
 	}
 
 	// Wait for the server to be ready
 	if err := lspClient.WaitForServerReady(initCtx); err != nil {
 		logging.Error("Server failed to become ready", "name", name, "error", err)
 		// We'll continue anyway, as some functionality might still work
-		lspClient.SetServerState(lsp.StateError)
+		app.setLSPState(name, lspClient, lsp.StateError)
 	} else {
 		logging.Info("LSP server is ready", "name", name)
-		lspClient.SetServerState(lsp.StateReady)
+		app.setLSPState(name, lspClient, lsp.StateReady)
 	}
 
 	logging.Info("LSP client initialized", "name", name)
-	
-	// Create a child context that can be canceled when the app is shutting down
-	watchCtx, cancelFunc := context.WithCancel(ctx)
-	
+	return lspClient, nil
+}
+
+// setLSPState sets the state of a client and publishes it
+func (app *App) setLSPState(name string, client *lsp.Client, state lsp.ServerState) {
+	client.SetServerState(state)
+	app.lspEvents.Publish(pubsub.UpdatedEvent, LSPEvent{Name: name, State: state})
+}
+
+// startWorkspaceWatcher watches the workspace for a client until ctx is done
+func (app *App) startWorkspaceWatcher(ctx context.Context, name string, lspClient *lsp.Client) {
 	// Create a context with the server name for better identification
-	watchCtx = context.WithValue(watchCtx, "serverName", name)
-	
+	watchCtx := context.WithValue(ctx, "serverName", name)
+
 	// Create the workspace watcher
 	workspaceWatcher := watcher.NewWorkspaceWatcher(lspClient)
 
-	// Store the cancel function to be called during cleanup
-	app.cancelFuncsMutex.Lock()
-	app.watcherCancelFuncs = append(app.watcherCancelFuncs, cancelFunc)
-	app.cancelFuncsMutex.Unlock()
-
 	// Add the watcher to a WaitGroup to track active goroutines
 	app.watcherWG.Add(1)
-
-	// Add to map with mutex protection before starting goroutine
-	app.clientsMutex.Lock()
-	app.LSPClients[name] = lspClient
-	app.clientsMutex.Unlock()
-
 	go app.runWorkspaceWatcher(watchCtx, name, workspaceWatcher)
 }
 
@@ -88,39 +234,9 @@ func (app *App) runWorkspaceWatcher(ctx context.Context, name string, workspaceW
 	defer app.watcherWG.Done()
 	defer logging.RecoverPanic("LSP-"+name, func() {
 		// Try to restart the client
-		app.restartLSPClient(ctx, name)
+		app.restartLSPClient(name)
 	})
 
 	workspaceWatcher.WatchWorkspace(ctx, config.WorkingDirectory())
 	logging.Info("Workspace watcher stopped", "client", name)
 }
-
-// restartLSPClient attempts to restart a crashed or failed LSP client
-func (app *App) restartLSPClient(ctx context.Context, name string) {
-	// Get the original configuration
-	cfg := config.Get()
-	clientConfig, exists := cfg.LSP[name]
-	if !exists {
-		logging.Error("Cannot restart client, configuration not found", "client", name)
-		return
-	}
-
-	// Clean up the old client if it exists
-	app.clientsMutex.Lock()
-	oldClient, exists := app.LSPClients[name]
-	if exists {
-		delete(app.LSPClients, name) // Remove from map before potentially slow shutdown
-	}
-	app.clientsMutex.Unlock()
-
-	if exists && oldClient != nil {
-		// Try to shut it down gracefully, but don't block on errors
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		_ = oldClient.Shutdown(shutdownCtx)
-		cancel()
-	}
-
-	// Create a new client using the shared function
-	app.createAndStartLSPClient(ctx, name, clientConfig.Command, clientConfig.Args...)
-	logging.Info("Successfully restarted LSP client", "client", name)
-}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+// RevertPlan describes what reverting a session to the checkpoint of one of
+// its prompts does, so it can be reviewed before it is applied.
+type RevertPlan struct {
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	// Prompt is the text of the prompt the session is reverted to
+	Prompt string `json:"prompt"`
+	// Messages are marked as reverted, starting with the prompt itself
+	Messages []message.Message    `json:"-"`
+	Changes  []history.FileChange `json:"changes"`
+}
+
+// PlanRevert prepares reverting a session to the checkpoint taken when the
+// given user message was sent. An empty messageID picks the last prompt that
+// was not reverted yet, which undoes the last turn.
+func (a *App) PlanRevert(ctx context.Context, sessionID, messageID string) (RevertPlan, error) {
+	msgs, err := a.Messages.List(ctx, sessionID)
+	if err != nil {
+		return RevertPlan{}, err
+	}
+
+	idx := -1
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if messageID == "" && msgs[i].Role == message.User && msgs[i].RevertedAt == 0 {
+			idx = i
+			break
+		}
+		if messageID != "" && msgs[i].ID == messageID {
+			idx = i
+			break
+		}
+	}
+	switch {
+	case idx == -1 && messageID == "":
+		return RevertPlan{}, errors.New("nothing to undo")
+	case idx == -1:
+		return RevertPlan{}, fmt.Errorf("message %s not found in session %s", messageID, sessionID)
+	case msgs[idx].Role != message.User:
+		return RevertPlan{}, fmt.Errorf("message %s is not a prompt, only prompts have checkpoints", msgs[idx].ID)
+	case msgs[idx].RevertedAt != 0:
+		return RevertPlan{}, fmt.Errorf("message %s was already reverted", msgs[idx].ID)
+	}
+
+	checkpoint, err := a.History.GetCheckpoint(ctx, msgs[idx].ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RevertPlan{}, fmt.Errorf("no checkpoint was recorded for message %s", msgs[idx].ID)
+	}
+	if err != nil {
+		return RevertPlan{}, err
+	}
+	changes, err := a.History.PlanRestore(ctx, checkpoint)
+	if err != nil {
+		return RevertPlan{}, err
+	}
+
+	plan := RevertPlan{
+		SessionID: sessionID,
+		MessageID: msgs[idx].ID,
+		Prompt:    msgs[idx].Content().String(),
+		Changes:   changes,
+	}
+	for _, msg := range msgs[idx:] {
+		if msg.RevertedAt == 0 {
+			plan.Messages = append(plan.Messages, msg)
+		}
+	}
+	return plan, nil
+}
+
+// Revert restores the files of a plan and marks its messages as reverted
+func (a *App) Revert(ctx context.Context, plan RevertPlan) error {
+	if a.CoderAgent.IsSessionBusy(plan.SessionID) {
+		return agent.ErrSessionBusy
+	}
+	if err := a.History.Restore(ctx, plan.SessionID, plan.Changes); err != nil {
+		return fmt.Errorf("failed to restore files: %w", err)
+	}
+
+	session, err := a.Sessions.Get(ctx, plan.SessionID)
+	if err != nil {
+		return err
+	}
+	for _, msg := range plan.Messages {
+		if err := a.Messages.Revert(ctx, msg.ID); err != nil {
+			return fmt.Errorf("failed to revert message: %w", err)
+		}
+		// A summary that was rolled back can't be used to cut the history anymore
+		if msg.ID == session.SummaryMessageID {
+			session.SummaryMessageID = ""
+			if _, err := a.Sessions.Save(ctx, session); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Summary lists the files a plan changes, in the style of git status
+func (p RevertPlan) Summary() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Reverting %d message(s) starting with %q\n", len(p.Messages), firstLine(p.Prompt))
+	if len(p.Changes) == 0 {
+		sb.WriteString("No file changes to restore\n")
+		return sb.String()
+	}
+	additions, removals := 0, 0
+	for _, change := range p.Changes {
+		path := change.Path
+		if rel, err := filepath.Rel(config.WorkingDirectory(), path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		status := "M"
+		if change.Removed {
+			status = "D"
+		}
+		fmt.Fprintf(&sb, "  %s %s (+%d -%d)\n", status, path, change.Additions, change.Removals)
+		additions += change.Additions
+		removals += change.Removals
+	}
+	fmt.Fprintf(&sb, "%d file(s) changed, %d insertion(s), %d deletion(s)\n", len(p.Changes), additions, removals)
+	return sb.String()
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	const maxLength = 60
+	if runes := []rune(s); len(runes) > maxLength {
+		s = string(runes[:maxLength]) + "..."
+	}
+	return s
+}
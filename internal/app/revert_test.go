@@ -0,0 +1,18 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstLine(t *testing.T) {
+	assert.Equal(t, "fix the parser", firstLine("  fix the parser\nand the tests"))
+
+	long := strings.Repeat("é", 70)
+	line := firstLine(long)
+	assert.True(t, utf8.ValidString(line))
+	assert.Equal(t, strings.Repeat("é", 60)+"...", line)
+}
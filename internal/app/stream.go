@@ -0,0 +1,264 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// Types of the events of the stream-json output format
+const (
+	streamEventStart         = "start"
+	streamEventContentDelta  = "content_delta"
+	streamEventThinkingDelta = "thinking_delta"
+	streamEventToolCall      = "tool_call"
+	streamEventToolResult    = "tool_result"
+	streamEventPermission    = "permission"
+	streamEventUsage         = "usage"
+	streamEventResult        = "result"
+)
+
+// streamEvent is a line of the stream-json output. SessionID is the one of a
+// sub-agent for the events of its run.
+type streamEvent struct {
+	Type       string                        `json:"type"`
+	SessionID  string                        `json:"session_id"`
+	MessageID  string                        `json:"message_id,omitempty"`
+	Agent      string                        `json:"agent,omitempty"`
+	Model      string                        `json:"model,omitempty"`
+	Text       string                        `json:"text,omitempty"`
+	ToolCall   *message.ToolCall             `json:"tool_call,omitempty"`
+	ToolResult *message.ToolResult           `json:"tool_result,omitempty"`
+	Permission *permission.PermissionRequest `json:"permission,omitempty"`
+	Usage      *streamUsage                  `json:"usage,omitempty"`
+
+	// Set on the result event
+	Result       string               `json:"result,omitempty"`
+	FinishReason message.FinishReason `json:"finish_reason,omitempty"`
+	Budget       *agent.BudgetStatus  `json:"budget,omitempty"`
+	IsError      bool                 `json:"is_error,omitempty"`
+	Error        string               `json:"error,omitempty"`
+}
+
+// streamUsage is the total usage of a session so far
+type streamUsage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// streamWriter writes the progress of a non-interactive run as one JSON event
+// per line. Messages are compared with what was already written, so updates
+// dropped by a full subscription only delay the output.
+type streamWriter struct {
+	out       io.Writer
+	sessionID string
+
+	mu        sync.Mutex
+	sessions  map[string]bool
+	written   map[string]bool
+	content   map[string]int
+	thinking  map[string]int
+	toolCalls map[string]bool
+	usage     map[string]streamUsage
+}
+
+func newStreamWriter(out io.Writer, sessionID string) *streamWriter {
+	return &streamWriter{
+		out:       out,
+		sessionID: sessionID,
+		sessions:  map[string]bool{sessionID: true},
+		written:   map[string]bool{},
+		content:   map[string]int{},
+		thinking:  map[string]int{},
+		toolCalls: map[string]bool{},
+		usage:     map[string]streamUsage{},
+	}
+}
+
+func (w *streamWriter) write(event streamEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		logging.Error("Failed to encode stream event", "type", event.Type, "error", err)
+		return
+	}
+	if _, err := w.out.Write(append(data, '\n')); err != nil {
+		logging.Error("Failed to write stream event", "type", event.Type, "error", err)
+	}
+}
+
+// skip marks the messages that were in the session before the run
+func (w *streamWriter) skip(msgs []message.Message) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, msg := range msgs {
+		w.written[msg.ID] = true
+	}
+}
+
+// follow writes the events of the session and of its sub-agents until ctx is
+// done. The returned channel is closed once every received event is written.
+func (w *streamWriter) follow(ctx context.Context, app *App) <-chan struct{} {
+	sessions := app.Sessions.Subscribe(ctx)
+	messages := app.Messages.Subscribe(ctx)
+	permissions := app.Permissions.Subscribe(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer logging.RecoverPanic("stream-json", nil)
+		for sessions != nil || messages != nil || permissions != nil {
+			select {
+			case event, ok := <-sessions:
+				if !ok {
+					sessions = nil
+					continue
+				}
+				w.session(event.Payload)
+			case event, ok := <-messages:
+				if !ok {
+					messages = nil
+					continue
+				}
+				w.message(event.Payload)
+			case event, ok := <-permissions:
+				if !ok {
+					permissions = nil
+					continue
+				}
+				w.permission(event)
+			}
+		}
+	}()
+	return done
+}
+
+func (w *streamWriter) session(sess session.Session) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sess.ParentSessionID != "" && w.sessions[sess.ParentSessionID] {
+		w.sessions[sess.ID] = true
+	}
+	if !w.sessions[sess.ID] {
+		return
+	}
+	usage := streamUsage{
+		PromptTokens:     sess.PromptTokens,
+		CompletionTokens: sess.CompletionTokens,
+		Cost:             sess.Cost,
+	}
+	if usage == w.usage[sess.ID] {
+		return
+	}
+	w.usage[sess.ID] = usage
+	w.write(streamEvent{Type: streamEventUsage, SessionID: sess.ID, Usage: &usage})
+}
+
+func (w *streamWriter) message(msg message.Message) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.sessions[msg.SessionID] || msg.Role == message.User {
+		return
+	}
+	if w.written[msg.ID] {
+		return
+	}
+
+	if thinking := msg.ReasoningContent().Thinking; len(thinking) > w.thinking[msg.ID] {
+		w.write(streamEvent{
+			Type:      streamEventThinkingDelta,
+			SessionID: msg.SessionID,
+			MessageID: msg.ID,
+			Text:      thinking[w.thinking[msg.ID]:],
+		})
+		w.thinking[msg.ID] = len(thinking)
+	}
+	if text := msg.Content().Text; len(text) > w.content[msg.ID] {
+		w.write(streamEvent{
+			Type:      streamEventContentDelta,
+			SessionID: msg.SessionID,
+			MessageID: msg.ID,
+			Text:      text[w.content[msg.ID]:],
+		})
+		w.content[msg.ID] = len(text)
+	}
+	for _, call := range msg.ToolCalls() {
+		if !call.Finished || w.toolCalls[call.ID] {
+			continue
+		}
+		w.toolCalls[call.ID] = true
+		w.write(streamEvent{
+			Type:      streamEventToolCall,
+			SessionID: msg.SessionID,
+			MessageID: msg.ID,
+			ToolCall:  &call,
+		})
+	}
+	// Tool messages are created with their results
+	if msg.Role == message.Tool {
+		for _, result := range msg.ToolResults() {
+			w.write(streamEvent{
+				Type:       streamEventToolResult,
+				SessionID:  msg.SessionID,
+				MessageID:  msg.ID,
+				ToolResult: &result,
+			})
+		}
+	}
+	if msg.Role == message.Tool || msg.IsFinished() {
+		w.written[msg.ID] = true
+	}
+}
+
+func (w *streamWriter) permission(event pubsub.Event[permission.PermissionRequest]) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.sessions[event.Payload.SessionID] || event.Type != pubsub.CreatedEvent {
+		return
+	}
+	w.write(streamEvent{
+		Type:       streamEventPermission,
+		SessionID:  event.Payload.SessionID,
+		Permission: &event.Payload,
+	})
+}
+
+// finish writes what the subscriptions missed in the session and the result
+// of the run.
+func (w *streamWriter) finish(ctx context.Context, app *App, result agent.AgentEvent) {
+	if msgs, err := app.Messages.List(ctx, w.sessionID); err == nil {
+		for _, msg := range msgs {
+			w.message(msg)
+		}
+	}
+	if sess, err := app.Sessions.Get(ctx, w.sessionID); err == nil {
+		w.session(sess)
+	}
+
+	event := streamEvent{
+		Type:         streamEventResult,
+		SessionID:    w.sessionID,
+		MessageID:    result.Message.ID,
+		Result:       result.Message.Content().String(),
+		FinishReason: result.Message.FinishReason(),
+		Budget:       result.Budget,
+	}
+	if usage, ok := w.usage[w.sessionID]; ok {
+		event.Usage = &usage
+	}
+	if result.Error != nil {
+		event.IsError = true
+		event.Error = result.Error.Error()
+	}
+	if event.FinishReason == message.FinishReasonBudgetExceeded {
+		event.IsError = true
+	}
+	w.write(event)
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := newStreamWriter(&out, "s1")
+	call := message.ToolCall{ID: "c1", Name: "ls", Input: "{}"}
+	assistant := message.Message{ID: "m1", SessionID: "s1", Role: message.Assistant}
+
+	assistant.Parts = []message.ContentPart{message.TextContent{Text: "Let me"}}
+	w.message(assistant)
+	assistant.Parts = []message.ContentPart{message.TextContent{Text: "Let me look"}, call}
+	w.message(assistant)
+	call.Finished = true
+	assistant.Parts = []message.ContentPart{message.TextContent{Text: "Let me look"}, call, message.Finish{Reason: message.FinishReasonToolUse}}
+	w.message(assistant)
+	// Updates received after the message was finished are ignored
+	w.message(assistant)
+	w.message(message.Message{ID: "m2", SessionID: "s1", Role: message.Tool, Parts: []message.ContentPart{
+		message.ToolResult{ToolCallID: "c1", Content: "a.go"},
+	}})
+	// Sub-agents are followed, other sessions aren't
+	w.session(session.Session{ID: "s2", ParentSessionID: "s1", Cost: 0.5})
+	w.message(message.Message{ID: "m3", SessionID: "s2", Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "sub"}}})
+	w.message(message.Message{ID: "m4", SessionID: "other", Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "no"}}})
+
+	var events []streamEvent
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event streamEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	assert.Equal(t, []string{
+		streamEventContentDelta,
+		streamEventContentDelta,
+		streamEventToolCall,
+		streamEventToolResult,
+		streamEventUsage,
+		streamEventContentDelta,
+	}, types)
+	assert.Equal(t, " look", events[1].Text)
+	assert.Equal(t, "c1", events[2].ToolCall.ID)
+	assert.Equal(t, "a.go", events[3].ToolResult.Content)
+	assert.Equal(t, "s2", events[5].SessionID)
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/opencode-ai/opencode/internal/llm/models"
@@ -44,10 +46,67 @@ const (
 )
 
 // Agent defines configuration for different LLM models and their token limits.
+// Entries of Agents other than the built-in ones are user-defined agents that
+// can take the place of the coder in a session.
 type Agent struct {
 	Model           models.ModelID `json:"model"`
 	MaxTokens       int64          `json:"maxTokens"`
 	ReasoningEffort string         `json:"reasoningEffort"` // For openai models low,medium,heigh
+	Description     string         `json:"description,omitempty"`
+	// Prompt is a file with the system prompt, relative to the working directory
+	Prompt string `json:"prompt,omitempty"`
+	// Tools and DisabledTools hold tool names or glob patterns like "github_*"
+	Tools         []string `json:"tools,omitempty"`
+	DisabledTools []string `json:"disabledTools,omitempty"`
+	// Fallbacks are the models tried in order when the model fails with an
+	// overloaded, rate limit or context length error
+	Fallbacks []models.ModelID `json:"fallbacks,omitempty"`
+}
+
+// AllowsTool reports whether the agent can use a tool. Every tool is allowed
+// when Tools is empty, DisabledTools wins over Tools.
+func (a Agent) AllowsTool(name string) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if matches(a.DisabledTools) {
+		return false
+	}
+	return len(a.Tools) == 0 || matches(a.Tools)
+}
+
+// PromptPath returns the absolute path of the prompt file, empty without one
+func (a Agent) PromptPath() string {
+	if a.Prompt == "" || filepath.IsAbs(a.Prompt) {
+		return a.Prompt
+	}
+	return filepath.Join(WorkingDirectory(), a.Prompt)
+}
+
+// IsBuiltinAgent reports whether name is one of the agents OpenCode relies on
+func IsBuiltinAgent(name AgentName) bool {
+	switch name {
+	case AgentCoder, AgentSummarizer, AgentTask, AgentTitle:
+		return true
+	}
+	return false
+}
+
+// CustomAgents returns the names of the user-defined agents, sorted
+func CustomAgents() []AgentName {
+	names := []AgentName{}
+	for name := range cfg.Agents {
+		if !IsBuiltinAgent(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
 }
 
 // Provider defines configuration for an LLM provider.
@@ -80,20 +139,245 @@ type ShellConfig struct {
 	Args []string `json:"args,omitempty"`
 }
 
+// FormatConfig defines how the files written by the edit, write and patch
+// tools are formatted.
+type FormatConfig struct {
+	// OnWrite formats the files once the tools wrote them, with the formatter
+	// of their extension or else their language server
+	OnWrite    bool        `json:"onWrite,omitempty"`
+	Formatters []Formatter `json:"formatters,omitempty"`
+}
+
+// Formatter is a command formatting a file in place, the path of the file is
+// in $FILE.
+type Formatter struct {
+	Extensions []string `json:"extensions"`
+	Command    string   `json:"command"`
+}
+
+// FormatterFor returns the formatter of the extension of the file, nil when
+// there is none.
+func (f FormatConfig) FormatterFor(path string) *Formatter {
+	ext := strings.ToLower(filepath.Ext(path))
+	for i, formatter := range f.Formatters {
+		for _, e := range formatter.Extensions {
+			if strings.ToLower(e) == ext {
+				return &f.Formatters[i]
+			}
+		}
+	}
+	return nil
+}
+
+// CompactionConfig defines how sessions are summarized when they get close
+// to the context window of the model.
+type CompactionConfig struct {
+	// Threshold is the fraction of the context window at which AutoCompact
+	// summarizes the session
+	Threshold float64 `json:"threshold,omitempty"`
+	// KeepTurns is the number of recent turns kept verbatim, 0 summarizes
+	// the whole conversation
+	KeepTurns int `json:"keepTurns"`
+	// MaxToolOutput is the number of characters above which old tool
+	// outputs are truncated, before they are summarized and when they are
+	// sent to the model
+	MaxToolOutput int `json:"maxToolOutput,omitempty"`
+}
+
+// Budget is a spending limit, a zero field means no limit.
+type Budget struct {
+	Cost   float64 `json:"cost,omitempty"`
+	Tokens int64   `json:"tokens,omitempty"`
+}
+
+// IsZero reports whether the budget has no limit at all.
+func (b Budget) IsZero() bool {
+	return b.Cost <= 0 && b.Tokens <= 0
+}
+
+// BudgetsConfig defines the spending limits of the agents. Session limits
+// include the sub-agents of the session, Daily limits cover every session of
+// the day and SubAgent limits apply to each task agent run.
+type BudgetsConfig struct {
+	Session  Budget `json:"session,omitempty"`
+	Daily    Budget `json:"daily,omitempty"`
+	SubAgent Budget `json:"subAgent,omitempty"`
+	// WarnAt is the fraction of a limit at which a warning is sent
+	WarnAt float64 `json:"warnAt,omitempty"`
+}
+
+// CassetteMode is how the agents use the cassette of CassetteConfig.
+type CassetteMode string
+
+const (
+	// CassetteRecord saves the responses of the models to the cassette
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay answers from the cassette instead of calling the models
+	CassetteReplay CassetteMode = "replay"
+)
+
+// CassetteConfig defines the file the responses of the models are recorded
+// to or replayed from, for tests that don't call the APIs.
+type CassetteConfig struct {
+	Path string       `json:"path,omitempty"`
+	Mode CassetteMode `json:"mode,omitempty"`
+}
+
+// PermissionAction is what a permission rule does with the requests it
+// matches.
+type PermissionAction string
+
+const (
+	PermissionAllow PermissionAction = "allow"
+	PermissionDeny  PermissionAction = "deny"
+	// PermissionAsk always asks, even when the request was allowed for the
+	// session
+	PermissionAsk PermissionAction = "ask"
+)
+
+// PermissionRule allows, denies or always asks for the requests of the tools
+// matching Tool, a tool name or a glob pattern like "github_*". Command
+// matches the commands run by bash and Path the files changed by the editing
+// tools. Agents and Sessions limit the rule to some agents or sessions.
+type PermissionRule struct {
+	Tool   string           `json:"tool"`
+	Action PermissionAction `json:"action"`
+	// Command is a glob where * matches any text, "go test *". Without a
+	// wildcard it also matches the command followed by arguments.
+	Command string `json:"command,omitempty"`
+	// Path is a glob relative to the working directory where * matches
+	// inside a directory and ** across directories, "migrations/**". Without
+	// a wildcard it also matches the files below the path.
+	Path     string      `json:"path,omitempty"`
+	Agents   []AgentName `json:"agents,omitempty"`
+	Sessions []string    `json:"sessions,omitempty"`
+	// Exact matches Command as the whole command line, without wildcards or
+	// arguments. The rules added by "allow always" are exact.
+	Exact bool `json:"exact,omitempty"`
+}
+
+// Validate checks the action and patterns of the rule.
+func (r PermissionRule) Validate() error {
+	switch r.Action {
+	case PermissionAllow, PermissionDeny, PermissionAsk:
+	default:
+		return fmt.Errorf("invalid action %q, expected %q, %q or %q", r.Action, PermissionAllow, PermissionDeny, PermissionAsk)
+	}
+	if _, err := path.Match(r.Tool, ""); err != nil {
+		return fmt.Errorf("invalid tool pattern %q: %w", r.Tool, err)
+	}
+	return nil
+}
+
+// SandboxConfig runs the commands of the bash tool in a sandbox on Linux,
+// where only the working directory, the temporary directory and
+// WritablePaths are writable and the network is off unless Network is set.
+type SandboxConfig struct {
+	Enabled       bool     `json:"enabled,omitempty"`
+	WritablePaths []string `json:"writablePaths,omitempty"`
+	Network       bool     `json:"network,omitempty"`
+	// AllowedPorts limits the TCP connections to these ports when the
+	// network is on
+	AllowedPorts []int `json:"allowedPorts,omitempty"`
+	// AutoApprove runs the sandboxed commands without asking, unless a
+	// permission rule denies or asks for them
+	AutoApprove bool `json:"autoApprove,omitempty"`
+}
+
+// HookEvent is a point of the lifecycle of the agent where hooks run.
+type HookEvent string
+
+const (
+	// HookPreToolUse hooks run before a tool, they can block it or rewrite
+	// its input
+	HookPreToolUse HookEvent = "preToolUse"
+	// HookPostToolUse hooks run after a tool, they can add feedback to its
+	// result
+	HookPostToolUse      HookEvent = "postToolUse"
+	HookUserPromptSubmit HookEvent = "userPromptSubmit"
+	// HookStop hooks run when the agent finished responding to a prompt
+	HookStop         HookEvent = "stop"
+	HookSessionStart HookEvent = "sessionStart"
+	HookSessionEnd   HookEvent = "sessionEnd"
+)
+
+// Hook is a shell command receiving the event as JSON on stdin.
+type Hook struct {
+	Command string `json:"command"`
+	// Tools limits the tool hooks to the tools matching one of these globs
+	Tools []string `json:"tools,omitempty"`
+	// Timeout is in seconds, 60 by default
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// HooksConfig holds the hooks of each event, run in order.
+type HooksConfig struct {
+	PreToolUse       []Hook `json:"preToolUse,omitempty"`
+	PostToolUse      []Hook `json:"postToolUse,omitempty"`
+	UserPromptSubmit []Hook `json:"userPromptSubmit,omitempty"`
+	Stop             []Hook `json:"stop,omitempty"`
+	SessionStart     []Hook `json:"sessionStart,omitempty"`
+	SessionEnd       []Hook `json:"sessionEnd,omitempty"`
+}
+
+// For returns the hooks of an event.
+func (h HooksConfig) For(event HookEvent) []Hook {
+	switch event {
+	case HookPreToolUse:
+		return h.PreToolUse
+	case HookPostToolUse:
+		return h.PostToolUse
+	case HookUserPromptSubmit:
+		return h.UserPromptSubmit
+	case HookStop:
+		return h.Stop
+	case HookSessionStart:
+		return h.SessionStart
+	case HookSessionEnd:
+		return h.SessionEnd
+	}
+	return nil
+}
+
+// Validate checks the commands and tool patterns of the hook.
+func (h Hook) Validate() error {
+	if strings.TrimSpace(h.Command) == "" {
+		return fmt.Errorf("missing command")
+	}
+	for _, tool := range h.Tools {
+		if _, err := path.Match(tool, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", tool, err)
+		}
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %d", h.Timeout)
+	}
+	return nil
+}
+
 // Config is the main configuration structure for the application.
 type Config struct {
-	Data         Data                              `json:"data"`
-	WorkingDir   string                            `json:"wd,omitempty"`
-	MCPServers   map[string]MCPServer              `json:"mcpServers,omitempty"`
-	Providers    map[models.ModelProvider]Provider `json:"providers,omitempty"`
-	LSP          map[string]LSPConfig              `json:"lsp,omitempty"`
-	Agents       map[AgentName]Agent               `json:"agents,omitempty"`
-	Debug        bool                              `json:"debug,omitempty"`
-	DebugLSP     bool                              `json:"debugLSP,omitempty"`
-	ContextPaths []string                          `json:"contextPaths,omitempty"`
-	TUI          TUIConfig                         `json:"tui"`
-	Shell        ShellConfig                       `json:"shell,omitempty"`
-	AutoCompact  bool                              `json:"autoCompact,omitempty"`
+	Data             Data                              `json:"data"`
+	WorkingDir       string                            `json:"wd,omitempty"`
+	MCPServers       map[string]MCPServer              `json:"mcpServers,omitempty"`
+	Providers        map[models.ModelProvider]Provider `json:"providers,omitempty"`
+	LSP              map[string]LSPConfig              `json:"lsp,omitempty"`
+	LSPDiscovery     bool                              `json:"lspDiscovery,omitempty"`
+	Agents           map[AgentName]Agent               `json:"agents,omitempty"`
+	Debug            bool                              `json:"debug,omitempty"`
+	DebugLSP         bool                              `json:"debugLSP,omitempty"`
+	ContextPaths     []string                          `json:"contextPaths,omitempty"`
+	TUI              TUIConfig                         `json:"tui"`
+	Shell            ShellConfig                       `json:"shell,omitempty"`
+	AutoCompact      bool                              `json:"autoCompact,omitempty"`
+	Compaction       CompactionConfig                  `json:"compaction,omitempty"`
+	MaxParallelTools int                               `json:"maxParallelTools,omitempty"`
+	Budgets          BudgetsConfig                     `json:"budgets,omitempty"`
+	Cassette         CassetteConfig                    `json:"cassette,omitempty"`
+	Permissions      []PermissionRule                  `json:"permissions,omitempty"`
+	Sandbox          SandboxConfig                     `json:"sandbox,omitempty"`
+	Hooks            HooksConfig                       `json:"hooks,omitempty"`
+	Format           FormatConfig                      `json:"format,omitempty"`
 }
 
 // Application constants
@@ -231,7 +515,13 @@ func setDefaults(debug bool) {
 	viper.SetDefault("data.directory", defaultDataDirectory)
 	viper.SetDefault("contextPaths", defaultContextPaths)
 	viper.SetDefault("tui.theme", "opencode")
+	viper.SetDefault("lspDiscovery", true)
 	viper.SetDefault("autoCompact", true)
+	viper.SetDefault("compaction.threshold", 0.95)
+	viper.SetDefault("compaction.keepTurns", 2)
+	viper.SetDefault("compaction.maxToolOutput", 2000)
+	viper.SetDefault("maxParallelTools", 4)
+	viper.SetDefault("budgets.warnAt", 0.8)
 
 	// Set default shell from environment or fallback to /bin/bash
 	shellPath := os.Getenv("SHELL")
@@ -450,10 +740,10 @@ func readConfig(err error) error {
 
 // mergeLocalConfig loads and merges configuration from the local directory.
 func mergeLocalConfig(workingDir string) {
+	// Only the file the sandbox protects is read, not the other extensions
+	// viper looks for
 	local := viper.New()
-	local.SetConfigName(fmt.Sprintf(".%s", appName))
-	local.SetConfigType("json")
-	local.AddConfigPath(workingDir)
+	local.SetConfigFile(filepath.Join(workingDir, fmt.Sprintf(".%s.json", appName)))
 
 	// Merge local config if it exists
 	if err := local.ReadInConfig(); err == nil {
@@ -497,7 +787,9 @@ func validateAgent(cfg *Config, name AgentName, agent Agent) error {
 	provider := model.Provider
 	providerCfg, providerExists := cfg.Providers[provider]
 
-	if !providerExists {
+	if cfg.Cassette.Mode == CassetteReplay {
+		// Replayed responses don't need the provider
+	} else if !providerExists {
 		// Provider not configured, check if we have environment variables
 		apiKey := getProviderAPIKey(provider)
 		if apiKey == "" {
@@ -612,6 +904,19 @@ func Validate() error {
 		return fmt.Errorf("config not loaded")
 	}
 
+	// User-defined agents use the model of the coder unless they set one
+	for name, agent := range cfg.Agents {
+		if !IsBuiltinAgent(name) && agent.Model == "" {
+			agent.Model = cfg.Agents[AgentCoder].Model
+			cfg.Agents[name] = agent
+		}
+		if agent.Prompt != "" {
+			if _, err := os.Stat(agent.PromptPath()); err != nil {
+				return fmt.Errorf("prompt of agent %s: %w", name, err)
+			}
+		}
+	}
+
 	// Validate agent models
 	for name, agent := range cfg.Agents {
 		if err := validateAgent(cfg, name, agent); err != nil {
@@ -638,6 +943,73 @@ func Validate() error {
 		}
 	}
 
+	// Validate compaction
+	if cfg.Compaction.Threshold <= 0 || cfg.Compaction.Threshold > 1 {
+		logging.Warn("invalid compaction threshold, using 0.95", "threshold", cfg.Compaction.Threshold)
+		cfg.Compaction.Threshold = 0.95
+	}
+	if cfg.Compaction.KeepTurns < 0 {
+		cfg.Compaction.KeepTurns = 0
+	}
+
+	// Validate cassette
+	switch cfg.Cassette.Mode {
+	case "", CassetteRecord, CassetteReplay:
+	default:
+		return fmt.Errorf("invalid cassette mode %q, expected %q or %q", cfg.Cassette.Mode, CassetteRecord, CassetteReplay)
+	}
+	if cfg.Cassette.Mode != "" && cfg.Cassette.Path == "" {
+		return fmt.Errorf("cassette mode %s needs a path", cfg.Cassette.Mode)
+	}
+
+	// Validate budgets
+	if cfg.Budgets.WarnAt <= 0 || cfg.Budgets.WarnAt > 1 {
+		logging.Warn("invalid budget warning threshold, using 0.8", "warnAt", cfg.Budgets.WarnAt)
+		cfg.Budgets.WarnAt = 0.8
+	}
+
+	// Validate permission rules
+	for i, rule := range cfg.Permissions {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("permission rule %d: %w", i+1, err)
+		}
+	}
+
+	// Validate hooks
+	for _, event := range []HookEvent{HookPreToolUse, HookPostToolUse, HookUserPromptSubmit, HookStop, HookSessionStart, HookSessionEnd} {
+		for i, hook := range cfg.Hooks.For(event) {
+			if err := hook.Validate(); err != nil {
+				return fmt.Errorf("%s hook %d: %w", event, i+1, err)
+			}
+		}
+	}
+
+	// Validate formatters
+	for i, formatter := range cfg.Format.Formatters {
+		if strings.TrimSpace(formatter.Command) == "" {
+			return fmt.Errorf("formatter %d: missing command", i+1)
+		}
+		if len(formatter.Extensions) == 0 {
+			return fmt.Errorf("formatter %d: missing extensions", i+1)
+		}
+		for _, ext := range formatter.Extensions {
+			if !strings.HasPrefix(ext, ".") {
+				return fmt.Errorf("formatter %d: extension %q must start with a dot", i+1, ext)
+			}
+		}
+	}
+
+	// Validate sandbox
+	if cfg.Sandbox.Enabled && runtime.GOOS != "linux" {
+		logging.Warn("the sandbox is only supported on Linux, disabling it", "os", runtime.GOOS)
+		cfg.Sandbox.Enabled = false
+	}
+	for _, port := range cfg.Sandbox.AllowedPorts {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid sandbox port %d", port)
+		}
+	}
+
 	return nil
 }
 
@@ -670,6 +1042,21 @@ func getProviderAPIKey(provider models.ModelProvider) string {
 
 // setDefaultModelForAgent sets a default model for an agent based on available providers
 func setDefaultModelForAgent(agent AgentName) bool {
+	// Only the model settings are replaced, keep the rest of the agent config
+	existing := cfg.Agents[agent]
+	defer func() {
+		updated, ok := cfg.Agents[agent]
+		if !ok {
+			return
+		}
+		updated.Description = existing.Description
+		updated.Prompt = existing.Prompt
+		updated.Tools = existing.Tools
+		updated.DisabledTools = existing.DisabledTools
+		updated.Fallbacks = existing.Fallbacks
+		cfg.Agents[agent] = updated
+	}()
+
 	if hasCopilotCredentials() {
 		maxTokens := int64(5000)
 		if agent == AgentTitle {
@@ -894,11 +1281,9 @@ func UpdateAgentModel(agentName AgentName, modelID models.ModelID) error {
 		maxTokens = model.DefaultMaxTokens
 	}
 
-	newAgentCfg := Agent{
-		Model:           modelID,
-		MaxTokens:       maxTokens,
-		ReasoningEffort: existingAgentCfg.ReasoningEffort,
-	}
+	newAgentCfg := existingAgentCfg
+	newAgentCfg.Model = modelID
+	newAgentCfg.MaxTokens = maxTokens
 	cfg.Agents[agentName] = newAgentCfg
 
 	if err := validateAgent(cfg, agentName, newAgentCfg); err != nil {
@@ -24,6 +24,12 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.copyMessageStmt, err = db.PrepareContext(ctx, copyMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query CopyMessage: %w", err)
+	}
+	if q.createCheckpointStmt, err = db.PrepareContext(ctx, createCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateCheckpoint: %w", err)
+	}
 	if q.createFileStmt, err = db.PrepareContext(ctx, createFile); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateFile: %w", err)
 	}
@@ -33,6 +39,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
+	if q.createUsageStmt, err = db.PrepareContext(ctx, createUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateUsage: %w", err)
+	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
@@ -48,6 +57,12 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.deleteSessionMessagesStmt, err = db.PrepareContext(ctx, deleteSessionMessages); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSessionMessages: %w", err)
 	}
+	if q.getCheckpointByMessageStmt, err = db.PrepareContext(ctx, getCheckpointByMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query GetCheckpointByMessage: %w", err)
+	}
+	if q.getDailySpendingStmt, err = db.PrepareContext(ctx, getDailySpending); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDailySpending: %w", err)
+	}
 	if q.getFileStmt, err = db.PrepareContext(ctx, getFile); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFile: %w", err)
 	}
@@ -60,6 +75,24 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.getSessionSpendingStmt, err = db.PrepareContext(ctx, getSessionSpending); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionSpending: %w", err)
+	}
+	if q.importCheckpointStmt, err = db.PrepareContext(ctx, importCheckpoint); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportCheckpoint: %w", err)
+	}
+	if q.importFileStmt, err = db.PrepareContext(ctx, importFile); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportFile: %w", err)
+	}
+	if q.importSessionStmt, err = db.PrepareContext(ctx, importSession); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportSession: %w", err)
+	}
+	if q.listCheckpointsBySessionStmt, err = db.PrepareContext(ctx, listCheckpointsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListCheckpointsBySession: %w", err)
+	}
+	if q.listChildSessionsStmt, err = db.PrepareContext(ctx, listChildSessions); err != nil {
+		return nil, fmt.Errorf("error preparing query ListChildSessions: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
@@ -78,6 +111,15 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
+	if q.listUsageStmt, err = db.PrepareContext(ctx, listUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUsage: %w", err)
+	}
+	if q.revertMessageStmt, err = db.PrepareContext(ctx, revertMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query RevertMessage: %w", err)
+	}
+	if q.searchMessagesStmt, err = db.PrepareContext(ctx, searchMessages); err != nil {
+		return nil, fmt.Errorf("error preparing query SearchMessages: %w", err)
+	}
 	if q.updateFileStmt, err = db.PrepareContext(ctx, updateFile); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateFile: %w", err)
 	}
@@ -92,6 +134,16 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 
 func (q *Queries) Close() error {
 	var err error
+	if q.copyMessageStmt != nil {
+		if cerr := q.copyMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing copyMessageStmt: %w", cerr)
+		}
+	}
+	if q.createCheckpointStmt != nil {
+		if cerr := q.createCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createCheckpointStmt: %w", cerr)
+		}
+	}
 	if q.createFileStmt != nil {
 		if cerr := q.createFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createFileStmt: %w", cerr)
@@ -107,6 +159,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
 		}
 	}
+	if q.createUsageStmt != nil {
+		if cerr := q.createUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createUsageStmt: %w", cerr)
+		}
+	}
 	if q.deleteFileStmt != nil {
 		if cerr := q.deleteFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
@@ -132,6 +189,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteSessionMessagesStmt: %w", cerr)
 		}
 	}
+	if q.getCheckpointByMessageStmt != nil {
+		if cerr := q.getCheckpointByMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getCheckpointByMessageStmt: %w", cerr)
+		}
+	}
+	if q.getDailySpendingStmt != nil {
+		if cerr := q.getDailySpendingStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDailySpendingStmt: %w", cerr)
+		}
+	}
 	if q.getFileStmt != nil {
 		if cerr := q.getFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getFileStmt: %w", cerr)
@@ -152,6 +219,36 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.getSessionSpendingStmt != nil {
+		if cerr := q.getSessionSpendingStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionSpendingStmt: %w", cerr)
+		}
+	}
+	if q.importCheckpointStmt != nil {
+		if cerr := q.importCheckpointStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importCheckpointStmt: %w", cerr)
+		}
+	}
+	if q.importFileStmt != nil {
+		if cerr := q.importFileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importFileStmt: %w", cerr)
+		}
+	}
+	if q.importSessionStmt != nil {
+		if cerr := q.importSessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importSessionStmt: %w", cerr)
+		}
+	}
+	if q.listCheckpointsBySessionStmt != nil {
+		if cerr := q.listCheckpointsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listCheckpointsBySessionStmt: %w", cerr)
+		}
+	}
+	if q.listChildSessionsStmt != nil {
+		if cerr := q.listChildSessionsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listChildSessionsStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -182,6 +279,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
 		}
 	}
+	if q.listUsageStmt != nil {
+		if cerr := q.listUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUsageStmt: %w", cerr)
+		}
+	}
+	if q.revertMessageStmt != nil {
+		if cerr := q.revertMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing revertMessageStmt: %w", cerr)
+		}
+	}
+	if q.searchMessagesStmt != nil {
+		if cerr := q.searchMessagesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing searchMessagesStmt: %w", cerr)
+		}
+	}
 	if q.updateFileStmt != nil {
 		if cerr := q.updateFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateFileStmt: %w", cerr)
@@ -234,55 +346,83 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                          DBTX
-	tx                          *sql.Tx
-	createFileStmt              *sql.Stmt
-	createMessageStmt           *sql.Stmt
-	createSessionStmt           *sql.Stmt
-	deleteFileStmt              *sql.Stmt
-	deleteMessageStmt           *sql.Stmt
-	deleteSessionStmt           *sql.Stmt
-	deleteSessionFilesStmt      *sql.Stmt
-	deleteSessionMessagesStmt   *sql.Stmt
-	getFileStmt                 *sql.Stmt
-	getFileByPathAndSessionStmt *sql.Stmt
-	getMessageStmt              *sql.Stmt
-	getSessionByIDStmt          *sql.Stmt
-	listFilesByPathStmt         *sql.Stmt
-	listFilesBySessionStmt      *sql.Stmt
-	listLatestSessionFilesStmt  *sql.Stmt
-	listMessagesBySessionStmt   *sql.Stmt
-	listNewFilesStmt            *sql.Stmt
-	listSessionsStmt            *sql.Stmt
-	updateFileStmt              *sql.Stmt
-	updateMessageStmt           *sql.Stmt
-	updateSessionStmt           *sql.Stmt
+	db                           DBTX
+	tx                           *sql.Tx
+	copyMessageStmt              *sql.Stmt
+	createCheckpointStmt         *sql.Stmt
+	createFileStmt               *sql.Stmt
+	createMessageStmt            *sql.Stmt
+	createSessionStmt            *sql.Stmt
+	createUsageStmt              *sql.Stmt
+	deleteFileStmt               *sql.Stmt
+	deleteMessageStmt            *sql.Stmt
+	deleteSessionStmt            *sql.Stmt
+	deleteSessionFilesStmt       *sql.Stmt
+	deleteSessionMessagesStmt    *sql.Stmt
+	getCheckpointByMessageStmt   *sql.Stmt
+	getDailySpendingStmt         *sql.Stmt
+	getFileStmt                  *sql.Stmt
+	getFileByPathAndSessionStmt  *sql.Stmt
+	getMessageStmt               *sql.Stmt
+	getSessionByIDStmt           *sql.Stmt
+	getSessionSpendingStmt       *sql.Stmt
+	importCheckpointStmt         *sql.Stmt
+	importFileStmt               *sql.Stmt
+	importSessionStmt            *sql.Stmt
+	listCheckpointsBySessionStmt *sql.Stmt
+	listChildSessionsStmt        *sql.Stmt
+	listFilesByPathStmt          *sql.Stmt
+	listFilesBySessionStmt       *sql.Stmt
+	listLatestSessionFilesStmt   *sql.Stmt
+	listMessagesBySessionStmt    *sql.Stmt
+	listNewFilesStmt             *sql.Stmt
+	listSessionsStmt             *sql.Stmt
+	listUsageStmt                *sql.Stmt
+	revertMessageStmt            *sql.Stmt
+	searchMessagesStmt           *sql.Stmt
+	updateFileStmt               *sql.Stmt
+	updateMessageStmt            *sql.Stmt
+	updateSessionStmt            *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                          tx,
-		tx:                          tx,
-		createFileStmt:              q.createFileStmt,
-		createMessageStmt:           q.createMessageStmt,
-		createSessionStmt:           q.createSessionStmt,
-		deleteFileStmt:              q.deleteFileStmt,
-		deleteMessageStmt:           q.deleteMessageStmt,
-		deleteSessionStmt:           q.deleteSessionStmt,
-		deleteSessionFilesStmt:      q.deleteSessionFilesStmt,
-		deleteSessionMessagesStmt:   q.deleteSessionMessagesStmt,
-		getFileStmt:                 q.getFileStmt,
-		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
-		getMessageStmt:              q.getMessageStmt,
-		getSessionByIDStmt:          q.getSessionByIDStmt,
-		listFilesByPathStmt:         q.listFilesByPathStmt,
-		listFilesBySessionStmt:      q.listFilesBySessionStmt,
-		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
-		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
-		listNewFilesStmt:            q.listNewFilesStmt,
-		listSessionsStmt:            q.listSessionsStmt,
-		updateFileStmt:              q.updateFileStmt,
-		updateMessageStmt:           q.updateMessageStmt,
-		updateSessionStmt:           q.updateSessionStmt,
+		db:                           tx,
+		tx:                           tx,
+		copyMessageStmt:              q.copyMessageStmt,
+		createCheckpointStmt:         q.createCheckpointStmt,
+		createFileStmt:               q.createFileStmt,
+		createMessageStmt:            q.createMessageStmt,
+		createSessionStmt:            q.createSessionStmt,
+		createUsageStmt:              q.createUsageStmt,
+		deleteFileStmt:               q.deleteFileStmt,
+		deleteMessageStmt:            q.deleteMessageStmt,
+		deleteSessionStmt:            q.deleteSessionStmt,
+		deleteSessionFilesStmt:       q.deleteSessionFilesStmt,
+		deleteSessionMessagesStmt:    q.deleteSessionMessagesStmt,
+		getCheckpointByMessageStmt:   q.getCheckpointByMessageStmt,
+		getDailySpendingStmt:         q.getDailySpendingStmt,
+		getFileStmt:                  q.getFileStmt,
+		getFileByPathAndSessionStmt:  q.getFileByPathAndSessionStmt,
+		getMessageStmt:               q.getMessageStmt,
+		getSessionByIDStmt:           q.getSessionByIDStmt,
+		getSessionSpendingStmt:       q.getSessionSpendingStmt,
+		importCheckpointStmt:         q.importCheckpointStmt,
+		importFileStmt:               q.importFileStmt,
+		importSessionStmt:            q.importSessionStmt,
+		listCheckpointsBySessionStmt: q.listCheckpointsBySessionStmt,
+		listChildSessionsStmt:        q.listChildSessionsStmt,
+		listFilesByPathStmt:          q.listFilesByPathStmt,
+		listFilesBySessionStmt:       q.listFilesBySessionStmt,
+		listLatestSessionFilesStmt:   q.listLatestSessionFilesStmt,
+		listMessagesBySessionStmt:    q.listMessagesBySessionStmt,
+		listNewFilesStmt:             q.listNewFilesStmt,
+		listSessionsStmt:             q.listSessionsStmt,
+		listUsageStmt:                q.listUsageStmt,
+		revertMessageStmt:            q.revertMessageStmt,
+		searchMessagesStmt:           q.searchMessagesStmt,
+		updateFileStmt:               q.updateFileStmt,
+		updateMessageStmt:            q.updateMessageStmt,
+		updateSessionStmt:            q.updateSessionStmt,
 	}
 }
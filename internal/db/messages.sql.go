@@ -10,6 +10,49 @@ import (
 	"database/sql"
 )
 
+const copyMessage = `-- name: CopyMessage :exec
+INSERT INTO messages (
+    id,
+    session_id,
+    role,
+    parts,
+    model,
+    created_at,
+    updated_at,
+    finished_at,
+    reverted_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type CopyMessageParams struct {
+	ID         string         `json:"id"`
+	SessionID  string         `json:"session_id"`
+	Role       string         `json:"role"`
+	Parts      string         `json:"parts"`
+	Model      sql.NullString `json:"model"`
+	CreatedAt  int64          `json:"created_at"`
+	UpdatedAt  int64          `json:"updated_at"`
+	FinishedAt sql.NullInt64  `json:"finished_at"`
+	RevertedAt sql.NullInt64  `json:"reverted_at"`
+}
+
+func (q *Queries) CopyMessage(ctx context.Context, arg CopyMessageParams) error {
+	_, err := q.exec(ctx, q.copyMessageStmt, copyMessage,
+		arg.ID,
+		arg.SessionID,
+		arg.Role,
+		arg.Parts,
+		arg.Model,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.FinishedAt,
+		arg.RevertedAt,
+	)
+	return err
+}
+
 const createMessage = `-- name: CreateMessage :one
 INSERT INTO messages (
     id,
@@ -22,7 +65,7 @@ INSERT INTO messages (
 ) VALUES (
     ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
-RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at
+RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, reverted_at
 `
 
 type CreateMessageParams struct {
@@ -51,6 +94,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.FinishedAt,
+		&i.RevertedAt,
 	)
 	return i, err
 }
@@ -76,7 +120,7 @@ func (q *Queries) DeleteSessionMessages(ctx context.Context, sessionID string) e
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, reverted_at
 FROM messages
 WHERE id = ? LIMIT 1
 `
@@ -93,12 +137,13 @@ func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.FinishedAt,
+		&i.RevertedAt,
 	)
 	return i, err
 }
 
 const listMessagesBySession = `-- name: ListMessagesBySession :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, reverted_at
 FROM messages
 WHERE session_id = ?
 ORDER BY created_at ASC
@@ -122,6 +167,78 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.FinishedAt,
+			&i.RevertedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revertMessage = `-- name: RevertMessage :exec
+UPDATE messages
+SET reverted_at = strftime('%s', 'now')
+WHERE id = ?
+`
+
+func (q *Queries) RevertMessage(ctx context.Context, id string) error {
+	_, err := q.exec(ctx, q.revertMessageStmt, revertMessage, id)
+	return err
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT
+    m.id,
+    m.session_id,
+    m.role,
+    m.created_at,
+    s.title AS session_title,
+    snippet(message_search, 0, char(2), char(3), '…', 16) AS snippet
+FROM message_search
+JOIN messages AS m ON m.rowid = message_search.rowid
+JOIN sessions AS s ON s.id = m.session_id
+WHERE message_search MATCH ?1
+ORDER BY rank, m.created_at DESC
+LIMIT ?2
+`
+
+type SearchMessagesParams struct {
+	Query string `json:"query"`
+	Limit int64  `json:"limit"`
+}
+
+type SearchMessagesRow struct {
+	ID           string `json:"id"`
+	SessionID    string `json:"session_id"`
+	Role         string `json:"role"`
+	CreatedAt    int64  `json:"created_at"`
+	SessionTitle string `json:"session_title"`
+	Snippet      string `json:"snippet"`
+}
+
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error) {
+	rows, err := q.query(ctx, q.searchMessagesStmt, searchMessages, arg.Query, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchMessagesRow{}
+	for rows.Next() {
+		var i SearchMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.SessionTitle,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -140,18 +257,25 @@ const updateMessage = `-- name: UpdateMessage :exec
 UPDATE messages
 SET
     parts = ?,
+    model = ?,
     finished_at = ?,
     updated_at = strftime('%s', 'now')
 WHERE id = ?
 `
 
 type UpdateMessageParams struct {
-	Parts      string        `json:"parts"`
-	FinishedAt sql.NullInt64 `json:"finished_at"`
-	ID         string        `json:"id"`
+	Parts      string         `json:"parts"`
+	Model      sql.NullString `json:"model"`
+	FinishedAt sql.NullInt64  `json:"finished_at"`
+	ID         string         `json:"id"`
 }
 
 func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) error {
-	_, err := q.exec(ctx, q.updateMessageStmt, updateMessage, arg.Parts, arg.FinishedAt, arg.ID)
+	_, err := q.exec(ctx, q.updateMessageStmt, updateMessage,
+		arg.Parts,
+		arg.Model,
+		arg.FinishedAt,
+		arg.ID,
+	)
 	return err
 }
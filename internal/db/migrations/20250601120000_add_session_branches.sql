@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN branch_parent_id TEXT;
+ALTER TABLE sessions ADD COLUMN branch_message_id TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN branch_message_id;
+ALTER TABLE sessions DROP COLUMN branch_parent_id;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Checkpoints
+CREATE TABLE IF NOT EXISTS checkpoints (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    message_id TEXT NOT NULL UNIQUE,
+    files TEXT NOT NULL DEFAULT '{}',  -- JSON object mapping paths to file version ids
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_checkpoints_session_id ON checkpoints (session_id);
+
+ALTER TABLE messages ADD COLUMN reverted_at INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE messages DROP COLUMN reverted_at;
+DROP TABLE IF EXISTS checkpoints;
+-- +goose StatementEnd
@@ -0,0 +1,74 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The searchable text of a message: its text, reasoning, tool calls with
+-- their inputs, tool results and attachment paths
+CREATE VIEW IF NOT EXISTS message_text AS
+SELECT
+    m.rowid AS message_rowid,
+    m.id AS message_id,
+    m.session_id AS session_id,
+    (
+        SELECT group_concat(
+            CASE json_extract(p.value, '$.type')
+                WHEN 'text' THEN json_extract(p.value, '$.data.text')
+                WHEN 'reasoning' THEN json_extract(p.value, '$.data.thinking')
+                WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || char(10) || json_extract(p.value, '$.data.input')
+                WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+                WHEN 'binary' THEN json_extract(p.value, '$.data.Path')
+            END,
+            char(10)
+        )
+        FROM json_each(m.parts) AS p
+    ) AS content
+FROM messages AS m;
+
+-- Full-text index of the messages, its rowid is the one of the message
+CREATE VIRTUAL TABLE IF NOT EXISTS message_search USING fts5(
+    content,
+    message_id UNINDEXED,
+    session_id UNINDEXED,
+    tokenize = 'porter unicode61'
+);
+
+INSERT INTO message_search (rowid, content, message_id, session_id)
+SELECT message_rowid, content, message_id, session_id
+FROM message_text;
+
+CREATE TRIGGER IF NOT EXISTS message_search_on_insert
+AFTER INSERT ON messages
+BEGIN
+INSERT INTO message_search (rowid, content, message_id, session_id)
+SELECT message_rowid, content, message_id, session_id
+FROM message_text
+WHERE message_id = new.id;
+END;
+
+-- Only the parts are indexed, so the other updates don't touch the index. The
+-- messages are updated with every streamed delta, they are indexed again once
+-- finished rather than on each of them.
+CREATE TRIGGER IF NOT EXISTS message_search_on_update
+AFTER UPDATE OF parts, finished_at ON messages
+WHEN new.finished_at IS NOT NULL
+BEGIN
+DELETE FROM message_search WHERE rowid = old.rowid;
+INSERT INTO message_search (rowid, content, message_id, session_id)
+SELECT message_rowid, content, message_id, session_id
+FROM message_text
+WHERE message_id = new.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS message_search_on_delete
+AFTER DELETE ON messages
+BEGIN
+DELETE FROM message_search WHERE rowid = old.rowid;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS message_search_on_delete;
+DROP TRIGGER IF EXISTS message_search_on_update;
+DROP TRIGGER IF EXISTS message_search_on_insert;
+DROP TABLE IF EXISTS message_search;
+DROP VIEW IF EXISTS message_text;
+-- +goose StatementEnd
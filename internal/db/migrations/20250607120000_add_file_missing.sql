@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Versions recording that a file did not exist, before it was created or
+-- after it was deleted. The empty versions recorded before can't be told
+-- from empty files, they are restored as empty files.
+ALTER TABLE files ADD COLUMN missing BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE files DROP COLUMN missing;
+-- +goose StatementEnd
@@ -8,6 +8,14 @@ import (
 	"database/sql"
 )
 
+type Checkpoint struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	Files     string `json:"files"`
+	CreatedAt int64  `json:"created_at"`
+}
+
 type File struct {
 	ID        string `json:"id"`
 	SessionID string `json:"session_id"`
@@ -16,6 +24,7 @@ type File struct {
 	Version   string `json:"version"`
 	CreatedAt int64  `json:"created_at"`
 	UpdatedAt int64  `json:"updated_at"`
+	Missing   bool   `json:"missing"`
 }
 
 type Message struct {
@@ -27,6 +36,7 @@ type Message struct {
 	CreatedAt  int64          `json:"created_at"`
 	UpdatedAt  int64          `json:"updated_at"`
 	FinishedAt sql.NullInt64  `json:"finished_at"`
+	RevertedAt sql.NullInt64  `json:"reverted_at"`
 }
 
 type Session struct {
@@ -40,4 +50,22 @@ type Session struct {
 	UpdatedAt        int64          `json:"updated_at"`
 	CreatedAt        int64          `json:"created_at"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	BranchParentID   sql.NullString `json:"branch_parent_id"`
+	BranchMessageID  sql.NullString `json:"branch_message_id"`
+	Agent            string         `json:"agent"`
+}
+
+type Usage struct {
+	ID               string  `json:"id"`
+	SessionID        string  `json:"session_id"`
+	Agent            string  `json:"agent"`
+	Model            string  `json:"model"`
+	Provider         string  `json:"provider"`
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CacheReadTokens  int64   `json:"cache_read_tokens"`
+	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	Cost             float64 `json:"cost"`
+	LatencyMs        int64   `json:"latency_ms"`
+	CreatedAt        int64   `json:"created_at"`
 }
@@ -0,0 +1,33 @@
+-- name: CreateCheckpoint :one
+INSERT INTO checkpoints (
+    id,
+    session_id,
+    message_id,
+    files,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetCheckpointByMessage :one
+SELECT *
+FROM checkpoints
+WHERE message_id = ? LIMIT 1;
+
+-- name: ListCheckpointsBySession :many
+SELECT *
+FROM checkpoints
+WHERE session_id = ?
+ORDER BY created_at ASC;
+
+-- name: ImportCheckpoint :exec
+INSERT INTO checkpoints (
+    id,
+    session_id,
+    message_id,
+    files,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?
+);
@@ -23,10 +23,31 @@ INSERT INTO messages (
 )
 RETURNING *;
 
+-- name: CopyMessage :exec
+INSERT INTO messages (
+    id,
+    session_id,
+    role,
+    parts,
+    model,
+    created_at,
+    updated_at,
+    finished_at,
+    reverted_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: RevertMessage :exec
+UPDATE messages
+SET reverted_at = strftime('%s', 'now')
+WHERE id = ?;
+
 -- name: UpdateMessage :exec
 UPDATE messages
 SET
     parts = ?,
+    model = ?,
     finished_at = ?,
     updated_at = strftime('%s', 'now')
 WHERE id = ?;
@@ -39,3 +60,18 @@ WHERE id = ?;
 -- name: DeleteSessionMessages :exec
 DELETE FROM messages
 WHERE session_id = ?;
+
+-- name: SearchMessages :many
+SELECT
+    m.id,
+    m.session_id,
+    m.role,
+    m.created_at,
+    s.title AS session_title,
+    snippet(message_search, 0, char(2), char(3), '…', 16) AS snippet
+FROM message_search
+JOIN messages AS m ON m.rowid = message_search.rowid
+JOIN sessions AS s ON s.id = m.session_id
+WHERE message_search MATCH sqlc.arg(query)
+ORDER BY rank, m.created_at DESC
+LIMIT ?;
@@ -0,0 +1,52 @@
+-- name: CreateUsage :exec
+INSERT INTO usage (
+    id,
+    session_id,
+    agent,
+    model,
+    provider,
+    input_tokens,
+    output_tokens,
+    cache_read_tokens,
+    cache_write_tokens,
+    cost,
+    latency_ms,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListUsage :many
+SELECT
+    u.id,
+    u.session_id,
+    u.agent,
+    u.model,
+    u.provider,
+    u.input_tokens,
+    u.output_tokens,
+    u.cache_read_tokens,
+    u.cache_write_tokens,
+    u.cost,
+    u.latency_ms,
+    u.created_at,
+    CAST(COALESCE(s.title, '') AS TEXT) AS session_title
+FROM usage AS u
+LEFT JOIN sessions AS s ON s.id = u.session_id
+WHERE u.created_at >= sqlc.arg(from_time) AND u.created_at < sqlc.arg(to_time)
+ORDER BY u.created_at ASC;
+
+-- name: GetSessionSpending :one
+SELECT
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost,
+    CAST(COALESCE(SUM(input_tokens + output_tokens + cache_read_tokens + cache_write_tokens), 0) AS INTEGER) AS tokens
+FROM usage
+WHERE session_id = ?1
+   OR session_id IN (SELECT id FROM sessions WHERE parent_session_id = ?1);
+
+-- name: GetDailySpending :one
+SELECT
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost,
+    CAST(COALESCE(SUM(input_tokens + output_tokens + cache_read_tokens + cache_write_tokens), 0) AS INTEGER) AS tokens
+FROM usage
+WHERE created_at >= sqlc.arg(from_time) AND created_at < sqlc.arg(to_time);
@@ -10,6 +10,7 @@ import (
 
 	"github.com/alecthomas/chroma/v2"
 	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/aymanbagabas/go-udiff"
@@ -324,23 +325,52 @@ func pairLines(lines []DiffLine) []linePair {
 
 // SyntaxHighlight applies syntax highlighting to text based on file extension
 func SyntaxHighlight(w io.Writer, source, fileName, formatter string, bg lipgloss.TerminalColor) error {
-	t := theme.CurrentTheme()
+	// Get the formatter
+	f := formatters.Get(formatter)
+	if f == nil {
+		f = formatters.Fallback
+	}
 
-	// Determine the language lexer to use
+	// Tokenize and format
+	it, err := lexerFor(fileName, source).Tokenise(nil, source)
+	if err != nil {
+		return err
+	}
+
+	return f.Format(w, themeStyle(bg), it)
+}
+
+// HighlightHTML writes source as a <pre> block with inline styles, using the
+// syntax colors of the current theme
+func HighlightHTML(w io.Writer, source, fileName string) error {
+	it, err := lexerFor(fileName, source).Tokenise(nil, source)
+	if err != nil {
+		return err
+	}
+	f := html.New(html.WithClasses(false), html.TabWidth(4))
+	return f.Format(w, themeStyle(theme.CurrentTheme().BackgroundSecondary()), it)
+}
+
+// lexerFor determines the language lexer to use for a file, fileName can
+// also be the name of a language
+func lexerFor(fileName, source string) chroma.Lexer {
 	l := lexers.Match(fileName)
+	if l == nil {
+		l = lexers.Get(fileName)
+	}
 	if l == nil {
 		l = lexers.Analyse(source)
 	}
 	if l == nil {
 		l = lexers.Fallback
 	}
-	l = chroma.Coalesce(l)
+	return chroma.Coalesce(l)
+}
 
-	// Get the formatter
-	f := formatters.Get(formatter)
-	if f == nil {
-		f = formatters.Fallback
-	}
+// themeStyle builds a chroma style from the current theme, on the given
+// background
+func themeStyle(bg lipgloss.TerminalColor) *chroma.Style {
+	t := theme.CurrentTheme()
 
 	// Dynamic theme based on current theme values
 	syntaxThemeXml := fmt.Sprintf(`
@@ -521,14 +551,7 @@ func SyntaxHighlight(w io.Writer, source, fileName, formatter string, bg lipglos
 	if err != nil {
 		s = styles.Fallback
 	}
-
-	// Tokenize and format
-	it, err := l.Tokenise(nil, source)
-	if err != nil {
-		return err
-	}
-
-	return f.Format(w, s, it)
+	return s
 }
 
 // getColor returns the appropriate hex color string based on terminal background
@@ -0,0 +1,229 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/diff"
+)
+
+// Checkpoint records the version of every file tracked in a session at the
+// time a user message was sent.
+type Checkpoint struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	// Files maps each tracked path to the id of its version at the checkpoint
+	Files     map[string]string `json:"files"`
+	CreatedAt int64             `json:"created_at"`
+}
+
+// FileChange describes how a file is changed to bring it back to a checkpoint
+type FileChange struct {
+	Path string `json:"path"`
+	// Content is what the file is restored to, unless it is removed
+	Content   string `json:"-"`
+	Removed   bool   `json:"removed"`
+	Diff      string `json:"diff"`
+	Additions int    `json:"additions"`
+	Removals  int    `json:"removals"`
+}
+
+func (s *service) CreateCheckpoint(ctx context.Context, sessionID, messageID string) (Checkpoint, error) {
+	files, err := s.ListBySession(ctx, sessionID)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	versions := make(map[string]string)
+	for path, file := range LatestVersions(files) {
+		versions[path] = file.ID
+	}
+	filesJSON, err := json.Marshal(versions)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	dbCheckpoint, err := s.q.CreateCheckpoint(ctx, db.CreateCheckpointParams{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		MessageID: messageID,
+		Files:     string(filesJSON),
+	})
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return checkpointFromDBItem(dbCheckpoint)
+}
+
+func (s *service) GetCheckpoint(ctx context.Context, messageID string) (Checkpoint, error) {
+	dbCheckpoint, err := s.q.GetCheckpointByMessage(ctx, messageID)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return checkpointFromDBItem(dbCheckpoint)
+}
+
+func (s *service) ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error) {
+	dbCheckpoints, err := s.q.ListCheckpointsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints := make([]Checkpoint, len(dbCheckpoints))
+	for i, dbCheckpoint := range dbCheckpoints {
+		checkpoints[i], err = checkpointFromDBItem(dbCheckpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return checkpoints, nil
+}
+
+func (s *service) ImportCheckpoint(ctx context.Context, sessionID string, checkpoint Checkpoint) (Checkpoint, error) {
+	filesJSON, err := json.Marshal(checkpoint.Files)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	imported := Checkpoint{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		MessageID: checkpoint.MessageID,
+		Files:     checkpoint.Files,
+		CreatedAt: checkpoint.CreatedAt,
+	}
+	err = s.q.ImportCheckpoint(ctx, db.ImportCheckpointParams{
+		ID:        imported.ID,
+		SessionID: imported.SessionID,
+		MessageID: imported.MessageID,
+		Files:     string(filesJSON),
+		CreatedAt: imported.CreatedAt,
+	})
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return imported, nil
+}
+
+// PlanRestore compares the files on disk with their state at the checkpoint
+// and returns the changes needed to restore them. Files that were first
+// touched after the checkpoint go back to their content from before the
+// session, and are removed when the session created them.
+func (s *service) PlanRestore(ctx context.Context, checkpoint Checkpoint) ([]FileChange, error) {
+	files, err := s.ListBySession(ctx, checkpoint.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]File, len(files))
+	initial := make(map[string]File)
+	for _, file := range files {
+		byID[file.ID] = file
+		if first, ok := initial[file.Path]; !ok || versionNumber(file.Version) < versionNumber(first.Version) {
+			initial[file.Path] = file
+		}
+	}
+
+	paths := make([]string, 0, len(initial))
+	for path := range initial {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	changes := []FileChange{}
+	for _, path := range paths {
+		target, tracked := byID[checkpoint.Files[path]]
+		if !tracked {
+			target = initial[path]
+		}
+		remove := target.Missing
+
+		current, err := os.ReadFile(path)
+		exists := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if (remove && !exists) || (!remove && exists && string(current) == target.Content) {
+			continue
+		}
+
+		diffText, additions, removals := diff.GenerateDiff(string(current), target.Content, path)
+		changes = append(changes, FileChange{
+			Path:      path,
+			Content:   target.Content,
+			Removed:   remove,
+			Diff:      diffText,
+			Additions: additions,
+			Removals:  removals,
+		})
+	}
+	return changes, nil
+}
+
+// Restore applies the changes returned by PlanRestore and records the
+// restored content as a new version of each file.
+func (s *service) Restore(ctx context.Context, sessionID string, changes []FileChange) error {
+	for _, change := range changes {
+		if change.Removed {
+			if err := os.Remove(change.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if _, err := s.CreateMissing(ctx, sessionID, change.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(change.Path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(change.Path, []byte(change.Content), 0o644); err != nil {
+			return err
+		}
+		if _, err := s.CreateVersion(ctx, sessionID, change.Path, change.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LatestVersions returns the most recent version of every path
+func LatestVersions(files []File) map[string]File {
+	latest := make(map[string]File)
+	for _, file := range files {
+		if current, ok := latest[file.Path]; !ok || versionNumber(file.Version) >= versionNumber(current.Version) {
+			latest[file.Path] = file
+		}
+	}
+	return latest
+}
+
+// versionNumber orders the versions of a path, timestamps only have a one
+// second resolution so they can't be used for that.
+func versionNumber(version string) int64 {
+	if version == InitialVersion {
+		return 0
+	}
+	n, err := strconv.ParseInt(strings.TrimPrefix(version, "v"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func checkpointFromDBItem(item db.Checkpoint) (Checkpoint, error) {
+	files := make(map[string]string)
+	if err := json.Unmarshal([]byte(item.Files), &files); err != nil {
+		return Checkpoint{}, err
+	}
+	return Checkpoint{
+		ID:        item.ID,
+		SessionID: item.SessionID,
+		MessageID: item.MessageID,
+		Files:     files,
+		CreatedAt: item.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	files := NewService(q, conn)
+	ctx := context.Background()
+
+	sess, err := session.NewService(q, conn).Create(ctx, "Restore")
+	require.NoError(t, err)
+	prompt, err := message.NewService(q).Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "change the files"}},
+	})
+	require.NoError(t, err)
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	// write changes a file on disk and records it like the tools do
+	write := func(name, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path(name), []byte(content), 0o644))
+		_, err := files.CreateVersion(ctx, sess.ID, path(name), content)
+		require.NoError(t, err)
+	}
+	create := func(name, content string) {
+		t.Helper()
+		_, err := files.CreateMissing(ctx, sess.ID, path(name))
+		require.NoError(t, err)
+		write(name, content)
+	}
+	track := func(name, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path(name), []byte(content), 0o644))
+		_, err := files.Create(ctx, sess.ID, path(name), content)
+		require.NoError(t, err)
+	}
+
+	// Before the checkpoint
+	track("modified.go", "package a\n")
+	write("modified.go", "package b\n")
+	create("early.go", "package early\n")
+	create("deleted.go", "package deleted\n")
+	require.NoError(t, os.Remove(path("deleted.go")))
+	_, err = files.CreateMissing(ctx, sess.ID, path("deleted.go"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path("untracked.go"), []byte("package untracked\n"), 0o644))
+
+	checkpoint, err := files.CreateCheckpoint(ctx, sess.ID, prompt.ID)
+	require.NoError(t, err)
+
+	// After the checkpoint
+	write("modified.go", "package c\n")
+	write("early.go", "package later\n")
+	create("deleted.go", "package recreated\n")
+	create("created.go", "package created\n")
+	track("empty.txt", "")
+	write("empty.txt", "not empty\n")
+	require.NoError(t, os.WriteFile(path("untracked.go"), []byte("package changed\n"), 0o644))
+
+	changes, err := files.PlanRestore(ctx, checkpoint)
+	require.NoError(t, err)
+	planned := make(map[string]FileChange)
+	for _, change := range changes {
+		planned[filepath.Base(change.Path)] = change
+	}
+	require.Len(t, planned, 5)
+	assert.Equal(t, "package b\n", planned["modified.go"].Content)
+	assert.False(t, planned["modified.go"].Removed)
+	assert.Equal(t, "package early\n", planned["early.go"].Content)
+	assert.True(t, planned["deleted.go"].Removed)
+	assert.True(t, planned["created.go"].Removed)
+	// An empty file from before the session is emptied, not removed
+	assert.False(t, planned["empty.txt"].Removed)
+	assert.Equal(t, "", planned["empty.txt"].Content)
+
+	require.NoError(t, files.Restore(ctx, sess.ID, changes))
+	content := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(path(name))
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "package b\n", content("modified.go"))
+	assert.Equal(t, "package early\n", content("early.go"))
+	assert.Equal(t, "", content("empty.txt"))
+	assert.Equal(t, "package changed\n", content("untracked.go"))
+	assert.NoFileExists(t, path("deleted.go"))
+	assert.NoFileExists(t, path("created.go"))
+
+	// The restored files are recorded, nothing is left to restore
+	versions, err := files.ListBySession(ctx, sess.ID)
+	require.NoError(t, err)
+	latest := LatestVersions(versions)
+	assert.True(t, latest[path("created.go")].Missing)
+	assert.Equal(t, "package b\n", latest[path("modified.go")].Content)
+	changes, err = files.PlanRestore(ctx, checkpoint)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
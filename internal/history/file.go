@@ -18,19 +18,25 @@ const (
 )
 
 type File struct {
-	ID        string
-	SessionID string
-	Path      string
-	Content   string
-	Version   string
-	CreatedAt int64
-	UpdatedAt int64
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Version   string `json:"version"`
+	// Missing versions record that the file did not exist, before it was
+	// created or after it was deleted
+	Missing   bool  `json:"missing,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
 }
 
 type Service interface {
 	pubsub.Suscriber[File]
 	Create(ctx context.Context, sessionID, path, content string) (File, error)
 	CreateVersion(ctx context.Context, sessionID, path, content string) (File, error)
+	// CreateMissing records that a file does not exist, as its initial
+	// version or as a new one when it is deleted
+	CreateMissing(ctx context.Context, sessionID, path string) (File, error)
 	Get(ctx context.Context, id string) (File, error)
 	GetByPathAndSession(ctx context.Context, path, sessionID string) (File, error)
 	ListBySession(ctx context.Context, sessionID string) ([]File, error)
@@ -38,6 +44,15 @@ type Service interface {
 	Update(ctx context.Context, file File) (File, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
+	CreateCheckpoint(ctx context.Context, sessionID, messageID string) (Checkpoint, error)
+	GetCheckpoint(ctx context.Context, messageID string) (Checkpoint, error)
+	PlanRestore(ctx context.Context, checkpoint Checkpoint) ([]FileChange, error)
+	Restore(ctx context.Context, sessionID string, changes []FileChange) error
+	ListCheckpoints(ctx context.Context, sessionID string) ([]Checkpoint, error)
+	// Import adds a copy of an exported file version to a session, and
+	// ImportCheckpoint a checkpoint pointing to the imported versions
+	Import(ctx context.Context, sessionID string, file File) (File, error)
+	ImportCheckpoint(ctx context.Context, sessionID string, checkpoint Checkpoint) (Checkpoint, error)
 }
 
 type service struct {
@@ -55,10 +70,18 @@ func NewService(q *db.Queries, db *sql.DB) Service {
 }
 
 func (s *service) Create(ctx context.Context, sessionID, path, content string) (File, error) {
-	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, InitialVersion, false)
 }
 
 func (s *service) CreateVersion(ctx context.Context, sessionID, path, content string) (File, error) {
+	return s.createNextVersion(ctx, sessionID, path, content, false)
+}
+
+func (s *service) CreateMissing(ctx context.Context, sessionID, path string) (File, error) {
+	return s.createNextVersion(ctx, sessionID, path, "", true)
+}
+
+func (s *service) createNextVersion(ctx context.Context, sessionID, path, content string, missing bool) (File, error) {
 	// Get the latest version for this path
 	files, err := s.q.ListFilesByPath(ctx, path)
 	if err != nil {
@@ -67,11 +90,17 @@ func (s *service) CreateVersion(ctx context.Context, sessionID, path, content st
 
 	if len(files) == 0 {
 		// No previous versions, create initial
-		return s.Create(ctx, sessionID, path, content)
+		return s.createWithVersion(ctx, sessionID, path, content, InitialVersion, missing)
 	}
 
-	// Get the latest version
-	latestFile := files[0] // Files are ordered by created_at DESC
+	// Get the latest version, files are ordered by created_at DESC but several
+	// versions can be created within the same second
+	latestFile := files[0]
+	for _, file := range files[1:] {
+		if versionNumber(file.Version) > versionNumber(latestFile.Version) {
+			latestFile = file
+		}
+	}
 	latestVersion := latestFile.Version
 
 	// Generate the next version
@@ -91,10 +120,10 @@ func (s *service) CreateVersion(ctx context.Context, sessionID, path, content st
 		nextVersion = fmt.Sprintf("v%d", latestFile.CreatedAt)
 	}
 
-	return s.createWithVersion(ctx, sessionID, path, content, nextVersion)
+	return s.createWithVersion(ctx, sessionID, path, content, nextVersion, missing)
 }
 
-func (s *service) createWithVersion(ctx context.Context, sessionID, path, content, version string) (File, error) {
+func (s *service) createWithVersion(ctx context.Context, sessionID, path, content, version string, missing bool) (File, error) {
 	// Maximum number of retries for transaction conflicts
 	const maxRetries = 3
 	var file File
@@ -118,6 +147,7 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 			Path:      path,
 			Content:   content,
 			Version:   version,
+			Missing:   missing,
 		})
 		if txErr != nil {
 			// Rollback the transaction
@@ -155,6 +185,25 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 	return file, err
 }
 
+func (s *service) Import(ctx context.Context, sessionID string, file File) (File, error) {
+	dbFile, err := s.q.ImportFile(ctx, db.ImportFileParams{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Path:      file.Path,
+		Content:   file.Content,
+		Version:   file.Version,
+		Missing:   file.Missing,
+		CreatedAt: file.CreatedAt,
+		UpdatedAt: file.UpdatedAt,
+	})
+	if err != nil {
+		return File{}, err
+	}
+	imported := s.fromDBItem(dbFile)
+	s.Publish(pubsub.CreatedEvent, imported)
+	return imported, nil
+}
+
 func (s *service) Get(ctx context.Context, id string) (File, error) {
 	dbFile, err := s.q.GetFile(ctx, id)
 	if err != nil {
@@ -246,6 +295,7 @@ func (s *service) fromDBItem(item db.File) File {
 		Path:      item.Path,
 		Content:   item.Content,
 		Version:   item.Version,
+		Missing:   item.Missing,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
 	}
@@ -0,0 +1,195 @@
+// Package hooks runs the user scripts configured for the events of the
+// lifecycle of the agent, like the calls to the tools and the prompts.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/tools/shell"
+	"github.com/opencode-ai/opencode/internal/logging"
+)
+
+// DefaultTimeout is how long a hook runs when its timeout is not set
+const DefaultTimeout = 60 * time.Second
+
+// exitBlock is the exit code of the hooks blocking the event, their stderr is
+// the reason
+const exitBlock = 2
+
+// Input is the event sent as JSON on the stdin of the hooks.
+type Input struct {
+	Event      config.HookEvent `json:"event"`
+	SessionID  string           `json:"session_id"`
+	Agent      string           `json:"agent,omitempty"`
+	WorkingDir string           `json:"cwd"`
+
+	// Tool events
+	ToolName     string          `json:"tool_name,omitempty"`
+	ToolInput    json.RawMessage `json:"tool_input,omitempty"`
+	ToolResponse *ToolResponse   `json:"tool_response,omitempty"`
+
+	// Prompt is the prompt of the user, for userPromptSubmit
+	Prompt string `json:"prompt,omitempty"`
+
+	// FinishReason, Response and Error describe how the turn ended, for stop
+	FinishReason string `json:"finish_reason,omitempty"`
+	Response     string `json:"response,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ToolResponse is the result of a tool, for postToolUse
+type ToolResponse struct {
+	Content string `json:"content"`
+	IsError bool   `json:"is_error"`
+}
+
+// output is what a hook can write as JSON on its stdout
+type output struct {
+	// Decision is "block" to block the event
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	// ToolInput replaces the input of the tool, for preToolUse
+	ToolInput json.RawMessage `json:"tool_input"`
+	// Feedback is added to the result of the tool, for postToolUse
+	Feedback string `json:"feedback"`
+}
+
+// Result is what the hooks of an event decided.
+type Result struct {
+	// Blocked is set when a hook blocked the tool call or the prompt
+	Blocked bool
+	Reason  string
+	// ToolInput is the input of the tool rewritten by the hooks, empty when
+	// it is unchanged
+	ToolInput string
+	// Feedback is the feedback of the hooks on the result of the tool
+	Feedback string
+}
+
+// Run runs the hooks of the event in order, each one receiving the tool
+// input rewritten by the ones before. Only preToolUse and userPromptSubmit
+// hooks can block, and the first one that does stops the others. A hook that
+// fails otherwise is logged and ignored.
+func Run(ctx context.Context, input Input) Result {
+	cfg := config.Get()
+	if cfg == nil {
+		return Result{}
+	}
+	if input.WorkingDir == "" {
+		input.WorkingDir = cfg.WorkingDir
+	}
+	// The models can send invalid JSON, which is passed on as a string
+	if len(input.ToolInput) > 0 && !json.Valid(input.ToolInput) {
+		input.ToolInput, _ = json.Marshal(string(input.ToolInput))
+	}
+	var result Result
+	var feedback []string
+	for _, hook := range cfg.Hooks.For(input.Event) {
+		if !matches(hook, input.ToolName) {
+			continue
+		}
+		out, err := run(ctx, hook, input)
+		if err != nil {
+			logging.WarnPersist(fmt.Sprintf("%s hook %q failed: %v", input.Event, hook.Command, err))
+			continue
+		}
+		if out.Feedback != "" {
+			feedback = append(feedback, out.Feedback)
+		}
+		if out.Decision == "block" && canBlock(input.Event) {
+			result.Blocked = true
+			result.Reason = out.Reason
+			break
+		}
+		if len(out.ToolInput) > 0 && input.Event == config.HookPreToolUse {
+			input.ToolInput = out.ToolInput
+			result.ToolInput = string(out.ToolInput)
+		}
+	}
+	result.Feedback = strings.Join(feedback, "\n")
+	return result
+}
+
+// matches reports whether the hook runs for the tool, the hooks without
+// tools run for all of them
+func matches(h config.Hook, toolName string) bool {
+	if toolName == "" || len(h.Tools) == 0 {
+		return true
+	}
+	for _, pattern := range h.Tools {
+		if ok, _ := path.Match(pattern, toolName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func canBlock(event config.HookEvent) bool {
+	return event == config.HookPreToolUse || event == config.HookUserPromptSubmit
+}
+
+// run runs a hook outside of the sandbox, in the working directory
+func run(ctx context.Context, h config.Hook, input Input) (output, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return output{}, err
+	}
+	timeout := DefaultTimeout
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, shell.Path(), "-c", h.Command)
+	cmd.Dir = input.WorkingDir
+	cmd.Stdin = bytes.NewReader(data)
+	// The processes left running by the hook would keep its output open
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitBlock {
+		reason := strings.TrimSpace(stderr.String())
+		if input.Event == config.HookPostToolUse {
+			return output{Feedback: reason}, nil
+		}
+		return output{Decision: "block", Reason: reason}, nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return output{}, fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return output{}, fmt.Errorf("%w: %s", err, msg)
+		}
+		return output{}, err
+	}
+
+	// A hook that doesn't print JSON only observes the event
+	var out output
+	if trimmed := bytes.TrimSpace(stdout.Bytes()); bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &out); err != nil {
+			return output{}, fmt.Errorf("invalid output: %w", err)
+		}
+	}
+	if len(out.ToolInput) > 0 {
+		var params map[string]any
+		if err := json.Unmarshal(out.ToolInput, &params); err != nil {
+			return output{}, fmt.Errorf("invalid tool_input: %w", err)
+		}
+	}
+	return out, nil
+}
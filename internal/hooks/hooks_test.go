@@ -0,0 +1,71 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Shell.Path = "/bin/sh"
+	config.Get().Hooks = config.HooksConfig{
+		PreToolUse: []config.Hook{
+			{Command: "cat > pre.json; echo '{\"tool_input\": {\"command\": \"ls -la\"}}'", Tools: []string{"bash"}},
+			{Command: "grep -q 'rm -rf' && { echo 'no rm -rf' >&2; exit 2; }; true"},
+			{Command: "echo ignored", Tools: []string{"edit"}},
+		},
+		PostToolUse: []config.Hook{
+			{Command: "echo '{\"feedback\": \"run the tests\"}'"},
+			{Command: "echo 'lint failed' >&2; exit 2"},
+			{Command: "exit 1"},
+		},
+		Stop: []config.Hook{
+			{Command: "echo '{\"decision\": \"block\"}'"},
+		},
+	}
+
+	// The input of the bash hook is rewritten
+	result := Run(t.Context(), Input{
+		Event:     config.HookPreToolUse,
+		SessionID: "session",
+		ToolName:  "bash",
+		ToolInput: json.RawMessage(`{"command": "ls"}`),
+	})
+	assert.False(t, result.Blocked)
+	assert.JSONEq(t, `{"command": "ls -la"}`, result.ToolInput)
+	data, err := os.ReadFile(filepath.Join(dir, "pre.json"))
+	require.NoError(t, err)
+	var input Input
+	require.NoError(t, json.Unmarshal(data, &input))
+	assert.Equal(t, config.HookPreToolUse, input.Event)
+	assert.Equal(t, "session", input.SessionID)
+	assert.Equal(t, dir, input.WorkingDir)
+
+	result = Run(t.Context(), Input{
+		Event:     config.HookPreToolUse,
+		ToolName:  "write",
+		ToolInput: json.RawMessage(`{"command": "rm -rf /"}`),
+	})
+	assert.True(t, result.Blocked)
+	assert.Equal(t, "no rm -rf", result.Reason)
+
+	result = Run(t.Context(), Input{
+		Event:        config.HookPostToolUse,
+		ToolName:     "bash",
+		ToolResponse: &ToolResponse{Content: "done"},
+	})
+	assert.False(t, result.Blocked)
+	assert.Equal(t, "run the tests\nlint failed", result.Feedback)
+
+	// Only tool calls and prompts can be blocked
+	result = Run(t.Context(), Input{Event: config.HookStop})
+	assert.False(t, result.Blocked)
+}
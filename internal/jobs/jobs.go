@@ -0,0 +1,305 @@
+// Package jobs runs shell commands in the background, for the dev servers,
+// watchers and long test suites that would block the shell of the bash tool.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/llm/tools/shell"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+)
+
+// MaxOutput is the number of bytes of output kept for each job, the oldest
+// are dropped first
+const MaxOutput = 1 << 20
+
+var (
+	ErrNotFound   = errors.New("job not found")
+	ErrNotRunning = errors.New("job is not running")
+)
+
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusExited  Status = "exited"
+	// StatusKilled is the status of the jobs terminated by a signal
+	StatusKilled Status = "killed"
+)
+
+type Job struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Command   string `json:"command"`
+	Dir       string `json:"dir"`
+	Status    Status `json:"status"`
+	// ExitCode is set when the job exited, Signal when it was killed
+	ExitCode  int    `json:"exit_code"`
+	Signal    string `json:"signal,omitempty"`
+	StartedAt int64  `json:"started_at"`
+	EndedAt   int64  `json:"ended_at,omitempty"`
+}
+
+// Running reports whether the job is still running.
+func (j Job) Running() bool {
+	return j.Status == StatusRunning
+}
+
+type Service interface {
+	pubsub.Suscriber[Job]
+	// Start runs command with the shell of the config in dir, the output of
+	// the command is kept until it is read with Output
+	Start(sessionID, command, dir string) (Job, error)
+	Get(id string) (Job, error)
+	// List returns the jobs of a session, or all of them when sessionID is
+	// empty, oldest first
+	List(sessionID string) []Job
+	// Output returns the output of the job since the last call, stdout and
+	// stderr interleaved
+	Output(id string) (string, error)
+	// Wait waits for the job to end, up to timeout
+	Wait(ctx context.Context, id string, timeout time.Duration) (Job, error)
+	// Write sends input to the job
+	Write(id, input string) error
+	Signal(id string, sig syscall.Signal) error
+	// Kill kills the job and the processes it started
+	Kill(id string) error
+	// Shutdown kills every running job
+	Shutdown()
+}
+
+type job struct {
+	Job
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *output
+	done  chan struct{}
+}
+
+type service struct {
+	*pubsub.Broker[Job]
+
+	mu     sync.Mutex
+	jobs   []*job
+	nextID int
+}
+
+func (s *service) Start(sessionID, command, dir string) (Job, error) {
+	cmd, err := shell.Command(dir, "-c", command)
+	if err != nil {
+		return Job{}, err
+	}
+	setProcessGroup(cmd)
+	// The processes left running by the job would keep its output open
+	cmd.WaitDelay = time.Second
+	out := &output{}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return Job{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return Job{}, fmt.Errorf("failed to start the job: %w", err)
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	j := &job{
+		Job: Job{
+			ID:        strconv.Itoa(s.nextID),
+			SessionID: sessionID,
+			Command:   command,
+			Dir:       dir,
+			Status:    StatusRunning,
+			StartedAt: time.Now().Unix(),
+		},
+		cmd:   cmd,
+		stdin: stdin,
+		out:   out,
+		done:  make(chan struct{}),
+	}
+	s.jobs = append(s.jobs, j)
+	started := j.Job
+	s.mu.Unlock()
+	s.Publish(pubsub.CreatedEvent, started)
+
+	go s.wait(j)
+	return started, nil
+}
+
+// wait records how the job ended
+func (s *service) wait(j *job) {
+	err := j.cmd.Wait()
+	s.mu.Lock()
+	j.Status = StatusExited
+	j.EndedAt = time.Now().Unix()
+	if state := j.cmd.ProcessState; state != nil {
+		j.ExitCode = state.ExitCode()
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			j.Status = StatusKilled
+			j.Signal = status.Signal().String()
+		}
+	} else if err != nil {
+		j.ExitCode = -1
+		logging.Error("Failed to wait for a job", "id", j.ID, "error", err)
+	}
+	ended := j.Job
+	s.mu.Unlock()
+	close(j.done)
+	s.Publish(pubsub.UpdatedEvent, ended)
+}
+
+func (s *service) find(id string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *service) Get(id string) (Job, error) {
+	j, err := s.find(id)
+	if err != nil {
+		return Job{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return j.Job, nil
+}
+
+func (s *service) List(sessionID string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		if sessionID == "" || j.SessionID == sessionID {
+			jobs = append(jobs, j.Job)
+		}
+	}
+	return jobs
+}
+
+func (s *service) Output(id string) (string, error) {
+	j, err := s.find(id)
+	if err != nil {
+		return "", err
+	}
+	return j.out.unread(), nil
+}
+
+func (s *service) Wait(ctx context.Context, id string, timeout time.Duration) (Job, error) {
+	j, err := s.find(id)
+	if err != nil {
+		return Job{}, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-j.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+	return s.Get(id)
+}
+
+func (s *service) Write(id, input string) error {
+	j, err := s.running(id)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(j.stdin, input)
+	return err
+}
+
+func (s *service) Signal(id string, sig syscall.Signal) error {
+	j, err := s.running(id)
+	if err != nil {
+		return err
+	}
+	return signalGroup(j.cmd, sig)
+}
+
+func (s *service) Kill(id string) error {
+	return s.Signal(id, syscall.SIGKILL)
+}
+
+func (s *service) Shutdown() {
+	for _, j := range s.List("") {
+		if !j.Running() {
+			continue
+		}
+		if err := s.Kill(j.ID); err != nil && !errors.Is(err, ErrNotRunning) {
+			logging.Error("Failed to kill a job", "id", j.ID, "error", err)
+		}
+	}
+}
+
+// running returns a job that didn't end yet
+func (s *service) running(id string) (*job, error) {
+	j, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-j.done:
+		return nil, ErrNotRunning
+	default:
+		return j, nil
+	}
+}
+
+// output keeps the last MaxOutput bytes written by a job and what was read
+type output struct {
+	mu      sync.Mutex
+	buf     []byte
+	written int64
+	read    int64
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	// Trimmed once in a while rather than on every write
+	if len(o.buf) > 2*MaxOutput {
+		o.buf = append(o.buf[:0], o.buf[len(o.buf)-MaxOutput:]...)
+	}
+	o.written += int64(len(p))
+	return len(p), nil
+}
+
+// unread returns the output written since the last call
+func (o *output) unread() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	kept := o.buf[max(len(o.buf)-MaxOutput, 0):]
+	first := o.written - int64(len(kept))
+	var dropped string
+	if o.read < first {
+		dropped = fmt.Sprintf("[%d bytes dropped]\n", first-o.read)
+		o.read = first
+	}
+	unread := dropped + string(kept[o.read-first:])
+	o.read = o.written
+	return unread
+}
+
+func NewService() Service {
+	return &service{
+		Broker: pubsub.NewBroker[Job](),
+	}
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobs(t *testing.T) {
+	s := NewService()
+	defer s.Shutdown()
+
+	job, err := s.Start("session", "echo started; read line; echo got $line; exit 3", t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, job.Running())
+	require.Eventually(t, func() bool {
+		out, err := s.Output(job.ID)
+		require.NoError(t, err)
+		return out == "started\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, s.Write(job.ID, "input\n"))
+	job, err = s.Wait(t.Context(), job.ID, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, StatusExited, job.Status)
+	assert.Equal(t, 3, job.ExitCode)
+	out, err := s.Output(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "got input\n", out)
+	assert.ErrorIs(t, s.Kill(job.ID), ErrNotRunning)
+
+	// Killing a job kills what it started
+	job, err = s.Start("other", "sleep 60 & sleep 60", t.TempDir())
+	require.NoError(t, err)
+	job, err = s.Wait(t.Context(), job.ID, 100*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, job.Running())
+	require.NoError(t, s.Signal(job.ID, syscall.SIGTERM))
+	job, err = s.Wait(t.Context(), job.ID, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, StatusKilled, job.Status)
+	assert.Equal(t, "terminated", job.Signal)
+
+	assert.Len(t, s.List(""), 2)
+	assert.Len(t, s.List("other"), 1)
+	_, err = s.Get("3")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestOutputDropsOldest(t *testing.T) {
+	out := &output{}
+	out.Write([]byte("a"))
+	out.Write([]byte(strings.Repeat("b", MaxOutput)))
+	assert.Equal(t, "[1 bytes dropped]\n"+strings.Repeat("b", MaxOutput), out.unread())
+	out.Write([]byte("c"))
+	assert.Equal(t, "c", out.unread())
+	assert.Equal(t, "", out.unread())
+}
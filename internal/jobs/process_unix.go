@@ -0,0 +1,21 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the job in its own process group, so that the
+// signals reach the processes it starts
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup does nothing on Windows, only the job itself is signaled
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return cmd.Process.Kill()
+	}
+	return cmd.Process.Signal(sig)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
@@ -15,7 +16,10 @@ import (
 type agentTool struct {
 	sessions   session.Service
 	messages   message.Service
-	lspClients map[string]*lsp.Client
+	lspClients *lsp.Clients
+
+	// Sub-agents can run concurrently, serialize the updates of the parent cost
+	mu sync.Mutex
 }
 
 const (
@@ -54,7 +58,7 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 		return tools.ToolResponse{}, fmt.Errorf("session_id and message_id are required")
 	}
 
-	agent, err := NewAgent(config.AgentTask, b.sessions, b.messages, TaskAgentTools(b.lspClients))
+	agent, err := NewAgent(config.AgentTask, b.sessions, b.messages, nil, TaskAgentTools(b.lspClients), nil)
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error creating agent: %s", err)
 	}
@@ -82,6 +86,8 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error getting session: %s", err)
 	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	parentSession, err := b.sessions.Get(ctx, sessionID)
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error getting parent session: %s", err)
@@ -93,13 +99,16 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 	if err != nil {
 		return tools.ToolResponse{}, fmt.Errorf("error saving parent session: %s", err)
 	}
+	if response.FinishReason() == message.FinishReasonBudgetExceeded && result.Budget != nil {
+		return tools.NewTextErrorResponse(fmt.Sprintf("The agent stopped before finishing, %s\n\n%s", result.Budget, response.Content().String())), nil
+	}
 	return tools.NewTextResponse(response.Content().String()), nil
 }
 
 func NewAgentTool(
 	Sessions session.Service,
 	Messages message.Service,
-	LspClients map[string]*lsp.Client,
+	LspClients *lsp.Clients,
 ) tools.BaseTool {
 	return &agentTool{
 		sessions:   Sessions,
@@ -4,18 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/hooks"
 	"github.com/opencode-ai/opencode/internal/llm/models"
 	"github.com/opencode-ai/opencode/internal/llm/prompt"
 	"github.com/opencode-ai/opencode/internal/llm/provider"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/message"
-	"github.com/opencode-ai/opencode/internal/permission"
 	"github.com/opencode-ai/opencode/internal/pubsub"
 	"github.com/opencode-ai/opencode/internal/session"
 )
@@ -24,6 +26,8 @@ import (
 var (
 	ErrRequestCancelled = errors.New("request cancelled by user")
 	ErrSessionBusy      = errors.New("session is currently processing another request")
+	ErrBudgetExceeded   = errors.New("budget exceeded")
+	ErrPromptBlocked    = errors.New("prompt blocked by a hook")
 )
 
 type AgentEventType string
@@ -32,17 +36,32 @@ const (
 	AgentEventTypeError     AgentEventType = "error"
 	AgentEventTypeResponse  AgentEventType = "response"
 	AgentEventTypeSummarize AgentEventType = "summarize"
+	// AgentEventTypeBudget and AgentEventTypeBudgetWarning report the spending
+	// of a session with limits, the warning is sent once per run when it gets
+	// close to a limit.
+	AgentEventTypeBudget        AgentEventType = "budget"
+	AgentEventTypeBudgetWarning AgentEventType = "budget_warning"
+	// AgentEventTypeToolProgress carries output of a running tool call in
+	// Progress, like a chunk of the output of a command
+	AgentEventTypeToolProgress AgentEventType = "tool_progress"
 )
 
 type AgentEvent struct {
 	Type    AgentEventType
 	Message message.Message
 	Error   error
+	// Plan is set when the response ends with a plan to approve
+	Plan *Plan
+	// Budget is set for budget events and when a limit stopped the run
+	Budget *BudgetStatus
 
 	// When summarizing
 	SessionID string
 	Progress  string
 	Done      bool
+
+	// ToolCallID is the tool call of tool progress events
+	ToolCallID string
 }
 
 type Service interface {
@@ -54,27 +73,58 @@ type Service interface {
 	IsBusy() bool
 	Update(agentName config.AgentName, modelID models.ModelID) (models.Model, error)
 	Summarize(ctx context.Context, sessionID string) error
+	Mode(sessionID string) Mode
+	SetMode(sessionID string, mode Mode) error
+	SetAgent(ctx context.Context, sessionID string, agentName config.AgentName) error
+	// EndSessions runs the sessionEnd hooks of the sessions that ran since
+	// OpenCode started
+	EndSessions(ctx context.Context)
+}
+
+type customAgent struct {
+	provider provider.Provider
+	tools    []tools.BaseTool
 }
 
 type agent struct {
 	*pubsub.Broker[AgentEvent]
+	// name is the agent usage is accounted to, unless a session switched to
+	// one of the custom agents
+	name     config.AgentName
 	sessions session.Service
 	messages message.Service
+	// history records checkpoints, it is nil for agents that can't edit files
+	history history.Service
 
 	tools    []tools.BaseTool
 	provider provider.Provider
+	// planTools are used in plan mode, nil when the agent has no plan mode
+	planTools []tools.BaseTool
+	modes     sync.Map
+	// customAgents are the user-defined agents a session can switch to, Update
+	// changes their provider while sessions run
+	customAgents   map[config.AgentName]customAgent
+	customAgentsMu sync.RWMutex
 
 	titleProvider     provider.Provider
 	summarizeProvider provider.Provider
 
 	activeRequests sync.Map
+	// startedSessions are the sessions whose sessionStart hooks ran, with
+	// their agent name
+	startedSessions sync.Map
+	// stopHooks are the running stop hooks, they run after the session is
+	// free again
+	stopHooks sync.WaitGroup
 }
 
 func NewAgent(
 	agentName config.AgentName,
 	sessions session.Service,
 	messages message.Service,
+	history history.Service,
 	agentTools []tools.BaseTool,
+	planTools []tools.BaseTool,
 ) (Service, error) {
 	agentProvider, err := createAgentProvider(agentName)
 	if err != nil {
@@ -96,12 +146,33 @@ func NewAgent(
 		}
 	}
 
+	// User-defined agents pick their tools from the ones of the coder, one
+	// that can't be created doesn't keep the others from starting
+	customAgents := make(map[config.AgentName]customAgent)
+	if agentName == config.AgentCoder {
+		for _, name := range config.CustomAgents() {
+			customProvider, err := createAgentProvider(name)
+			if err != nil {
+				logging.WarnPersist(fmt.Sprintf("Agent %s is disabled: %v", name, err))
+				continue
+			}
+			customAgents[name] = customAgent{
+				provider: customProvider,
+				tools:    allowedTools(name, agentTools),
+			}
+		}
+	}
+
 	agent := &agent{
 		Broker:            pubsub.NewBroker[AgentEvent](),
+		name:              agentName,
 		provider:          agentProvider,
 		messages:          messages,
 		sessions:          sessions,
-		tools:             agentTools,
+		history:           history,
+		tools:             allowedTools(agentName, agentTools),
+		planTools:         planTools,
+		customAgents:      customAgents,
 		titleProvider:     titleProvider,
 		summarizeProvider: summarizeProvider,
 		activeRequests:    sync.Map{},
@@ -132,6 +203,75 @@ func (a *agent) Cancel(sessionID string) {
 	}
 }
 
+func (a *agent) Mode(sessionID string) Mode {
+	if mode, ok := a.modes.Load(sessionID); ok {
+		return mode.(Mode)
+	}
+	return ModeBuild
+}
+
+// SetMode switches the tools of a session, the mode can't change while a
+// request is running so a turn never mixes the tools of both modes.
+func (a *agent) SetMode(sessionID string, mode Mode) error {
+	if a.IsSessionBusy(sessionID) {
+		return ErrSessionBusy
+	}
+	switch mode {
+	case ModeBuild:
+		a.modes.Delete(sessionID)
+	case ModePlan:
+		if a.planTools == nil {
+			return errors.New("this agent has no plan mode")
+		}
+		a.modes.Store(sessionID, mode)
+	default:
+		return fmt.Errorf("unknown mode %q", mode)
+	}
+	return nil
+}
+
+// SetAgent selects the agent answering in a session, config.AgentCoder or
+// one of the user-defined agents.
+func (a *agent) SetAgent(ctx context.Context, sessionID string, agentName config.AgentName) error {
+	if a.IsSessionBusy(sessionID) {
+		return ErrSessionBusy
+	}
+	if _, ok := a.customAgent(agentName); !ok && agentName != config.AgentCoder {
+		return fmt.Errorf("unknown agent %q", agentName)
+	}
+	session, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	session.Agent = ""
+	if agentName != config.AgentCoder {
+		session.Agent = string(agentName)
+	}
+	_, err = a.sessions.Save(ctx, session)
+	return err
+}
+
+// forSession returns the provider and tools answering in a session, the ones
+// of its agent limited to the plan tools in plan mode.
+func (a *agent) forSession(session session.Session) (provider.Provider, []tools.BaseTool) {
+	agentProvider, agentTools := a.provider, a.tools
+	if custom, ok := a.customAgent(config.AgentName(session.Agent)); ok {
+		agentProvider, agentTools = custom.provider, custom.tools
+	}
+	if a.Mode(session.ID) == ModePlan {
+		agentTools = a.planTools
+	}
+	return agentProvider, agentTools
+}
+
+// allowedTools filters tools by the allow and deny lists of an agent
+func allowedTools(agentName config.AgentName, agentTools []tools.BaseTool) []tools.BaseTool {
+	agentCfg := config.Get().Agents[agentName]
+	return slices.DeleteFunc(slices.Clone(agentTools), func(tool tools.BaseTool) bool {
+		return !agentCfg.AllowsTool(tool.Info().Name)
+	})
+}
+
 func (a *agent) IsBusy() bool {
 	busy := false
 	a.activeRequests.Range(func(key, value interface{}) bool {
@@ -164,6 +304,7 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 	}
 	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
 	parts := []message.ContentPart{message.TextContent{Text: content}}
+	started := time.Now()
 	response, err := a.titleProvider.SendMessages(
 		ctx,
 		[]message.Message{
@@ -177,6 +318,13 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 	if err != nil {
 		return err
 	}
+	// The title counts toward the budgets like the other requests
+	model := a.titleProvider.Model()
+	titleUsage := a.usage(session, model, response.Usage, requestCost(model, response.Usage), time.Since(started))
+	titleUsage.Agent = string(config.AgentTitle)
+	if err := a.sessions.RecordUsage(ctx, titleUsage); err != nil {
+		logging.Warn("Failed to record usage", "sessionID", sessionID, "error", err)
+	}
 
 	title := strings.TrimSpace(strings.ReplaceAll(response.Content, "\n", " "))
 	if title == "" {
@@ -196,9 +344,6 @@ func (a *agent) err(err error) AgentEvent {
 }
 
 func (a *agent) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
-	if !a.provider.Model().SupportsAttachments && attachments != nil {
-		attachments = nil
-	}
 	events := make(chan AgentEvent)
 	if a.IsSessionBusy(sessionID) {
 		return nil, ErrSessionBusy
@@ -223,6 +368,12 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 		logging.Debug("Request completed", "sessionID", sessionID)
 		a.activeRequests.Delete(sessionID)
 		cancel()
+		// The stop hooks don't hold the result or the session
+		a.stopHooks.Add(1)
+		go func() {
+			defer a.stopHooks.Done()
+			a.runStopHooks(sessionID, result)
+		}()
 		a.Publish(pubsub.CreatedEvent, result)
 		events <- result
 		close(events)
@@ -232,11 +383,43 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 
 func (a *agent) processGeneration(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) AgentEvent {
 	cfg := config.Get()
+	session, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return a.err(fmt.Errorf("failed to get session: %w", err))
+	}
+	// Nothing is sent to the models once a limit is exceeded
+	budget, err := a.budgetStatus(ctx, session)
+	if err != nil {
+		return a.err(fmt.Errorf("failed to check budget: %w", err))
+	}
+	if budget.Exceeded() {
+		return a.err(fmt.Errorf("%w, %s", ErrBudgetExceeded, budget))
+	}
+	warned := a.publishBudget(sessionID, budget, false)
+	agentProvider, agentTools := a.forSession(session)
+	// The agent of the session is the one that receives the attachments
+	if !agentProvider.Model().SupportsAttachments {
+		attachmentParts = nil
+	}
+	ctx = context.WithValue(ctx, tools.AgentNameContextKey, a.agentName(session))
+	if err := a.runPromptHooks(ctx, session, content); err != nil {
+		return a.err(err)
+	}
+
+	if a.shouldCompact(session, agentProvider.Model()) {
+		if err := a.compact(ctx, sessionID); err != nil {
+			logging.WarnPersist(fmt.Sprintf("Failed to summarize the session: %v", err))
+		} else if session, err = a.sessions.Get(ctx, sessionID); err != nil {
+			return a.err(fmt.Errorf("failed to get session: %w", err))
+		}
+	}
+
 	// List existing messages; if none, start title generation asynchronously.
 	msgs, err := a.messages.List(ctx, sessionID)
 	if err != nil {
 		return a.err(fmt.Errorf("failed to list messages: %w", err))
 	}
+	msgs = conversation(msgs, session.SummaryMessageID)
 	if len(msgs) == 0 {
 		go func() {
 			defer logging.RecoverPanic("agent.Run", func() {
@@ -248,30 +431,25 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 			}
 		}()
 	}
-	session, err := a.sessions.Get(ctx, sessionID)
-	if err != nil {
-		return a.err(fmt.Errorf("failed to get session: %w", err))
-	}
-	if session.SummaryMessageID != "" {
-		summaryMsgInex := -1
-		for i, msg := range msgs {
-			if msg.ID == session.SummaryMessageID {
-				summaryMsgInex = i
-				break
-			}
-		}
-		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
-		}
-	}
 
 	userMsg, err := a.createUserMessage(ctx, sessionID, content, attachmentParts)
 	if err != nil {
 		return a.err(fmt.Errorf("failed to create user message: %w", err))
 	}
-	// Append the new user message to the conversation history.
+	if a.history != nil {
+		if _, err := a.history.CreateCheckpoint(ctx, sessionID, userMsg.ID); err != nil {
+			logging.Warn("Failed to create checkpoint", "sessionID", sessionID, "error", err)
+		}
+	}
+
+	// Append the new user message to the conversation history, the old tool
+	// outputs are elided like in the summaries
+	compaction := cfg.Compaction
+	msgs = elideOlderTurns(msgs, session.SummaryMessageID, compaction.KeepTurns, compaction.MaxToolOutput)
 	msgHistory := append(msgs, userMsg)
+	if a.Mode(sessionID) == ModePlan {
+		msgHistory[len(msgHistory)-1] = withPlanInstructions(userMsg)
+	}
 
 	for {
 		// Check for cancellation before each iteration
@@ -281,7 +459,7 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		default:
 			// Continue processing
 		}
-		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
+		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, agentProvider, agentTools, msgHistory)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				agentMessage.AddFinish(message.FinishReasonCanceled)
@@ -297,11 +475,41 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		} else {
 			logging.Info("Result", "message", agentMessage.FinishReason(), "toolResults", toolResults)
 		}
+		if toolResults != nil {
+			if plan := submittedPlan(agentMessage.ToolCalls(), toolResults.ToolResults()); plan != nil {
+				return AgentEvent{
+					Type:    AgentEventTypeResponse,
+					Message: agentMessage,
+					Plan:    plan,
+					Done:    true,
+				}
+			}
+		}
 		if (agentMessage.FinishReason() == message.FinishReasonToolUse) && toolResults != nil {
+			budget, err := a.budgetStatus(ctx, session)
+			if err != nil {
+				return a.err(fmt.Errorf("failed to check budget: %w", err))
+			}
+			warned = a.publishBudget(sessionID, budget, warned)
+			if budget.Exceeded() {
+				// Stop before sending the tool results, the next prompt
+				// continues from them once the limit is raised
+				logging.Warn("Budget exceeded", "sessionID", sessionID, "budget", budget.String())
+				a.finishMessage(context.Background(), &agentMessage, message.FinishReasonBudgetExceeded)
+				return AgentEvent{
+					Type:    AgentEventTypeResponse,
+					Message: agentMessage,
+					Budget:  &budget,
+					Done:    true,
+				}
+			}
 			// We are not done, we need to respond with the tool response
 			msgHistory = append(msgHistory, agentMessage, *toolResults)
 			continue
 		}
+		if budget, err := a.budgetStatus(ctx, session); err == nil {
+			a.publishBudget(sessionID, budget, warned)
+		}
 		return AgentEvent{
 			Type:    AgentEventTypeResponse,
 			Message: agentMessage,
@@ -310,6 +518,101 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 }
 
+// runPromptHooks runs the sessionStart hooks the first time a session runs,
+// then the userPromptSubmit hooks. The sessions of the task agents don't run
+// them, their prompts come from the model.
+func (a *agent) runPromptHooks(ctx context.Context, session session.Session, content string) error {
+	if session.ParentSessionID != "" {
+		return nil
+	}
+	agentName := a.agentName(session)
+	if _, started := a.startedSessions.LoadOrStore(session.ID, agentName); !started {
+		hooks.Run(ctx, hooks.Input{
+			Event:     config.HookSessionStart,
+			SessionID: session.ID,
+			Agent:     agentName,
+		})
+	}
+	result := hooks.Run(ctx, hooks.Input{
+		Event:     config.HookUserPromptSubmit,
+		SessionID: session.ID,
+		Agent:     agentName,
+		Prompt:    content,
+	})
+	if result.Blocked {
+		if result.Reason != "" {
+			return fmt.Errorf("%w: %s", ErrPromptBlocked, result.Reason)
+		}
+		return ErrPromptBlocked
+	}
+	return nil
+}
+
+// runStopHooks runs the stop hooks once the agent finished responding to a
+// prompt of a session started by the user
+func (a *agent) runStopHooks(sessionID string, result AgentEvent) {
+	agentName, started := a.startedSessions.Load(sessionID)
+	if !started {
+		return
+	}
+	input := hooks.Input{
+		Event:     config.HookStop,
+		SessionID: sessionID,
+		Agent:     agentName.(string),
+	}
+	if result.Error != nil {
+		input.Error = result.Error.Error()
+	} else {
+		input.FinishReason = string(result.Message.FinishReason())
+		input.Response = result.Message.Content().String()
+	}
+	hooks.Run(context.Background(), input)
+}
+
+func (a *agent) EndSessions(ctx context.Context) {
+	// The sessions end after their last stop hooks
+	stopped := make(chan struct{})
+	go func() {
+		a.stopHooks.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+	}
+	a.startedSessions.Range(func(key, value any) bool {
+		hooks.Run(ctx, hooks.Input{
+			Event:     config.HookSessionEnd,
+			SessionID: key.(string),
+			Agent:     value.(string),
+		})
+		a.startedSessions.Delete(key)
+		return true
+	})
+}
+
+// withoutReverted drops the turns that were rolled back, they are not part of
+// the conversation anymore.
+func withoutReverted(msgs []message.Message) []message.Message {
+	return slices.DeleteFunc(msgs, func(msg message.Message) bool {
+		return msg.RevertedAt != 0
+	})
+}
+
+// withPlanInstructions adds the plan mode instructions to the prompt sent to
+// the provider, they are not stored with the message.
+func withPlanInstructions(msg message.Message) message.Message {
+	parts := slices.Clone(msg.Parts)
+	for i, part := range parts {
+		if text, ok := part.(message.TextContent); ok {
+			parts[i] = message.TextContent{Text: text.Text + "\n\n" + prompt.PlanModeInstructions()}
+			break
+		}
+	}
+	msg.Parts = parts
+	return msg
+}
+
 func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
 	parts := []message.ContentPart{message.TextContent{Text: content}}
 	parts = append(parts, attachmentParts...)
@@ -319,14 +622,15 @@ func (a *agent) createUserMessage(ctx context.Context, sessionID, content string
 	})
 }
 
-func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msgHistory []message.Message) (message.Message, *message.Message, error) {
+func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, agentProvider provider.Provider, agentTools []tools.BaseTool, msgHistory []message.Message) (message.Message, *message.Message, error) {
 	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
-	eventChan := a.provider.StreamResponse(ctx, msgHistory, a.tools)
+	started := time.Now()
+	eventChan := agentProvider.StreamResponse(ctx, msgHistory, agentTools)
 
 	assistantMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
 		Role:  message.Assistant,
 		Parts: []message.ContentPart{},
-		Model: a.provider.Model().ID,
+		Model: agentProvider.Model().ID,
 	})
 	if err != nil {
 		return assistantMsg, nil, fmt.Errorf("failed to create assistant message: %w", err)
@@ -337,7 +641,7 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 
 	// Process each event in the stream.
 	for event := range eventChan {
-		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event); processErr != nil {
+		if processErr := a.processEvent(ctx, sessionID, agentProvider.Model(), started, &assistantMsg, event); processErr != nil {
 			a.finishMessage(ctx, &assistantMsg, message.FinishReasonCanceled)
 			return assistantMsg, nil, processErr
 		}
@@ -347,78 +651,7 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 		}
 	}
 
-	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
-	toolCalls := assistantMsg.ToolCalls()
-	for i, toolCall := range toolCalls {
-		select {
-		case <-ctx.Done():
-			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled)
-			// Make all future tool calls cancelled
-			for j := i; j < len(toolCalls); j++ {
-				toolResults[j] = message.ToolResult{
-					ToolCallID: toolCalls[j].ID,
-					Content:    "Tool execution canceled by user",
-					IsError:    true,
-				}
-			}
-			goto out
-		default:
-			// Continue processing
-			var tool tools.BaseTool
-			for _, availableTool := range a.tools {
-				if availableTool.Info().Name == toolCall.Name {
-					tool = availableTool
-					break
-				}
-				// Monkey patch for Copilot Sonnet-4 tool repetition obfuscation
-				// if strings.HasPrefix(toolCall.Name, availableTool.Info().Name) &&
-				// 	strings.HasPrefix(toolCall.Name, availableTool.Info().Name+availableTool.Info().Name) {
-				// 	tool = availableTool
-				// 	break
-				// }
-			}
-
-			// Tool not found
-			if tool == nil {
-				toolResults[i] = message.ToolResult{
-					ToolCallID: toolCall.ID,
-					Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
-					IsError:    true,
-				}
-				continue
-			}
-			toolResult, toolErr := tool.Run(ctx, tools.ToolCall{
-				ID:    toolCall.ID,
-				Name:  toolCall.Name,
-				Input: toolCall.Input,
-			})
-			if toolErr != nil {
-				if errors.Is(toolErr, permission.ErrorPermissionDenied) {
-					toolResults[i] = message.ToolResult{
-						ToolCallID: toolCall.ID,
-						Content:    "Permission denied",
-						IsError:    true,
-					}
-					for j := i + 1; j < len(toolCalls); j++ {
-						toolResults[j] = message.ToolResult{
-							ToolCallID: toolCalls[j].ID,
-							Content:    "Tool execution canceled by user",
-							IsError:    true,
-						}
-					}
-					a.finishMessage(ctx, &assistantMsg, message.FinishReasonPermissionDenied)
-					break
-				}
-			}
-			toolResults[i] = message.ToolResult{
-				ToolCallID: toolCall.ID,
-				Content:    toolResult.Content,
-				Metadata:   toolResult.Metadata,
-				IsError:    toolResult.IsError,
-			}
-		}
-	}
-out:
+	toolResults := a.runToolCalls(ctx, agentTools, &assistantMsg, assistantMsg.ToolCalls())
 	if len(toolResults) == 0 {
 		return assistantMsg, nil, nil
 	}
@@ -442,7 +675,8 @@ func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishR
 	_ = a.messages.Update(ctx, *msg)
 }
 
-func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, event provider.ProviderEvent) error {
+// processEvent handles an event of the response to a request sent at started
+func (a *agent) processEvent(ctx context.Context, sessionID string, model models.Model, started time.Time, assistantMsg *message.Message, event provider.ProviderEvent) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -480,27 +714,29 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		logging.ErrorPersist(event.Error.Error())
 		return event.Error
 	case provider.EventComplete:
+		// A fallback model answered, it is the one that is billed
+		if answered, ok := models.SupportedModels[event.Response.Model]; ok {
+			assistantMsg.Model = answered.ID
+			model = answered
+		}
 		assistantMsg.SetToolCalls(event.Response.ToolCalls)
 		assistantMsg.AddFinish(event.Response.FinishReason)
 		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.TrackUsage(ctx, sessionID, a.provider.Model(), event.Response.Usage)
+		return a.TrackUsage(ctx, sessionID, model, event.Response.Usage, time.Since(started))
 	}
 
 	return nil
 }
 
-func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.Model, usage provider.TokenUsage) error {
+func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.Model, usage provider.TokenUsage, latency time.Duration) error {
 	sess, err := a.sessions.Get(ctx, sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to get session: %w", err)
 	}
 
-	cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+	cost := requestCost(model, usage)
 
 	sess.Cost += cost
 	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
@@ -510,9 +746,45 @@ func (a *agent) TrackUsage(ctx context.Context, sessionID string, model models.M
 	if err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
+	if err := a.sessions.RecordUsage(ctx, a.usage(sess, model, usage, cost, latency)); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
 	return nil
 }
 
+// agentName is the agent answering in a session, the custom agent of the
+// session if it has one
+func (a *agent) agentName(sess session.Session) string {
+	if _, ok := a.customAgent(config.AgentName(sess.Agent)); ok {
+		return sess.Agent
+	}
+	return string(a.name)
+}
+
+// customAgent returns a user-defined agent by its name
+func (a *agent) customAgent(name config.AgentName) (customAgent, bool) {
+	a.customAgentsMu.RLock()
+	defer a.customAgentsMu.RUnlock()
+	custom, ok := a.customAgents[name]
+	return custom, ok
+}
+
+// usage is the ledger entry of a request made in a session
+func (a *agent) usage(sess session.Session, model models.Model, usage provider.TokenUsage, cost float64, latency time.Duration) session.Usage {
+	return session.Usage{
+		SessionID:        sess.ID,
+		Agent:            a.agentName(sess),
+		Model:            string(model.ID),
+		Provider:         string(model.Provider),
+		InputTokens:      usage.InputTokens,
+		OutputTokens:     usage.OutputTokens,
+		CacheReadTokens:  usage.CacheReadTokens,
+		CacheWriteTokens: usage.CacheCreationTokens,
+		Cost:             cost,
+		LatencyMs:        latency.Milliseconds(),
+	}
+}
+
 func (a *agent) Update(agentName config.AgentName, modelID models.ModelID) (models.Model, error) {
 	if a.IsBusy() {
 		return models.Model{}, fmt.Errorf("cannot change model while processing requests")
@@ -527,6 +799,16 @@ func (a *agent) Update(agentName config.AgentName, modelID models.ModelID) (mode
 		return models.Model{}, fmt.Errorf("failed to create provider for model %s: %w", modelID, err)
 	}
 
+	a.customAgentsMu.Lock()
+	custom, ok := a.customAgents[agentName]
+	if ok {
+		custom.provider = provider
+		a.customAgents[agentName] = custom
+	}
+	a.customAgentsMu.Unlock()
+	if ok {
+		return provider.Model(), nil
+	}
 	a.provider = provider
 
 	return a.provider.Model(), nil
@@ -551,153 +833,14 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 	go func() {
 		defer a.activeRequests.Delete(sessionID + "-summarize")
 		defer cancel()
-		event := AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Starting summarization...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-		// Get all messages from the session
-		msgs, err := a.messages.List(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to list messages: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		summarizeCtx = context.WithValue(summarizeCtx, tools.SessionIDContextKey, sessionID)
-
-		if len(msgs) == 0 {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("no messages to summarize"),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Analyzing conversation...",
-		}
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Add a system message to guide the summarization
-		summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
-
-		// Create a new message with the summarize prompt
-		promptMsg := message.Message{
-			Role:  message.User,
-			Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
-		}
-
-		// Append the prompt to the messages
-		msgsWithPrompt := append(msgs, promptMsg)
-
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Generating summary...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Send the messages to the summarize provider
-		response, err := a.summarizeProvider.SendMessages(
-			summarizeCtx,
-			msgsWithPrompt,
-			make([]tools.BaseTool, 0),
-		)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to summarize: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-
-		summary := strings.TrimSpace(response.Content)
-		if summary == "" {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("empty summary returned"),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Creating new session...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-		oldSession, err := a.sessions.Get(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to get session: %w", err),
-				Done:  true,
-			}
-
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		// Create a message in the new session with the summary
-		msg, err := a.messages.Create(summarizeCtx, oldSession.ID, message.CreateMessageParams{
-			Role: message.Assistant,
-			Parts: []message.ContentPart{
-				message.TextContent{Text: summary},
-				message.Finish{
-					Reason: message.FinishReasonEndTurn,
-					Time:   time.Now().Unix(),
-				},
-			},
-			Model: a.summarizeProvider.Model().ID,
-		})
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to create summary message: %w", err),
-				Done:  true,
-			}
-
-			a.Publish(pubsub.CreatedEvent, event)
-			return
-		}
-		oldSession.SummaryMessageID = msg.ID
-		oldSession.CompletionTokens = response.Usage.OutputTokens
-		oldSession.PromptTokens = 0
-		model := a.summarizeProvider.Model()
-		usage := response.Usage
-		cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-			model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-		oldSession.Cost += cost
-		_, err = a.sessions.Save(summarizeCtx, oldSession)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to save session: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-		}
-
-		event = AgentEvent{
-			Type:      AgentEventTypeSummarize,
-			SessionID: oldSession.ID,
-			Progress:  "Summary complete",
-			Done:      true,
+		if err := a.compact(summarizeCtx, sessionID); err != nil {
+			a.Publish(pubsub.CreatedEvent, AgentEvent{
+				Type:      AgentEventTypeError,
+				SessionID: sessionID,
+				Error:     err,
+				Done:      true,
+			})
 		}
-		a.Publish(pubsub.CreatedEvent, event)
-		// Send final success event with the new session ID
 	}()
 
 	return nil
@@ -709,9 +852,52 @@ func createAgentProvider(agentName config.AgentName) (provider.Provider, error)
 	if !ok {
 		return nil, fmt.Errorf("agent %s not found", agentName)
 	}
-	model, ok := models.SupportedModels[agentConfig.Model]
+	agentProvider, err := createModelProvider(agentName, agentConfig, agentConfig.Model, agentConfig.MaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	if len(agentConfig.Fallbacks) == 0 {
+		return agentProvider, nil
+	}
+
+	// The max tokens of the agent are meant for its own model, fallbacks
+	// use their defaults
+	providers := []provider.Provider{agentProvider}
+	for _, modelID := range agentConfig.Fallbacks {
+		fallbackProvider, err := createModelProvider(agentName, agentConfig, modelID, 0)
+		if err != nil {
+			logging.Warn("Skipping fallback model", "agent", agentName, "model", modelID, "error", err)
+			continue
+		}
+		providers = append(providers, fallbackProvider)
+	}
+	return provider.NewFallbackProvider(providers...), nil
+}
+
+func createModelProvider(agentName config.AgentName, agentConfig config.Agent, modelID models.ModelID, maxTokens int64) (provider.Provider, error) {
+	cfg := config.Get()
+	model, ok := models.SupportedModels[modelID]
 	if !ok {
-		return nil, fmt.Errorf("model %s not supported", agentConfig.Model)
+		return nil, fmt.Errorf("model %s not supported", modelID)
+	}
+
+	if maxTokens <= 0 {
+		maxTokens = model.DefaultMaxTokens
+	}
+	systemMessage := prompt.GetAgentPrompt(agentName, model.Provider)
+	if cfg.Cassette.Mode == config.CassetteReplay {
+		cassette, err := provider.ReplayCassette(cfg.Cassette.Path)
+		if err != nil {
+			return nil, err
+		}
+		// The mock provider stands in for the model that was recorded
+		return provider.NewProvider(
+			models.ProviderMock,
+			provider.WithModel(model),
+			provider.WithSystemMessage(systemMessage),
+			provider.WithMaxTokens(maxTokens),
+			provider.WithCassette(cassette),
+		)
 	}
 
 	providerCfg, ok := cfg.Providers[model.Provider]
@@ -721,14 +907,10 @@ func createAgentProvider(agentName config.AgentName) (provider.Provider, error)
 	if providerCfg.Disabled {
 		return nil, fmt.Errorf("provider %s is not enabled", model.Provider)
 	}
-	maxTokens := model.DefaultMaxTokens
-	if agentConfig.MaxTokens > 0 {
-		maxTokens = agentConfig.MaxTokens
-	}
 	opts := []provider.ProviderClientOption{
 		provider.WithAPIKey(providerCfg.APIKey),
 		provider.WithModel(model),
-		provider.WithSystemMessage(prompt.GetAgentPrompt(agentName, model.Provider)),
+		provider.WithSystemMessage(systemMessage),
 		provider.WithMaxTokens(maxTokens),
 	}
 	if model.Provider == models.ProviderOpenAI || model.Provider == models.ProviderLocal && model.CanReason {
@@ -738,7 +920,7 @@ func createAgentProvider(agentName config.AgentName) (provider.Provider, error)
 				provider.WithReasoningEffort(agentConfig.ReasoningEffort),
 			),
 		)
-	} else if model.Provider == models.ProviderAnthropic && model.CanReason && agentName == config.AgentCoder {
+	} else if model.Provider == models.ProviderAnthropic && model.CanReason && (agentName == config.AgentCoder || !config.IsBuiltinAgent(agentName)) {
 		opts = append(
 			opts,
 			provider.WithAnthropicOptions(
@@ -753,6 +935,13 @@ func createAgentProvider(agentName config.AgentName) (provider.Provider, error)
 	if err != nil {
 		return nil, fmt.Errorf("could not create provider: %v", err)
 	}
+	if cfg.Cassette.Mode == config.CassetteRecord {
+		cassette, err := provider.RecordCassette(cfg.Cassette.Path)
+		if err != nil {
+			return nil, err
+		}
+		agentProvider = provider.NewRecordingProvider(agentProvider, cassette, systemMessage)
+	}
 
 	return agentProvider, nil
 }
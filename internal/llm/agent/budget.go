@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// BudgetScope is what a spending limit applies to
+type BudgetScope string
+
+const (
+	BudgetScopeSession  BudgetScope = "session"
+	BudgetScopeDaily    BudgetScope = "daily"
+	BudgetScopeSubAgent BudgetScope = "sub-agent"
+)
+
+// BudgetStatus is the spending of a scope against its limit. The zero value
+// means that no limit applies.
+type BudgetStatus struct {
+	Scope BudgetScope      `json:"scope"`
+	Spent session.Spending `json:"spent"`
+	Limit config.Budget    `json:"limit"`
+}
+
+// Used returns the fraction of the limit that was spent, the highest of the
+// cost and token fractions.
+func (b BudgetStatus) Used() float64 {
+	used := 0.0
+	if b.Limit.Cost > 0 {
+		used = max(used, b.Spent.Cost/b.Limit.Cost)
+	}
+	if b.Limit.Tokens > 0 {
+		used = max(used, float64(b.Spent.Tokens)/float64(b.Limit.Tokens))
+	}
+	return used
+}
+
+// Exceeded reports whether the limit is reached
+func (b BudgetStatus) Exceeded() bool {
+	return b.Used() >= 1
+}
+
+// Warning reports whether the spending is close enough to the limit to warn
+func (b BudgetStatus) Warning() bool {
+	return !b.Limit.IsZero() && b.Used() >= config.Get().Budgets.WarnAt
+}
+
+func (b BudgetStatus) String() string {
+	var parts []string
+	if b.Limit.Cost > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f of $%.2f", b.Spent.Cost, b.Limit.Cost))
+	}
+	if b.Limit.Tokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d tokens", b.Spent.Tokens, b.Limit.Tokens))
+	}
+	return fmt.Sprintf("%s budget: %s spent", b.Scope, strings.Join(parts, ", "))
+}
+
+// budgetStatus returns the status of the limit of a session that is the
+// closest to be exhausted. Sub-agents are bound by their own limit and by the
+// ones of the session that started them.
+func (a *agent) budgetStatus(ctx context.Context, sess session.Session) (BudgetStatus, error) {
+	budgets := config.Get().Budgets
+	var statuses []BudgetStatus
+	rootID := sess.ID
+	if sess.ParentSessionID != "" {
+		rootID = sess.ParentSessionID
+		if !budgets.SubAgent.IsZero() {
+			spent, err := a.sessions.Spending(ctx, sess.ID)
+			if err != nil {
+				return BudgetStatus{}, err
+			}
+			statuses = append(statuses, BudgetStatus{Scope: BudgetScopeSubAgent, Spent: spent, Limit: budgets.SubAgent})
+		}
+	}
+	if !budgets.Session.IsZero() {
+		spent, err := a.sessions.Spending(ctx, rootID)
+		if err != nil {
+			return BudgetStatus{}, err
+		}
+		statuses = append(statuses, BudgetStatus{Scope: BudgetScopeSession, Spent: spent, Limit: budgets.Session})
+	}
+	if !budgets.Daily.IsZero() {
+		spent, err := a.sessions.DailySpending(ctx, time.Now())
+		if err != nil {
+			return BudgetStatus{}, err
+		}
+		statuses = append(statuses, BudgetStatus{Scope: BudgetScopeDaily, Spent: spent, Limit: budgets.Daily})
+	}
+
+	var closest BudgetStatus
+	for _, status := range statuses {
+		if closest.Limit.IsZero() || status.Used() > closest.Used() {
+			closest = status
+		}
+	}
+	return closest, nil
+}
+
+// publishBudget sends the budget of a session to the subscribers, as a
+// warning the first time a run gets close to the limit. It returns whether
+// the run was warned.
+func (a *agent) publishBudget(sessionID string, status BudgetStatus, warned bool) bool {
+	if status.Limit.IsZero() {
+		return warned
+	}
+	eventType := AgentEventTypeBudget
+	if !warned && status.Warning() {
+		logging.Warn("Approaching budget limit", "sessionID", sessionID, "budget", status.String())
+		eventType = AgentEventTypeBudgetWarning
+		warned = true
+	}
+	a.Publish(pubsub.CreatedEvent, AgentEvent{
+		Type:      eventType,
+		SessionID: sessionID,
+		Budget:    &status,
+	})
+	return warned
+}
+
+// requestCost is what a request to a model cost, in dollars
+func requestCost(model models.Model, usage provider.TokenUsage) float64 {
+	return model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
+		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
+		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
+		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+}
@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBudgetAgent returns an agent answering with the responses, under the
+// budgets
+func newBudgetAgent(t *testing.T, budgets config.BudgetsConfig, responses ...[]provider.ProviderEvent) (*agent, session.Service) {
+	t.Helper()
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg := config.Get()
+	cfg.Data.Directory = dir
+	cfg.WorkingDir = dir
+	cfg.Budgets = budgets
+	t.Cleanup(func() { cfg.Budgets = config.BudgetsConfig{} })
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := session.NewService(q, conn)
+	a := &agent{
+		Broker:         pubsub.NewBroker[AgentEvent](),
+		provider:       &scriptedProvider{model: models.SupportedModels[models.Claude4Sonnet], responses: responses},
+		sessions:       sessions,
+		messages:       message.NewService(q),
+		tools:          []tools.BaseTool{echoTool{}},
+		activeRequests: sync.Map{},
+	}
+	// The stop hooks read the configuration after the result, the next test
+	// changes it
+	t.Cleanup(a.stopHooks.Wait)
+	return a, sessions
+}
+
+// toolStep is a response calling the echo tool, using tokens of the prompt
+func toolStep(id string, inputTokens int64) []provider.ProviderEvent {
+	call := message.ToolCall{ID: id, Name: "echo", Input: `{}`, Type: "tool_use", Finished: true}
+	return []provider.ProviderEvent{
+		{Type: provider.EventToolUseStart, ToolCall: &message.ToolCall{ID: call.ID, Name: call.Name}},
+		{Type: provider.EventComplete, Response: &provider.ProviderResponse{
+			ToolCalls:    []message.ToolCall{call},
+			Usage:        provider.TokenUsage{InputTokens: inputTokens},
+			FinishReason: message.FinishReasonToolUse,
+		}},
+	}
+}
+
+func TestBudgetStatus(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	config.Get().Budgets.WarnAt = 0.8
+
+	tests := []struct {
+		name     string
+		status   BudgetStatus
+		used     float64
+		warning  bool
+		exceeded bool
+	}{
+		{"no limit", BudgetStatus{Spent: session.Spending{Cost: 100}}, 0, false, false},
+		{"under", BudgetStatus{Spent: session.Spending{Cost: 0.5}, Limit: config.Budget{Cost: 1}}, 0.5, false, false},
+		{"warn", BudgetStatus{Spent: session.Spending{Cost: 0.8}, Limit: config.Budget{Cost: 1}}, 0.8, true, false},
+		{"exceeded", BudgetStatus{Spent: session.Spending{Cost: 1}, Limit: config.Budget{Cost: 1}}, 1, true, true},
+		{"tokens closer than cost", BudgetStatus{
+			Spent: session.Spending{Cost: 0.1, Tokens: 900},
+			Limit: config.Budget{Cost: 1, Tokens: 1000},
+		}, 0.9, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.used, tt.status.Used(), 1e-9)
+			assert.Equal(t, tt.warning, tt.status.Warning())
+			assert.Equal(t, tt.exceeded, tt.status.Exceeded())
+		})
+	}
+}
+
+func TestSessionBudget(t *testing.T) {
+	// Claude 4 Sonnet costs $3 per million input tokens: the first step
+	// spends $0.9 and the second one $0.3
+	a, sessions := newBudgetAgent(t, config.BudgetsConfig{Session: config.Budget{Cost: 1}, WarnAt: 0.8},
+		toolStep("call-1", 300_000),
+		toolStep("call-2", 100_000),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	agentEvents := a.Subscribe(ctx)
+
+	sess, err := sessions.Create(context.Background(), "test")
+	require.NoError(t, err)
+	events, err := a.Run(context.Background(), sess.ID, "loop")
+	require.NoError(t, err)
+	result := <-events
+	require.NoError(t, result.Error)
+
+	// The run stops after the step exceeding the limit
+	assert.Equal(t, message.FinishReasonBudgetExceeded, result.Message.FinishReason())
+	require.NotNil(t, result.Budget)
+	assert.Equal(t, BudgetScopeSession, result.Budget.Scope)
+	assert.InDelta(t, 1.2, result.Budget.Spent.Cost, 1e-9)
+
+	// The warning is sent once, before the limit is exceeded
+	warnings := 0
+	for {
+		event := <-agentEvents
+		if event.Payload.Type == AgentEventTypeBudgetWarning {
+			warnings++
+		}
+		if event.Payload.Done {
+			break
+		}
+	}
+	assert.Equal(t, 1, warnings)
+
+	// New prompts are refused until the limit is raised
+	events, err = a.Run(context.Background(), sess.ID, "again")
+	require.NoError(t, err)
+	result = <-events
+	assert.ErrorIs(t, result.Error, ErrBudgetExceeded)
+}
+
+func TestDailyBudget(t *testing.T) {
+	a, sessions := newBudgetAgent(t, config.BudgetsConfig{Daily: config.Budget{Cost: 1}, WarnAt: 0.8})
+	ctx := context.Background()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	spend := func(sessionID string, at time.Time, cost float64) {
+		require.NoError(t, sessions.RecordUsage(ctx, session.Usage{
+			SessionID: sessionID,
+			Agent:     "coder",
+			Model:     "claude-4-sonnet",
+			Cost:      cost,
+			CreatedAt: at.Unix(),
+		}))
+	}
+	first, err := sessions.Create(ctx, "first")
+	require.NoError(t, err)
+	second, err := sessions.Create(ctx, "second")
+	require.NoError(t, err)
+
+	// What was spent yesterday doesn't count
+	spend(first.ID, today.Add(-time.Hour), 5)
+	status, err := a.budgetStatus(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, BudgetScopeDaily, status.Scope)
+	assert.False(t, status.Exceeded())
+	assert.Zero(t, status.Spent.Cost)
+
+	// The sessions of the day share the limit, even deleted ones
+	spend(first.ID, today.Add(time.Minute), 0.6)
+	require.NoError(t, sessions.Delete(ctx, first.ID))
+	status, err = a.budgetStatus(ctx, second)
+	require.NoError(t, err)
+	assert.False(t, status.Exceeded())
+	spend(second.ID, now, 0.4)
+	status, err = a.budgetStatus(ctx, second)
+	require.NoError(t, err)
+	assert.True(t, status.Exceeded())
+	assert.InDelta(t, 1, status.Spent.Cost, 1e-9)
+}
+
+func TestSubAgentBudget(t *testing.T) {
+	a, sessions := newBudgetAgent(t, config.BudgetsConfig{
+		Session:  config.Budget{Tokens: 1000},
+		SubAgent: config.Budget{Tokens: 100},
+		WarnAt:   0.8,
+	})
+	ctx := context.Background()
+
+	parent, err := sessions.Create(ctx, "parent")
+	require.NoError(t, err)
+	task, err := sessions.CreateTaskSession(ctx, "call-1", parent.ID, "task")
+	require.NoError(t, err)
+	require.NoError(t, sessions.RecordUsage(ctx, session.Usage{SessionID: task.ID, InputTokens: 150}))
+
+	// The sub-agent is stopped by its own limit, its parent counts its tokens
+	status, err := a.budgetStatus(ctx, task)
+	require.NoError(t, err)
+	assert.Equal(t, BudgetScopeSubAgent, status.Scope)
+	assert.True(t, status.Exceeded())
+	status, err = a.budgetStatus(ctx, parent)
+	require.NoError(t, err)
+	assert.Equal(t, BudgetScopeSession, status.Scope)
+	assert.Equal(t, int64(150), status.Spent.Tokens)
+	assert.False(t, status.Exceeded())
+}
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/prompt"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider streams its responses in order, one per request
+type scriptedProvider struct {
+	model     models.Model
+	responses [][]provider.ProviderEvent
+	calls     int
+}
+
+func (s *scriptedProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*provider.ProviderResponse, error) {
+	panic("not used")
+}
+
+func (s *scriptedProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan provider.ProviderEvent {
+	events := make(chan provider.ProviderEvent, len(s.responses[s.calls]))
+	for _, event := range s.responses[s.calls] {
+		events <- event
+	}
+	s.calls++
+	close(events)
+	return events
+}
+
+func (s *scriptedProvider) Model() models.Model {
+	return s.model
+}
+
+type echoTool struct{}
+
+func (echoTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{Name: "echo"}
+}
+
+func (echoTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.NewTextResponse("echo: " + call.Input), nil
+}
+
+func runPrompt(t *testing.T, a Service, sessions session.Service, messages message.Service, prompt string) []message.Message {
+	sess, err := sessions.Create(context.Background(), "test")
+	require.NoError(t, err)
+	events, err := a.Run(context.Background(), sess.ID, prompt)
+	require.NoError(t, err)
+	result := <-events
+	require.NoError(t, result.Error)
+	assert.Equal(t, "done", result.Message.Content().String())
+	// The stop hooks read the configuration after the result, wait for them
+	// before the test changes it
+	a.EndSessions(t.Context())
+
+	msgs, err := messages.List(context.Background(), sess.ID)
+	require.NoError(t, err)
+	return msgs
+}
+
+func TestRunFromCassette(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg := config.Get()
+	cfg.Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := session.NewService(q, conn)
+	messages := message.NewService(q)
+
+	model := models.SupportedModels[models.Claude4Sonnet]
+	cfg.Agents[config.AgentTask] = config.Agent{Model: model.ID, MaxTokens: model.DefaultMaxTokens}
+	call := message.ToolCall{ID: "call-1", Name: "echo", Input: `{"text":"hi"}`, Type: "tool_use", Finished: true}
+	scripted := &scriptedProvider{model: model, responses: [][]provider.ProviderEvent{
+		{
+			{Type: provider.EventToolUseStart, ToolCall: &message.ToolCall{ID: call.ID, Name: call.Name}},
+			{Type: provider.EventComplete, Response: &provider.ProviderResponse{
+				ToolCalls:    []message.ToolCall{call},
+				Usage:        provider.TokenUsage{InputTokens: 10, OutputTokens: 5},
+				FinishReason: message.FinishReasonToolUse,
+			}},
+		},
+		{
+			{Type: provider.EventContentDelta, Content: "done"},
+			{Type: provider.EventComplete, Response: &provider.ProviderResponse{
+				Content:      "done",
+				Usage:        provider.TokenUsage{InputTokens: 20, OutputTokens: 1},
+				FinishReason: message.FinishReasonEndTurn,
+			}},
+		},
+	}}
+
+	path := filepath.Join(dir, "cassette.json")
+	cassette, err := provider.RecordCassette(path)
+	require.NoError(t, err)
+	recording := &agent{
+		Broker:         pubsub.NewBroker[AgentEvent](),
+		provider:       provider.NewRecordingProvider(scripted, cassette, prompt.GetAgentPrompt(config.AgentTask, model.Provider)),
+		sessions:       sessions,
+		messages:       messages,
+		tools:          []tools.BaseTool{echoTool{}},
+		activeRequests: sync.Map{},
+	}
+	recorded := runPrompt(t, recording, sessions, messages, "echo hi")
+	assert.Equal(t, 2, scripted.calls)
+
+	// The replay goes through the configuration like a real run
+	cfg.Cassette = config.CassetteConfig{Path: path, Mode: config.CassetteReplay}
+	t.Cleanup(func() { cfg.Cassette = config.CassetteConfig{} })
+	replaying, err := NewAgent(config.AgentTask, sessions, messages, nil, []tools.BaseTool{echoTool{}}, nil)
+	require.NoError(t, err)
+	replayed := runPrompt(t, replaying, sessions, messages, "echo hi")
+
+	require.Len(t, replayed, len(recorded))
+	for i := range recorded {
+		assert.Equal(t, recorded[i].Role, replayed[i].Role)
+		assert.Equal(t, recorded[i].ToolCalls(), replayed[i].ToolCalls())
+		assert.Equal(t, recorded[i].ToolResults(), replayed[i].ToolResults())
+		assert.Equal(t, recorded[i].Content(), replayed[i].Content())
+	}
+	assert.Equal(t, "echo: "+call.Input, replayed[2].ToolResults()[0].Content)
+	assert.Equal(t, 2, scripted.calls, "the replay doesn't call the model")
+
+	sess, err := sessions.Get(context.Background(), replayed[0].SessionID)
+	require.NoError(t, err)
+	assert.Positive(t, sess.Cost)
+}
@@ -0,0 +1,267 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+const summarizePrompt = "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
+
+// conversation returns the messages of a session as they are sent to the
+// model. Once the session was summarized it starts with the summary, followed
+// by the messages the summary kept verbatim and the ones that came after it.
+func conversation(msgs []message.Message, summaryMessageID string) []message.Message {
+	idx := -1
+	if summaryMessageID != "" {
+		idx = slices.IndexFunc(msgs, func(msg message.Message) bool {
+			return msg.ID == summaryMessageID
+		})
+	}
+	if idx == -1 || msgs[idx].RevertedAt != 0 {
+		return withoutReverted(slices.Clone(msgs))
+	}
+
+	summary := msgs[idx]
+	summary.Role = message.User
+	kept := 0
+	if part := summary.SummaryPart(); part != nil {
+		kept = min(part.Kept, idx)
+	}
+	rest := append(slices.Clone(msgs[idx-kept:idx]), msgs[idx+1:]...)
+	// Older summaries are part of this one
+	rest = slices.DeleteFunc(rest, func(msg message.Message) bool {
+		return msg.SummaryPart() != nil
+	})
+	return append([]message.Message{summary}, withoutReverted(rest)...)
+}
+
+// splitTurns splits a conversation before its last keepTurns turns, a turn
+// starting with a prompt of the user. The first turn is never kept, so there
+// is always something to summarize.
+func splitTurns(msgs []message.Message, summaryMessageID string, keepTurns int) (older, recent []message.Message) {
+	var starts []int
+	for i, msg := range msgs {
+		if msg.Role == message.User && msg.ID != summaryMessageID {
+			starts = append(starts, i)
+		}
+	}
+	keep := min(keepTurns, len(starts)-1)
+	if keep <= 0 {
+		return msgs, nil
+	}
+	cut := starts[len(starts)-keep]
+	return msgs[:cut], msgs[cut:]
+}
+
+// elideToolResults truncates the tool outputs longer than maxChars, they are
+// the bulk of old turns and rarely matter once the work moved on.
+func elideToolResults(msgs []message.Message, maxChars int) []message.Message {
+	elided := make([]message.Message, len(msgs))
+	for i, msg := range msgs {
+		if msg.Role == message.Tool && maxChars > 0 {
+			parts := slices.Clone(msg.Parts)
+			for j, part := range parts {
+				result, ok := part.(message.ToolResult)
+				if !ok || len(result.Content) <= maxChars {
+					continue
+				}
+				result.Content = fmt.Sprintf(
+					"%s\n[%d characters elided]",
+					strings.ToValidUTF8(result.Content[:maxChars], ""),
+					len(result.Content)-maxChars,
+				)
+				parts[j] = result
+			}
+			msg.Parts = parts
+		}
+		elided[i] = msg
+	}
+	return elided
+}
+
+// elideOlderTurns truncates the long tool outputs of the turns before the last
+// keepTurns ones of a conversation, at least the last turn is left as is
+func elideOlderTurns(msgs []message.Message, summaryMessageID string, keepTurns, maxChars int) []message.Message {
+	if maxChars <= 0 {
+		return msgs
+	}
+	var starts []int
+	for i, msg := range msgs {
+		if msg.Role == message.User && msg.ID != summaryMessageID {
+			starts = append(starts, i)
+		}
+	}
+	keep := max(keepTurns, 1)
+	if len(starts) <= keep {
+		return msgs
+	}
+	cut := starts[len(starts)-keep]
+	return append(elideToolResults(msgs[:cut], maxChars), msgs[cut:]...)
+}
+
+// changedFiles lists the files a session changed, relative to the working
+// directory when they are inside it.
+func (a *agent) changedFiles(ctx context.Context, sessionID string) []string {
+	if a.history == nil {
+		return nil
+	}
+	files, err := a.history.ListLatestSessionFiles(ctx, sessionID)
+	if err != nil {
+		logging.Warn("Failed to list the files of the session", "sessionID", sessionID, "error", err)
+		return nil
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		// Only the content before the first change was recorded
+		if file.Version == history.InitialVersion {
+			continue
+		}
+		path := file.Path
+		if rel, err := filepath.Rel(config.WorkingDirectory(), path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// shouldCompact reports whether the context of the last response got close
+// enough to the context window to summarize the session before the next one.
+func (a *agent) shouldCompact(sess session.Session, model models.Model) bool {
+	cfg := config.Get()
+	if !cfg.AutoCompact || a.summarizeProvider == nil || model.ContextWindow <= 0 {
+		return false
+	}
+	tokens := sess.PromptTokens + sess.CompletionTokens
+	return float64(tokens) >= float64(model.ContextWindow)*cfg.Compaction.Threshold
+}
+
+// compact summarizes the older turns of a session and keeps the last ones
+// verbatim. The summary is added at the end of the session and replaces the
+// conversation it covers when the session is sent to the model.
+func (a *agent) compact(ctx context.Context, sessionID string) error {
+	cfg := config.Get().Compaction
+	progress := func(text string) {
+		a.Publish(pubsub.CreatedEvent, AgentEvent{
+			Type:      AgentEventTypeSummarize,
+			SessionID: sessionID,
+			Progress:  text,
+		})
+	}
+
+	progress("Starting summarization...")
+	msgs, err := a.messages.List(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to list messages: %w", err)
+	}
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	conv := conversation(msgs, sess.SummaryMessageID)
+	if len(conv) == 0 {
+		return fmt.Errorf("no messages to summarize")
+	}
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+
+	progress("Analyzing conversation...")
+	older, recent := splitTurns(conv, sess.SummaryMessageID, cfg.KeepTurns)
+	prompt := summarizePrompt
+	if len(recent) > 0 {
+		prompt += " The most recent messages are kept as they are after your summary, so focus on the conversation above."
+	}
+	files := a.changedFiles(ctx, sessionID)
+	toSummarize := append(elideToolResults(older, cfg.MaxToolOutput), message.Message{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: prompt}},
+	})
+
+	progress("Generating summary...")
+	started := time.Now()
+	response, err := a.summarizeProvider.SendMessages(
+		ctx,
+		toSummarize,
+		make([]tools.BaseTool, 0),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to summarize: %w", err)
+	}
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return fmt.Errorf("empty summary returned")
+	}
+	if len(files) > 0 {
+		summary += "\n\nFiles changed in this session:\n- " + strings.Join(files, "\n- ")
+	}
+
+	progress("Saving summary...")
+	// The summary is created after every message of the session, the kept
+	// ones are the messages between the first recent one and the summary
+	kept := 0
+	if len(recent) > 0 {
+		kept = len(msgs) - slices.IndexFunc(msgs, func(msg message.Message) bool {
+			return msg.ID == recent[0].ID
+		})
+	}
+	model := a.summarizeProvider.Model()
+	if answered, ok := models.SupportedModels[response.Model]; ok {
+		model = answered
+	}
+	msg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: summary},
+			message.Summary{Kept: kept, Files: files},
+			message.Finish{
+				Reason: message.FinishReasonEndTurn,
+				Time:   time.Now().Unix(),
+			},
+		},
+		Model: model.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create summary message: %w", err)
+	}
+
+	// Re-read the session, its cost may have changed while summarizing
+	sess, err = a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	sess.SummaryMessageID = msg.ID
+	sess.CompletionTokens = response.Usage.OutputTokens
+	sess.PromptTokens = 0
+	usage := response.Usage
+	cost := requestCost(model, usage)
+	sess.Cost += cost
+	summaryUsage := a.usage(sess, model, usage, cost, time.Since(started))
+	summaryUsage.Agent = string(config.AgentSummarizer)
+	if err := a.sessions.RecordUsage(ctx, summaryUsage); err != nil {
+		logging.Warn("Failed to record usage", "sessionID", sessionID, "error", err)
+	}
+	if _, err := a.sessions.Save(ctx, sess); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	a.Publish(pubsub.CreatedEvent, AgentEvent{
+		Type:      AgentEventTypeSummarize,
+		SessionID: sessionID,
+		Progress:  "Summary complete",
+		Done:      true,
+	})
+	return nil
+}
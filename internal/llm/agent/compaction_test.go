@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func textMessage(id string, role message.MessageRole, parts ...message.ContentPart) message.Message {
+	return message.Message{
+		ID:    id,
+		Role:  role,
+		Parts: append([]message.ContentPart{message.TextContent{Text: id}}, parts...),
+	}
+}
+
+func messageIDs(msgs []message.Message) []string {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestConversation(t *testing.T) {
+	msgs := []message.Message{
+		textMessage("u1", message.User),
+		textMessage("a1", message.Assistant),
+		textMessage("u2", message.User),
+		textMessage("a2", message.Assistant),
+		textMessage("s1", message.Assistant, message.Summary{Kept: 2}),
+		textMessage("u3", message.User),
+		textMessage("a3", message.Assistant),
+	}
+
+	t.Run("without summary", func(t *testing.T) {
+		assert.Equal(t, messageIDs(msgs), messageIDs(conversation(msgs, "")))
+	})
+
+	t.Run("keeps recent messages after the summary", func(t *testing.T) {
+		conv := conversation(msgs, "s1")
+		assert.Equal(t, []string{"s1", "u2", "a2", "u3", "a3"}, messageIDs(conv))
+		assert.Equal(t, message.User, conv[0].Role)
+		assert.Equal(t, message.Assistant, msgs[4].Role)
+	})
+
+	t.Run("drops older summaries", func(t *testing.T) {
+		withSecond := append(msgs, textMessage("s2", message.Assistant, message.Summary{Kept: 3}))
+		assert.Equal(t, []string{"s2", "u3", "a3"}, messageIDs(conversation(withSecond, "s2")))
+	})
+
+	t.Run("drops reverted messages", func(t *testing.T) {
+		reverted := append([]message.Message{}, msgs...)
+		reverted[3].RevertedAt = 1
+		assert.Equal(t, []string{"s1", "u2", "u3", "a3"}, messageIDs(conversation(reverted, "s1")))
+	})
+}
+
+func TestSplitTurns(t *testing.T) {
+	conv := []message.Message{
+		textMessage("s1", message.User),
+		textMessage("u1", message.User),
+		textMessage("a1", message.Assistant),
+		textMessage("u2", message.User),
+		textMessage("a2", message.Assistant),
+		textMessage("t2", message.Tool),
+		textMessage("u3", message.User),
+	}
+
+	older, recent := splitTurns(conv, "s1", 2)
+	assert.Equal(t, []string{"s1", "u1", "a1"}, messageIDs(older))
+	assert.Equal(t, []string{"u2", "a2", "t2", "u3"}, messageIDs(recent))
+
+	// The first turn is always summarized
+	older, recent = splitTurns(conv, "s1", 5)
+	assert.Equal(t, []string{"s1", "u1", "a1"}, messageIDs(older))
+	assert.Len(t, recent, 4)
+
+	older, recent = splitTurns(conv, "s1", 0)
+	assert.Equal(t, conv, older)
+	assert.Empty(t, recent)
+}
+
+func TestElideToolResults(t *testing.T) {
+	msgs := []message.Message{
+		textMessage("u1", message.User),
+		{ID: "t1", Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "c1", Content: strings.Repeat("x", 30)},
+			message.ToolResult{ToolCallID: "c2", Content: "short"},
+		}},
+	}
+
+	elided := elideToolResults(msgs, 10)
+	results := elided[1].ToolResults()
+	assert.Equal(t, strings.Repeat("x", 10)+"\n[20 characters elided]", results[0].Content)
+	assert.Equal(t, "short", results[1].Content)
+	// The stored messages are left untouched
+	assert.Len(t, msgs[1].ToolResults()[0].Content, 30)
+}
+
+func TestElideOlderTurns(t *testing.T) {
+	long := strings.Repeat("x", 30)
+	toolMessage := func(id string) message.Message {
+		return message.Message{ID: id, Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: id, Content: long},
+		}}
+	}
+	conv := []message.Message{
+		textMessage("s1", message.User),
+		textMessage("u1", message.User),
+		toolMessage("t1"),
+		textMessage("u2", message.User),
+		toolMessage("t2"),
+		textMessage("u3", message.User),
+		toolMessage("t3"),
+	}
+	content := func(msgs []message.Message) []int {
+		var lengths []int
+		for _, msg := range msgs {
+			for _, result := range msg.ToolResults() {
+				lengths = append(lengths, len(result.Content))
+			}
+		}
+		return lengths
+	}
+	elidedLength := len(strings.Repeat("x", 10) + "\n[20 characters elided]")
+
+	// The last turns are sent as they are
+	elided := elideOlderTurns(conv, "s1", 2, 10)
+	assert.Equal(t, messageIDs(conv), messageIDs(elided))
+	assert.Equal(t, []int{elidedLength, 30, 30}, content(elided))
+	assert.Equal(t, []int{elidedLength, elidedLength, 30}, content(elideOlderTurns(conv, "s1", 0, 10)))
+	assert.Equal(t, []int{30, 30, 30}, content(elideOlderTurns(conv, "s1", 3, 10)))
+	assert.Equal(t, []int{30, 30, 30}, content(elideOlderTurns(conv, "s1", 1, 0)))
+	// The stored messages are left untouched
+	assert.Equal(t, []int{30, 30, 30}, content(conv))
+}
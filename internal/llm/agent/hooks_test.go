@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopHooksRunAfterTheResult(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg := config.Get()
+	cfg.Data.Directory = dir
+	cfg.WorkingDir = dir
+	cfg.Shell.Path = "/bin/sh"
+	// The stop hook waits for the test to release it
+	cfg.Hooks = config.HooksConfig{
+		Stop:       []config.Hook{{Command: "while [ ! -f release ]; do sleep 0.01; done; touch stopped"}},
+		SessionEnd: []config.Hook{{Command: "[ -f stopped ] && touch ended"}},
+	}
+	t.Cleanup(func() { cfg.Hooks = config.HooksConfig{} })
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := session.NewService(q, conn)
+	messages := message.NewService(q)
+
+	model := models.SupportedModels[models.Claude4Sonnet]
+	a := &agent{
+		Broker: pubsub.NewBroker[AgentEvent](),
+		provider: &scriptedProvider{model: model, responses: [][]provider.ProviderEvent{{
+			{Type: provider.EventContentDelta, Content: "done"},
+			{Type: provider.EventComplete, Response: &provider.ProviderResponse{
+				Content:      "done",
+				FinishReason: message.FinishReasonEndTurn,
+			}},
+		}}},
+		sessions:       sessions,
+		messages:       messages,
+		activeRequests: sync.Map{},
+	}
+	sess, err := sessions.Create(context.Background(), "test")
+	require.NoError(t, err)
+	events, err := a.Run(context.Background(), sess.ID, "hi")
+	require.NoError(t, err)
+
+	// The result and the session don't wait for the stop hook
+	result := <-events
+	require.NoError(t, result.Error)
+	assert.False(t, a.IsSessionBusy(sess.ID))
+	assert.NoFileExists(t, filepath.Join(dir, "stopped"))
+
+	// The session ends once its stop hook ran
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "release"), nil, 0o644))
+	a.EndSessions(t.Context())
+	assert.FileExists(t, filepath.Join(dir, "stopped"))
+	assert.FileExists(t, filepath.Join(dir, "ended"))
+}
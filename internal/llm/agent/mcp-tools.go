@@ -95,6 +95,7 @@ func (b *mcpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolRes
 			Path:        config.WorkingDirectory(),
 			ToolName:    b.Info().Name,
 			Action:      "execute",
+			Agent:       tools.GetAgentName(ctx),
 			Description: permissionDescription,
 			Params:      params.Input,
 		},
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+// Mode controls which tools the agent can use in a session
+type Mode string
+
+const (
+	// ModeBuild gives the agent all of its tools
+	ModeBuild Mode = "build"
+	// ModePlan limits the agent to read-only tools until it submits a plan
+	ModePlan Mode = "plan"
+)
+
+const (
+	PlanToolName = "submit_plan"
+)
+
+// Plan is the approach the agent proposes in plan mode
+type Plan struct {
+	Title   string   `json:"title"`
+	Summary string   `json:"summary"`
+	Steps   []string `json:"steps"`
+}
+
+// Markdown renders the plan for the user and as the prompt that executes it
+func (p Plan) Markdown() string {
+	var sb strings.Builder
+	if p.Title != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", p.Title)
+	}
+	if p.Summary != "" {
+		fmt.Fprintf(&sb, "%s\n\n", p.Summary)
+	}
+	for i, step := range p.Steps {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, step)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// ExecutePrompt is the prompt sent once a plan is approved
+func (p Plan) ExecutePrompt() string {
+	return "The plan was approved, implement it now:\n\n" + p.Markdown()
+}
+
+type planTool struct{}
+
+func (p *planTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{
+		Name: PlanToolName,
+		Description: `Submit the plan you propose for the user's request. Use this tool once you have explored the codebase enough to know what needs to change.
+
+Usage notes:
+1. The plan is shown to the user, who approves, edits or rejects it. Once approved you get access to the tools that modify files and are asked to implement it.
+2. Steps must be concrete: name the files, functions and commands involved, in the order you will go through them.
+3. Stop after calling this tool and wait for the user's decision. Do not call any other tool in the same message.
+4. If the user rejects the plan, revise it from their feedback and submit it again.`,
+		Parameters: map[string]any{
+			"title": map[string]any{
+				"type":        "string",
+				"description": "A short title for the plan",
+			},
+			"summary": map[string]any{
+				"type":        "string",
+				"description": "The approach in a few sentences, including the trade-offs that matter",
+			},
+			"steps": map[string]any{
+				"type":        "array",
+				"description": "The ordered steps to implement the plan",
+				"items": map[string]any{
+					"type": "string",
+				},
+			},
+		},
+		Required: []string{"title", "steps"},
+	}
+}
+
+func (p *planTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	var plan Plan
+	if err := json.Unmarshal([]byte(call.Input), &plan); err != nil {
+		return tools.NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if len(plan.Steps) == 0 {
+		return tools.NewTextErrorResponse("a plan needs at least one step"), nil
+	}
+	return tools.WithResponseMetadata(
+		tools.NewTextResponse("The plan was submitted to the user. Stop here and wait for their decision."),
+		plan,
+	), nil
+}
+
+// NewPlanTool creates the tool the agent uses to submit a plan in plan mode
+func NewPlanTool() tools.BaseTool {
+	return &planTool{}
+}
+
+// submittedPlan returns the plan of the first successful call to the plan
+// tool, nil when no plan was submitted.
+func submittedPlan(toolCalls []message.ToolCall, toolResults []message.ToolResult) *Plan {
+	for i, call := range toolCalls {
+		if call.Name != PlanToolName || i >= len(toolResults) || toolResults[i].IsError {
+			continue
+		}
+		var plan Plan
+		if err := json.Unmarshal([]byte(call.Input), &plan); err != nil {
+			continue
+		}
+		return &plan
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/hooks"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+)
+
+// concurrentTools neither modify the workspace nor ask for permissions, so
+// consecutive calls to them can run in parallel.
+var concurrentTools = map[string]bool{
+	tools.GlobToolName:              true,
+	tools.GrepToolName:              true,
+	tools.LSToolName:                true,
+	tools.ViewToolName:              true,
+	tools.SourcegraphToolName:       true,
+	tools.DiagnosticsToolName:       true,
+	tools.LSPDefinitionToolName:     true,
+	tools.LSPImplementationToolName: true,
+	tools.LSPReferencesToolName:     true,
+	tools.LSPHoverToolName:          true,
+	tools.LSPSymbolsToolName:        true,
+	tools.LSPCallsToolName:          true,
+	AgentToolName:                   true,
+}
+
+func maxParallelTools() int {
+	cfg := config.Get()
+	if cfg == nil || cfg.MaxParallelTools < 1 {
+		return 1
+	}
+	return cfg.MaxParallelTools
+}
+
+// runToolCalls executes the tool calls of an assistant message. Consecutive
+// calls to concurrent tools run in parallel, bounded by maxParallelTools. Every
+// other call runs on its own and in order, so permission prompts and file
+// changes stay deterministic. Results are returned in the order of the calls.
+func (a *agent) runToolCalls(ctx context.Context, agentTools []tools.BaseTool, assistantMsg *message.Message, toolCalls []message.ToolCall) []message.ToolResult {
+	toolResults := make([]message.ToolResult, len(toolCalls))
+	limit := maxParallelTools()
+
+	for i := 0; i < len(toolCalls); {
+		if ctx.Err() != nil {
+			a.finishMessage(context.Background(), assistantMsg, message.FinishReasonCanceled)
+			// Make all future tool calls cancelled
+			cancelToolCalls(toolResults, toolCalls, i)
+			return toolResults
+		}
+
+		end := i + 1
+		if limit > 1 && concurrentTools[toolCalls[i].Name] {
+			for end < len(toolCalls) && concurrentTools[toolCalls[end].Name] {
+				end++
+			}
+		}
+
+		if end-i == 1 {
+			result, err := a.runToolCall(ctx, agentTools, toolCalls[i])
+			toolResults[i] = result
+			if errors.Is(err, permission.ErrorPermissionDenied) {
+				cancelToolCalls(toolResults, toolCalls, i+1)
+				a.finishMessage(ctx, assistantMsg, message.FinishReasonPermissionDenied)
+				return toolResults
+			}
+			i = end
+			continue
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, limit)
+		for j := i; j < end; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				defer logging.RecoverPanic("agent.runToolCall", func() {
+					toolResults[j] = message.ToolResult{
+						ToolCallID: toolCalls[j].ID,
+						Content:    "Tool execution failed unexpectedly",
+						IsError:    true,
+					}
+				})
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				toolResults[j], _ = a.runToolCall(ctx, agentTools, toolCalls[j])
+			}(j)
+		}
+		wg.Wait()
+		i = end
+	}
+	return toolResults
+}
+
+func (a *agent) runToolCall(ctx context.Context, agentTools []tools.BaseTool, toolCall message.ToolCall) (message.ToolResult, error) {
+	var tool tools.BaseTool
+	for _, availableTool := range agentTools {
+		if availableTool.Info().Name == toolCall.Name {
+			tool = availableTool
+			break
+		}
+	}
+
+	// Tool not found
+	if tool == nil {
+		return message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
+			IsError:    true,
+		}, nil
+	}
+
+	// The output of the tool is shown while it runs
+	sessionID, _ := tools.GetContextValues(ctx)
+	ctx = context.WithValue(ctx, tools.ProgressContextKey, tools.ProgressFunc(func(content string) {
+		a.Publish(pubsub.UpdatedEvent, AgentEvent{
+			Type:       AgentEventTypeToolProgress,
+			SessionID:  sessionID,
+			ToolCallID: toolCall.ID,
+			Progress:   content,
+		})
+	}))
+
+	// The hooks can block the call or rewrite its input
+	input := toolCall.Input
+	pre := hooks.Run(ctx, hooks.Input{
+		Event:     config.HookPreToolUse,
+		SessionID: sessionID,
+		Agent:     tools.GetAgentName(ctx),
+		ToolName:  toolCall.Name,
+		ToolInput: json.RawMessage(input),
+	})
+	if pre.Blocked {
+		content := "Blocked by a hook"
+		if pre.Reason != "" {
+			content += ": " + pre.Reason
+		}
+		return message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    content,
+			IsError:    true,
+		}, nil
+	}
+	if pre.ToolInput != "" {
+		input = pre.ToolInput
+	}
+
+	toolResult, toolErr := tool.Run(ctx, tools.ToolCall{
+		ID:    toolCall.ID,
+		Name:  toolCall.Name,
+		Input: input,
+	})
+	if toolErr != nil {
+		if errors.Is(toolErr, permission.ErrorPermissionDenied) {
+			return message.ToolResult{
+				ToolCallID: toolCall.ID,
+				Content:    "Permission denied",
+				IsError:    true,
+			}, toolErr
+		}
+		// The other errors keep the result the tool returned with them
+		return message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    toolResult.Content,
+			Metadata:   toolResult.Metadata,
+			IsError:    toolResult.IsError,
+		}, toolErr
+	}
+
+	post := hooks.Run(ctx, hooks.Input{
+		Event:     config.HookPostToolUse,
+		SessionID: sessionID,
+		Agent:     tools.GetAgentName(ctx),
+		ToolName:  toolCall.Name,
+		ToolInput: json.RawMessage(input),
+		ToolResponse: &hooks.ToolResponse{
+			Content: toolResult.Content,
+			IsError: toolResult.IsError,
+		},
+	})
+	if post.Feedback != "" {
+		toolResult.Content += "\n\n<hook_feedback>\n" + post.Feedback + "\n</hook_feedback>"
+	}
+	return message.ToolResult{
+		ToolCallID: toolCall.ID,
+		Content:    toolResult.Content,
+		Metadata:   toolResult.Metadata,
+		IsError:    toolResult.IsError,
+	}, nil
+}
+
+func cancelToolCalls(toolResults []message.ToolResult, toolCalls []message.ToolCall, from int) {
+	for j := from; j < len(toolCalls); j++ {
+		toolResults[j] = message.ToolResult{
+			ToolCallID: toolCalls[j].ID,
+			Content:    "Tool execution canceled by user",
+			IsError:    true,
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/provider"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTool struct {
+	name    string
+	delay   time.Duration
+	running *atomic.Int32
+	peak    *atomic.Int32
+
+	mu    *sync.Mutex
+	order *[]string
+}
+
+func (f *fakeTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{Name: f.name}
+}
+
+func (f *fakeTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	n := f.running.Add(1)
+	defer f.running.Add(-1)
+	for {
+		peak := f.peak.Load()
+		if n <= peak || f.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	*f.order = append(*f.order, call.ID)
+	f.mu.Unlock()
+	return tools.NewTextResponse(call.Input), nil
+}
+
+func TestRunToolCalls(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	config.Get().MaxParallelTools = 4
+
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	var order []string
+	newTool := func(name string) tools.BaseTool {
+		return &fakeTool{name: name, delay: 20 * time.Millisecond, running: &running, peak: &peak, mu: &mu, order: &order}
+	}
+	a := &agent{tools: []tools.BaseTool{newTool(tools.ViewToolName), newTool(tools.GrepToolName), newTool(tools.EditToolName)}}
+
+	calls := []message.ToolCall{
+		{ID: "1", Name: tools.ViewToolName, Input: "a"},
+		{ID: "2", Name: tools.GrepToolName, Input: "b"},
+		{ID: "3", Name: tools.ViewToolName, Input: "c"},
+		{ID: "4", Name: tools.EditToolName, Input: "d"},
+		{ID: "5", Name: tools.ViewToolName, Input: "e"},
+		{ID: "6", Name: "missing", Input: "f"},
+	}
+	results := a.runToolCalls(context.Background(), a.tools, &message.Message{}, calls)
+
+	require.Len(t, results, len(calls))
+	for i, call := range calls[:5] {
+		assert.Equal(t, call.ID, results[i].ToolCallID)
+		assert.Equal(t, call.Input, results[i].Content)
+		assert.False(t, results[i].IsError)
+	}
+	assert.True(t, results[5].IsError)
+	assert.Equal(t, int32(3), peak.Load(), "read-only calls should run together")
+	// The edit only starts once the reads before it are done
+	assert.Equal(t, "4", order[3])
+	assert.Equal(t, "5", order[4])
+}
+
+func TestRunToolCallsPlanMode(t *testing.T) {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	var order []string
+	newTool := func(name string) tools.BaseTool {
+		return &fakeTool{name: name, running: &running, peak: &peak, mu: &mu, order: &order}
+	}
+	a := &agent{
+		tools:     []tools.BaseTool{newTool(tools.ViewToolName), newTool(tools.EditToolName)},
+		planTools: []tools.BaseTool{newTool(tools.ViewToolName), NewPlanTool()},
+	}
+	require.NoError(t, a.SetMode("session", ModePlan))
+
+	calls := []message.ToolCall{
+		{ID: "1", Name: tools.EditToolName, Input: "a"},
+		{ID: "2", Name: PlanToolName, Input: `{"title":"Fix","steps":["edit a.go"]}`},
+	}
+	_, agentTools := a.forSession(session.Session{ID: "session"})
+	results := a.runToolCalls(context.Background(), agentTools, &message.Message{SessionID: "session"}, calls)
+
+	require.Len(t, results, len(calls))
+	assert.True(t, results[0].IsError, "edit is not available in plan mode")
+	assert.False(t, results[1].IsError)
+	plan := submittedPlan(calls, results)
+	require.NotNil(t, plan)
+	assert.Equal(t, "# Fix\n\n1. edit a.go", plan.Markdown())
+
+	require.NoError(t, a.SetMode("session", ModeBuild))
+	assert.Equal(t, ModeBuild, a.Mode("session"))
+	assert.Error(t, (&agent{}).SetMode("session", ModePlan))
+}
+
+func TestAttachmentsOfCustomAgent(t *testing.T) {
+	done := []provider.ProviderEvent{{Type: provider.EventComplete, Response: &provider.ProviderResponse{
+		Content:      "done",
+		FinishReason: message.FinishReasonEndTurn,
+	}}}
+	a, sessions := newBudgetAgent(t, config.BudgetsConfig{})
+	a.customAgents = map[config.AgentName]customAgent{
+		"reviewer": {provider: &scriptedProvider{model: models.Model{ID: "text-only"}, responses: [][]provider.ProviderEvent{done}}},
+	}
+	ctx := context.Background()
+	sess, err := sessions.Create(ctx, "test")
+	require.NoError(t, err)
+	sess.Agent = "reviewer"
+	_, err = sessions.Save(ctx, sess)
+	require.NoError(t, err)
+
+	// The model of the coder takes images, the one of the session doesn't
+	events, err := a.Run(ctx, sess.ID, "look", message.Attachment{FilePath: "a.png", MimeType: "image/png", Content: []byte("png")})
+	require.NoError(t, err)
+	require.NoError(t, (<-events).Error)
+	msgs, err := a.messages.List(ctx, sess.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, msgs)
+	assert.Empty(t, msgs[0].BinaryContent())
+}
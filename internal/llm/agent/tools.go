@@ -3,7 +3,9 @@ package agent
 import (
 	"context"
 
+	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/jobs"
 	"github.com/opencode-ai/opencode/internal/llm/tools"
 	"github.com/opencode-ai/opencode/internal/lsp"
 	"github.com/opencode-ai/opencode/internal/message"
@@ -16,16 +18,28 @@ func CoderAgentTools(
 	sessions session.Service,
 	messages message.Service,
 	history history.Service,
-	lspClients map[string]*lsp.Client,
+	jobs jobs.Service,
+	lspClients *lsp.Clients,
 ) []tools.BaseTool {
 	ctx := context.Background()
 	otherTools := GetMcpTools(ctx, permissions)
-	if len(lspClients) > 0 {
+	if hasLSP() {
 		otherTools = append(otherTools, tools.NewDiagnosticsTool(lspClients))
 	}
+	otherTools = append(otherTools, lspNavigationTools(lspClients)...)
+	if hasLSP() {
+		otherTools = append(otherTools,
+			tools.NewLSPRenameTool(lspClients, permissions, history),
+			tools.NewLSPCodeActionTool(lspClients, permissions, history),
+		)
+	}
 	return append(
 		[]tools.BaseTool{
-			tools.NewBashTool(permissions),
+			tools.NewBashTool(permissions, jobs),
+			tools.NewJobOutputTool(jobs),
+			tools.NewJobInputTool(jobs),
+			tools.NewJobListTool(jobs),
+			tools.NewJobKillTool(jobs),
 			tools.NewEditTool(lspClients, permissions, history),
 			tools.NewFetchTool(permissions),
 			tools.NewGlobTool(),
@@ -40,12 +54,53 @@ func CoderAgentTools(
 	)
 }
 
-func TaskAgentTools(lspClients map[string]*lsp.Client) []tools.BaseTool {
-	return []tools.BaseTool{
+// PlanAgentTools are the read-only tools the coder agent uses in plan mode
+func PlanAgentTools(
+	sessions session.Service,
+	messages message.Service,
+	lspClients *lsp.Clients,
+) []tools.BaseTool {
+	return append([]tools.BaseTool{
+		tools.NewReadOnlyBashTool(),
+		tools.NewGlobTool(),
+		tools.NewGrepTool(),
+		tools.NewLsTool(),
+		tools.NewSourcegraphTool(),
+		tools.NewViewTool(lspClients),
+		NewAgentTool(sessions, messages, lspClients),
+		NewPlanTool(),
+	}, lspNavigationTools(lspClients)...)
+}
+
+func TaskAgentTools(lspClients *lsp.Clients) []tools.BaseTool {
+	return append([]tools.BaseTool{
 		tools.NewGlobTool(),
 		tools.NewGrepTool(),
 		tools.NewLsTool(),
 		tools.NewSourcegraphTool(),
 		tools.NewViewTool(lspClients),
+	}, lspNavigationTools(lspClients)...)
+}
+
+// hasLSP reports whether language servers are configured. The clients start
+// in the background, after the tools are created.
+func hasLSP() bool {
+	cfg := config.Get()
+	return cfg != nil && len(cfg.LSP) > 0
+}
+
+// lspNavigationTools are the read-only tools asking the language servers
+// about the code
+func lspNavigationTools(lspClients *lsp.Clients) []tools.BaseTool {
+	if !hasLSP() {
+		return nil
+	}
+	return []tools.BaseTool{
+		tools.NewLSPDefinitionTool(lspClients),
+		tools.NewLSPImplementationTool(lspClients),
+		tools.NewLSPReferencesTool(lspClients),
+		tools.NewLSPHoverTool(lspClients),
+		tools.NewLSPSymbolsTool(lspClients),
+		tools.NewLSPCallsTool(lspClients),
 	}
 }
@@ -0,0 +1,13 @@
+package prompt
+
+// PlanModeInstructions are added to the prompts sent while a session is in
+// plan mode.
+func PlanModeInstructions() string {
+	return `<system-reminder>
+Plan mode is active. You can only use read-only tools: you MUST NOT edit, create or delete files, or run commands that change the system, even if the user asks you to.
+1. Explore the codebase with the available tools until you understand what the request involves.
+2. Finish by calling the submit_plan tool with a concrete, ordered plan. Do not implement anything.
+3. If the user gave feedback on a previous plan, submit a revised plan that addresses it.
+Once the user approves the plan, you will get all your tools back and be asked to implement it.
+</system-reminder>`
+}
@@ -24,10 +24,14 @@ func GetAgentPrompt(agentName config.AgentName, provider models.ModelProvider) s
 	case config.AgentSummarizer:
 		basePrompt = SummarizerPrompt(provider)
 	default:
-		basePrompt = "You are a helpful assistant"
+		// User-defined agents start from the coder prompt
+		basePrompt = CoderPrompt(provider)
+	}
+	if custom := customPrompt(agentName); custom != "" {
+		basePrompt = custom
 	}
 
-	if agentName == config.AgentCoder || agentName == config.AgentTask {
+	if agentName == config.AgentCoder || agentName == config.AgentTask || !config.IsBuiltinAgent(agentName) {
 		// Add context from project-specific instruction files if they exist
 		contextContent := getContextFromPaths()
 		logging.Debug("Context content", "Context", contextContent)
@@ -38,6 +42,21 @@ func GetAgentPrompt(agentName config.AgentName, provider models.ModelProvider) s
 	return basePrompt
 }
 
+// customPrompt reads the prompt file configured for an agent, it is empty when
+// there is none.
+func customPrompt(agentName config.AgentName) string {
+	agentCfg, ok := config.Get().Agents[agentName]
+	if !ok || agentCfg.Prompt == "" {
+		return ""
+	}
+	content, err := os.ReadFile(agentCfg.PromptPath())
+	if err != nil {
+		logging.Warn("Failed to read agent prompt", "agent", agentName, "error", err)
+		return ""
+	}
+	return fmt.Sprintf("%s\n%s\n", strings.TrimSpace(string(content)), getEnvironmentInfo())
+}
+
 var (
 	onceContext    sync.Once
 	contextContent string
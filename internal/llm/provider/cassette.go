@@ -0,0 +1,327 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+// Cassette is a file of recorded model responses, keyed by a hash of the
+// requests that produced them. Recording providers add to it and the mock
+// provider replays it, so the agents can run without calling the APIs.
+type Cassette struct {
+	path      string
+	recording bool
+
+	mu           sync.Mutex
+	interactions []cassetteInteraction
+	// played counts the replayed interactions of each key, the same request
+	// can be recorded several times
+	played map[string]int
+}
+
+type cassetteInteraction struct {
+	Key    string          `json:"key"`
+	Model  models.ModelID  `json:"model"`
+	Events []cassetteEvent `json:"events"`
+}
+
+// cassetteEvent is a ProviderEvent that can be stored as JSON
+type cassetteEvent struct {
+	Type     EventType         `json:"type"`
+	Content  string            `json:"content,omitempty"`
+	Thinking string            `json:"thinking,omitempty"`
+	Response *ProviderResponse `json:"response,omitempty"`
+	ToolCall *message.ToolCall `json:"toolCall,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type cassetteFile struct {
+	Interactions []cassetteInteraction `json:"interactions"`
+}
+
+var (
+	cassettesMu sync.Mutex
+	cassettes   = map[string]*Cassette{}
+)
+
+// RecordCassette returns the cassette that records the responses to path.
+// It starts empty and overwrites the file, every provider of the process
+// records to the same cassette.
+func RecordCassette(path string) (*Cassette, error) {
+	return openCassette(path, true)
+}
+
+// ReplayCassette returns the cassette stored at path to replay it
+func ReplayCassette(path string) (*Cassette, error) {
+	return openCassette(path, false)
+}
+
+func openCassette(path string, recording bool) (*Cassette, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+	// Switching modes starts over, replaying what was just recorded or
+	// recording a new cassette
+	if c, ok := cassettes[path]; ok && c.recording == recording {
+		return c, nil
+	}
+
+	c := &Cassette{path: path, recording: recording, played: map[string]int{}}
+	if !recording {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		var file cassetteFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		c.interactions = file.Interactions
+	}
+	cassettes[path] = c
+	return c, nil
+}
+
+func (c *Cassette) record(key string, model models.ModelID, events []ProviderEvent) error {
+	interaction := cassetteInteraction{Key: key, Model: model}
+	for _, event := range events {
+		stored := cassetteEvent{
+			Type:     event.Type,
+			Content:  event.Content,
+			Thinking: event.Thinking,
+		}
+		if event.Response != nil {
+			response := *event.Response
+			stored.Response = &response
+		}
+		if event.ToolCall != nil {
+			toolCall := *event.ToolCall
+			stored.ToolCall = &toolCall
+		}
+		if event.Error != nil {
+			stored.Error = event.Error.Error()
+		}
+		interaction.Events = append(interaction.Events, stored)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+// replay returns the events recorded for a request. Requests recorded several
+// times are replayed in order, the last recording is reused once they were
+// all played.
+func (c *Cassette) replay(key string) ([]ProviderEvent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matches []cassetteInteraction
+	for _, interaction := range c.interactions {
+		if interaction.Key == key {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no recorded response for request %s in cassette %s", key, c.path)
+	}
+	interaction := matches[min(c.played[key], len(matches)-1)]
+	c.played[key]++
+
+	events := make([]ProviderEvent, 0, len(interaction.Events))
+	for _, stored := range interaction.Events {
+		event := ProviderEvent{
+			Type:     stored.Type,
+			Content:  stored.Content,
+			Thinking: stored.Thinking,
+		}
+		// The agent may change the events it receives
+		if stored.Response != nil {
+			response := *stored.Response
+			event.Response = &response
+		}
+		if stored.ToolCall != nil {
+			toolCall := *stored.ToolCall
+			event.ToolCall = &toolCall
+		}
+		if stored.Error != "" {
+			event.Error = errors.New(stored.Error)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// environmentInfo matches the environment the prompts end with, the date and
+// the files of the project change from one run to another
+var environmentInfo = regexp.MustCompile(`(?s)<env>.*?</env>\s*<project>.*?</project>`)
+
+// requestKey hashes what decides the response of a model: the model, the
+// system prompt, the conversation and the available tools. The parts that
+// change from one run to another, the finish times, the environment of the
+// prompt and the working directory, are left out.
+func requestKey(model models.ModelID, system string, messages []message.Message, tools []tools.BaseTool) string {
+	type request struct {
+		Role  message.MessageRole   `json:"role"`
+		Parts []message.ContentPart `json:"parts"`
+	}
+	var requests []request
+	for _, msg := range messages {
+		var parts []message.ContentPart
+		for _, part := range msg.Parts {
+			if _, ok := part.(message.Finish); !ok {
+				parts = append(parts, part)
+			}
+		}
+		// Messages without content are never sent
+		if len(parts) == 0 {
+			continue
+		}
+		requests = append(requests, request{Role: msg.Role, Parts: parts})
+	}
+	toolNames := make([]string, len(tools))
+	for i, tool := range tools {
+		toolNames[i] = tool.Info().Name
+	}
+
+	data, _ := json.Marshal(struct {
+		Model    models.ModelID `json:"model"`
+		System   string         `json:"system,omitempty"`
+		Messages []request      `json:"messages"`
+		Tools    []string       `json:"tools"`
+	}{model, environmentInfo.ReplaceAllString(system, "<env/>"), requests, toolNames})
+	key := string(data)
+	if config.Get() != nil && config.WorkingDirectory() != "" {
+		wd, _ := json.Marshal(config.WorkingDirectory())
+		key = strings.ReplaceAll(key, strings.Trim(string(wd), `"`), "$WORKDIR")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordingProvider saves the responses of the provider it wraps to a
+// cassette
+type recordingProvider struct {
+	provider      Provider
+	cassette      *Cassette
+	systemMessage string
+}
+
+// NewRecordingProvider creates a provider that records the responses of p to
+// the cassette, systemMessage is the prompt p was created with
+func NewRecordingProvider(p Provider, cassette *Cassette, systemMessage string) Provider {
+	return &recordingProvider{provider: p, cassette: cassette, systemMessage: systemMessage}
+}
+
+func (p *recordingProvider) Model() models.Model {
+	return p.provider.Model()
+}
+
+func (p *recordingProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	key := requestKey(p.Model().ID, p.systemMessage, messages, tools)
+	response, err := p.provider.SendMessages(ctx, messages, tools)
+	event := ProviderEvent{Type: EventComplete, Response: response}
+	if err != nil {
+		event = ProviderEvent{Type: EventError, Error: err}
+	}
+	// A cancelled request says nothing about the model
+	if !errors.Is(err, context.Canceled) {
+		if recordErr := p.cassette.record(key, p.Model().ID, []ProviderEvent{event}); recordErr != nil {
+			return nil, fmt.Errorf("failed to record response: %w", recordErr)
+		}
+	}
+	return response, err
+}
+
+func (p *recordingProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	key := requestKey(p.Model().ID, p.systemMessage, messages, tools)
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		var events []ProviderEvent
+		for event := range p.provider.StreamResponse(ctx, messages, tools) {
+			events = append(events, event)
+			eventChan <- event
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err := p.cassette.record(key, p.Model().ID, events); err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: fmt.Errorf("failed to record response: %w", err)}
+		}
+	}()
+	return eventChan
+}
+
+// mockClient replays the responses recorded in a cassette, for the model
+// given to the provider
+type mockClient struct {
+	providerOptions providerClientOptions
+}
+
+type MockClient ProviderClient
+
+func newMockClient(opts providerClientOptions) MockClient {
+	return &mockClient{providerOptions: opts}
+}
+
+func (m *mockClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	events, err := m.providerOptions.cassette.replay(requestKey(m.providerOptions.model.ID, m.providerOptions.systemMessage, messages, tools))
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		switch event.Type {
+		case EventError:
+			return nil, event.Error
+		case EventComplete:
+			return event.Response, nil
+		}
+	}
+	return nil, errors.New("recorded response has no result")
+}
+
+func (m *mockClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		events, err := m.providerOptions.cassette.replay(requestKey(m.providerOptions.model.ID, m.providerOptions.systemMessage, messages, tools))
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+		for _, event := range events {
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+				eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+				return
+			}
+		}
+	}()
+	return eventChan
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	model := models.SupportedModels[models.Claude4Sonnet]
+	prompt := []message.Message{{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "hi"}}}}
+
+	cassette, err := RecordCassette(path)
+	require.NoError(t, err)
+	inner := answering(model.ID, "hello")
+	recorded := collect(NewRecordingProvider(inner, cassette, "You are a helpful assistant.").StreamResponse(context.Background(), prompt, nil))
+	inner.events = []ProviderEvent{{Type: EventComplete, Response: &ProviderResponse{Content: "again"}}}
+	_, err = NewRecordingProvider(inner, cassette, "You are a helpful assistant.").SendMessages(context.Background(), prompt, nil)
+	require.NoError(t, err)
+
+	cassette, err = ReplayCassette(path)
+	require.NoError(t, err)
+	mock, err := NewProvider(models.ProviderMock, WithModel(model), WithSystemMessage("You are a helpful assistant."), WithCassette(cassette))
+	require.NoError(t, err)
+
+	replayed := collect(mock.StreamResponse(context.Background(), prompt, nil))
+	assert.Equal(t, recorded, replayed)
+	// The same request recorded twice is replayed in order
+	response, err := mock.SendMessages(context.Background(), prompt, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "again", response.Content)
+
+	other := []message.Message{{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "bye"}}}}
+	events := collect(mock.StreamResponse(context.Background(), other, nil))
+	require.Len(t, events, 1)
+	assert.ErrorContains(t, events[0].Error, "no recorded response")
+
+	// A changed prompt is a different request
+	changed, err := NewProvider(models.ProviderMock, WithModel(model), WithSystemMessage("You are a pirate."), WithCassette(cassette))
+	require.NoError(t, err)
+	_, err = changed.SendMessages(context.Background(), prompt, nil)
+	assert.ErrorContains(t, err, "no recorded response")
+
+	_, err = NewProvider(models.ProviderMock, WithModel(model))
+	assert.Error(t, err)
+}
+
+func TestRequestKey(t *testing.T) {
+	prompt := []message.Message{{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "hi"}}}}
+	// A message left with only its finish part is never sent
+	finished := append(prompt, message.Message{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{message.Finish{Reason: message.FinishReasonCanceled}},
+	})
+	assert.Equal(t, requestKey(models.Claude4Sonnet, "", prompt, nil), requestKey(models.Claude4Sonnet, "", finished, nil))
+
+	// The environment of the prompt changes from one day to another
+	today := "Be brief.\n<env>\nToday's date: 10/16/2026\n</env>\n<project>\n- a.go\n</project>\n"
+	tomorrow := "Be brief.\n<env>\nToday's date: 10/17/2026\n</env>\n<project>\n- a.go\n- b.go\n</project>\n"
+	assert.Equal(t, requestKey(models.Claude4Sonnet, today, prompt, nil), requestKey(models.Claude4Sonnet, tomorrow, prompt, nil))
+	assert.NotEqual(t, requestKey(models.Claude4Sonnet, today, prompt, nil), requestKey(models.Claude4Sonnet, "Be verbose.\n<env>\n</env>\n<project>\n</project>\n", prompt, nil))
+}
@@ -596,7 +596,7 @@ func (c *copilotClient) shouldRetry(attempts int, err error) (bool, int64, error
 	}
 
 	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+		return false, 0, fmt.Errorf("%w: %d retries", ErrMaxRetries, maxRetries)
 	}
 
 	retryMs := 0
@@ -0,0 +1,147 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+)
+
+// fallbackProvider sends the requests to its first provider and moves to the
+// next ones when a model is overloaded, rate limited or can't fit the
+// conversation in its context window.
+type fallbackProvider struct {
+	providers []Provider
+}
+
+// NewFallbackProvider creates a provider that tries the given providers in
+// order. Model returns the model of the first one, the responses of the other
+// ones carry the model that answered.
+func NewFallbackProvider(providers ...Provider) Provider {
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	return &fallbackProvider{providers: providers}
+}
+
+func (p *fallbackProvider) Model() models.Model {
+	return p.providers[0].Model()
+}
+
+func (p *fallbackProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	var err error
+	for i, provider := range p.providers {
+		var response *ProviderResponse
+		response, err = provider.SendMessages(ctx, messages, tools)
+		if err == nil {
+			if i > 0 {
+				response.Model = provider.Model().ID
+			}
+			return response, nil
+		}
+		if !p.canFallback(i, err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (p *fallbackProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		for i, provider := range p.providers {
+			// Once part of the answer was forwarded the next model can't
+			// take over without repeating it
+			started := false
+			fallback := false
+			events := provider.StreamResponse(ctx, messages, tools)
+			for event := range events {
+				switch event.Type {
+				case EventError:
+					if !started && p.canFallback(i, event.Error) {
+						fallback = true
+					}
+				case EventComplete:
+					if i > 0 && event.Response != nil {
+						event.Response.Model = provider.Model().ID
+					}
+				case EventWarning:
+				default:
+					started = true
+				}
+				if fallback {
+					break
+				}
+				eventChan <- event
+			}
+			if !fallback {
+				return
+			}
+			// Let the abandoned stream finish in the background
+			go func() {
+				for range events {
+				}
+			}()
+		}
+	}()
+	return eventChan
+}
+
+// canFallback reports whether the request that failed with err on the i-th
+// provider can be sent to the next one, and logs the switch.
+func (p *fallbackProvider) canFallback(i int, err error) bool {
+	if i == len(p.providers)-1 || !shouldFallback(err) {
+		return false
+	}
+	current, next := p.providers[i].Model(), p.providers[i+1].Model()
+	logging.WarnPersist(fmt.Sprintf("%s failed, falling back to %s: %v", current.Name, next.Name, err))
+	return true
+}
+
+// shouldFallback reports whether another model could succeed where a request
+// failed: the model was overloaded or rate limited after all the retries, or
+// the conversation doesn't fit its context window.
+func shouldFallback(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrMaxRetries) {
+		return true
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) && isFallbackStatus(anthropicErr.StatusCode) {
+		return true
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) && isFallbackStatus(openaiErr.StatusCode) {
+		return true
+	}
+	return contains(err.Error(),
+		"overloaded",
+		"rate limit",
+		"too many requests",
+		"quota exceeded",
+		"context length",
+		"context_length_exceeded",
+		"context window",
+		"prompt is too long",
+		"maximum context",
+	)
+}
+
+func isFallbackStatus(statusCode int) bool {
+	switch {
+	case statusCode == 413, statusCode == 429, statusCode == 529:
+		return true
+	case statusCode >= 500:
+		return true
+	}
+	return false
+}
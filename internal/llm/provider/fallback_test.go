@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/llm/tools"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	model  models.Model
+	events []ProviderEvent
+	calls  int
+}
+
+func (f *fakeProvider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	f.calls++
+	for _, event := range f.events {
+		if event.Type == EventError {
+			return nil, event.Error
+		}
+		if event.Type == EventComplete {
+			return event.Response, nil
+		}
+	}
+	return nil, errors.New("no response")
+}
+
+func (f *fakeProvider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	f.calls++
+	events := make(chan ProviderEvent, len(f.events))
+	for _, event := range f.events {
+		events <- event
+	}
+	close(events)
+	return events
+}
+
+func (f *fakeProvider) Model() models.Model {
+	return f.model
+}
+
+func answering(id models.ModelID, content string) *fakeProvider {
+	return &fakeProvider{
+		model: models.Model{ID: id, Name: string(id)},
+		events: []ProviderEvent{
+			{Type: EventContentDelta, Content: content},
+			{Type: EventComplete, Response: &ProviderResponse{Content: content, FinishReason: message.FinishReasonEndTurn}},
+		},
+	}
+}
+
+func failing(id models.ModelID, err error, before ...ProviderEvent) *fakeProvider {
+	return &fakeProvider{
+		model:  models.Model{ID: id, Name: string(id)},
+		events: append(before, ProviderEvent{Type: EventError, Error: err}),
+	}
+}
+
+func collect(events <-chan ProviderEvent) []ProviderEvent {
+	var all []ProviderEvent
+	for event := range events {
+		all = append(all, event)
+	}
+	return all
+}
+
+func TestFallbackProviderStream(t *testing.T) {
+	overloaded := fmt.Errorf("%w: %d retries", ErrMaxRetries, maxRetries)
+
+	t.Run("moves to the next model", func(t *testing.T) {
+		primary := failing("primary", overloaded)
+		secondary := answering("secondary", "hello")
+		p := NewFallbackProvider(primary, secondary)
+
+		events := collect(p.StreamResponse(context.Background(), nil, nil))
+		require.Len(t, events, 2)
+		assert.Equal(t, "hello", events[0].Content)
+		assert.Equal(t, models.ModelID("secondary"), events[1].Response.Model)
+		assert.Equal(t, models.ModelID("primary"), p.Model().ID)
+	})
+
+	t.Run("keeps the error that another model can't fix", func(t *testing.T) {
+		primary := failing("primary", errors.New("invalid api key"))
+		secondary := answering("secondary", "hello")
+		events := collect(NewFallbackProvider(primary, secondary).StreamResponse(context.Background(), nil, nil))
+		require.Len(t, events, 1)
+		assert.Equal(t, EventError, events[0].Type)
+		assert.Zero(t, secondary.calls)
+	})
+
+	t.Run("doesn't switch once the answer started", func(t *testing.T) {
+		primary := failing("primary", overloaded, ProviderEvent{Type: EventContentDelta, Content: "hel"})
+		secondary := answering("secondary", "hello")
+		events := collect(NewFallbackProvider(primary, secondary).StreamResponse(context.Background(), nil, nil))
+		require.Len(t, events, 2)
+		assert.Equal(t, EventError, events[1].Type)
+		assert.Zero(t, secondary.calls)
+	})
+
+	t.Run("returns the error of the last model", func(t *testing.T) {
+		primary := failing("primary", overloaded)
+		secondary := failing("secondary", errors.New("prompt is too long: 250000 tokens"))
+		events := collect(NewFallbackProvider(primary, secondary).StreamResponse(context.Background(), nil, nil))
+		require.Len(t, events, 1)
+		assert.ErrorContains(t, events[0].Error, "prompt is too long")
+	})
+}
+
+func TestFallbackProviderSend(t *testing.T) {
+	primary := failing("primary", errors.New("429 Too Many Requests"))
+	secondary := answering("secondary", "hello")
+	response, err := NewFallbackProvider(primary, secondary).SendMessages(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", response.Content)
+	assert.Equal(t, models.ModelID("secondary"), response.Model)
+
+	_, err = NewFallbackProvider(failing("primary", context.Canceled), secondary).SendMessages(context.Background(), nil, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
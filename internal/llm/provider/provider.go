@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
@@ -14,6 +15,10 @@ type EventType string
 
 const maxRetries = 8
 
+// ErrMaxRetries is returned once a rate limited request was retried
+// maxRetries times
+var ErrMaxRetries = errors.New("maximum retry attempts reached for rate limit")
+
 const (
 	EventContentStart  EventType = "content_start"
 	EventToolUseStart  EventType = "tool_use_start"
@@ -28,17 +33,20 @@ const (
 )
 
 type TokenUsage struct {
-	InputTokens         int64
-	OutputTokens        int64
-	CacheCreationTokens int64
-	CacheReadTokens     int64
+	InputTokens         int64 `json:"inputTokens"`
+	OutputTokens        int64 `json:"outputTokens"`
+	CacheCreationTokens int64 `json:"cacheCreationTokens"`
+	CacheReadTokens     int64 `json:"cacheReadTokens"`
 }
 
 type ProviderResponse struct {
-	Content      string
-	ToolCalls    []message.ToolCall
-	Usage        TokenUsage
-	FinishReason message.FinishReason
+	Content      string               `json:"content"`
+	ToolCalls    []message.ToolCall   `json:"toolCalls,omitempty"`
+	Usage        TokenUsage           `json:"usage"`
+	FinishReason message.FinishReason `json:"finishReason"`
+	// Model is the model that answered when it isn't the one of the
+	// provider, after a fallback
+	Model models.ModelID `json:"model,omitempty"`
 }
 
 type ProviderEvent struct {
@@ -63,6 +71,8 @@ type providerClientOptions struct {
 	model         models.Model
 	maxTokens     int64
 	systemMessage string
+	// cassette holds the responses replayed by the mock provider
+	cassette *Cassette
 
 	anthropicOptions []AnthropicOption
 	openaiOptions    []OpenAIOption
@@ -168,8 +178,13 @@ func NewProvider(providerName models.ModelProvider, opts ...ProviderClientOption
 			client:  newOpenAIClient(clientOptions),
 		}, nil
 	case models.ProviderMock:
-		// TODO: implement mock client for test
-		panic("not implemented")
+		if clientOptions.cassette == nil {
+			return nil, errors.New("the mock provider needs a cassette to replay")
+		}
+		return &baseProvider[MockClient]{
+			options: clientOptions,
+			client:  newMockClient(clientOptions),
+		}, nil
 	}
 	return nil, fmt.Errorf("provider not supported: %s", providerName)
 }
@@ -223,6 +238,12 @@ func WithSystemMessage(systemMessage string) ProviderClientOption {
 	}
 }
 
+func WithCassette(cassette *Cassette) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.cassette = cassette
+	}
+}
+
 func WithAnthropicOptions(anthropicOptions ...AnthropicOption) ProviderClientOption {
 	return func(options *providerClientOptions) {
 		options.anthropicOptions = anthropicOptions
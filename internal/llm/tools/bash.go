@@ -4,30 +4,39 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/jobs"
 	"github.com/opencode-ai/opencode/internal/llm/tools/shell"
 	"github.com/opencode-ai/opencode/internal/permission"
 )
 
 type BashParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command    string `json:"command"`
+	Timeout    int    `json:"timeout"`
+	Background bool   `json:"background"`
 }
 
 type BashPermissionsParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command    string `json:"command"`
+	Timeout    int    `json:"timeout"`
+	Background bool   `json:"background,omitempty"`
 }
 
 type BashResponseMetadata struct {
 	StartTime int64 `json:"start_time"`
 	EndTime   int64 `json:"end_time"`
+	// JobID is the job running a background command
+	JobID string `json:"job_id,omitempty"`
 }
 type bashTool struct {
 	permissions permission.Service
+	jobs        jobs.Service
+	// readOnly only allows the safe read-only commands
+	readOnly bool
 }
 
 const (
@@ -54,6 +63,45 @@ var safeReadOnlyCommands = []string{
 	"go version", "go help", "go list", "go env", "go doc", "go vet", "go fmt", "go mod", "go test", "go build", "go run", "go install", "go clean",
 }
 
+// planReadOnlyCommands are the only commands of the read-only shell of plan
+// mode. Unlike safeReadOnlyCommands, there are no wrappers running other
+// commands, like env or timeout, and nothing changing files, processes or
+// the state of the shell.
+var planReadOnlyCommands = []string{
+	"ls", "pwd", "echo", "whoami", "id", "groups", "uname", "uptime", "free", "df", "du", "ps",
+	"which", "type", "whereis", "whatis", "printenv",
+
+	"git status", "git log", "git diff", "git show", "git ls-files", "git rev-parse", "git describe",
+	"git blame", "git shortlog", "git config --get", "git config --list",
+
+	"go version", "go help", "go list", "go doc", "go mod graph", "go mod why",
+}
+
+// planUnsafeArgs are the prefixes of the arguments rejected in plan mode,
+// --output of git writes a file and -toolexec of go runs another program.
+// The prefix also catches the abbreviations git accepts for long options.
+var planUnsafeArgs = []string{"--ou", "-toolexec", "--toolexec"}
+
+// isPlanReadOnly reports whether command is one of planReadOnlyCommands
+// without unsafe arguments. Operators are checked by the caller.
+func isPlanReadOnly(command string) bool {
+	fields := strings.Fields(command)
+	for _, field := range fields {
+		for _, arg := range planUnsafeArgs {
+			if strings.HasPrefix(field, arg) {
+				return false
+			}
+		}
+	}
+	for _, safe := range planReadOnlyCommands {
+		safeFields := strings.Fields(safe)
+		if len(fields) >= len(safeFields) && slices.Equal(fields[:len(safeFields)], safeFields) {
+			return true
+		}
+	}
+	return false
+}
+
 func bashDescription() string {
 	bannedCommandsStr := strings.Join(bannedCommands, ", ")
 	return fmt.Sprintf(`Executes a given bash command in a persistent shell session with optional timeout, ensuring proper handling and security measures.
@@ -203,16 +251,42 @@ Important:
 - Never update git config`, bannedCommandsStr, MaxOutputLength)
 }
 
-func NewBashTool(permission permission.Service) BaseTool {
+func NewBashTool(permission permission.Service, jobs jobs.Service) BaseTool {
 	return &bashTool{
 		permissions: permission,
+		jobs:        jobs,
+	}
+}
+
+// NewReadOnlyBashTool creates a bash tool that only runs the read-only
+// commands of plan mode, without operators that could chain or redirect them.
+func NewReadOnlyBashTool() BaseTool {
+	return &bashTool{
+		readOnly: true,
 	}
 }
 
 func (b *bashTool) Info() ToolInfo {
+	description := bashDescription()
+	if b.readOnly {
+		description += fmt.Sprintf("\n\nIMPORTANT: This shell is read-only. Only these commands are allowed, without pipes, redirections or command chaining: %s", strings.Join(planReadOnlyCommands, ", "))
+	}
+	if b.jobs != nil {
+		description += fmt.Sprintf("\n\nBackground jobs:\n- Set background to true for commands that don't end by themselves or take longer than the timeout, like dev servers, watchers and long test suites. The command runs in its own shell in the working directory and a job ID is returned right away.\n- Use %s to read the new output of a job or wait for it to end, %s to send it input or a signal, %s to list the jobs and %s to stop a job.", JobOutputToolName, JobInputToolName, JobListToolName, JobKillToolName)
+	}
+	if cfg := config.Get(); cfg != nil && cfg.Sandbox.Enabled {
+		description += "\n\nIMPORTANT: This shell runs in a sandbox. Only the working directory, the temporary directory"
+		if len(cfg.Sandbox.WritablePaths) > 0 {
+			description += fmt.Sprintf(" and %s", strings.Join(cfg.Sandbox.WritablePaths, ", "))
+		}
+		description += " can be written to."
+		if !cfg.Sandbox.Network {
+			description += " There is no network access, only servers started in the sandbox can be reached on localhost."
+		}
+	}
 	return ToolInfo{
 		Name:        BashToolName,
-		Description: bashDescription(),
+		Description: description,
 		Parameters: map[string]any{
 			"command": map[string]any{
 				"type":        "string",
@@ -222,6 +296,10 @@ func (b *bashTool) Info() ToolInfo {
 				"type":        "number",
 				"description": "Optional timeout in milliseconds (max 600000)",
 			},
+			"background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command as a background job and return its ID without waiting",
+			},
 		},
 		Required: []string{"command"},
 	}
@@ -262,20 +340,33 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		}
 	}
 
+	if b.readOnly {
+		if !isPlanReadOnly(params.Command) || strings.ContainsAny(params.Command, ";&|<>`$()\\\n\r") {
+			return NewTextErrorResponse(fmt.Sprintf("command '%s' is not allowed in read-only mode", params.Command)), nil
+		}
+		// The read-only shell never asks for permission
+		isSafeReadOnly = true
+	}
+
 	sessionID, messageID := GetContextValues(ctx)
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 	if !isSafeReadOnly {
+		sandbox := config.Get().Sandbox
 		p := b.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
 				Path:        config.WorkingDirectory(),
 				ToolName:    BashToolName,
 				Action:      "execute",
+				Agent:       GetAgentName(ctx),
+				Command:     params.Command,
+				Sandboxed:   sandbox.Enabled && sandbox.AutoApprove,
 				Description: fmt.Sprintf("Execute command: %s", params.Command),
 				Params: BashPermissionsParams{
-					Command: params.Command,
+					Command:    params.Command,
+					Background: params.Background,
 				},
 			},
 		)
@@ -284,8 +375,17 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		}
 	}
 	startTime := time.Now()
-	shell := shell.GetPersistentShell(config.WorkingDirectory())
-	stdout, stderr, exitCode, interrupted, err := shell.Exec(ctx, params.Command, params.Timeout)
+	if params.Background {
+		return b.startJob(sessionID, params.Command, startTime)
+	}
+	persistentShell := shell.GetPersistentShell(config.WorkingDirectory())
+	if persistentShell == nil {
+		return ToolResponse{}, fmt.Errorf("failed to start the shell")
+	}
+	// The output is shown while the command runs
+	stdout, stderr, exitCode, interrupted, err := persistentShell.Exec(ctx, params.Command, params.Timeout, func(_ shell.Stream, chunk string) {
+		ReportProgress(ctx, chunk)
+	})
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("error executing command: %w", err)
 	}
@@ -326,6 +426,23 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	return WithResponseMetadata(NewTextResponse(stdout), metadata), nil
 }
 
+// startJob runs a command as a background job
+func (b *bashTool) startJob(sessionID, command string, startTime time.Time) (ToolResponse, error) {
+	if b.jobs == nil {
+		return NewTextErrorResponse("background jobs are not available"), nil
+	}
+	job, err := b.jobs.Start(sessionID, command, config.WorkingDirectory())
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to start the job: %s", err)), nil
+	}
+	metadata := BashResponseMetadata{
+		StartTime: startTime.UnixMilli(),
+		EndTime:   time.Now().UnixMilli(),
+		JobID:     job.ID,
+	}
+	return WithResponseMetadata(NewTextResponse(fmt.Sprintf("Started job %s, use %s to read its output", job.ID, JobOutputToolName)), metadata), nil
+}
+
 func truncateOutput(content string) string {
 	if len(content) <= MaxOutputLength {
 		return content
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPlanReadOnly(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"ls -la", true},
+		{"git log --oneline -5", true},
+		{"git config --get user.name", true},
+		{"go mod graph", true},
+		{"printenv HOME", true},
+
+		{"env rm -rf x", false},
+		{"timeout 5 sh -c 'rm x'", false},
+		{"nohup rm x", false},
+		{"kill 1", false},
+		{"go build ./...", false},
+		{"go mod tidy", false},
+		{"go env -w GOFLAGS=-x", false},
+		{"go list -toolexec=./evil ./...", false},
+		{"git branch new", false},
+		{"git config user.name x", false},
+		{"git diff --output=main.go", false},
+		{"git log --outp main.go", false},
+		{"git status-x", false},
+		{"lsblk", false},
+		{"rm -rf x", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isPlanReadOnly(tt.command), tt.command)
+	}
+}
+
+func TestReadOnlyBashRejects(t *testing.T) {
+	tool := NewReadOnlyBashTool()
+	for _, command := range []string{
+		"env rm -rf x",
+		"ls; rm x",
+		"ls $(rm x)",
+		"echo x > main.go",
+		"ls\nrm x",
+	} {
+		input, err := json.Marshal(BashParams{Command: command})
+		require.NoError(t, err)
+		response, err := tool.Run(t.Context(), ToolCall{Input: string(input)})
+		require.NoError(t, err)
+		assert.True(t, response.IsError, command)
+		assert.Contains(t, response.Content, "not allowed in read-only mode", command)
+	}
+}
@@ -35,7 +35,7 @@ type EditResponseMetadata struct {
 }
 
 type editTool struct {
-	lspClients  map[string]*lsp.Client
+	lspClients  *lsp.Clients
 	permissions permission.Service
 	files       history.Service
 }
@@ -91,7 +91,7 @@ When making edits:
 Remember: when making multiple file edits in a row to the same file, you should prefer to send all edits in a single message with multiple calls to this tool, rather than multiple messages with a single call each.`
 )
 
-func NewEditTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service) BaseTool {
+func NewEditTool(lspClients *lsp.Clients, permissions permission.Service, files history.Service) BaseTool {
 	return &editTool{
 		lspClients:  lspClients,
 		permissions: permissions,
@@ -163,9 +163,9 @@ func (e *editTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return response, nil
 	}
 
-	waitForLspDiagnostics(ctx, params.FilePath, e.lspClients)
+	waitForLspDiagnostics(ctx, params.FilePath, e.lspClients.All())
 	text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
-	text += getDiagnostics(params.FilePath, e.lspClients)
+	text += getDiagnostics(params.FilePath, e.lspClients.All())
 	response.Content = text
 	return response, nil
 }
@@ -191,7 +191,7 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 
-	diff, additions, removals := diff.GenerateDiff(
+	fileDiff, additions, removals := diff.GenerateDiff(
 		"",
 		content,
 		filePath,
@@ -207,10 +207,12 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 			Path:        permissionPath,
 			ToolName:    EditToolName,
 			Action:      "write",
+			Agent:       GetAgentName(ctx),
+			FilePath:    filePath,
 			Description: fmt.Sprintf("Create file %s", filePath),
 			Params: EditPermissionsParams{
 				FilePath: filePath,
-				Diff:     diff,
+				Diff:     fileDiff,
 			},
 		},
 	)
@@ -223,8 +225,16 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 		return ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	// The history and the diff are the ones of the formatted file
+	result := "File created: " + filePath
+	if formatted := formatWritten(ctx, filePath, content, e.lspClients.All()); formatted != content {
+		content = formatted
+		fileDiff, additions, removals = diff.GenerateDiff("", content, filePath)
+		result += "\n" + formattedNote
+	}
+
 	// File can't be in the history so we create a new file history
-	_, err = e.files.Create(ctx, sessionID, filePath, "")
+	_, err = e.files.CreateMissing(ctx, sessionID, filePath)
 	if err != nil {
 		// Log error but don't fail the operation
 		return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -241,9 +251,9 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string)
 	recordFileRead(filePath)
 
 	return WithResponseMetadata(
-		NewTextResponse("File created: "+filePath),
+		NewTextResponse(result),
 		EditResponseMetadata{
-			Diff:      diff,
+			Diff:      fileDiff,
 			Additions: additions,
 			Removals:  removals,
 		},
@@ -301,7 +311,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
 
-	diff, additions, removals := diff.GenerateDiff(
+	fileDiff, additions, removals := diff.GenerateDiff(
 		oldContent,
 		newContent,
 		filePath,
@@ -318,10 +328,12 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 			Path:        permissionPath,
 			ToolName:    EditToolName,
 			Action:      "write",
+			Agent:       GetAgentName(ctx),
+			FilePath:    filePath,
 			Description: fmt.Sprintf("Delete content from file %s", filePath),
 			Params: EditPermissionsParams{
 				FilePath: filePath,
-				Diff:     diff,
+				Diff:     fileDiff,
 			},
 		},
 	)
@@ -334,6 +346,14 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 		return ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	// The history and the diff are the ones of the formatted file
+	result := "Content deleted from file: " + filePath
+	if formatted := formatWritten(ctx, filePath, newContent, e.lspClients.All()); formatted != newContent {
+		newContent = formatted
+		fileDiff, additions, removals = diff.GenerateDiff(oldContent, newContent, filePath)
+		result += "\n" + formattedNote
+	}
+
 	// Check if file exists in history
 	file, err := e.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
@@ -351,7 +371,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 		}
 	}
 	// Store the new version
-	_, err = e.files.CreateVersion(ctx, sessionID, filePath, "")
+	_, err = e.files.CreateVersion(ctx, sessionID, filePath, newContent)
 	if err != nil {
 		logging.Debug("Error creating file history version", "error", err)
 	}
@@ -360,9 +380,9 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	recordFileRead(filePath)
 
 	return WithResponseMetadata(
-		NewTextResponse("Content deleted from file: "+filePath),
+		NewTextResponse(result),
 		EditResponseMetadata{
-			Diff:      diff,
+			Diff:      fileDiff,
 			Additions: additions,
 			Removals:  removals,
 		},
@@ -422,7 +442,7 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
-	diff, additions, removals := diff.GenerateDiff(
+	fileDiff, additions, removals := diff.GenerateDiff(
 		oldContent,
 		newContent,
 		filePath,
@@ -438,10 +458,12 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 			Path:        permissionPath,
 			ToolName:    EditToolName,
 			Action:      "write",
+			Agent:       GetAgentName(ctx),
+			FilePath:    filePath,
 			Description: fmt.Sprintf("Replace content in file %s", filePath),
 			Params: EditPermissionsParams{
 				FilePath: filePath,
-				Diff:     diff,
+				Diff:     fileDiff,
 			},
 		},
 	)
@@ -454,6 +476,14 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 		return ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	// The history and the diff are the ones of the formatted file
+	result := "Content replaced in file: " + filePath
+	if formatted := formatWritten(ctx, filePath, newContent, e.lspClients.All()); formatted != newContent {
+		newContent = formatted
+		fileDiff, additions, removals = diff.GenerateDiff(oldContent, newContent, filePath)
+		result += "\n" + formattedNote
+	}
+
 	// Check if file exists in history
 	file, err := e.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
@@ -480,9 +510,9 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	recordFileRead(filePath)
 
 	return WithResponseMetadata(
-		NewTextResponse("Content replaced in file: "+filePath),
+		NewTextResponse(result),
 		EditResponseMetadata{
-			Diff:      diff,
+			Diff:      fileDiff,
 			Additions: additions,
 			Removals:  removals,
 		}), nil
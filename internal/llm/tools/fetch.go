@@ -127,6 +127,7 @@ func (t *fetchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 			Path:        config.WorkingDirectory(),
 			ToolName:    FetchToolName,
 			Action:      "fetch",
+			Agent:       GetAgentName(ctx),
 			Description: fmt.Sprintf("Fetch content from URL: %s", params.URL),
 			Params:      FetchPermissionsParams(params),
 		},
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/tools/shell"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/lsp"
+)
+
+// formatTimeout is how long a formatter command can run
+const formatTimeout = 30 * time.Second
+
+// formattedNote tells the model the file it wrote changed
+const formattedNote = "The file was formatted after it was written, its content differs from what you sent."
+
+// formatWritten formats a file a tool just wrote when format on write is on,
+// with the formatter of its extension or else the first language server that
+// formats it. It returns the content of the file after the formatting, or
+// content when it was not formatted. Errors are logged and leave the file as
+// the formatter left it.
+func formatWritten(ctx context.Context, filePath, content string, lspClients map[string]*lsp.Client) string {
+	cfg := config.Get()
+	if cfg == nil || !cfg.Format.OnWrite {
+		return content
+	}
+	formatted, err := formatFile(ctx, filePath, cfg.Format, lspClients)
+	if err != nil {
+		logging.Warn("Failed to format file", "path", filePath, "error", err)
+	}
+	if !formatted {
+		return content
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		logging.Warn("Failed to read formatted file", "path", filePath, "error", err)
+		return content
+	}
+	return string(data)
+}
+
+// formatFile reports whether the file may have been changed
+func formatFile(ctx context.Context, filePath string, cfg config.FormatConfig, lspClients map[string]*lsp.Client) (bool, error) {
+	if formatter := cfg.FormatterFor(filePath); formatter != nil {
+		return true, runFormatter(ctx, formatter.Command, filePath)
+	}
+	for _, name := range slices.Sorted(maps.Keys(lspClients)) {
+		client := lspClients[name]
+		if client.GetServerState() != lsp.StateReady || !client.CanFormat() || !client.HandlesFile(filePath) {
+			continue
+		}
+		return client.FormatFile(ctx, filePath)
+	}
+	return false, nil
+}
+
+// runFormatter runs a formatter command on a file, outside of the sandbox
+func runFormatter(ctx context.Context, command, filePath string) error {
+	ctx, cancel := context.WithTimeout(ctx, formatTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, shell.Path(), "-c", command)
+	cmd.Dir = config.WorkingDirectory()
+	cmd.Env = append(os.Environ(), "FILE="+filePath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWritten(t *testing.T) {
+	_, err := config.Load(".", false)
+	require.NoError(t, err)
+	cfg := config.Get()
+	previous := cfg.Format
+	t.Cleanup(func() { cfg.Format = previous })
+
+	dir := t.TempDir()
+	upper := filepath.Join(dir, "file.txt")
+	other := filepath.Join(dir, "file.md")
+	for _, path := range []string{upper, other} {
+		require.NoError(t, os.WriteFile(path, []byte("hello\n"), 0o644))
+	}
+	cfg.Format = config.FormatConfig{
+		Formatters: []config.Formatter{
+			{Extensions: []string{".TXT"}, Command: `tr a-z A-Z < "$FILE" > "$FILE.tmp" && mv "$FILE.tmp" "$FILE"`},
+		},
+	}
+
+	// Nothing is formatted unless format on write is on
+	assert.Equal(t, "hello\n", formatWritten(t.Context(), upper, "hello\n", nil))
+
+	cfg.Format.OnWrite = true
+	assert.Equal(t, "HELLO\n", formatWritten(t.Context(), upper, "hello\n", nil))
+	assert.Equal(t, "hello\n", formatWritten(t.Context(), other, "hello\n", nil))
+
+	// A failing formatter leaves the file as written
+	cfg.Format.Formatters[0].Command = "exit 1"
+	require.NoError(t, os.WriteFile(upper, []byte("hello\n"), 0o644))
+	assert.Equal(t, "hello\n", formatWritten(t.Context(), upper, "hello\n", nil))
+}
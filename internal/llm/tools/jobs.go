@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/jobs"
+)
+
+type JobOutputParams struct {
+	ID      string `json:"id"`
+	Timeout int    `json:"timeout"`
+}
+
+type JobInputParams struct {
+	ID     string `json:"id"`
+	Input  string `json:"input"`
+	Signal string `json:"signal"`
+}
+
+type JobKillParams struct {
+	ID string `json:"id"`
+}
+
+type jobOutputTool struct {
+	jobs jobs.Service
+}
+
+type jobInputTool struct {
+	jobs jobs.Service
+}
+
+type jobListTool struct {
+	jobs jobs.Service
+}
+
+type jobKillTool struct {
+	jobs jobs.Service
+}
+
+const (
+	JobOutputToolName = "job_output"
+	JobInputToolName  = "job_input"
+	JobListToolName   = "job_list"
+	JobKillToolName   = "job_kill"
+
+	// MaxJobWait is the longest job_output waits for a job to end
+	MaxJobWait = MaxTimeout
+)
+
+// signals are the signals job_input can send
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+func NewJobOutputTool(jobs jobs.Service) BaseTool {
+	return &jobOutputTool{jobs: jobs}
+}
+
+func (t *jobOutputTool) Info() ToolInfo {
+	return ToolInfo{
+		Name: JobOutputToolName,
+		Description: `Reads the output of a background job started with the bash tool, and optionally waits for the job to end.
+- Only the output written since the last read is returned, stdout and stderr interleaved
+- Set timeout to wait up to that many milliseconds for the job to end, the output is returned as soon as it ends
+- The status of the job is returned with its exit code once it ended`,
+		Parameters: map[string]any{
+			"id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job",
+			},
+			"timeout": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("Optional time to wait for the job to end in milliseconds (max %d)", MaxJobWait),
+			},
+		},
+		Required: []string{"id"},
+	}
+}
+
+func (t *jobOutputTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobOutputParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	job, err := t.jobs.Get(params.ID)
+	if err != nil {
+		return jobErrorResponse(params.ID, err)
+	}
+	if params.Timeout > 0 {
+		job, err = t.jobs.Wait(ctx, params.ID, time.Duration(min(params.Timeout, MaxJobWait))*time.Millisecond)
+		if err != nil {
+			return ToolResponse{}, err
+		}
+	}
+	output, err := t.jobs.Output(params.ID)
+	if err != nil {
+		return jobErrorResponse(params.ID, err)
+	}
+	output = truncateOutput(output)
+	if output == "" {
+		output = "no new output"
+	}
+	return NewTextResponse(fmt.Sprintf("%s\n\n%s", jobSummary(job), output)), nil
+}
+
+func NewJobInputTool(jobs jobs.Service) BaseTool {
+	return &jobInputTool{jobs: jobs}
+}
+
+func (t *jobInputTool) Info() ToolInfo {
+	return ToolInfo{
+		Name: JobInputToolName,
+		Description: `Sends input or a signal to a running background job.
+- The input is written to the standard input of the job as is, end it with a newline to send a line
+- The signal is sent to the job and the processes it started, SIGINT acts like Ctrl+C`,
+		Parameters: map[string]any{
+			"id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job",
+			},
+			"input": map[string]any{
+				"type":        "string",
+				"description": "The input to write to the job",
+			},
+			"signal": map[string]any{
+				"type":        "string",
+				"description": "The signal to send to the job",
+				"enum":        slices.Sorted(maps.Keys(signals)),
+			},
+		},
+		Required: []string{"id"},
+	}
+}
+
+func (t *jobInputTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobInputParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.Input == "" && params.Signal == "" {
+		return NewTextErrorResponse("input or signal is required"), nil
+	}
+	if params.Input != "" {
+		if err := t.jobs.Write(params.ID, params.Input); err != nil {
+			return jobErrorResponse(params.ID, err)
+		}
+	}
+	if params.Signal != "" {
+		sig, ok := signals[strings.ToUpper(params.Signal)]
+		if !ok {
+			return NewTextErrorResponse(fmt.Sprintf("unknown signal %q", params.Signal)), nil
+		}
+		if err := t.jobs.Signal(params.ID, sig); err != nil {
+			return jobErrorResponse(params.ID, err)
+		}
+	}
+	return NewTextResponse(fmt.Sprintf("Sent to job %s", params.ID)), nil
+}
+
+func NewJobListTool(jobs jobs.Service) BaseTool {
+	return &jobListTool{jobs: jobs}
+}
+
+func (t *jobListTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobListToolName,
+		Description: "Lists the background jobs started in this session with their status and command.",
+		Parameters:  map[string]any{},
+		Required:    []string{},
+	}
+}
+
+func (t *jobListTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	sessionID, _ := GetContextValues(ctx)
+	list := t.jobs.List(sessionID)
+	if len(list) == 0 {
+		return NewTextResponse("No background jobs"), nil
+	}
+	var lines []string
+	for _, job := range list {
+		lines = append(lines, fmt.Sprintf("%s: %s", jobSummary(job), job.Command))
+	}
+	return NewTextResponse(strings.Join(lines, "\n")), nil
+}
+
+func NewJobKillTool(jobs jobs.Service) BaseTool {
+	return &jobKillTool{jobs: jobs}
+}
+
+func (t *jobKillTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobKillToolName,
+		Description: "Kills a running background job and the processes it started. Use job_input with SIGINT or SIGTERM first to let the job stop cleanly.",
+		Parameters: map[string]any{
+			"id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job",
+			},
+		},
+		Required: []string{"id"},
+	}
+}
+
+func (t *jobKillTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobKillParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if err := t.jobs.Kill(params.ID); err != nil {
+		return jobErrorResponse(params.ID, err)
+	}
+	return NewTextResponse(fmt.Sprintf("Killed job %s", params.ID)), nil
+}
+
+// jobSummary describes the status of a job
+func jobSummary(job jobs.Job) string {
+	switch job.Status {
+	case jobs.StatusExited:
+		return fmt.Sprintf("Job %s exited with code %d", job.ID, job.ExitCode)
+	case jobs.StatusKilled:
+		return fmt.Sprintf("Job %s was killed by signal %s", job.ID, job.Signal)
+	default:
+		return fmt.Sprintf("Job %s is running for %s", job.ID, time.Since(time.Unix(job.StartedAt, 0)).Round(time.Second))
+	}
+}
+
+// jobErrorResponse reports the errors of the jobs to the model, like a job
+// that doesn't exist or already ended
+func jobErrorResponse(id string, err error) (ToolResponse, error) {
+	return NewTextErrorResponse(fmt.Sprintf("job %s: %s", id, err)), nil
+}
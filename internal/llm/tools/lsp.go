@@ -0,0 +1,648 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/lsp"
+	"github.com/opencode-ai/opencode/internal/lsp/protocol"
+)
+
+// LSPPositionParams locate a symbol for the language servers, by its name on
+// a line rather than by a column the model can't count
+type LSPPositionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Symbol   string `json:"symbol"`
+	Column   int    `json:"column"`
+}
+
+type LSPReferencesParams struct {
+	LSPPositionParams
+	IncludeDeclaration bool `json:"include_declaration"`
+}
+
+type LSPSymbolsParams struct {
+	FilePath string `json:"file_path"`
+	Query    string `json:"query"`
+}
+
+type LSPCallsParams struct {
+	LSPPositionParams
+	Direction string `json:"direction"`
+}
+
+type lspDefinitionTool struct {
+	lspClients *lsp.Clients
+}
+
+type lspImplementationTool struct {
+	lspClients *lsp.Clients
+}
+
+type lspReferencesTool struct {
+	lspClients *lsp.Clients
+}
+
+type lspHoverTool struct {
+	lspClients *lsp.Clients
+}
+
+type lspSymbolsTool struct {
+	lspClients *lsp.Clients
+}
+
+type lspCallsTool struct {
+	lspClients *lsp.Clients
+}
+
+const (
+	LSPDefinitionToolName     = "lsp_definition"
+	LSPImplementationToolName = "lsp_implementation"
+	LSPReferencesToolName     = "lsp_references"
+	LSPHoverToolName          = "lsp_hover"
+	LSPSymbolsToolName        = "lsp_symbols"
+	LSPCallsToolName          = "lsp_calls"
+
+	// maxLSPResults is the number of locations or symbols returned
+	maxLSPResults = 100
+
+	lspPositionHelp = `The symbol is given by the file, the line and its name on the line, like a function, type or variable name. For qualified names like pkg.Func, the last part is used.`
+)
+
+func positionParameters() map[string]any {
+	return map[string]any{
+		"file_path": map[string]any{
+			"type":        "string",
+			"description": "The path to the file",
+		},
+		"line": map[string]any{
+			"type":        "integer",
+			"description": "The line of the symbol, starting at 1",
+		},
+		"symbol": map[string]any{
+			"type":        "string",
+			"description": "The name of the symbol on the line",
+		},
+		"column": map[string]any{
+			"type":        "integer",
+			"description": "The column of the symbol, starting at 1, only used without symbol",
+		},
+	}
+}
+
+func NewLSPDefinitionTool(lspClients *lsp.Clients) BaseTool {
+	return &lspDefinitionTool{lspClients: lspClients}
+}
+
+func (t *lspDefinitionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name: LSPDefinitionToolName,
+		Description: `Finds where a symbol is defined, with the language server of the file. More precise than grep for functions, types, methods and variables.
+` + lspPositionHelp + `
+Returns the definitions as path:line with the code at the line.`,
+		Parameters: positionParameters(),
+		Required:   []string{"file_path", "line"},
+	}
+}
+
+func (t *lspDefinitionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPPositionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path, position, err := params.position()
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return queryLSP(ctx, t.lspClients.All(), path, func(client *lsp.Client) ([]string, error) {
+		result, err := client.Definition(ctx, protocol.DefinitionParams{
+			TextDocumentPositionParams: textDocumentPosition(path, position),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return sourceLines{}.locations(definitionLocations(result.Value)), nil
+	})
+}
+
+func NewLSPImplementationTool(lspClients *lsp.Clients) BaseTool {
+	return &lspImplementationTool{lspClients: lspClients}
+}
+
+func (t *lspImplementationTool) Info() ToolInfo {
+	return ToolInfo{
+		Name: LSPImplementationToolName,
+		Description: `Finds the implementations of an interface or of an interface method, or the interfaces a type implements, with the language server of the file.
+` + lspPositionHelp + `
+Returns the implementations as path:line with the code at the line.`,
+		Parameters: positionParameters(),
+		Required:   []string{"file_path", "line"},
+	}
+}
+
+func (t *lspImplementationTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPPositionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path, position, err := params.position()
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return queryLSP(ctx, t.lspClients.All(), path, func(client *lsp.Client) ([]string, error) {
+		result, err := client.Implementation(ctx, protocol.ImplementationParams{
+			TextDocumentPositionParams: textDocumentPosition(path, position),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return sourceLines{}.locations(definitionLocations(result.Value)), nil
+	})
+}
+
+func NewLSPReferencesTool(lspClients *lsp.Clients) BaseTool {
+	return &lspReferencesTool{lspClients: lspClients}
+}
+
+func (t *lspReferencesTool) Info() ToolInfo {
+	parameters := positionParameters()
+	parameters["include_declaration"] = map[string]any{
+		"type":        "boolean",
+		"description": "Include the declaration of the symbol in the results",
+	}
+	return ToolInfo{
+		Name: LSPReferencesToolName,
+		Description: fmt.Sprintf(`Finds the references to a symbol in the workspace, with the language server of the file. Unlike grep, it skips other symbols of the same name.
+%s
+Returns the references as path:line with the code at the line, sorted by file, up to %d.`, lspPositionHelp, maxLSPResults),
+		Parameters: parameters,
+		Required:   []string{"file_path", "line"},
+	}
+}
+
+func (t *lspReferencesTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPReferencesParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path, position, err := params.position()
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return queryLSP(ctx, t.lspClients.All(), path, func(client *lsp.Client) ([]string, error) {
+		locations, err := client.References(ctx, protocol.ReferenceParams{
+			TextDocumentPositionParams: textDocumentPosition(path, position),
+			Context:                    protocol.ReferenceContext{IncludeDeclaration: params.IncludeDeclaration},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return sourceLines{}.locations(locations), nil
+	})
+}
+
+func NewLSPHoverTool(lspClients *lsp.Clients) BaseTool {
+	return &lspHoverTool{lspClients: lspClients}
+}
+
+func (t *lspHoverTool) Info() ToolInfo {
+	return ToolInfo{
+		Name: LSPHoverToolName,
+		Description: `Shows the type, signature and documentation of a symbol, with the language server of the file. Use it to learn the type of a variable or the signature of a function without reading its definition.
+` + lspPositionHelp,
+		Parameters: positionParameters(),
+		Required:   []string{"file_path", "line"},
+	}
+}
+
+func (t *lspHoverTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPPositionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	path, position, err := params.position()
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return queryLSP(ctx, t.lspClients.All(), path, func(client *lsp.Client) ([]string, error) {
+		hover, err := client.Hover(ctx, protocol.HoverParams{
+			TextDocumentPositionParams: textDocumentPosition(path, position),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if contents := strings.TrimSpace(hover.Contents.Value); contents != "" {
+			return []string{contents}, nil
+		}
+		return nil, nil
+	})
+}
+
+func NewLSPSymbolsTool(lspClients *lsp.Clients) BaseTool {
+	return &lspSymbolsTool{lspClients: lspClients}
+}
+
+func (t *lspSymbolsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name: LSPSymbolsToolName,
+		Description: fmt.Sprintf(`Lists symbols with the language servers.
+- With file_path, returns the outline of the file: its types, functions, methods and fields with their lines
+- With query, searches the symbols of the workspace by name, like "where is X defined" when the file is unknown
+- With both, only the symbols of the file containing query are kept
+Returns up to %d symbols.`, maxLSPResults),
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The file to list the symbols of",
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The name of the symbols to search in the workspace",
+			},
+		},
+		Required: []string{},
+	}
+}
+
+func (t *lspSymbolsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPSymbolsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.FilePath == "" && params.Query == "" {
+		return NewTextErrorResponse("file_path or query is required"), nil
+	}
+
+	if params.FilePath == "" {
+		return queryLSP(ctx, t.lspClients.All(), "", func(client *lsp.Client) ([]string, error) {
+			result, err := client.Symbol(ctx, protocol.WorkspaceSymbolParams{Query: params.Query})
+			if err != nil {
+				return nil, err
+			}
+			return workspaceSymbolLines(result), nil
+		})
+	}
+
+	path := resolvePath(params.FilePath)
+	if _, err := os.Stat(path); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("file not found: %s", path)), nil
+	}
+	return queryLSP(ctx, t.lspClients.All(), path, func(client *lsp.Client) ([]string, error) {
+		result, err := client.DocumentSymbol(ctx, protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: fileURI(path)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return documentSymbolLines(result, params.Query), nil
+	})
+}
+
+func NewLSPCallsTool(lspClients *lsp.Clients) BaseTool {
+	return &lspCallsTool{lspClients: lspClients}
+}
+
+func (t *lspCallsTool) Info() ToolInfo {
+	parameters := positionParameters()
+	parameters["direction"] = map[string]any{
+		"type":        "string",
+		"description": "incoming for the callers of the function, outgoing for the functions it calls",
+		"enum":        []string{"incoming", "outgoing"},
+	}
+	return ToolInfo{
+		Name: LSPCallsToolName,
+		Description: fmt.Sprintf(`Finds who calls a function or method, or what it calls, with the language server of the file.
+%s
+Incoming calls are returned as the call sites with the calling function, outgoing calls as the called functions with their definitions, up to %d.`, lspPositionHelp, maxLSPResults),
+		Parameters: parameters,
+		Required:   []string{"file_path", "line", "direction"},
+	}
+}
+
+func (t *lspCallsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPCallsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.Direction != "incoming" && params.Direction != "outgoing" {
+		return NewTextErrorResponse("direction must be incoming or outgoing"), nil
+	}
+	path, position, err := params.position()
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return queryLSP(ctx, t.lspClients.All(), path, func(client *lsp.Client) ([]string, error) {
+		items, err := client.PrepareCallHierarchy(ctx, protocol.CallHierarchyPrepareParams{
+			TextDocumentPositionParams: textDocumentPosition(path, position),
+		})
+		if err != nil {
+			return nil, err
+		}
+		source := sourceLines{}
+		var lines []string
+		for _, item := range items {
+			if params.Direction == "incoming" {
+				calls, err := client.IncomingCalls(ctx, protocol.CallHierarchyIncomingCallsParams{Item: item})
+				if err != nil {
+					return nil, err
+				}
+				for _, call := range calls {
+					for _, r := range call.FromRanges {
+						lines = append(lines, fmt.Sprintf("%s (in %s)", source.location(call.From.URI, r), call.From.Name))
+					}
+				}
+				continue
+			}
+			calls, err := client.OutgoingCalls(ctx, protocol.CallHierarchyOutgoingCallsParams{Item: item})
+			if err != nil {
+				return nil, err
+			}
+			for _, call := range calls {
+				lines = append(lines, fmt.Sprintf("%s: %s", call.To.Name, source.location(call.To.URI, call.To.SelectionRange)))
+			}
+		}
+		return lines, nil
+	})
+}
+
+// position resolves the symbol to the position the servers expect, where
+// the columns count UTF-16 code units
+func (p LSPPositionParams) position() (string, protocol.Position, error) {
+	if p.FilePath == "" {
+		return "", protocol.Position{}, fmt.Errorf("file_path is required")
+	}
+	if p.Line < 1 {
+		return "", protocol.Position{}, fmt.Errorf("line must start at 1")
+	}
+	path := resolvePath(p.FilePath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", protocol.Position{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if p.Line > len(lines) {
+		return "", protocol.Position{}, fmt.Errorf("line %d is past the end of the file, which has %d lines", p.Line, len(lines))
+	}
+	line := strings.TrimSuffix(lines[p.Line-1], "\r")
+
+	var offset int
+	switch {
+	case p.Symbol != "":
+		offset = symbolOffset(line, p.Symbol)
+		if offset < 0 {
+			return "", protocol.Position{}, fmt.Errorf("symbol %q not found on line %d: %s", p.Symbol, p.Line, strings.TrimSpace(line))
+		}
+	case p.Column > 0:
+		offset = len(line)
+		for i := range line {
+			if p.Column == 1 {
+				offset = i
+				break
+			}
+			p.Column--
+		}
+	default:
+		return "", protocol.Position{}, fmt.Errorf("symbol or column is required")
+	}
+	return path, protocol.Position{
+		Line:      uint32(p.Line - 1),
+		Character: uint32(len(utf16.Encode([]rune(line[:offset])))),
+	}, nil
+}
+
+// symbolOffset returns the offset of the first occurrence of the symbol on
+// the line that is not part of a longer identifier, or of its last part for
+// qualified names
+func symbolOffset(line, symbol string) int {
+	for start := 0; start < len(line); {
+		i := strings.Index(line[start:], symbol)
+		if i < 0 {
+			return -1
+		}
+		i += start
+		end := i + len(symbol)
+		before, _ := utf8.DecodeLastRuneInString(line[:i])
+		after, _ := utf8.DecodeRuneInString(line[end:])
+		if !isIdentifierRune(before) && !isIdentifierRune(after) {
+			if dot := strings.LastIndex(symbol, "."); dot >= 0 {
+				return i + dot + 1
+			}
+			return i
+		}
+		start = i + 1
+	}
+	return -1
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// queryLSP asks the ready servers of the file in turn, until one of them
+// returns results. Without a file, every ready server is asked.
+func queryLSP(ctx context.Context, lspClients map[string]*lsp.Client, path string, query func(client *lsp.Client) ([]string, error)) (ToolResponse, error) {
+	clients := readyClients(lspClients, path)
+	if len(clients) == 0 {
+		if path == "" {
+			return NewTextErrorResponse("no language server is ready"), nil
+		}
+		return NewTextErrorResponse(fmt.Sprintf("no language server is ready for %s", path)), nil
+	}
+
+	var errs []string
+	for _, client := range clients {
+		if path != "" {
+			if err := client.OpenFileOnDemand(ctx, path); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+		}
+		lines, err := query(client)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if len(lines) > 0 {
+			if len(lines) > maxLSPResults {
+				lines = append(lines[:maxLSPResults], fmt.Sprintf("... and %d more", len(lines)-maxLSPResults))
+			}
+			return NewTextResponse(strings.Join(lines, "\n")), nil
+		}
+	}
+	if len(errs) > 0 {
+		return NewTextErrorResponse("language server error: " + strings.Join(errs, "; ")), nil
+	}
+	return NewTextResponse("No results"), nil
+}
+
+// readyClients returns the ready servers handling a file, sorted by name.
+// Without a file, every ready server is returned.
+func readyClients(lspClients map[string]*lsp.Client, path string) []*lsp.Client {
+	var clients []*lsp.Client
+	for _, name := range slices.Sorted(maps.Keys(lspClients)) {
+		client := lspClients[name]
+		if client.GetServerState() == lsp.StateReady && (path == "" || client.HandlesFile(path)) {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+func resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(config.WorkingDirectory(), path)
+}
+
+func fileURI(path string) protocol.DocumentUri {
+	return protocol.DocumentUri("file://" + path)
+}
+
+func textDocumentPosition(path string, position protocol.Position) protocol.TextDocumentPositionParams {
+	return protocol.TextDocumentPositionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: fileURI(path)},
+		Position:     position,
+	}
+}
+
+// definitionLocations flattens the results of the definition and
+// implementation requests
+func definitionLocations(value any) []protocol.Location {
+	switch v := value.(type) {
+	case protocol.Definition:
+		switch d := v.Value.(type) {
+		case protocol.Location:
+			return []protocol.Location{d}
+		case []protocol.Location:
+			return d
+		}
+	case []protocol.DefinitionLink:
+		locations := make([]protocol.Location, 0, len(v))
+		for _, link := range v {
+			locations = append(locations, protocol.Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+		}
+		return locations
+	}
+	return nil
+}
+
+// documentSymbolLines renders the outline of a file, the children indented
+// under their parent. With a query, only the symbols containing it are kept.
+func documentSymbolLines(result protocol.Or_Result_textDocument_documentSymbol, query string) []string {
+	var lines []string
+	var walk func(symbols []protocol.DocumentSymbol, depth int)
+	walk = func(symbols []protocol.DocumentSymbol, depth int) {
+		for _, symbol := range symbols {
+			if query == "" || strings.Contains(strings.ToLower(symbol.Name), strings.ToLower(query)) {
+				line := fmt.Sprintf("%s%s %s, line %d", strings.Repeat("  ", depth), protocol.TableKindMap[symbol.Kind], symbol.Name, symbol.SelectionRange.Start.Line+1)
+				if symbol.Detail != "" {
+					line += ": " + symbol.Detail
+				}
+				lines = append(lines, line)
+			}
+			walk(symbol.Children, depth+1)
+		}
+	}
+	switch v := result.Value.(type) {
+	case []protocol.DocumentSymbol:
+		walk(v, 0)
+	case []protocol.SymbolInformation:
+		for _, symbol := range v {
+			if query == "" || strings.Contains(strings.ToLower(symbol.Name), strings.ToLower(query)) {
+				lines = append(lines, fmt.Sprintf("%s %s, line %d", protocol.TableKindMap[symbol.Kind], symbol.Name, symbol.Location.Range.Start.Line+1))
+			}
+		}
+	}
+	return lines
+}
+
+func workspaceSymbolLines(result protocol.Or_Result_workspace_symbol) []string {
+	symbols, err := result.Results()
+	if err != nil {
+		return nil
+	}
+	lines := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		var kind protocol.SymbolKind
+		var container string
+		switch s := symbol.(type) {
+		case *protocol.WorkspaceSymbol:
+			kind, container = s.Kind, s.ContainerName
+		case *protocol.SymbolInformation:
+			kind, container = s.Kind, s.ContainerName
+		}
+		name := symbol.GetName()
+		if container != "" {
+			name = container + "." + name
+		}
+		location := symbol.GetLocation()
+		lines = append(lines, fmt.Sprintf("%s %s: %s:%d", protocol.TableKindMap[kind], name, displayPath(uriPath(location.URI)), location.Range.Start.Line+1))
+	}
+	return lines
+}
+
+// sourceLines caches the lines of the files of the locations
+type sourceLines map[string][]string
+
+// locations renders locations as path:line with the code at the line, sorted
+// by file and line
+func (s sourceLines) locations(locations []protocol.Location) []string {
+	slices.SortStableFunc(locations, func(a, b protocol.Location) int {
+		if c := strings.Compare(string(a.URI), string(b.URI)); c != 0 {
+			return c
+		}
+		return int(a.Range.Start.Line) - int(b.Range.Start.Line)
+	})
+	lines := make([]string, 0, len(locations))
+	for _, location := range locations {
+		lines = append(lines, s.location(location.URI, location.Range))
+	}
+	return lines
+}
+
+func (s sourceLines) location(uri protocol.DocumentUri, r protocol.Range) string {
+	path := uriPath(uri)
+	lines, ok := s[path]
+	if !ok {
+		if content, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		s[path] = lines
+	}
+	location := fmt.Sprintf("%s:%d", displayPath(path), r.Start.Line+1)
+	if line := int(r.Start.Line); line < len(lines) {
+		if code := strings.TrimSpace(lines[line]); code != "" {
+			location += ": " + code
+		}
+	}
+	return location
+}
+
+// uriPath returns the path of file URIs, other URIs like the ones of the
+// files in archives are kept as they are
+func uriPath(uri protocol.DocumentUri) string {
+	if !strings.HasPrefix(string(uri), "file://") {
+		return string(uri)
+	}
+	return uri.Path()
+}
+
+// displayPath makes the paths of the working directory relative to it
+func displayPath(path string) string {
+	if rel, err := filepath.Rel(config.WorkingDirectory(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -0,0 +1,639 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/diff"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/lsp"
+	"github.com/opencode-ai/opencode/internal/lsp/protocol"
+	"github.com/opencode-ai/opencode/internal/lsp/util"
+	"github.com/opencode-ai/opencode/internal/permission"
+)
+
+type LSPRenameParams struct {
+	LSPPositionParams
+	NewName string `json:"new_name"`
+}
+
+type LSPCodeActionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	EndLine  int    `json:"end_line"`
+	Action   string `json:"action"`
+}
+
+// WorkspaceEditPermissionsParams are the diffs of the files a language server
+// changes together
+type WorkspaceEditPermissionsParams struct {
+	Files []EditPermissionsParams `json:"files"`
+}
+
+// LSPCommandPermissionsParams are the command a language server is asked to
+// run for a code action
+type LSPCommandPermissionsParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+type WorkspaceEditResponseMetadata struct {
+	FilesChanged []string `json:"files_changed"`
+	Additions    int      `json:"additions"`
+	Removals     int      `json:"removals"`
+}
+
+// workspaceEditor applies the edits of the language servers, like the other
+// editing tools apply theirs
+type workspaceEditor struct {
+	lspClients  *lsp.Clients
+	permissions permission.Service
+	files       history.Service
+}
+
+type lspRenameTool struct {
+	workspaceEditor
+}
+
+type lspCodeActionTool struct {
+	workspaceEditor
+}
+
+const (
+	LSPRenameToolName     = "lsp_rename"
+	LSPCodeActionToolName = "lsp_code_action"
+)
+
+func NewLSPRenameTool(lspClients *lsp.Clients, permissions permission.Service, files history.Service) BaseTool {
+	return &lspRenameTool{workspaceEditor{lspClients: lspClients, permissions: permissions, files: files}}
+}
+
+func (t *lspRenameTool) Info() ToolInfo {
+	parameters := positionParameters()
+	parameters["new_name"] = map[string]any{
+		"type":        "string",
+		"description": "The new name of the symbol",
+	}
+	return ToolInfo{
+		Name: LSPRenameToolName,
+		Description: `Renames a symbol everywhere it is used in the workspace, with the language server of the file. Use it instead of editing each use of a function, type, method, field or variable: it updates the references the server knows about in every file, as a single change.
+` + lspPositionHelp + `
+Returns the files changed and the diagnostics after the change.`,
+		Parameters: parameters,
+		Required:   []string{"file_path", "line", "new_name"},
+	}
+}
+
+func (t *lspRenameTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPRenameParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.NewName == "" {
+		return NewTextErrorResponse("new_name is required"), nil
+	}
+	path, position, err := params.position()
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	clients := slices.DeleteFunc(readyClients(t.lspClients.All(), path), func(client *lsp.Client) bool {
+		rename, _ := client.CanRename()
+		return !rename
+	})
+	if len(clients) == 0 {
+		return NewTextErrorResponse(fmt.Sprintf("no language server ready for %s renames symbols", path)), nil
+	}
+	client := clients[0]
+	if err := client.OpenFileOnDemand(ctx, path); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("language server error: %s", err)), nil
+	}
+
+	symbol := params.Symbol
+	if symbol == "" {
+		symbol = fmt.Sprintf("the symbol at line %d", params.Line)
+	}
+	if _, prepare := client.CanRename(); prepare {
+		result, err := client.PrepareRename(ctx, protocol.PrepareRenameParams{
+			TextDocumentPositionParams: textDocumentPosition(path, position),
+		})
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("cannot rename %s: %s", symbol, err)), nil
+		}
+		if result.Value == nil {
+			return NewTextErrorResponse(fmt.Sprintf("cannot rename %s, it is not a symbol the language server renames", symbol)), nil
+		}
+	}
+	edit, err := client.Rename(ctx, protocol.RenameParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: fileURI(path)},
+		Position:     position,
+		NewName:      params.NewName,
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("cannot rename %s: %s", symbol, err)), nil
+	}
+	return t.apply(ctx, LSPRenameToolName, fmt.Sprintf("Rename %s to %s", symbol, params.NewName), path, edit)
+}
+
+func NewLSPCodeActionTool(lspClients *lsp.Clients, permissions permission.Service, files history.Service) BaseTool {
+	return &lspCodeActionTool{workspaceEditor{lspClients: lspClients, permissions: permissions, files: files}}
+}
+
+func (t *lspCodeActionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name: LSPCodeActionToolName,
+		Description: `Lists or applies the code actions of the language server of a file on a range of lines: quick fixes for the diagnostics of the lines, like adding a missing import, and refactorings, like extracting a function or organizing the imports.
+- Without action, returns the titles of the actions available on the lines
+- With action set to one of these titles, applies the action as a single change, which may span several files
+Returns the files changed and the diagnostics after the change.`,
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path to the file",
+			},
+			"line": map[string]any{
+				"type":        "integer",
+				"description": "The first line of the range, starting at 1",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "The last line of the range, defaults to line",
+			},
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The title of the action to apply, as listed without it",
+			},
+		},
+		Required: []string{"file_path", "line"},
+	}
+}
+
+// codeAction is an action offered by a server, commands are kept as actions
+// running them
+type codeAction struct {
+	client *lsp.Client
+	action protocol.CodeAction
+}
+
+func (t *lspCodeActionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params LSPCodeActionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if params.Line < 1 {
+		return NewTextErrorResponse("line must start at 1"), nil
+	}
+	if params.EndLine == 0 {
+		params.EndLine = params.Line
+	}
+	if params.EndLine < params.Line {
+		return NewTextErrorResponse("end_line must not be before line"), nil
+	}
+	path := resolvePath(params.FilePath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to read %s: %s", path, err)), nil
+	}
+	lines := strings.Split(string(content), "\n")
+	if params.EndLine > len(lines) {
+		return NewTextErrorResponse(fmt.Sprintf("line %d is past the end of the file, which has %d lines", params.EndLine, len(lines))), nil
+	}
+	lastLine := strings.TrimSuffix(lines[params.EndLine-1], "\r")
+	lineRange := protocol.Range{
+		Start: protocol.Position{Line: uint32(params.Line - 1)},
+		End:   protocol.Position{Line: uint32(params.EndLine - 1), Character: uint32(len(utf16.Encode([]rune(lastLine))))},
+	}
+
+	clients := slices.DeleteFunc(readyClients(t.lspClients.All(), path), func(client *lsp.Client) bool {
+		return !client.CanCodeAction()
+	})
+	if len(clients) == 0 {
+		return NewTextErrorResponse(fmt.Sprintf("no language server ready for %s provides code actions", path)), nil
+	}
+	var actions []codeAction
+	var errs []string
+	for _, client := range clients {
+		if err := client.OpenFileOnDemand(ctx, path); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		// The diagnostics of the lines get their quick fixes
+		var diagnostics []protocol.Diagnostic
+		for _, diagnostic := range client.GetFileDiagnostics(fileURI(path)) {
+			if diagnostic.Range.Start.Line <= lineRange.End.Line && diagnostic.Range.End.Line >= lineRange.Start.Line {
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+		triggerKind := protocol.CodeActionInvoked
+		result, err := client.CodeAction(ctx, protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: fileURI(path)},
+			Range:        lineRange,
+			Context: protocol.CodeActionContext{
+				Diagnostics: diagnostics,
+				TriggerKind: &triggerKind,
+			},
+		})
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, item := range result {
+			switch v := item.Value.(type) {
+			case protocol.CodeAction:
+				actions = append(actions, codeAction{client: client, action: v})
+			case protocol.Command:
+				actions = append(actions, codeAction{client: client, action: protocol.CodeAction{Title: v.Title, Command: &v}})
+			}
+		}
+	}
+	if len(actions) == 0 {
+		if len(errs) > 0 {
+			return NewTextErrorResponse("language server error: " + strings.Join(errs, "; ")), nil
+		}
+		return NewTextResponse(fmt.Sprintf("No code actions on %s", lineRangeText(params))), nil
+	}
+
+	if params.Action == "" {
+		return NewTextResponse(fmt.Sprintf("Code actions on %s of %s:\n%s\nCall %s again with action set to the title of the one to apply.",
+			lineRangeText(params), displayPath(path), codeActionList(actions), LSPCodeActionToolName)), nil
+	}
+	index := slices.IndexFunc(actions, func(a codeAction) bool { return a.action.Title == params.Action })
+	if index < 0 {
+		index = slices.IndexFunc(actions, func(a codeAction) bool { return strings.EqualFold(a.action.Title, params.Action) })
+	}
+	if index < 0 {
+		return NewTextErrorResponse(fmt.Sprintf("no code action titled %q on %s, the actions are:\n%s", params.Action, lineRangeText(params), codeActionList(actions))), nil
+	}
+	client, action := actions[index].client, actions[index].action
+	if action.Disabled != nil {
+		return NewTextErrorResponse(fmt.Sprintf("the code action %q is disabled: %s", action.Title, action.Disabled.Reason)), nil
+	}
+
+	// The servers may leave the edit to be resolved once the action is chosen
+	if action.Edit == nil && action.Data != nil {
+		action, err = client.ResolveCodeAction(ctx, action)
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("failed to resolve the code action %q: %s", params.Action, err)), nil
+		}
+	}
+	var edits []protocol.WorkspaceEdit
+	if action.Edit != nil {
+		edits = append(edits, *action.Edit)
+	}
+	if action.Command != nil {
+		// The server runs the command itself and may change files without
+		// asking, so it runs only once allowed. The edits it asks to apply
+		// are still reviewed with the others.
+		if !t.allowCommand(ctx, action.Title, path, *action.Command) {
+			return ToolResponse{}, permission.ErrorPermissionDenied
+		}
+		commandEdits, err := client.ExecuteCommandEdits(ctx, *action.Command)
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("failed to run the code action %q: %s", action.Title, err)), nil
+		}
+		edits = append(edits, commandEdits...)
+	}
+	return t.apply(ctx, LSPCodeActionToolName, action.Title, path, edits...)
+}
+
+func lineRangeText(params LSPCodeActionParams) string {
+	if params.EndLine == params.Line {
+		return fmt.Sprintf("line %d", params.Line)
+	}
+	return fmt.Sprintf("lines %d-%d", params.Line, params.EndLine)
+}
+
+func codeActionList(actions []codeAction) string {
+	lines := make([]string, 0, len(actions))
+	for _, a := range actions {
+		var notes []string
+		if a.action.Kind != "" {
+			notes = append(notes, string(a.action.Kind))
+		}
+		if a.action.IsPreferred {
+			notes = append(notes, "preferred")
+		}
+		if a.action.Disabled != nil {
+			notes = append(notes, "disabled: "+a.action.Disabled.Reason)
+		}
+		line := "- " + a.action.Title
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, ", ") + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// allowCommand asks to let a language server run the command of a code action
+func (w workspaceEditor) allowCommand(ctx context.Context, title, filePath string, command protocol.Command) bool {
+	sessionID, _ := GetContextValues(ctx)
+	arguments, _ := json.Marshal(command.Arguments)
+	return w.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        permissionDir(filePath),
+			ToolName:    LSPCodeActionToolName,
+			Action:      "execute",
+			Agent:       GetAgentName(ctx),
+			FilePath:    filePath,
+			Description: fmt.Sprintf("Let the language server run the command %s of the code action %q, with the arguments %s", command.Command, title, arguments),
+			Params:      LSPCommandPermissionsParams{Command: command.Command, Arguments: command.Arguments},
+		},
+	)
+}
+
+// permissionDir is the directory of the permission of a change starting from
+// a file, the working directory for the files of the project
+func permissionDir(filePath string) string {
+	if rootDir := config.WorkingDirectory(); strings.HasPrefix(filePath, rootDir) {
+		return rootDir
+	}
+	return filepath.Dir(filePath)
+}
+
+// apply asks to apply the changes of the files of workspace edits as a
+// single change, writes them and records them in the history
+func (w workspaceEditor) apply(ctx context.Context, toolName, description, filePath string, edits ...protocol.WorkspaceEdit) (ToolResponse, error) {
+	changes, err := workspaceFileChanges(edits...)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to apply the changes of the language server: %s", err)), nil
+	}
+	if len(changes) == 0 {
+		return NewTextResponse("No file needed changes"), nil
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for applying a change")
+	}
+
+	diffs := make([]EditPermissionsParams, 0, len(changes))
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fileDiff, _, _ := diff.GenerateDiff(change.OldContent, change.NewContent, change.Path)
+		diffs = append(diffs, EditPermissionsParams{FilePath: change.Path, Diff: fileDiff})
+		paths = append(paths, change.Path)
+	}
+	// The rules are checked against each changed file, a file denied denies
+	// the whole change
+	p := w.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        permissionDir(filePath),
+			ToolName:    toolName,
+			Action:      "write",
+			Agent:       GetAgentName(ctx),
+			FilePath:    filePath,
+			FilePaths:   paths,
+			Description: fmt.Sprintf("%s, changing %d files", description, len(changes)),
+			Params:      WorkspaceEditPermissionsParams{Files: diffs},
+		},
+	)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	for _, change := range changes {
+		if change.Deleted {
+			if err := os.Remove(change.Path); err != nil {
+				return ToolResponse{}, fmt.Errorf("failed to delete file %s: %w", change.Path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(change.Path), 0o755); err != nil {
+			return ToolResponse{}, fmt.Errorf("failed to create parent directories for %s: %w", change.Path, err)
+		}
+		if err := os.WriteFile(change.Path, []byte(change.NewContent), 0o644); err != nil {
+			return ToolResponse{}, fmt.Errorf("failed to write file %s: %w", change.Path, err)
+		}
+	}
+
+	metadata := WorkspaceEditResponseMetadata{}
+	var summary []string
+	for _, change := range changes {
+		_, additions, removals := diff.GenerateDiff(change.OldContent, change.NewContent, change.Path)
+		metadata.FilesChanged = append(metadata.FilesChanged, change.Path)
+		metadata.Additions += additions
+		metadata.Removals += removals
+		switch {
+		case change.Created:
+			summary = append(summary, fmt.Sprintf("- %s (created)", displayPath(change.Path)))
+		case change.Deleted:
+			summary = append(summary, fmt.Sprintf("- %s (deleted)", displayPath(change.Path)))
+		default:
+			summary = append(summary, fmt.Sprintf("- %s (+%d -%d)", displayPath(change.Path), additions, removals))
+		}
+
+		file, err := w.files.GetByPathAndSession(ctx, change.Path, sessionID)
+		if err != nil {
+			// Create the history entry of the file, a created one didn't exist
+			if change.Created {
+				_, err = w.files.CreateMissing(ctx, sessionID, change.Path)
+			} else {
+				_, err = w.files.Create(ctx, sessionID, change.Path, change.OldContent)
+			}
+			if err != nil {
+				logging.Debug("Error creating file history", "error", err)
+			}
+		}
+		if err == nil && !change.Created && file.Content != change.OldContent {
+			// User manually changed content, store intermediate version
+			_, err = w.files.CreateVersion(ctx, sessionID, change.Path, change.OldContent)
+			if err != nil {
+				logging.Debug("Error creating file history version", "error", err)
+			}
+		}
+		if change.Deleted {
+			_, err = w.files.CreateMissing(ctx, sessionID, change.Path)
+		} else {
+			_, err = w.files.CreateVersion(ctx, sessionID, change.Path, change.NewContent)
+		}
+		if err != nil {
+			logging.Debug("Error creating file history version", "error", err)
+		}
+
+		recordFileWrite(change.Path)
+		recordFileRead(change.Path)
+	}
+
+	// The servers learn about the other files they have open, the diagnostics
+	// are the ones of the file of the change
+	for _, change := range changes {
+		if change.Path == filePath {
+			continue
+		}
+		for _, client := range w.lspClients.All() {
+			if !client.IsFileOpen(change.Path) {
+				continue
+			}
+			if change.Deleted {
+				err = client.CloseFile(ctx, change.Path)
+			} else {
+				err = client.NotifyChange(ctx, change.Path)
+			}
+			if err != nil {
+				logging.Debug("Error notifying the language server of a change", "path", change.Path, "error", err)
+			}
+		}
+	}
+	waitForLspDiagnostics(ctx, filePath, w.lspClients.All())
+
+	result := fmt.Sprintf("%s: %d files changed, %d additions, %d removals\n%s",
+		description, len(changes), metadata.Additions, metadata.Removals, strings.Join(summary, "\n"))
+	if diagnostics := getDiagnostics(filePath, w.lspClients.All()); diagnostics != "" {
+		result += "\n\nDiagnostics:\n" + diagnostics
+	}
+	return WithResponseMetadata(NewTextResponse(result), metadata), nil
+}
+
+// workspaceFileChange is the change of a file by workspace edits
+type workspaceFileChange struct {
+	Path       string
+	OldContent string
+	NewContent string
+	Created    bool
+	Deleted    bool
+}
+
+// workspaceFileChanges computes the changes of the files of workspace edits,
+// applied in turn, without writing them. The changes are sorted by path.
+func workspaceFileChanges(edits ...protocol.WorkspaceEdit) ([]workspaceFileChange, error) {
+	// The contents of the files, nil for the ones that don't exist
+	original := map[string]*string{}
+	current := map[string]*string{}
+	load := func(uri protocol.DocumentUri) (string, *string, error) {
+		path := uriPath(uri)
+		if content, ok := current[path]; ok {
+			return path, content, nil
+		}
+		var content *string
+		data, err := os.ReadFile(path)
+		if err == nil {
+			s := string(data)
+			content = &s
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return path, nil, err
+		}
+		original[path], current[path] = content, content
+		return path, content, nil
+	}
+	editFile := func(uri protocol.DocumentUri, textEdits []protocol.TextEdit) error {
+		path, content, err := load(uri)
+		if err != nil {
+			return err
+		}
+		if content == nil {
+			return fmt.Errorf("file not found: %s", path)
+		}
+		newContent, err := util.EditContent(*content, textEdits)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		current[path] = &newContent
+		return nil
+	}
+
+	for _, edit := range edits {
+		// The document changes replace the changes when there are both
+		if len(edit.DocumentChanges) == 0 {
+			for _, uri := range slices.Sorted(maps.Keys(edit.Changes)) {
+				if err := editFile(uri, edit.Changes[uri]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, change := range edit.DocumentChanges {
+			switch {
+			case change.TextDocumentEdit != nil:
+				textEdits := make([]protocol.TextEdit, len(change.TextDocumentEdit.Edits))
+				for i, e := range change.TextDocumentEdit.Edits {
+					textEdit, err := e.AsTextEdit()
+					if err != nil {
+						return nil, fmt.Errorf("invalid edit type: %w", err)
+					}
+					textEdits[i] = textEdit
+				}
+				if err := editFile(change.TextDocumentEdit.TextDocument.URI, textEdits); err != nil {
+					return nil, err
+				}
+			case change.CreateFile != nil:
+				path, content, err := load(change.CreateFile.URI)
+				if err != nil {
+					return nil, err
+				}
+				options := change.CreateFile.Options
+				if content != nil && (options == nil || !options.Overwrite) {
+					if options != nil && options.IgnoreIfExists {
+						continue
+					}
+					return nil, fmt.Errorf("file already exists: %s", path)
+				}
+				empty := ""
+				current[path] = &empty
+			case change.RenameFile != nil:
+				oldPath, content, err := load(change.RenameFile.OldURI)
+				if err != nil {
+					return nil, err
+				}
+				if content == nil {
+					return nil, fmt.Errorf("file not found: %s", oldPath)
+				}
+				newPath, existing, err := load(change.RenameFile.NewURI)
+				if err != nil {
+					return nil, err
+				}
+				options := change.RenameFile.Options
+				if existing != nil && (options == nil || !options.Overwrite) {
+					if options != nil && options.IgnoreIfExists {
+						continue
+					}
+					return nil, fmt.Errorf("file already exists: %s", newPath)
+				}
+				current[newPath], current[oldPath] = content, nil
+			case change.DeleteFile != nil:
+				path, content, err := load(change.DeleteFile.URI)
+				if err != nil {
+					return nil, err
+				}
+				if content == nil {
+					if options := change.DeleteFile.Options; options != nil && options.IgnoreIfNotExists {
+						continue
+					}
+					return nil, fmt.Errorf("file not found: %s", path)
+				}
+				current[path] = nil
+			}
+		}
+	}
+
+	var changes []workspaceFileChange
+	for _, path := range slices.Sorted(maps.Keys(current)) {
+		before, after := original[path], current[path]
+		if before == nil && after == nil || before != nil && after != nil && *before == *after {
+			continue
+		}
+		change := workspaceFileChange{Path: path, Created: before == nil, Deleted: after == nil}
+		if before != nil {
+			change.OldContent = *before
+		}
+		if after != nil {
+			change.NewContent = *after
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/lsp/protocol"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	util := filepath.Join(dir, "util.go")
+	old := filepath.Join(dir, "old.go")
+	require.NoError(t, os.WriteFile(main, []byte("func run() { é := run() }\n"), 0o644))
+	require.NoError(t, os.WriteFile(util, []byte("var x = run\n"), 0o644))
+	require.NoError(t, os.WriteFile(old, []byte("package main\n"), 0o644))
+
+	rename := func(line, character uint32) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: character},
+				End:   protocol.Position{Line: line, Character: character + 3},
+			},
+			NewText: "start",
+		}
+	}
+	changes, err := workspaceFileChanges(protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentUri][]protocol.TextEdit{
+			fileURI(main): {rename(0, 5), rename(0, 18)},
+			fileURI(util): {rename(0, 8)},
+		},
+	}, protocol.WorkspaceEdit{
+		DocumentChanges: []protocol.DocumentChange{
+			{RenameFile: &protocol.RenameFile{OldURI: fileURI(old), NewURI: fileURI(filepath.Join(dir, "new.go"))}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []workspaceFileChange{
+		{Path: main, OldContent: "func run() { é := run() }\n", NewContent: "func start() { é := start() }\n"},
+		{Path: filepath.Join(dir, "new.go"), NewContent: "package main\n", Created: true},
+		{Path: old, OldContent: "package main\n", Deleted: true},
+		{Path: util, OldContent: "var x = run\n", NewContent: "var x = start\n"},
+	}, changes)
+
+	// Nothing is written until the changes are applied
+	content, err := os.ReadFile(main)
+	require.NoError(t, err)
+	assert.Equal(t, "func run() { é := run() }\n", string(content))
+
+	_, err = workspaceFileChanges(protocol.WorkspaceEdit{
+		DocumentChanges: []protocol.DocumentChange{
+			{CreateFile: &protocol.CreateFile{URI: fileURI(util)}},
+		},
+	})
+	assert.ErrorContains(t, err, "file already exists")
+}
+
+func TestWorkspaceEditorApplyDenied(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().WorkingDir = dir
+	main := filepath.Join(dir, "main.go")
+	migration := filepath.Join(dir, "migrations", "001.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(migration), 0o755))
+	require.NoError(t, os.WriteFile(main, []byte("var x = run\n"), 0o644))
+	require.NoError(t, os.WriteFile(migration, []byte("var y = run\n"), 0o644))
+
+	editor := workspaceEditor{permissions: permission.NewPermissionService([]config.PermissionRule{
+		{Tool: LSPRenameToolName, Action: config.PermissionAllow},
+		{Tool: LSPRenameToolName, Action: config.PermissionDeny, Path: "migrations/**"},
+	}, "")}
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "session")
+	ctx = context.WithValue(ctx, MessageIDContextKey, "message")
+
+	// The rename starts from an allowed file but changes a denied one too
+	edit := protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentUri][]protocol.TextEdit{
+			fileURI(main):      {{Range: protocol.Range{Start: protocol.Position{Character: 8}, End: protocol.Position{Character: 11}}, NewText: "start"}},
+			fileURI(migration): {{Range: protocol.Range{Start: protocol.Position{Character: 8}, End: protocol.Position{Character: 11}}, NewText: "start"}},
+		},
+	}
+	_, err = editor.apply(ctx, LSPRenameToolName, "Rename run to start", main, edit)
+	assert.ErrorIs(t, err, permission.ErrorPermissionDenied)
+	for _, file := range []string{main, migration} {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "run", file)
+	}
+}
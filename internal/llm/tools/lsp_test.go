@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/lsp/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbolOffset(t *testing.T) {
+	tests := []struct {
+		line   string
+		symbol string
+		want   int
+	}{
+		{"func run(ctx context.Context) error {", "run", 5},
+		{"	return runAll(run)", "run", 15},
+		{"	x := config.Get()", "config.Get", 13},
+		{"	runner.run()", "run", 8},
+		{"	running := true", "run", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, symbolOffset(tt.line, tt.symbol), "%s in %q", tt.symbol, tt.line)
+	}
+}
+
+func TestLSPPosition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main\r\n\r\nvar héllo, wörld = 1, 2\n"), 0o644))
+
+	// The columns count UTF-16 code units
+	_, position, err := LSPPositionParams{FilePath: path, Line: 3, Symbol: "wörld"}.position()
+	require.NoError(t, err)
+	assert.Equal(t, protocol.Position{Line: 2, Character: 11}, position)
+	_, position, err = LSPPositionParams{FilePath: path, Line: 3, Column: 12}.position()
+	require.NoError(t, err)
+	assert.Equal(t, protocol.Position{Line: 2, Character: 11}, position)
+
+	_, _, err = LSPPositionParams{FilePath: path, Line: 1, Symbol: "main"}.position()
+	assert.NoError(t, err)
+	_, _, err = LSPPositionParams{FilePath: path, Line: 1, Symbol: "world"}.position()
+	assert.ErrorContains(t, err, `symbol "world" not found on line 1`)
+	_, _, err = LSPPositionParams{FilePath: path, Line: 5, Symbol: "main"}.position()
+	assert.ErrorContains(t, err, "past the end of the file")
+	_, _, err = LSPPositionParams{FilePath: path, Line: 1}.position()
+	assert.ErrorContains(t, err, "symbol or column is required")
+}
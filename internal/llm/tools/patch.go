@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/opencode-ai/opencode/internal/config"
@@ -27,7 +28,7 @@ type PatchResponseMetadata struct {
 }
 
 type patchTool struct {
-	lspClients  map[string]*lsp.Client
+	lspClients  *lsp.Clients
 	permissions permission.Service
 	files       history.Service
 }
@@ -64,7 +65,7 @@ CRITICAL REQUIREMENTS FOR USING THIS TOOL:
 The tool will apply all changes in a single atomic operation.`
 )
 
-func NewPatchTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service) BaseTool {
+func NewPatchTool(lspClients *lsp.Clients, permissions permission.Service, files history.Service) BaseTool {
 	return &patchTool{
 		lspClients:  lspClients,
 		permissions: permissions,
@@ -198,6 +199,8 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 					Path:        dir,
 					ToolName:    PatchToolName,
 					Action:      "create",
+					Agent:       GetAgentName(ctx),
+					FilePath:    path,
 					Description: fmt.Sprintf("Create file %s", path),
 					Params: EditPermissionsParams{
 						FilePath: path,
@@ -225,6 +228,8 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 					Path:        dir,
 					ToolName:    PatchToolName,
 					Action:      "update",
+					Agent:       GetAgentName(ctx),
+					FilePath:    path,
 					Description: fmt.Sprintf("Update file %s", path),
 					Params: EditPermissionsParams{
 						FilePath: path,
@@ -244,6 +249,8 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 					Path:        dir,
 					ToolName:    PatchToolName,
 					Action:      "delete",
+					Agent:       GetAgentName(ctx),
+					FilePath:    path,
 					Description: fmt.Sprintf("Delete file %s", path),
 					Params: EditPermissionsParams{
 						FilePath: path,
@@ -286,6 +293,7 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 
 	// Update file history for all modified files
 	changedFiles := []string{}
+	formattedFiles := []string{}
 	totalAdditions := 0
 	totalRemovals := 0
 
@@ -306,6 +314,13 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		if change.NewContent != nil {
 			newContent = *change.NewContent
 		}
+		if change.Type != diff.ActionDelete {
+			// The history and the statistics are the ones of the formatted file
+			if formatted := formatWritten(ctx, absPath, newContent, p.lspClients.All()); formatted != newContent {
+				newContent = formatted
+				formattedFiles = append(formattedFiles, absPath)
+			}
+		}
 
 		// Calculate diff statistics
 		_, additions, removals := diff.GenerateDiff(oldContent, newContent, path)
@@ -314,9 +329,13 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 
 		// Update history
 		file, err := p.files.GetByPathAndSession(ctx, absPath, sessionID)
-		if err != nil && change.Type != diff.ActionAdd {
-			// If not adding a file, create history entry for existing file
-			_, err = p.files.Create(ctx, sessionID, absPath, oldContent)
+		if err != nil {
+			// Create the history entry of the file, an added one didn't exist
+			if change.Type == diff.ActionAdd {
+				_, err = p.files.CreateMissing(ctx, sessionID, absPath)
+			} else {
+				_, err = p.files.Create(ctx, sessionID, absPath, oldContent)
+			}
 			if err != nil {
 				logging.Debug("Error creating file history", "error", err)
 			}
@@ -332,7 +351,7 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 
 		// Store new version
 		if change.Type == diff.ActionDelete {
-			_, err = p.files.CreateVersion(ctx, sessionID, absPath, "")
+			_, err = p.files.CreateMissing(ctx, sessionID, absPath)
 		} else {
 			_, err = p.files.CreateVersion(ctx, sessionID, absPath, newContent)
 		}
@@ -347,15 +366,18 @@ func (p *patchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 
 	// Run LSP diagnostics on all changed files
 	for _, filePath := range changedFiles {
-		waitForLspDiagnostics(ctx, filePath, p.lspClients)
+		waitForLspDiagnostics(ctx, filePath, p.lspClients.All())
 	}
 
 	result := fmt.Sprintf("Patch applied successfully. %d files changed, %d additions, %d removals",
 		len(changedFiles), totalAdditions, totalRemovals)
+	if len(formattedFiles) > 0 {
+		result += fmt.Sprintf("\nThese files were formatted after they were written, their content differs from the patch: %s", strings.Join(formattedFiles, ", "))
+	}
 
 	diagnosticsText := ""
 	for _, filePath := range changedFiles {
-		diagnosticsText += getDiagnostics(filePath, p.lspClients)
+		diagnosticsText += getDiagnostics(filePath, p.lspClients.All())
 	}
 
 	if diagnosticsText != "" {
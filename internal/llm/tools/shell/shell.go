@@ -1,9 +1,12 @@
 package shell
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,13 +14,19 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/sandbox"
 )
 
 type PersistentShell struct {
-	cmd          *exec.Cmd
-	stdin        *os.File
+	cmd   *exec.Cmd
+	stdin *os.File
+	// tempDir holds the files the shell writes the output, the status and
+	// the directory of a command to, it stays writable in the sandbox
+	tempDir      string
 	isAlive      bool
 	cwd          string
 	mu           sync.Mutex
@@ -27,10 +36,26 @@ type PersistentShell struct {
 type commandExecution struct {
 	command    string
 	timeout    time.Duration
+	onOutput   OutputFunc
 	resultChan chan commandResult
 	ctx        context.Context
 }
 
+// Stream is the output stream of a command
+type Stream string
+
+const (
+	Stdout Stream = "stdout"
+	Stderr Stream = "stderr"
+)
+
+// OutputFunc receives the output of a running command as it is written, in
+// chunks of complete UTF-8 characters
+type OutputFunc func(stream Stream, chunk string)
+
+// outputInterval is how often the output of a running command is read
+const outputInterval = 100 * time.Millisecond
+
 type commandResult struct {
 	stdout      string
 	stderr      string
@@ -58,49 +83,92 @@ func GetPersistentShell(workingDir string) *PersistentShell {
 	return shellInstance
 }
 
-func newPersistentShell(cwd string) *PersistentShell {
-	// Get shell configuration from config
-	cfg := config.Get()
-	
-	// Default to environment variable if config is not set or nil
-	var shellPath string
-	var shellArgs []string
-	
-	if cfg != nil {
-		shellPath = cfg.Shell.Path
-		shellArgs = cfg.Shell.Args
+// Path returns the shell of the config, the one of the environment by
+// default
+func Path() string {
+	if cfg := config.Get(); cfg != nil && cfg.Shell.Path != "" {
+		return cfg.Shell.Path
+	}
+	if shellPath := os.Getenv("SHELL"); shellPath != "" {
+		return shellPath
 	}
-	
-	if shellPath == "" {
-		shellPath = os.Getenv("SHELL")
-		if shellPath == "" {
-			shellPath = "/bin/bash"
+	return "/bin/bash"
+}
+
+// Command returns a command running the shell of the config with args in
+// dir, in the sandbox when it is enabled
+func Command(dir string, args ...string) (*exec.Cmd, error) {
+	return command(dir, nil, args...)
+}
+
+// command is Command, with more paths writable in the sandbox
+func command(dir string, writable []string, args ...string) (*exec.Cmd, error) {
+	cfg := config.Get()
+	shellPath := Path()
+
+	var cmd *exec.Cmd
+	if cfg != nil && cfg.Sandbox.Enabled {
+		opts := sandboxOptions(cfg, dir)
+		opts.Writable = append(opts.Writable, writable...)
+		var err error
+		cmd, err = sandbox.Command(shellPath, args, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sandbox the shell: %w", err)
 		}
+	} else {
+		cmd = exec.Command(shellPath, args...)
+	}
+	cmd.Dir = dir
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
 	}
-	
+	cmd.Env = append(cmd.Env, "GIT_EDITOR=true")
+	return cmd, nil
+}
+
+func newPersistentShell(cwd string) *PersistentShell {
 	// Default shell args
+	var shellArgs []string
+	if cfg := config.Get(); cfg != nil {
+		shellArgs = cfg.Shell.Args
+	}
 	if len(shellArgs) == 0 {
 		shellArgs = []string{"-l"}
 	}
 
-	cmd := exec.Command(shellPath, shellArgs...)
-	cmd.Dir = cwd
+	// The temporary directory of the sandbox is private, the files of the
+	// commands go to a directory of their own bound in it
+	tempDir, err := os.MkdirTemp("", "opencode-shell-")
+	if err != nil {
+		logging.Error("Failed to create the directory of the shell", "error", err)
+		return nil
+	}
+	cmd, err := command(cwd, []string{tempDir}, shellArgs...)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		logging.Error("Failed to create the shell", "error", err)
+		return nil
+	}
+	// Keeps what the shell itself prints, like the errors of the sandbox
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
 	stdinPipe, err := cmd.StdinPipe()
 	if err != nil {
+		os.RemoveAll(tempDir)
 		return nil
 	}
 
-	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
-
 	err = cmd.Start()
 	if err != nil {
+		os.RemoveAll(tempDir)
 		return nil
 	}
 
 	shell := &PersistentShell{
 		cmd:          cmd,
 		stdin:        stdinPipe.(*os.File),
+		tempDir:      tempDir,
 		isAlive:      true,
 		cwd:          cwd,
 		commandQueue: make(chan *commandExecution, 10),
@@ -120,23 +188,48 @@ func newPersistentShell(cwd string) *PersistentShell {
 	go func() {
 		err := cmd.Wait()
 		if err != nil {
-			// Log the error if needed
+			logging.Error("Shell exited", "error", err, "stderr", strings.TrimSpace(stderr.String()))
 		}
 		shell.isAlive = false
 		close(shell.commandQueue)
+		os.RemoveAll(tempDir)
 	}()
 
 	return shell
 }
 
+// sandboxOptions restricts the shell to the working directory, the temporary
+// directory and the writable paths of the config. The git directory and the
+// files of OpenCode stay read-only, the commands they run are not sandboxed.
+func sandboxOptions(cfg *config.Config, cwd string) sandbox.Options {
+	readOnly := sandbox.DefaultReadOnly(cwd)
+	if wd := config.WorkingDirectory(); wd != cwd {
+		readOnly = append(readOnly, sandbox.DefaultReadOnly(wd)...)
+	}
+	dataDir := cfg.Data.Directory
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(config.WorkingDirectory(), dataDir)
+	}
+	readOnly = append(readOnly, dataDir, "~/.opencode.json", "~/.opencode", "~/.config/opencode")
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		readOnly = append(readOnly, filepath.Join(dir, "opencode"))
+	}
+	return sandbox.Options{
+		Writable:     append(sandbox.DefaultWritable(cwd), cfg.Sandbox.WritablePaths...),
+		ReadOnly:     readOnly,
+		Network:      cfg.Sandbox.Network,
+		AllowedPorts: cfg.Sandbox.AllowedPorts,
+	}
+}
+
 func (s *PersistentShell) processCommands() {
 	for cmd := range s.commandQueue {
-		result := s.execCommand(cmd.command, cmd.timeout, cmd.ctx)
+		result := s.execCommand(cmd.command, cmd.timeout, cmd.onOutput, cmd.ctx)
 		cmd.resultChan <- result
 	}
 }
 
-func (s *PersistentShell) execCommand(command string, timeout time.Duration, ctx context.Context) commandResult {
+func (s *PersistentShell) execCommand(command string, timeout time.Duration, onOutput OutputFunc, ctx context.Context) commandResult {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -148,7 +241,7 @@ func (s *PersistentShell) execCommand(command string, timeout time.Duration, ctx
 		}
 	}
 
-	tempDir := os.TempDir()
+	tempDir := s.tempDir
 	stdoutFile := filepath.Join(tempDir, fmt.Sprintf("opencode-stdout-%d", time.Now().UnixNano()))
 	stderrFile := filepath.Join(tempDir, fmt.Sprintf("opencode-stderr-%d", time.Now().UnixNano()))
 	statusFile := filepath.Join(tempDir, fmt.Sprintf("opencode-status-%d", time.Now().UnixNano()))
@@ -186,6 +279,9 @@ echo $EXEC_EXIT_CODE > %s
 	interrupted := false
 
 	startTime := time.Now()
+	stdoutReader := &outputReader{path: stdoutFile}
+	stderrReader := &outputReader{path: stderrFile}
+	lastOutput := startTime
 
 	done := make(chan bool)
 	go func() {
@@ -203,6 +299,22 @@ echo $EXEC_EXIT_CODE > %s
 					return
 				}
 
+				// The shell won't write the status anymore
+				if !s.isAlive {
+					done <- true
+					return
+				}
+
+				if onOutput != nil && time.Since(lastOutput) >= outputInterval {
+					lastOutput = time.Now()
+					if chunk := stdoutReader.read(); chunk != "" {
+						onOutput(Stdout, chunk)
+					}
+					if chunk := stderrReader.read(); chunk != "" {
+						onOutput(Stderr, chunk)
+					}
+				}
+
 				if timeout > 0 {
 					elapsed := time.Since(startTime)
 					if elapsed > timeout {
@@ -229,6 +341,9 @@ echo $EXEC_EXIT_CODE > %s
 	} else if interrupted {
 		exitCode = 143
 		stderr += "\nCommand execution timed out or was interrupted"
+	} else if !s.isAlive {
+		exitCode = 1
+		stderr += "\nThe shell exited"
 	}
 
 	if newCwd != "" {
@@ -268,7 +383,9 @@ func (s *PersistentShell) killChildren() {
 	}
 }
 
-func (s *PersistentShell) Exec(ctx context.Context, command string, timeoutMs int) (string, string, int, bool, error) {
+// Exec runs command in the shell and returns its output once it ends.
+// onOutput, when not nil, receives the output while the command runs.
+func (s *PersistentShell) Exec(ctx context.Context, command string, timeoutMs int, onOutput OutputFunc) (string, string, int, bool, error) {
 	if !s.isAlive {
 		return "", "Shell is not alive", 1, false, errors.New("shell is not alive")
 	}
@@ -279,6 +396,7 @@ func (s *PersistentShell) Exec(ctx context.Context, command string, timeoutMs in
 	s.commandQueue <- &commandExecution{
 		command:    command,
 		timeout:    timeout,
+		onOutput:   onOutput,
 		resultChan: resultChan,
 		ctx:        ctx,
 	}
@@ -301,6 +419,36 @@ func (s *PersistentShell) Close() {
 	s.isAlive = false
 }
 
+// outputReader reads what was appended to an output file since the last read
+type outputReader struct {
+	path   string
+	offset int64
+}
+
+func (r *outputReader) read() string {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(io.NewSectionReader(f, r.offset, math.MaxInt64-r.offset))
+	if err != nil {
+		return ""
+	}
+	// A character cut at the end is read with the next chunk
+	end := len(buf)
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	r.offset += int64(end)
+	return string(buf[:end])
+}
+
 func shellQuote(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
 }
@@ -0,0 +1,92 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/sandbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// The sandboxed shells run the test binary first
+	sandbox.Init()
+	os.Exit(m.Run())
+}
+
+func TestExecStreamsOutput(t *testing.T) {
+	shell := newPersistentShell(t.TempDir())
+	require.NotNil(t, shell)
+	defer shell.Close()
+
+	var mu sync.Mutex
+	var chunks []string
+	var stderrChunks []string
+	stdout, stderr, exitCode, _, err := shell.Exec(t.Context(), "echo first; sleep 0.5; echo second; echo oops >&2", 5000, func(stream Stream, chunk string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stream == Stderr {
+			stderrChunks = append(stderrChunks, chunk)
+			return
+		}
+		chunks = append(chunks, chunk)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "first\nsecond\n", stdout)
+	assert.Equal(t, "oops\n", stderr)
+
+	// The first line is streamed before the command ends, what is written
+	// after the last read is only returned
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, chunks)
+	assert.Equal(t, "first\n", chunks[0])
+	assert.True(t, strings.HasPrefix(stdout, strings.Join(chunks, "")))
+	assert.True(t, strings.HasPrefix(stderr, strings.Join(stderrChunks, "")))
+}
+
+func TestOutputReaderKeepsCharactersWhole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	r := &outputReader{path: path}
+	assert.Equal(t, "", r.read())
+
+	euro := "€"
+	require.NoError(t, os.WriteFile(path, []byte("a"+euro[:2]), 0o644))
+	assert.Equal(t, "a", r.read())
+	require.NoError(t, os.WriteFile(path, []byte("a"+euro+"b"), 0o644))
+	assert.Equal(t, euro+"b", r.read())
+	assert.Equal(t, "", r.read())
+}
+
+func TestExecInSandbox(t *testing.T) {
+	if cmd, err := sandbox.Command("/bin/sh", []string{"-c", "true"}, sandbox.Options{}); err != nil {
+		t.Skip(err)
+	} else if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("no sandbox here: %s", out)
+	}
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	cfg := config.Get()
+	cfg.Sandbox.Enabled = true
+	t.Cleanup(func() { cfg.Sandbox.Enabled = false })
+
+	shell := newPersistentShell(dir)
+	require.NotNil(t, shell)
+	defer shell.Close()
+	// The files of the command are written in the private temporary
+	// directory of the sandbox
+	stdout, stderr, exitCode, interrupted, err := shell.Exec(t.Context(), "echo hello; echo oops >&2; cd /", 5000, nil)
+	require.NoError(t, err)
+	assert.False(t, interrupted)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "hello\n", stdout)
+	assert.Equal(t, "oops\n", stderr)
+	assert.Equal(t, "/", shell.cwd)
+}
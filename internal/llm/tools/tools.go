@@ -17,6 +17,8 @@ type toolResponseType string
 type (
 	sessionIDContextKey string
 	messageIDContextKey string
+	agentNameContextKey string
+	progressContextKey  string
 )
 
 const (
@@ -25,8 +27,17 @@ const (
 
 	SessionIDContextKey sessionIDContextKey = "session_id"
 	MessageIDContextKey messageIDContextKey = "message_id"
+	// AgentNameContextKey is the agent running the tools, for the permission
+	// rules targeting agents
+	AgentNameContextKey agentNameContextKey = "agent_name"
+	// ProgressContextKey holds the ProgressFunc of the running tool call
+	ProgressContextKey progressContextKey = "progress"
 )
 
+// ProgressFunc reports output of a tool while it runs, like the output of a
+// command as it is written
+type ProgressFunc func(content string)
+
 type ToolResponse struct {
 	Type     toolResponseType `json:"type"`
 	Content  string           `json:"content"`
@@ -82,3 +93,16 @@ func GetContextValues(ctx context.Context) (string, string) {
 	}
 	return sessionID.(string), messageID.(string)
 }
+
+// GetAgentName returns the agent running the tools, empty when unknown
+func GetAgentName(ctx context.Context) string {
+	name, _ := ctx.Value(AgentNameContextKey).(string)
+	return name
+}
+
+// ReportProgress reports output of the running tool, when someone listens
+func ReportProgress(ctx context.Context, content string) {
+	if progress, ok := ctx.Value(ProgressContextKey).(ProgressFunc); ok {
+		progress(content)
+	}
+}
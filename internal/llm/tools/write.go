@@ -28,7 +28,7 @@ type WritePermissionsParams struct {
 }
 
 type writeTool struct {
-	lspClients  map[string]*lsp.Client
+	lspClients  *lsp.Clients
 	permissions permission.Service
 	files       history.Service
 }
@@ -71,7 +71,7 @@ TIPS:
 - Always include descriptive comments when making changes to existing code`
 )
 
-func NewWriteTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service) BaseTool {
+func NewWriteTool(lspClients *lsp.Clients, permissions permission.Service, files history.Service) BaseTool {
 	return &writeTool{
 		lspClients:  lspClients,
 		permissions: permissions,
@@ -155,7 +155,7 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return ToolResponse{}, fmt.Errorf("session_id and message_id are required")
 	}
 
-	diff, additions, removals := diff.GenerateDiff(
+	fileDiff, additions, removals := diff.GenerateDiff(
 		oldContent,
 		params.Content,
 		filePath,
@@ -172,10 +172,12 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 			Path:        permissionPath,
 			ToolName:    WriteToolName,
 			Action:      "write",
+			Agent:       GetAgentName(ctx),
+			FilePath:    filePath,
 			Description: fmt.Sprintf("Create file %s", filePath),
 			Params: WritePermissionsParams{
 				FilePath: filePath,
-				Diff:     diff,
+				Diff:     fileDiff,
 			},
 		},
 	)
@@ -188,10 +190,21 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return ToolResponse{}, fmt.Errorf("error writing file: %w", err)
 	}
 
+	// The history and the diff are the ones of the formatted file
+	content := formatWritten(ctx, filePath, params.Content, w.lspClients.All())
+	formatted := content != params.Content
+	if formatted {
+		fileDiff, additions, removals = diff.GenerateDiff(oldContent, content, filePath)
+	}
+
 	// Check if file exists in history
 	file, err := w.files.GetByPathAndSession(ctx, filePath, sessionID)
 	if err != nil {
-		_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		if fileInfo != nil {
+			_, err = w.files.Create(ctx, sessionID, filePath, oldContent)
+		} else {
+			_, err = w.files.CreateMissing(ctx, sessionID, filePath)
+		}
 		if err != nil {
 			// Log error but don't fail the operation
 			return ToolResponse{}, fmt.Errorf("error creating file history: %w", err)
@@ -205,21 +218,24 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		}
 	}
 	// Store the new version
-	_, err = w.files.CreateVersion(ctx, sessionID, filePath, params.Content)
+	_, err = w.files.CreateVersion(ctx, sessionID, filePath, content)
 	if err != nil {
 		logging.Debug("Error creating file history version", "error", err)
 	}
 
 	recordFileWrite(filePath)
 	recordFileRead(filePath)
-	waitForLspDiagnostics(ctx, filePath, w.lspClients)
+	waitForLspDiagnostics(ctx, filePath, w.lspClients.All())
 
 	result := fmt.Sprintf("File successfully written: %s", filePath)
+	if formatted {
+		result += "\n" + formattedNote
+	}
 	result = fmt.Sprintf("<result>\n%s\n</result>", result)
-	result += getDiagnostics(filePath, w.lspClients)
+	result += getDiagnostics(filePath, w.lspClients.All())
 	return WithResponseMetadata(NewTextResponse(result),
 		WriteResponseMetadata{
-			Diff:      diff,
+			Diff:      fileDiff,
 			Additions: additions,
 			Removals:  removals,
 		},
@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/opencode-ai/opencode/internal/lsp/protocol"
+)
+
+// CanRename reports whether the server renames symbols, and whether it
+// checks the position of a rename first with PrepareRename
+func (c *Client) CanRename() (rename, prepare bool) {
+	switch provider := c.capabilities.RenameProvider.(type) {
+	case bool:
+		return provider, false
+	case map[string]any:
+		prepare, _ := provider["prepareProvider"].(bool)
+		return true, prepare
+	}
+	return false, false
+}
+
+// CanCodeAction reports whether the server provides code actions
+func (c *Client) CanCodeAction() bool {
+	switch provider := c.capabilities.CodeActionProvider.(type) {
+	case bool:
+		return provider
+	case nil:
+		return false
+	}
+	return true
+}
+
+// ExecuteCommandEdits runs a command, like the one of a code action, and
+// returns the edits the server asks to apply while it runs instead of
+// applying them, so that they can be reviewed first
+func (c *Client) ExecuteCommandEdits(ctx context.Context, command protocol.Command) ([]protocol.WorkspaceEdit, error) {
+	c.commandMu.Lock()
+	defer c.commandMu.Unlock()
+
+	c.editsMu.Lock()
+	c.capturedEdits, c.capturing = nil, true
+	c.editsMu.Unlock()
+	defer func() {
+		c.editsMu.Lock()
+		c.capturedEdits, c.capturing = nil, false
+		c.editsMu.Unlock()
+	}()
+
+	_, err := c.ExecuteCommand(ctx, protocol.ExecuteCommandParams{
+		Command:   command.Command,
+		Arguments: command.Arguments,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.editsMu.Lock()
+	defer c.editsMu.Unlock()
+	return c.capturedEdits, nil
+}
+
+// handleApplyEdit applies the edits of the server, unless a command started
+// by ExecuteCommandEdits is running
+func (c *Client) handleApplyEdit(params json.RawMessage) (any, error) {
+	c.editsMu.Lock()
+	if !c.capturing {
+		c.editsMu.Unlock()
+		return HandleApplyEdit(params)
+	}
+	defer c.editsMu.Unlock()
+	var edit protocol.ApplyWorkspaceEditParams
+	if err := json.Unmarshal(params, &edit); err != nil {
+		return nil, err
+	}
+	c.capturedEdits = append(c.capturedEdits, edit.Edit)
+	return protocol.ApplyWorkspaceEditResult{Applied: true}, nil
+}
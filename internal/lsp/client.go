@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -50,6 +51,20 @@ type Client struct {
 
 	// Server state
 	serverState atomic.Value
+
+	// capabilities are the ones the server sent on initialization
+	capabilities protocol.ServerCapabilities
+
+	// done is closed when the server process exits, with exitErr
+	done    chan struct{}
+	exitErr error
+
+	// The edits the server asks to apply while a command runs, see
+	// ExecuteCommandEdits
+	commandMu     sync.Mutex
+	editsMu       sync.Mutex
+	capturedEdits []protocol.WorkspaceEdit
+	capturing     bool
 }
 
 func NewClient(ctx context.Context, command string, args ...string) (*Client, error) {
@@ -82,6 +97,7 @@ func NewClient(ctx context.Context, command string, args ...string) (*Client, er
 		serverRequestHandlers: make(map[string]ServerRequestHandler),
 		diagnostics:           make(map[protocol.DocumentUri][]protocol.Diagnostic),
 		openFiles:             make(map[string]*OpenFileInfo),
+		done:                  make(chan struct{}),
 	}
 
 	// Initialize server state
@@ -92,6 +108,12 @@ func NewClient(ctx context.Context, command string, args ...string) (*Client, er
 		return nil, fmt.Errorf("failed to start LSP server: %w", err)
 	}
 
+	// Watch for the exit of the process, expected or not
+	go func() {
+		client.exitErr = cmd.Wait()
+		close(client.done)
+	}()
+
 	// Handle stderr in a separate goroutine
 	go func() {
 		scanner := bufio.NewScanner(stderr)
@@ -147,6 +169,11 @@ func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string) (
 			RootURI:  protocol.DocumentUri("file://" + workspaceDir),
 			Capabilities: protocol.ClientCapabilities{
 				Workspace: protocol.WorkspaceClientCapabilities{
+					ApplyEdit: true,
+					WorkspaceEdit: &protocol.WorkspaceEditClientCapabilities{
+						DocumentChanges:    true,
+						ResourceOperations: []protocol.ResourceOperationKind{protocol.Create, protocol.Rename, protocol.Delete},
+					},
 					Configuration: true,
 					DidChangeConfiguration: protocol.DidChangeConfigurationClientCapabilities{
 						DynamicRegistration: true,
@@ -174,6 +201,15 @@ func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string) (
 								ValueSet: []protocol.CodeActionKind{},
 							},
 						},
+						IsPreferredSupport: true,
+						DisabledSupport:    true,
+						DataSupport:        true,
+						ResolveSupport: &protocol.ClientCodeActionResolveOptions{
+							Properties: []string{"edit"},
+						},
+					},
+					Rename: &protocol.RenameClientCapabilities{
+						PrepareSupport: true,
 					},
 					PublishDiagnostics: protocol.PublishDiagnosticsClientCapabilities{
 						VersionSupport: true,
@@ -209,12 +245,14 @@ func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string) (
 		return nil, fmt.Errorf("initialize failed: %w", err)
 	}
 
+	c.capabilities = result.Capabilities
+
 	if err := c.Notify(ctx, "initialized", struct{}{}); err != nil {
 		return nil, fmt.Errorf("initialized notification failed: %w", err)
 	}
 
 	// Register handlers
-	c.RegisterServerRequestHandler("workspace/applyEdit", HandleApplyEdit)
+	c.RegisterServerRequestHandler("workspace/applyEdit", c.handleApplyEdit)
 	c.RegisterServerRequestHandler("workspace/configuration", HandleWorkspaceConfiguration)
 	c.RegisterServerRequestHandler("client/registerCapability", HandleRegisterCapability)
 	c.RegisterNotificationHandler("window/showMessage", HandleServerMessage)
@@ -243,16 +281,10 @@ func (c *Client) Close() error {
 		return fmt.Errorf("failed to close stdin: %w", err)
 	}
 
-	// Use a channel to handle the Wait with timeout
-	done := make(chan error, 1)
-	go func() {
-		done <- c.Cmd.Wait()
-	}()
-
 	// Wait for process to exit with timeout
 	select {
-	case err := <-done:
-		return err
+	case <-c.done:
+		return c.exitErr
 	case <-time.After(2 * time.Second):
 		// If we timeout, try to kill the process
 		if err := c.Cmd.Process.Kill(); err != nil {
@@ -262,14 +294,47 @@ func (c *Client) Close() error {
 	}
 }
 
+// Done returns a channel closed when the server process exits
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// ExitError returns the error of the exit of the server process, once Done
+// is closed
+func (c *Client) ExitError() error {
+	select {
+	case <-c.done:
+		return c.exitErr
+	default:
+		return nil
+	}
+}
+
 type ServerState int
 
 const (
 	StateStarting ServerState = iota
 	StateReady
 	StateError
+	// StateCrashed is the state of the servers whose process exited
+	// unexpectedly
+	StateCrashed
 )
 
+func (s ServerState) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateReady:
+		return "ready"
+	case StateError:
+		return "error"
+	case StateCrashed:
+		return "crashed"
+	}
+	return "unknown"
+}
+
 // GetServerState returns the current state of the LSP server
 func (c *Client) GetServerState() ServerState {
 	if val := c.serverState.Load(); val != nil {
@@ -374,6 +439,24 @@ func (c *Client) detectServerType() ServerType {
 	}
 }
 
+// serverExtensions are the files handled by the servers of known types
+var serverExtensions = map[ServerType][]string{
+	ServerTypeGo:         {".go"},
+	ServerTypeTypeScript: {".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts"},
+	ServerTypeRust:       {".rs"},
+	ServerTypePython:     {".py", ".pyi"},
+}
+
+// HandlesFile reports whether the server handles the language of the file,
+// the servers of unknown types are assumed to handle every file
+func (c *Client) HandlesFile(path string) bool {
+	extensions, ok := serverExtensions[c.detectServerType()]
+	if !ok {
+		return true
+	}
+	return slices.Contains(extensions, strings.ToLower(filepath.Ext(path)))
+}
+
 // openKeyConfigFiles opens important configuration files that help initialize the server
 func (c *Client) openKeyConfigFiles(ctx context.Context) {
 	workDir := config.WorkingDirectory()
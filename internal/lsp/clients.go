@@ -0,0 +1,47 @@
+package lsp
+
+import (
+	"maps"
+	"sync"
+)
+
+// Clients are the language servers by name. They are safe for concurrent
+// use, the servers are replaced while the tools run when they restart.
+type Clients struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// NewClients returns an empty set of clients
+func NewClients() *Clients {
+	return &Clients{clients: make(map[string]*Client)}
+}
+
+// Get returns the client of a server
+func (c *Clients) Get(name string) (*Client, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	client, ok := c.clients[name]
+	return client, ok
+}
+
+// Set adds the client of a server, replacing the previous one
+func (c *Clients) Set(name string, client *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clients[name] = client
+}
+
+// All returns a copy of the clients, which can be iterated while the servers
+// restart
+func (c *Clients) All() map[string]*Client {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return maps.Clone(c.clients)
+}
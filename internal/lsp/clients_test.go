@@ -0,0 +1,43 @@
+package lsp
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClients(t *testing.T) {
+	var none *Clients
+	assert.Empty(t, none.All())
+
+	clients := NewClients()
+	first := &Client{}
+	clients.Set("gopls", first)
+	snapshot := clients.All()
+
+	// Restarts replace the client without changing the snapshots
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			clients.Set("gopls", &Client{})
+		}()
+		go func() {
+			defer wg.Done()
+			for name, client := range clients.All() {
+				assert.Equal(t, "gopls", name)
+				assert.NotNil(t, client)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Same(t, first, snapshot["gopls"])
+	client, ok := clients.Get("gopls")
+	assert.True(t, ok)
+	assert.NotSame(t, first, client)
+	_, ok = clients.Get("rust-analyzer")
+	assert.False(t, ok)
+}
@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/opencode-ai/opencode/internal/config"
+)
+
+// knownServer is a language server started for the projects of its language
+type knownServer struct {
+	// language is the key of the server in the lsp config
+	language string
+	// markers are the files at the root of the projects of the language
+	markers []string
+	// commands are the servers of the language, the first one found is used
+	commands [][]string
+}
+
+var knownServers = []knownServer{
+	{
+		language: "go",
+		markers:  []string{"go.mod", "go.work"},
+		commands: [][]string{{"gopls"}},
+	},
+	{
+		language: "typescript",
+		markers:  []string{"package.json", "tsconfig.json", "jsconfig.json"},
+		commands: [][]string{{"typescript-language-server", "--stdio"}, {"vtsls", "--stdio"}},
+	},
+	{
+		language: "python",
+		markers:  []string{"pyproject.toml", "setup.py", "setup.cfg", "requirements.txt"},
+		commands: [][]string{{"pyright-langserver", "--stdio"}, {"basedpyright-langserver", "--stdio"}, {"pylsp"}},
+	},
+	{
+		language: "rust",
+		markers:  []string{"Cargo.toml"},
+		commands: [][]string{{"rust-analyzer"}},
+	},
+}
+
+// DiscoverServers returns the servers of the languages of the project in dir
+// that are found on the PATH. The languages configured in configured and the
+// servers already configured under another name are left out.
+func DiscoverServers(dir string, configured map[string]config.LSPConfig) map[string]config.LSPConfig {
+	return discoverServers(dir, configured, exec.LookPath)
+}
+
+func discoverServers(dir string, configured map[string]config.LSPConfig, lookPath func(string) (string, error)) map[string]config.LSPConfig {
+	commands := make([]string, 0, len(configured))
+	for _, cfg := range configured {
+		commands = append(commands, filepath.Base(cfg.Command))
+	}
+
+	servers := make(map[string]config.LSPConfig)
+	for _, server := range knownServers {
+		if _, ok := configured[server.language]; ok {
+			continue
+		}
+		if !slices.ContainsFunc(server.markers, func(marker string) bool {
+			_, err := os.Stat(filepath.Join(dir, marker))
+			return err == nil
+		}) {
+			continue
+		}
+		for _, command := range server.commands {
+			if slices.Contains(commands, command[0]) {
+				break
+			}
+			path, err := lookPath(command[0])
+			if err != nil {
+				continue
+			}
+			servers[server.language] = config.LSPConfig{Command: path, Args: command[1:]}
+			break
+		}
+	}
+	return servers
+}
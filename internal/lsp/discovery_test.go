@@ -0,0 +1,44 @@
+package lsp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverServers(t *testing.T) {
+	dir := t.TempDir()
+	for _, marker := range []string{"go.mod", "pyproject.toml", "Cargo.toml", "package.json"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, marker), nil, 0o644))
+	}
+	installed := []string{"gopls", "pylsp", "typescript-language-server"}
+	lookPath := func(name string) (string, error) {
+		for _, command := range installed {
+			if command == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+
+	servers := discoverServers(dir, nil, lookPath)
+	assert.Equal(t, map[string]config.LSPConfig{
+		"go":         {Command: "/usr/bin/gopls", Args: []string{}},
+		"python":     {Command: "/usr/bin/pylsp", Args: []string{}},
+		"typescript": {Command: "/usr/bin/typescript-language-server", Args: []string{"--stdio"}},
+	}, servers)
+
+	// The configured languages and servers are left out
+	servers = discoverServers(dir, map[string]config.LSPConfig{
+		"go":  {Disabled: true},
+		"tsc": {Command: "typescript-language-server", Args: []string{"--stdio"}},
+	}, lookPath)
+	assert.Equal(t, map[string]config.LSPConfig{
+		"python": {Command: "/usr/bin/pylsp", Args: []string{}},
+	}, servers)
+}
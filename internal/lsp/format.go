@@ -0,0 +1,76 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/opencode-ai/opencode/internal/lsp/protocol"
+	"github.com/opencode-ai/opencode/internal/lsp/util"
+)
+
+// CanFormat reports whether the server formats whole documents
+func (c *Client) CanFormat() bool {
+	provider := c.capabilities.DocumentFormattingProvider
+	if provider == nil || provider.Value == nil {
+		return false
+	}
+	enabled, isBool := provider.Value.(bool)
+	return !isBool || enabled
+}
+
+// FormatFile formats a file with the server and writes the result to it. It
+// reports whether the formatting changed the file.
+func (c *Client) FormatFile(ctx context.Context, filepath string) (bool, error) {
+	uri := protocol.DocumentUri(fmt.Sprintf("file://%s", filepath))
+
+	// The server formats the content it knows
+	if c.IsFileOpen(filepath) {
+		if err := c.NotifyChange(ctx, filepath); err != nil {
+			return false, err
+		}
+	} else if err := c.OpenFile(ctx, filepath); err != nil {
+		return false, err
+	}
+	content, err := os.ReadFile(filepath)
+	if err != nil {
+		return false, err
+	}
+
+	edits, err := c.Formatting(ctx, protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Options:      formattingOptions(content),
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(edits) == 0 {
+		return false, nil
+	}
+	if err := util.ApplyTextEdits(uri, edits); err != nil {
+		return false, err
+	}
+	return true, c.NotifyChange(ctx, filepath)
+}
+
+// formattingOptions follows the indentation of the first indented line, the
+// servers with their own configuration ignore them
+func formattingOptions(content []byte) protocol.FormattingOptions {
+	options := protocol.FormattingOptions{
+		TabSize:            4,
+		InsertSpaces:       true,
+		InsertFinalNewline: bytes.HasSuffix(content, []byte("\n")),
+	}
+	for line := range bytes.SplitSeq(content, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("\t")) {
+			options.InsertSpaces = false
+			break
+		}
+		if indent := len(line) - len(bytes.TrimLeft(line, " ")); indent > 0 && indent < len(line) {
+			options.TabSize = uint32(min(indent, 8))
+			break
+		}
+	}
+	return options
+}
@@ -221,8 +221,15 @@ func (c *Client) Call(ctx context.Context, method string, params any, result any
 		logging.Debug("Request sent", "method", method, "id", id)
 	}
 
-	// Wait for response
-	resp := <-ch
+	// Wait for response, the server may exit before it answers
+	var resp *Message
+	select {
+	case resp = <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return fmt.Errorf("language server exited before answering %s", method)
+	}
 
 	if cnf.DebugLSP {
 		logging.Debug("Received response", "id", id)
@@ -1,16 +1,17 @@
 package util
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 	"sort"
 	"strings"
+	"unicode/utf16"
 
 	"github.com/opencode-ai/opencode/internal/lsp/protocol"
 )
 
-func applyTextEdits(uri protocol.DocumentUri, edits []protocol.TextEdit) error {
+// ApplyTextEdits applies the edits of a document to its file
+func ApplyTextEdits(uri protocol.DocumentUri, edits []protocol.TextEdit) error {
 	path := strings.TrimPrefix(string(uri), "file://")
 
 	// Read the file content
@@ -19,25 +20,39 @@ func applyTextEdits(uri protocol.DocumentUri, edits []protocol.TextEdit) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
+	newContent, err := EditContent(string(content), edits)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, []byte(newContent), 0o644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// EditContent applies the edits of a document to its content
+func EditContent(content string, edits []protocol.TextEdit) (string, error) {
 	// Detect line ending style
 	var lineEnding string
-	if bytes.Contains(content, []byte("\r\n")) {
+	if strings.Contains(content, "\r\n") {
 		lineEnding = "\r\n"
 	} else {
 		lineEnding = "\n"
 	}
 
 	// Track if file ends with a newline
-	endsWithNewline := len(content) > 0 && bytes.HasSuffix(content, []byte(lineEnding))
+	endsWithNewline := len(content) > 0 && strings.HasSuffix(content, lineEnding)
 
 	// Split into lines without the endings
-	lines := strings.Split(string(content), lineEnding)
+	lines := strings.Split(content, lineEnding)
 
 	// Check for overlapping edits
 	for i, edit1 := range edits {
 		for j := i + 1; j < len(edits); j++ {
 			if rangesOverlap(edit1.Range, edits[j].Range) {
-				return fmt.Errorf("overlapping edits detected between edit %d and %d", i, j)
+				return "", fmt.Errorf("overlapping edits detected between edit %d and %d", i, j)
 			}
 		}
 	}
@@ -56,7 +71,7 @@ func applyTextEdits(uri protocol.DocumentUri, edits []protocol.TextEdit) error {
 	for _, edit := range sortedEdits {
 		newLines, err := applyTextEdit(lines, edit)
 		if err != nil {
-			return fmt.Errorf("failed to apply edit: %w", err)
+			return "", fmt.Errorf("failed to apply edit: %w", err)
 		}
 		lines = newLines
 	}
@@ -75,18 +90,12 @@ func applyTextEdits(uri protocol.DocumentUri, edits []protocol.TextEdit) error {
 		newContent.WriteString(lineEnding)
 	}
 
-	if err := os.WriteFile(path, []byte(newContent.String()), 0o644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
+	return newContent.String(), nil
 }
 
 func applyTextEdit(lines []string, edit protocol.TextEdit) ([]string, error) {
 	startLine := int(edit.Range.Start.Line)
 	endLine := int(edit.Range.End.Line)
-	startChar := int(edit.Range.Start.Character)
-	endChar := int(edit.Range.End.Character)
 
 	// Validate positions
 	if startLine < 0 || startLine >= len(lines) {
@@ -104,17 +113,11 @@ func applyTextEdit(lines []string, edit protocol.TextEdit) ([]string, error) {
 
 	// Get the prefix of the start line
 	startLineContent := lines[startLine]
-	if startChar < 0 || startChar > len(startLineContent) {
-		startChar = len(startLineContent)
-	}
-	prefix := startLineContent[:startChar]
+	prefix := startLineContent[:byteOffset(startLineContent, edit.Range.Start.Character)]
 
 	// Get the suffix of the end line
 	endLineContent := lines[endLine]
-	if endChar < 0 || endChar > len(endLineContent) {
-		endChar = len(endLineContent)
-	}
-	suffix := endLineContent[endChar:]
+	suffix := endLineContent[byteOffset(endLineContent, edit.Range.End.Character):]
 
 	// Handle the edit
 	if edit.NewText == "" {
@@ -145,6 +148,19 @@ func applyTextEdit(lines []string, edit protocol.TextEdit) ([]string, error) {
 	return result, nil
 }
 
+// byteOffset converts a character of a line, counted in UTF-16 code units,
+// to an offset in the line. Characters past the end are the end of the line.
+func byteOffset(line string, character uint32) int {
+	units := uint32(0)
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += uint32(utf16.RuneLen(r))
+	}
+	return len(line)
+}
+
 // applyDocumentChange applies a DocumentChange (create/rename/delete operations)
 func applyDocumentChange(change protocol.DocumentChange) error {
 	if change.CreateFile != nil {
@@ -200,7 +216,7 @@ func applyDocumentChange(change protocol.DocumentChange) error {
 				return fmt.Errorf("invalid edit type: %w", err)
 			}
 		}
-		return applyTextEdits(change.TextDocumentEdit.TextDocument.URI, textEdits)
+		return ApplyTextEdits(change.TextDocumentEdit.TextDocument.URI, textEdits)
 	}
 
 	return nil
@@ -210,7 +226,7 @@ func applyDocumentChange(change protocol.DocumentChange) error {
 func ApplyWorkspaceEdit(edit protocol.WorkspaceEdit) error {
 	// Handle Changes field
 	for uri, textEdits := range edit.Changes {
-		if err := applyTextEdits(uri, textEdits); err != nil {
+		if err := ApplyTextEdits(uri, textEdits); err != nil {
 			return fmt.Errorf("failed to apply text edits: %w", err)
 		}
 	}
@@ -1,8 +1,48 @@
 package message
 
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// MaxAttachmentSize is the size of the largest file that can be attached
+const MaxAttachmentSize = int64(5 * 1024 * 1024)
+
 type Attachment struct {
 	FilePath string
 	FileName string
 	MimeType string
 	Content  []byte
 }
+
+// NewAttachment creates the attachment of a file, its MIME type is detected
+// from the start of the content.
+func NewAttachment(path string, content []byte) Attachment {
+	return Attachment{
+		FilePath: path,
+		FileName: filepath.Base(path),
+		MimeType: http.DetectContentType(content[:min(512, len(content))]),
+		Content:  content,
+	}
+}
+
+// ReadAttachment reads a file of at most MaxAttachmentSize to attach it
+func ReadAttachment(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > MaxAttachmentSize {
+		return Attachment{}, fmt.Errorf("%s is too large, max %dMB", path, MaxAttachmentSize/1024/1024)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return NewAttachment(path, content), nil
+}
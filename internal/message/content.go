@@ -26,6 +26,7 @@ const (
 	FinishReasonCanceled         FinishReason = "canceled"
 	FinishReasonError            FinishReason = "error"
 	FinishReasonPermissionDenied FinishReason = "permission_denied"
+	FinishReasonBudgetExceeded   FinishReason = "budget_exceeded"
 
 	// Should never happen
 	FinishReasonUnknown FinishReason = "unknown"
@@ -108,6 +109,18 @@ type Finish struct {
 
 func (Finish) isPart() {}
 
+// Summary marks a message holding the summary of the conversation that came
+// before it.
+type Summary struct {
+	// Kept is the number of messages before the summary that are sent
+	// verbatim after it
+	Kept int `json:"kept"`
+	// Files are the files changed in the session when it was summarized
+	Files []string `json:"files,omitempty"`
+}
+
+func (Summary) isPart() {}
+
 type Message struct {
 	ID        string
 	Role      MessageRole
@@ -116,6 +129,8 @@ type Message struct {
 	Model     models.ModelID
 	CreatedAt int64
 	UpdatedAt int64
+	// RevertedAt is set once the message was rolled back by a revert
+	RevertedAt int64
 }
 
 func (m *Message) Content() TextContent {
@@ -194,6 +209,15 @@ func (m *Message) FinishPart() *Finish {
 	return nil
 }
 
+func (m *Message) SummaryPart() *Summary {
+	for _, part := range m.Parts {
+		if c, ok := part.(Summary); ok {
+			return &c
+		}
+	}
+	return nil
+}
+
 func (m *Message) FinishReason() FinishReason {
 	for _, part := range m.Parts {
 		if c, ok := part.(Finish); ok {
@@ -26,6 +26,10 @@ type Service interface {
 	Get(ctx context.Context, id string) (Message, error)
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
+	Revert(ctx context.Context, id string) error
+	// Import adds a copy of an exported message to a session, with its dates
+	Import(ctx context.Context, sessionID string, message Message) (Message, error)
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
 }
 
@@ -111,6 +115,7 @@ func (s *service) Update(ctx context.Context, message Message) error {
 	err = s.q.UpdateMessage(ctx, db.UpdateMessageParams{
 		ID:         message.ID,
 		Parts:      string(parts),
+		Model:      sql.NullString{String: string(message.Model), Valid: message.Model != ""},
 		FinishedAt: finishedAt,
 	})
 	if err != nil {
@@ -121,6 +126,54 @@ func (s *service) Update(ctx context.Context, message Message) error {
 	return nil
 }
 
+// Revert marks a message as rolled back. Reverted messages stay in the session
+// but are no longer part of the conversation sent to the model.
+func (s *service) Revert(ctx context.Context, id string) error {
+	err := s.q.RevertMessage(ctx, id)
+	if err != nil {
+		return err
+	}
+	message, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	s.Publish(pubsub.UpdatedEvent, message)
+	return nil
+}
+
+func (s *service) Import(ctx context.Context, sessionID string, message Message) (Message, error) {
+	parts, err := marshallParts(message.Parts)
+	if err != nil {
+		return Message{}, err
+	}
+	finishedAt := sql.NullInt64{}
+	if f := message.FinishPart(); f != nil {
+		finishedAt.Int64 = f.Time
+		finishedAt.Valid = true
+	}
+	id := uuid.New().String()
+	err = s.q.CopyMessage(ctx, db.CopyMessageParams{
+		ID:         id,
+		SessionID:  sessionID,
+		Role:       string(message.Role),
+		Parts:      string(parts),
+		Model:      sql.NullString{String: string(message.Model), Valid: message.Model != ""},
+		CreatedAt:  message.CreatedAt,
+		UpdatedAt:  message.UpdatedAt,
+		FinishedAt: finishedAt,
+		RevertedAt: sql.NullInt64{Int64: message.RevertedAt, Valid: message.RevertedAt != 0},
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	imported, err := s.Get(ctx, id)
+	if err != nil {
+		return Message{}, err
+	}
+	s.Publish(pubsub.CreatedEvent, imported)
+	return imported, nil
+}
+
 func (s *service) Get(ctx context.Context, id string) (Message, error) {
 	dbMessage, err := s.q.GetMessage(ctx, id)
 	if err != nil {
@@ -150,13 +203,14 @@ func (s *service) fromDBItem(item db.Message) (Message, error) {
 		return Message{}, err
 	}
 	return Message{
-		ID:        item.ID,
-		SessionID: item.SessionID,
-		Role:      MessageRole(item.Role),
-		Parts:     parts,
-		Model:     models.ModelID(item.Model.String),
-		CreatedAt: item.CreatedAt,
-		UpdatedAt: item.UpdatedAt,
+		ID:         item.ID,
+		SessionID:  item.SessionID,
+		Role:       MessageRole(item.Role),
+		Parts:      parts,
+		Model:      models.ModelID(item.Model.String),
+		CreatedAt:  item.CreatedAt,
+		UpdatedAt:  item.UpdatedAt,
+		RevertedAt: item.RevertedAt.Int64,
 	}, nil
 }
 
@@ -170,6 +224,7 @@ const (
 	toolCallType   partType = "tool_call"
 	toolResultType partType = "tool_result"
 	finishType     partType = "finish"
+	summaryType    partType = "summary"
 )
 
 type partWrapper struct {
@@ -177,6 +232,62 @@ type partWrapper struct {
 	Data ContentPart `json:"data"`
 }
 
+// messageJSON is the wire representation of a Message. Parts use the same
+// tagged encoding as the database so they can be decoded back losslessly.
+type messageJSON struct {
+	ID         string          `json:"id"`
+	SessionID  string          `json:"session_id"`
+	Role       MessageRole     `json:"role"`
+	Parts      json.RawMessage `json:"parts"`
+	Model      models.ModelID  `json:"model,omitempty"`
+	CreatedAt  int64           `json:"created_at"`
+	UpdatedAt  int64           `json:"updated_at"`
+	RevertedAt int64           `json:"reverted_at,omitempty"`
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	parts, err := marshallParts(m.Parts)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(messageJSON{
+		ID:         m.ID,
+		SessionID:  m.SessionID,
+		Role:       m.Role,
+		Parts:      parts,
+		Model:      m.Model,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+		RevertedAt: m.RevertedAt,
+	})
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw messageJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	parts := []ContentPart{}
+	if len(raw.Parts) > 0 {
+		var err error
+		parts, err = unmarshallParts(raw.Parts)
+		if err != nil {
+			return err
+		}
+	}
+	*m = Message{
+		ID:         raw.ID,
+		SessionID:  raw.SessionID,
+		Role:       raw.Role,
+		Parts:      parts,
+		Model:      raw.Model,
+		CreatedAt:  raw.CreatedAt,
+		UpdatedAt:  raw.UpdatedAt,
+		RevertedAt: raw.RevertedAt,
+	}
+	return nil
+}
+
 func marshallParts(parts []ContentPart) ([]byte, error) {
 	wrappedParts := make([]partWrapper, len(parts))
 
@@ -198,6 +309,8 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 			typ = toolResultType
 		case Finish:
 			typ = finishType
+		case Summary:
+			typ = summaryType
 		default:
 			return nil, fmt.Errorf("unknown part type: %T", part)
 		}
@@ -271,6 +384,12 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 				return nil, err
 			}
 			parts = append(parts, part)
+		case summaryType:
+			part := Summary{}
+			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unknown part type: %s", wrapper.Type)
 		}
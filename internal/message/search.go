@@ -0,0 +1,75 @@
+package message
+
+import (
+	"context"
+	"strings"
+
+	"github.com/opencode-ai/opencode/internal/db"
+)
+
+// Markers around the matched terms in the snippets of search results
+const (
+	MatchStart = "\x02"
+	MatchEnd   = "\x03"
+)
+
+// SearchResult is a message matching a search, with the part of its text
+// around the matches
+type SearchResult struct {
+	SessionID    string      `json:"session_id"`
+	SessionTitle string      `json:"session_title"`
+	MessageID    string      `json:"message_id"`
+	Role         MessageRole `json:"role"`
+	CreatedAt    int64       `json:"created_at"`
+	// Snippet has the matched terms between MatchStart and MatchEnd
+	Snippet string `json:"snippet"`
+}
+
+// Search finds the messages of every session containing all the words of
+// query, the best matches first. The text, reasoning, tool inputs and tool
+// results of the messages are searched.
+func (s *service) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	match := searchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := s.q.SearchMessages(ctx, db.SearchMessagesParams{
+		Query: match,
+		Limit: int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = SearchResult{
+			SessionID:    row.SessionID,
+			SessionTitle: row.SessionTitle,
+			MessageID:    row.ID,
+			Role:         MessageRole(row.Role),
+			CreatedAt:    row.CreatedAt,
+			Snippet:      row.Snippet,
+		}
+	}
+	return results, nil
+}
+
+// searchQuery turns the words of a query into an FTS5 query matching all of
+// them. Each word is quoted so the query syntax can't make the search fail,
+// a trailing * still matches a prefix.
+func searchQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,74 @@
+package message
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := session.NewService(q, conn)
+	messages := NewService(q)
+	ctx := context.Background()
+
+	sess, err := sessions.Create(ctx, "Migration bug")
+	require.NoError(t, err)
+	prompt, err := messages.Create(ctx, sess.ID, CreateMessageParams{
+		Role:  User,
+		Parts: []ContentPart{TextContent{Text: "The goose migrations fail on startup"}},
+	})
+	require.NoError(t, err)
+	answer, err := messages.Create(ctx, sess.ID, CreateMessageParams{Role: Assistant})
+	require.NoError(t, err)
+	answer.AppendContent("Let me run the tests")
+	answer.AddToolCall(ToolCall{ID: "c1", Name: "bash", Input: `{"command":"go test ./internal/db/..."}`})
+	require.NoError(t, messages.Update(ctx, answer))
+	// Messages being streamed are indexed once finished
+	results, err := messages.Search(ctx, "internal/db", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	answer.AddFinish(FinishReasonToolUse)
+	require.NoError(t, messages.Update(ctx, answer))
+	_, err = messages.Create(ctx, sess.ID, CreateMessageParams{
+		Role:  Tool,
+		Parts: []ContentPart{ToolResult{ToolCallID: "c1", Content: "FAIL: duplicate column reverted_at"}},
+	})
+	require.NoError(t, err)
+
+	search := func(query string) []SearchResult {
+		results, err := messages.Search(ctx, query, 10)
+		require.NoError(t, err)
+		return results
+	}
+
+	results = search("migration")
+	require.Len(t, results, 1)
+	assert.Equal(t, prompt.ID, results[0].MessageID)
+	assert.Equal(t, "Migration bug", results[0].SessionTitle)
+	assert.Contains(t, results[0].Snippet, MatchStart+"migrations"+MatchEnd)
+
+	// Updates, tool inputs and tool results are indexed
+	assert.Len(t, search("internal/db"), 1)
+	assert.Len(t, search("duplicate column"), 1)
+	assert.Len(t, search("dupl*"), 1)
+	assert.Empty(t, search("duplicate startup"))
+	// The query syntax of FTS5 is escaped
+	assert.Empty(t, search(`"unbalanced OR -`))
+	assert.Empty(t, search("  "))
+
+	require.NoError(t, sessions.Delete(ctx, sess.ID))
+	assert.Empty(t, search("migration"))
+}
@@ -2,12 +2,14 @@ package permission
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
 	"slices"
 	"sync"
 
 	"github.com/google/uuid"
 	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/pubsub"
 )
 
@@ -20,21 +22,41 @@ type CreatePermissionRequest struct {
 	Action      string `json:"action"`
 	Params      any    `json:"params"`
 	Path        string `json:"path"`
+	// Agent is the agent making the request, Command the command run by bash
+	// and FilePath the file changed by the editing tools, for the rules
+	Agent    string `json:"agent,omitempty"`
+	Command  string `json:"command,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+	// FilePaths are all the files changed by the request when it changes
+	// several, the rules must allow each of them
+	FilePaths []string `json:"file_paths,omitempty"`
+	// Sandboxed requests are approved unless a rule denies or asks for them
+	Sandboxed bool `json:"sandboxed,omitempty"`
 }
 
 type PermissionRequest struct {
-	ID          string `json:"id"`
-	SessionID   string `json:"session_id"`
-	ToolName    string `json:"tool_name"`
-	Description string `json:"description"`
-	Action      string `json:"action"`
-	Params      any    `json:"params"`
-	Path        string `json:"path"`
+	ID          string   `json:"id"`
+	SessionID   string   `json:"session_id"`
+	ToolName    string   `json:"tool_name"`
+	Description string   `json:"description"`
+	Action      string   `json:"action"`
+	Params      any      `json:"params"`
+	Path        string   `json:"path"`
+	Agent       string   `json:"agent,omitempty"`
+	Command     string   `json:"command,omitempty"`
+	FilePath    string   `json:"file_path,omitempty"`
+	FilePaths   []string `json:"file_paths,omitempty"`
+	Sandboxed   bool     `json:"sandboxed,omitempty"`
+	// AutoApproved is set for the requests of sessions that don't ask
+	AutoApproved bool `json:"auto_approved,omitempty"`
 }
 
 type Service interface {
 	pubsub.Suscriber[PermissionRequest]
 	GrantPersistant(permission PermissionRequest)
+	// GrantAlways allows the request and saves a rule allowing the same ones
+	// to the rules file of the project
+	GrantAlways(permission PermissionRequest) error
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
 	Request(opts CreatePermissionRequest) bool
@@ -47,6 +69,12 @@ type permissionService struct {
 	sessionPermissions  []PermissionRequest
 	pendingRequests     sync.Map
 	autoApproveSessions []string
+
+	// rules are the ones of the config, fileRules the ones of rulesFile
+	rulesMu   sync.RWMutex
+	rules     []config.PermissionRule
+	fileRules []config.PermissionRule
+	rulesFile string
 }
 
 func (s *permissionService) GrantPersistant(permission PermissionRequest) {
@@ -57,6 +85,31 @@ func (s *permissionService) GrantPersistant(permission PermissionRequest) {
 	s.sessionPermissions = append(s.sessionPermissions, permission)
 }
 
+func (s *permissionService) GrantAlways(permission PermissionRequest) error {
+	s.Grant(permission)
+
+	rule := grantRule(permission)
+	s.rulesMu.Lock()
+	defer s.rulesMu.Unlock()
+	if slices.ContainsFunc(s.fileRules, func(r config.PermissionRule) bool {
+		return r.Tool == rule.Tool && r.Action == rule.Action && r.Command == rule.Command && r.Path == rule.Path &&
+			r.Exact == rule.Exact && len(r.Agents) == 0 && len(r.Sessions) == 0
+	}) {
+		return nil
+	}
+	// Rules added to the file since it was loaded are kept
+	rules, err := LoadRules(s.rulesFile)
+	if err != nil {
+		return err
+	}
+	rules = append(rules, rule)
+	if err := saveRules(s.rulesFile, rules); err != nil {
+		return fmt.Errorf("failed to save permission rules: %w", err)
+	}
+	s.fileRules = rules
+	return nil
+}
+
 func (s *permissionService) Grant(permission PermissionRequest) {
 	respCh, ok := s.pendingRequests.Load(permission.ID)
 	if ok {
@@ -72,9 +125,6 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 }
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
-	if slices.Contains(s.autoApproveSessions, opts.SessionID) {
-		return true
-	}
 	dir := filepath.Dir(opts.Path)
 	if dir == "." {
 		dir = config.WorkingDirectory()
@@ -87,11 +137,35 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 		Description: opts.Description,
 		Action:      opts.Action,
 		Params:      opts.Params,
+		Agent:       opts.Agent,
+		Command:     opts.Command,
+		FilePath:    opts.FilePath,
+		FilePaths:   opts.FilePaths,
+		Sandboxed:   opts.Sandboxed,
+	}
+
+	s.rulesMu.RLock()
+	action, ruled := decideFiles(append(slices.Clone(s.rules), s.fileRules...), permission)
+	s.rulesMu.RUnlock()
+	if ruled && action == config.PermissionDeny {
+		logging.InfoPersist(fmt.Sprintf("Denied by a permission rule: %s", permission.Description))
+		return false
 	}
 
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			return true
+	// Auto-approved requests are still published so that they can be logged
+	if slices.Contains(s.autoApproveSessions, opts.SessionID) || ruled && action == config.PermissionAllow ||
+		opts.Sandboxed && action != config.PermissionAsk {
+		permission.AutoApproved = true
+		s.Publish(pubsub.CreatedEvent, permission)
+		return true
+	}
+
+	// Ask rules win over the requests allowed for the session
+	if action != config.PermissionAsk {
+		for _, p := range s.sessionPermissions {
+			if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
+				return true
+			}
 		}
 	}
 
@@ -104,6 +178,8 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 
 	// Wait for the response with a timeout
 	resp := <-respCh
+	// Let the other clients know the request was answered
+	s.Publish(pubsub.DeletedEvent, permission)
 	return resp
 }
 
@@ -111,9 +187,18 @@ func (s *permissionService) AutoApproveSession(sessionID string) {
 	s.autoApproveSessions = append(s.autoApproveSessions, sessionID)
 }
 
-func NewPermissionService() Service {
+// NewPermissionService creates a service applying the rules of the config and
+// of a rules file, see RulesFile
+func NewPermissionService(rules []config.PermissionRule, rulesFile string) Service {
+	fileRules, err := LoadRules(rulesFile)
+	if err != nil {
+		logging.Warn("Failed to load permission rules", "file", rulesFile, "error", err)
+	}
 	return &permissionService{
 		Broker:             pubsub.NewBroker[PermissionRequest](),
 		sessionPermissions: make([]PermissionRequest, 0),
+		rules:              rules,
+		fileRules:          fileRules,
+		rulesFile:          rulesFile,
 	}
 }
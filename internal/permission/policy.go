@@ -0,0 +1,293 @@
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/opencode-ai/opencode/internal/config"
+)
+
+// RulesFileName is the file of the data directory holding the permission
+// rules of a project, including the ones granted with "allow always"
+const RulesFileName = "permissions.json"
+
+type rulesFile struct {
+	Rules []config.PermissionRule `json:"rules"`
+}
+
+// RulesFile returns the path of the permission rules of the project
+func RulesFile() string {
+	return filepath.Join(config.Get().Data.Directory, RulesFileName)
+}
+
+// LoadRules reads a file of permission rules, a missing file has no rules
+func LoadRules(file string) ([]config.PermissionRule, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules rulesFile
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	for i, rule := range rules.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("rule %d of %s: %w", i+1, file, err)
+		}
+	}
+	return rules.Rules, nil
+}
+
+func saveRules(file string, rules []config.PermissionRule) error {
+	data, err := json.MarshalIndent(rulesFile{Rules: rules}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o644)
+}
+
+// decide returns the action of the rules matching a request, deny winning
+// over ask and ask over allow. ok is false when no rule matches.
+func decide(rules []config.PermissionRule, request PermissionRequest) (action config.PermissionAction, ok bool) {
+	priority := map[config.PermissionAction]int{
+		config.PermissionAllow: 1,
+		config.PermissionAsk:   2,
+		config.PermissionDeny:  3,
+	}
+	for _, rule := range rules {
+		if matchRule(rule, request) && priority[rule.Action] > priority[action] {
+			action, ok = rule.Action, true
+		}
+	}
+	return action, ok
+}
+
+// decideFiles decides like decide for a request changing several files,
+// checking the rules against each of them. A file denied or asked for decides
+// for the request, which is only allowed when every file is.
+func decideFiles(rules []config.PermissionRule, request PermissionRequest) (action config.PermissionAction, ok bool) {
+	if len(request.FilePaths) == 0 {
+		return decide(rules, request)
+	}
+	files := slices.Clone(request.FilePaths)
+	if request.FilePath != "" && !slices.Contains(files, request.FilePath) {
+		files = append(files, request.FilePath)
+	}
+	allowed := 0
+	for _, file := range files {
+		fileRequest := request
+		fileRequest.FilePath = file
+		fileAction, fileOk := decide(rules, fileRequest)
+		switch {
+		case !fileOk:
+		case fileAction == config.PermissionDeny:
+			return fileAction, true
+		case fileAction == config.PermissionAsk:
+			action, ok = fileAction, true
+		default:
+			allowed++
+		}
+	}
+	if ok {
+		return action, ok
+	}
+	if allowed == len(files) {
+		return config.PermissionAllow, true
+	}
+	return "", false
+}
+
+func matchRule(rule config.PermissionRule, request PermissionRequest) bool {
+	if rule.Tool != "" {
+		if ok, _ := path.Match(rule.Tool, request.ToolName); !ok {
+			return false
+		}
+	}
+	if len(rule.Agents) > 0 && !slices.Contains(rule.Agents, config.AgentName(request.Agent)) {
+		return false
+	}
+	if len(rule.Sessions) > 0 && !slices.Contains(rule.Sessions, request.SessionID) {
+		return false
+	}
+	if rule.Command != "" {
+		if rule.Exact && normalizeCommand(request.Command) != normalizeCommand(rule.Command) {
+			return false
+		}
+		if !rule.Exact && !matchCommand(rule.Command, request.Command, rule.Action == config.PermissionAllow) {
+			return false
+		}
+	}
+	if rule.Path != "" && !matchPath(rule.Path, request.FilePath) {
+		return false
+	}
+	return true
+}
+
+// matchCommand matches the simple commands of a shell command line. An allow
+// rule must match all of them, so that "go test *" doesn't allow
+// "go test ./... && rm -rf ~", while the other rules match any of them.
+// Command and process substitutions can run anything and are never allowed by
+// a rule.
+func matchCommand(pattern, command string, all bool) bool {
+	literal := !strings.ContainsAny(pattern, "*?")
+	// Command lines written in a rule are matched as a whole
+	if literal && normalizeCommand(command) == pattern {
+		return true
+	}
+	commands := splitCommand(command)
+	if len(commands) == 0 {
+		return false
+	}
+	if all && slices.ContainsFunc([]string{"$(", "`", "<(", ">("}, func(substitution string) bool {
+		return strings.Contains(command, substitution)
+	}) {
+		return false
+	}
+	re := globRegexp(pattern, ".*", ".")
+	matches := func(cmd string) bool {
+		if literal {
+			return cmd == pattern || strings.HasPrefix(cmd, pattern+" ")
+		}
+		return re.MatchString(cmd)
+	}
+	if all {
+		return !slices.ContainsFunc(commands, func(cmd string) bool { return !matches(cmd) })
+	}
+	return slices.ContainsFunc(commands, matches)
+}
+
+// normalizeCommand collapses the spaces of a command line
+func normalizeCommand(command string) string {
+	return strings.Join(strings.Fields(command), " ")
+}
+
+// splitCommand splits a command line on the shell control operators outside
+// of quotes, collapsing the spaces of each command
+func splitCommand(command string) []string {
+	var commands []string
+	var current strings.Builder
+	flush := func() {
+		if cmd := strings.Join(strings.Fields(current.String()), " "); cmd != "" {
+			commands = append(commands, cmd)
+		}
+		current.Reset()
+	}
+	var quote rune
+	escaped := false
+	runes := []rune(command)
+	for i, r := range runes {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '&' && (i > 0 && strings.ContainsRune("<>", runes[i-1]) || i+1 < len(runes) && runes[i+1] == '>'):
+			// A redirection like 2>&1 or &>
+		case strings.ContainsRune(";&|\n", r):
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return commands
+}
+
+// matchPath matches a file against a pattern relative to the working
+// directory, or an absolute one
+func matchPath(pattern, file string) bool {
+	if file == "" {
+		return false
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(config.WorkingDirectory(), file)
+	}
+	file = filepath.Clean(file)
+	if !filepath.IsAbs(pattern) {
+		rel, err := filepath.Rel(config.WorkingDirectory(), file)
+		if err != nil || outside(rel) {
+			return false
+		}
+		file = rel
+	}
+	file = filepath.ToSlash(file)
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	if !strings.ContainsAny(pattern, "*?") {
+		return file == pattern || pattern == "." || strings.HasPrefix(file, strings.TrimSuffix(pattern, "/")+"/")
+	}
+	return globRegexp(pattern, "[^/]*", "[^/]").MatchString(file)
+}
+
+// globRegexp compiles a glob where ** matches any text, * matches star and
+// ? matches one
+func globRegexp(pattern, star, one string) *regexp.Regexp {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			// Also matches no directory at all
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			re.WriteString(star)
+		case pattern[i] == '?':
+			re.WriteString(one)
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String())
+}
+
+// grantRule is the rule saved when a request is allowed always: exactly the
+// same command for bash, the files of the directory of the request for the
+// tools changing files and the tool otherwise
+func grantRule(request PermissionRequest) config.PermissionRule {
+	rule := config.PermissionRule{
+		Tool:   request.ToolName,
+		Action: config.PermissionAllow,
+	}
+	switch {
+	case request.Command != "":
+		rule.Command = normalizeCommand(request.Command)
+		rule.Exact = true
+	case request.FilePath != "":
+		rule.Path = filepath.ToSlash(request.Path) + "/*"
+		if rel, err := filepath.Rel(config.WorkingDirectory(), request.Path); err == nil && !outside(rel) {
+			rule.Path = "*"
+			if rel != "." {
+				rule.Path = filepath.ToSlash(rel) + "/*"
+			}
+		}
+	}
+	return rule
+}
+
+// outside reports whether a relative path leaves its base directory
+func outside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
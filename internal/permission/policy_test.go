@@ -0,0 +1,162 @@
+package permission
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchCommand(t *testing.T) {
+	tests := []struct {
+		pattern string
+		command string
+		all     bool
+		want    bool
+	}{
+		{"go test *", "go test ./...", true, true},
+		{"go test *", "go  test ./internal/... 2>&1", true, true},
+		{"go test", "go test -run TestX ./...", true, true},
+		{"go test", "go testdata", true, false},
+		{"go test *", "go test ./... && rm -rf ~", true, false},
+		{"go test *", "go test $(rm -rf ~)", true, false},
+		{"go test *", "go test `rm -rf ~`", true, false},
+		{"go test *", "go test ./... >(rm -rf ~)", true, false},
+		{"go test *", "go test ./... <(rm -rf ~)", true, false},
+		{"diff *", "diff <(ls a) <(ls b)", false, true},
+		{"go test *", "echo 'a && b' | go test ./...", true, false},
+		{"rm *", "go test ./... && rm -rf ~", false, true},
+		{"git push", "git add . ; git push origin", false, true},
+		{"git push", "echo 'git push'", false, false},
+		{"go build ./... && go test ./...", "go build ./... && go test ./...", true, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchCommand(tt.pattern, tt.command, tt.all), "%q ~ %q", tt.pattern, tt.command)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	wd := t.TempDir()
+	_, err := config.Load(wd, false)
+	require.NoError(t, err)
+	config.Get().WorkingDir = wd
+
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"migrations/**", filepath.Join(wd, "migrations/001.sql"), true},
+		{"migrations/**", filepath.Join(wd, "db/migrations/001.sql"), false},
+		{"**/migrations/*.sql", filepath.Join(wd, "db/migrations/001.sql"), true},
+		{"**/migrations/*.sql", filepath.Join(wd, "migrations/001.sql"), true},
+		{"*.go", filepath.Join(wd, "internal/a.go"), false},
+		{"internal", filepath.Join(wd, "internal/a.go"), true},
+		{"internal", filepath.Join(wd, "internals/a.go"), false},
+		{"**", "/etc/passwd", false},
+		{"/etc/**", "/etc/passwd", true},
+		{"cmd/*.go", "cmd/root.go", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchPath(tt.pattern, tt.file), "%q ~ %q", tt.pattern, tt.file)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	wd := t.TempDir()
+	_, err := config.Load(wd, false)
+	require.NoError(t, err)
+	config.Get().WorkingDir = wd
+
+	rulesFile := filepath.Join(wd, ".opencode", RulesFileName)
+	service := NewPermissionService([]config.PermissionRule{
+		{Tool: "bash", Action: config.PermissionAllow, Command: "go test *"},
+		{Tool: "edit", Action: config.PermissionAllow},
+		{Tool: "edit", Action: config.PermissionDeny, Path: "migrations/**"},
+		{Tool: "write", Action: config.PermissionDeny, Agents: []config.AgentName{"reviewer"}},
+		{Tool: "bash", Action: config.PermissionDeny, Command: "rm *"},
+	}, rulesFile)
+
+	// request fails the test when the user is asked. The subscription lasts
+	// for the test, the broker can send to a channel closed by a cancel.
+	events := service.Subscribe(t.Context())
+	request := func(opts CreatePermissionRequest) bool {
+		answered := make(chan bool, 1)
+		go func() { answered <- service.Request(opts) }()
+		for {
+			select {
+			case ok := <-answered:
+				return ok
+			case event := <-events:
+				if event.Type == pubsub.CreatedEvent && !event.Payload.AutoApproved {
+					t.Errorf("asked for %+v", opts)
+					service.Deny(event.Payload)
+				}
+			}
+		}
+	}
+
+	assert.True(t, request(CreatePermissionRequest{ToolName: "bash", Command: "go test ./..."}))
+	assert.True(t, request(CreatePermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "main.go")}))
+	assert.False(t, request(CreatePermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "migrations/001.sql")}))
+	assert.False(t, request(CreatePermissionRequest{ToolName: "write", Agent: "reviewer", FilePath: filepath.Join(wd, "a.go")}))
+	// A change of several files is denied when any of them is
+	assert.True(t, request(CreatePermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "main.go"), FilePaths: []string{
+		filepath.Join(wd, "main.go"), filepath.Join(wd, "util.go"),
+	}}))
+	assert.False(t, request(CreatePermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "main.go"), FilePaths: []string{
+		filepath.Join(wd, "main.go"), filepath.Join(wd, "migrations/001.sql"),
+	}}))
+	assert.True(t, request(CreatePermissionRequest{ToolName: "bash", Command: "make build", Sandboxed: true}))
+	assert.False(t, request(CreatePermissionRequest{ToolName: "bash", Command: "rm -rf build", Sandboxed: true}))
+
+	// Allowed always, even after a restart
+	grant := PermissionRequest{ToolName: "bash", Command: "make lint", Path: wd}
+	require.NoError(t, service.GrantAlways(grant))
+	require.NoError(t, service.GrantAlways(grant))
+	rules, err := LoadRules(rulesFile)
+	require.NoError(t, err)
+	assert.Equal(t, []config.PermissionRule{{Tool: "bash", Action: config.PermissionAllow, Command: "make lint", Exact: true}}, rules)
+	service = NewPermissionService(nil, rulesFile)
+	events = service.Subscribe(t.Context())
+	assert.True(t, request(CreatePermissionRequest{ToolName: "bash", Command: "make lint"}))
+}
+
+func TestGrantRule(t *testing.T) {
+	wd := t.TempDir()
+	_, err := config.Load(wd, false)
+	require.NoError(t, err)
+	config.Get().WorkingDir = wd
+
+	matches := func(rule config.PermissionRule, request PermissionRequest) bool {
+		action, ok := decide([]config.PermissionRule{rule}, request)
+		return ok && action == config.PermissionAllow
+	}
+
+	// Commands allowed always don't allow other arguments
+	rule := grantRule(PermissionRequest{ToolName: "bash", Command: "rm  build.log"})
+	assert.Equal(t, config.PermissionRule{Tool: "bash", Action: config.PermissionAllow, Command: "rm build.log", Exact: true}, rule)
+	assert.True(t, matches(rule, PermissionRequest{ToolName: "bash", Command: "rm build.log"}))
+	assert.False(t, matches(rule, PermissionRequest{ToolName: "bash", Command: "rm build.log -rf ~"}))
+	assert.False(t, matches(rule, PermissionRequest{ToolName: "bash", Command: "rm build.log && rm -rf ~"}))
+	rule = grantRule(PermissionRequest{ToolName: "bash", Command: "rm *.log"})
+	assert.False(t, matches(rule, PermissionRequest{ToolName: "bash", Command: "rm -rf ~ x.log"}))
+
+	// Files allowed always only allow the files of the same directory
+	rule = grantRule(PermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "main.go"), Path: wd})
+	assert.Equal(t, "*", rule.Path)
+	assert.True(t, matches(rule, PermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "go.mod")}))
+	assert.False(t, matches(rule, PermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "migrations/001.sql")}))
+
+	rule = grantRule(PermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "cmd/main.go"), Path: filepath.Join(wd, "cmd")})
+	assert.Equal(t, "cmd/*", rule.Path)
+	assert.True(t, matches(rule, PermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "cmd/root.go")}))
+	assert.False(t, matches(rule, PermissionRequest{ToolName: "edit", FilePath: filepath.Join(wd, "cmd/schema/main.go")}))
+
+	rule = grantRule(PermissionRequest{ToolName: "write", FilePath: "/etc/hosts", Path: "/etc"})
+	assert.Equal(t, "/etc/*", rule.Path)
+	assert.False(t, matches(rule, PermissionRequest{ToolName: "write", FilePath: "/etc/ssh/sshd_config"}))
+}
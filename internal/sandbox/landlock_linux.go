@@ -0,0 +1,110 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Landlock rules of TCP ports, missing from x/sys
+const landlockRuleNetPort = 2
+
+type landlockNetPortAttr struct {
+	allowedAccess uint64
+	port          uint64
+}
+
+// landlock restricts the writes to the writable paths, and the TCP
+// connections to the allowed ports when the network is shared. Without
+// Landlock in the kernel only the namespaces restrict the command.
+func landlock(writable []string, opts Options) error {
+	restrictNetwork := opts.Network && len(opts.AllowedPorts) > 0
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		if restrictNetwork {
+			return fmt.Errorf("allowed ports need Landlock: %w", errno)
+		}
+		return nil
+	}
+
+	// Reading and executing stay allowed everywhere, only the writes are
+	// handled
+	fsAccess := uint64(unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		fsAccess |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		fsAccess |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	attr := unix.LandlockRulesetAttr{Access_fs: fsAccess}
+	if restrictNetwork {
+		if abi < 4 {
+			return fmt.Errorf("allowed ports need Landlock ABI 4, the kernel has %d", abi)
+		}
+		attr.Access_net = unix.LANDLOCK_ACCESS_NET_CONNECT_TCP
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create the Landlock ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	for _, path := range writable {
+		if err := allowPath(ruleset, path, fsAccess); err != nil {
+			return fmt.Errorf("failed to allow writes to %s: %w", path, err)
+		}
+	}
+	if restrictNetwork {
+		for _, port := range opts.AllowedPorts {
+			rule := landlockNetPortAttr{
+				allowedAccess: unix.LANDLOCK_ACCESS_NET_CONNECT_TCP,
+				port:          uint64(port),
+			}
+			if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), landlockRuleNetPort, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+				return fmt.Errorf("failed to allow port %d: %w", port, errno)
+			}
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("failed to enforce the Landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+func allowPath(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	// Files only take the rights of files
+	if !info.IsDir() {
+		access &= unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	rule := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd),
+	}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package sandbox runs commands with restricted access to the filesystem and
+// the network. On Linux the command runs in its own user, mount and network
+// namespaces, where the filesystem is read-only except for a few writable
+// paths, and Landlock enforces the same restrictions where the kernel has it.
+// The runtime directories holding the sockets of the services of the host are
+// hidden, and the temporary directory is private to the sandbox.
+//
+// OpenCode sets the sandbox up by running itself with the options in the
+// environment, so main must call Init before anything else.
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrUnsupported is returned on the platforms without a sandbox
+var ErrUnsupported = errors.New("the sandbox is only supported on Linux")
+
+// envOptions holds the options of the sandbox in the environment of the
+// process setting it up
+const envOptions = "OPENCODE_SANDBOX"
+
+// Options restrict what a sandboxed command can do.
+type Options struct {
+	// Writable are the paths the command can write to, everything else is
+	// read-only
+	Writable []string `json:"writable"`
+	// ReadOnly are paths kept read-only below the writable ones. The missing
+	// ones are created as empty directories, so that the command can't
+	// create them either, and are left behind.
+	ReadOnly []string `json:"read_only,omitempty"`
+	// Network shares the network of the host, without it the command only
+	// has a loopback interface of its own
+	Network bool `json:"network"`
+	// AllowedPorts limits the TCP connections of a command sharing the
+	// network to these ports, it needs Landlock with network support
+	AllowedPorts []int `json:"allowed_ports,omitempty"`
+}
+
+// DefaultWritable are the paths writable in every sandbox: the working
+// directory, the temporary directory and the devices
+func DefaultWritable(workingDir string) []string {
+	return []string{workingDir, os.TempDir(), "/dev"}
+}
+
+// DefaultReadOnly are the paths of the working directory that stay read-only
+// in every sandbox: the git directory, whose hooks and config run commands,
+// and the files of OpenCode, whose config and permission rules run commands
+// outside of the sandbox. The config and the data directory are protected
+// even when they are missing.
+func DefaultReadOnly(workingDir string) []string {
+	paths := []string{
+		filepath.Join(workingDir, ".git"),
+		filepath.Join(workingDir, ".opencode"),
+		filepath.Join(workingDir, ".opencode.json"),
+	}
+	matches, _ := filepath.Glob(filepath.Join(workingDir, ".opencode*"))
+	for _, match := range matches {
+		if !slices.Contains(paths, match) {
+			paths = append(paths, match)
+		}
+	}
+	return paths
+}
+
+// writablePaths returns the absolute paths that exist among the writable
+// ones, see resolvePaths
+func (o Options) writablePaths() []string {
+	return resolvePaths(o.Writable, false)
+}
+
+// readOnlyPaths returns the absolute read-only paths, the missing ones
+// included, see resolvePaths
+func (o Options) readOnlyPaths() []string {
+	return resolvePaths(o.ReadOnly, true)
+}
+
+// resolvePaths returns the absolute paths of list, resolving symbolic links
+// so that the real path is bound, and drops the missing ones unless missing
+// is set. Relative paths are relative to the directory of the command, and ~
+// is the home directory.
+func resolvePaths(list []string, missing bool) []string {
+	var paths []string
+	for _, path := range list {
+		if path == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		} else if resolved, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+			// The parent of a missing path can be a link too
+			path = filepath.Join(resolved, filepath.Base(path))
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Lstat(abs); err == nil || missing {
+			paths = append(paths, abs)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,358 @@
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Init sets the sandbox up and runs the sandboxed command when OpenCode was
+// started by Command, and returns otherwise. It must be called first in main.
+func Init() {
+	data, ok := os.LookupEnv(envOptions)
+	if !ok {
+		return
+	}
+	os.Unsetenv(envOptions)
+	err := run(data, os.Args[1:])
+	fmt.Fprintf(os.Stderr, "opencode: sandbox: %v\n", err)
+	os.Exit(126)
+}
+
+// Command returns a command running name with args in a sandbox. The command
+// starts OpenCode in new namespaces, which restricts itself and then runs
+// name.
+func Command(name string, args []string, opts Options) (*exec.Cmd, error) {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("/proc/self/exe", append([]string{name}, args...)...)
+	cmd.Env = append(os.Environ(), envOptions+"="+string(data))
+
+	cloneflags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS)
+	if !opts.Network {
+		cloneflags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: cloneflags,
+		// The user keeps its IDs, and the capabilities it has in the new
+		// namespaces until the sandbox is set up
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+		AmbientCaps: []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_NET_ADMIN},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	return cmd, nil
+}
+
+// run restricts the process and replaces it with the command, it only
+// returns on errors
+func run(data string, command []string) error {
+	var opts Options
+	if err := json.Unmarshal([]byte(data), &opts); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("no command")
+	}
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return err
+	}
+
+	// Landlock and the prctl calls only apply to the calling thread, the one
+	// that execs the command
+	runtime.LockOSThread()
+	writable, err := restrictFilesystem(opts.writablePaths(), opts.readOnlyPaths())
+	if err != nil {
+		return err
+	}
+	// The agent socket is hidden with the temporary directory
+	os.Unsetenv("SSH_AUTH_SOCK")
+	// The working directory still refers to the mounts of before
+	if wd, err := os.Getwd(); err == nil {
+		if err := os.Chdir(wd); err != nil {
+			return fmt.Errorf("failed to change to the working directory: %w", err)
+		}
+	}
+	if !opts.Network {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("failed to set up the loopback interface: %w", err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	if err := landlock(writable, opts); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to drop the capabilities: %w", err)
+	}
+	return syscall.Exec(path, command, os.Environ())
+}
+
+// hiddenDirs are replaced by empty read-only directories, they hold the
+// sockets of the services of the host, like Docker and D-Bus, which commands
+// can connect to on a read-only filesystem
+func hiddenDirs() []string {
+	dirs := []string{"/run", "/var/run"}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return existingDirs(dirs)
+}
+
+// privateDirs are replaced by empty writable directories, the temporary
+// directories are shared by every process of the user, and hold sockets like
+// the one of ssh-agent
+func privateDirs() []string {
+	return existingDirs([]string{"/tmp", os.TempDir()})
+}
+
+// existingDirs resolves the symbolic links of dirs, and drops the missing
+// ones and the ones below another
+func existingDirs(dirs []string) []string {
+	var resolved []string
+	for _, dir := range dirs {
+		path, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if !slices.Contains(resolved, path) {
+			resolved = append(resolved, path)
+		}
+	}
+	var result []string
+	for _, dir := range resolved {
+		others := slices.DeleteFunc(slices.Clone(resolved), func(other string) bool { return other == dir })
+		if !below(dir, others) {
+			result = append(result, dir)
+		}
+	}
+	return result
+}
+
+// restrictFilesystem hides the runtime directories, makes the temporary ones
+// private, and makes every mount read-only except the writable paths, which
+// are bound on themselves first so that they keep their own mounts. The
+// read-only paths are bound on themselves so that they stay read-only below
+// the writable ones, the missing ones are created first. It returns the writable paths, with the private ones.
+func restrictFilesystem(writable, readOnly []string) ([]string, error) {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return nil, fmt.Errorf("failed to make the mounts private: %w", err)
+	}
+	// The resolver config often lives in /run
+	resolvConf, _ := filepath.EvalSymlinks("/etc/resolv.conf")
+	resolvData, _ := os.ReadFile(resolvConf)
+	for _, dir := range hiddenDirs() {
+		if err := replaceDir(dir, writable); err != nil {
+			return nil, err
+		}
+	}
+	if len(resolvData) > 0 {
+		if _, err := os.Stat(resolvConf); err != nil {
+			if err := os.MkdirAll(filepath.Dir(resolvConf), 0o755); err == nil {
+				os.WriteFile(resolvConf, resolvData, 0o644)
+			}
+		}
+	}
+	private := privateDirs()
+	for _, dir := range private {
+		if err := replaceDir(dir, writable); err != nil {
+			return nil, err
+		}
+	}
+	writable = append(slices.Clone(writable), private...)
+
+	for _, path := range writable {
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return nil, fmt.Errorf("failed to bind %s: %w", path, err)
+		}
+	}
+	for _, path := range readOnly {
+		if !below(path, writable) {
+			continue
+		}
+		// A missing path gets an empty directory to bind, which the command
+		// can't replace by a file of its own
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", path, err)
+			}
+		}
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return nil, fmt.Errorf("failed to bind %s: %w", path, err)
+		}
+	}
+	mounts, err := mountPoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, mount := range mounts {
+		protected := below(mount, readOnly)
+		if below(mount, writable) && !protected {
+			continue
+		}
+		// Mounts hidden by others can't be changed, Landlock still covers
+		// what is below them
+		if err := remountReadOnly(mount); err != nil && (mount == "/" || protected) {
+			return nil, fmt.Errorf("failed to make %s read-only: %w", mount, err)
+		}
+	}
+	return writable, nil
+}
+
+// replaceDir mounts an empty tmpfs on dir. The writable paths below it are
+// bound again on the tmpfs, so that a working directory in /tmp stays
+// reachable.
+func replaceDir(dir string, writable []string) error {
+	var kept []string
+	var fds []int
+	defer func() {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+	}()
+	for _, path := range writable {
+		if path == dir || !below(path, []string{dir}) {
+			continue
+		}
+		fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		kept = append(kept, path)
+		fds = append(fds, fd)
+	}
+
+	if err := unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to hide %s: %w", dir, err)
+	}
+	for i, path := range kept {
+		var st unix.Stat_t
+		err := unix.Fstat(fds[i], &st)
+		if err != nil {
+			return err
+		}
+		if st.Mode&unix.S_IFMT == unix.S_IFDIR {
+			err = os.MkdirAll(path, 0o755)
+		} else {
+			err = os.MkdirAll(filepath.Dir(path), 0o755)
+			if err == nil {
+				err = os.WriteFile(path, nil, 0o644)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		source := fmt.Sprintf("/proc/self/fd/%d", fds[i])
+		if err := unix.Mount(source, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// mountPoints lists the mount points of the mount namespace
+func mountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mounts = append(mounts, unescapeMountPoint(fields[4]))
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPoint decodes the octal escapes of spaces, tabs, newlines and
+// backslashes in mountinfo
+func unescapeMountPoint(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// remountReadOnly keeps the flags of the mount, the ones locked by the
+// parent user namespace can't be cleared
+func remountReadOnly(mount string) error {
+	var st unix.Statfs_t
+	if err := unix.Statfs(mount, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for stFlag, msFlag := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(st.Flags)&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	return unix.Mount("", mount, "", flags, "")
+}
+
+// below reports whether path is one of dirs or below one of them
+func below(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
+
+// loopbackUp brings up the loopback interface of a new network namespace,
+// so that commands can still talk to the servers they start
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
@@ -0,0 +1,127 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// The sandboxed commands run the test binary first
+	Init()
+	os.Exit(m.Run())
+}
+
+func sandboxed(t *testing.T, opts Options, script string) (string, error) {
+	cmd, err := Command("/bin/sh", []string{"-c", script}, opts)
+	require.NoError(t, err)
+	if len(opts.Writable) > 0 {
+		cmd.Dir = opts.Writable[0]
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil && strings.Contains(string(out), "sandbox:") {
+		t.Skipf("no sandbox here: %s", out)
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+func TestSandbox(t *testing.T) {
+	writable := t.TempDir()
+	readOnly, err := os.MkdirTemp(filepath.Dir(os.Args[0]), "readonly")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(readOnly) })
+
+	out, err := sandboxed(t, Options{Writable: []string{writable, "/dev"}},
+		"echo ok > a && cat "+filepath.Join(writable, "a"))
+	require.NoError(t, err, out)
+	assert.Equal(t, "ok", out)
+
+	_, err = sandboxed(t, Options{Writable: []string{writable, "/dev"}}, "touch "+filepath.Join(readOnly, "a"))
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(readOnly, "a"))
+
+	// Only the loopback interface is up without the network
+	out, err = sandboxed(t, Options{Writable: []string{"/dev"}}, "cat /proc/net/dev")
+	require.NoError(t, err, out)
+	assert.Contains(t, out, "lo:")
+	assert.Len(t, strings.Split(out, "\n"), 3)
+
+	// The environment of the sandbox doesn't leak to the command
+	out, err = sandboxed(t, Options{}, "env")
+	require.NoError(t, err, out)
+	assert.NotContains(t, out, envOptions)
+}
+
+func TestSandboxHidesHostSockets(t *testing.T) {
+	writable := t.TempDir()
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(os.TempDir(), "agent.sock"))
+	shared := filepath.Join(os.TempDir(), "opencode-sandbox-shared")
+	require.NoError(t, os.WriteFile(shared, []byte("host"), 0o644))
+	t.Cleanup(func() { os.Remove(shared) })
+
+	// The working directory in the temporary directory stays writable, but
+	// the rest of it is private
+	out, err := sandboxed(t, Options{Writable: DefaultWritable(writable)},
+		"echo ok > a && cat a && ls "+shared+"; echo sandbox > "+filepath.Join(os.TempDir(), "private"))
+	require.NoError(t, err, out)
+	assert.True(t, strings.HasPrefix(out, "ok\n"), out)
+	assert.Contains(t, out, "No such file")
+	assert.NoFileExists(t, filepath.Join(os.TempDir(), "private"))
+
+	// The runtime directories are empty
+	out, err = sandboxed(t, Options{Writable: DefaultWritable(writable)}, "ls -A /run; echo ${SSH_AUTH_SOCK:-unset}")
+	require.NoError(t, err, out)
+	assert.NotContains(t, out, "docker.sock")
+	assert.True(t, strings.HasSuffix(out, "unset"), out)
+}
+
+func TestSandboxReadOnlyPaths(t *testing.T) {
+	writable := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(writable, ".git", "hooks"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(writable, ".opencode.json"), []byte("{}"), 0o644))
+	opts := Options{Writable: DefaultWritable(writable), ReadOnly: DefaultReadOnly(writable)}
+
+	for _, script := range []string{
+		"echo exit > .git/hooks/pre-commit",
+		"echo '{}' > .opencode.json",
+		"rm -rf .git",
+		"mv .opencode.json other.json",
+	} {
+		_, err := sandboxed(t, opts, script)
+		assert.Error(t, err, script)
+	}
+	assert.NoFileExists(t, filepath.Join(writable, ".git", "hooks", "pre-commit"))
+	assert.FileExists(t, filepath.Join(writable, ".opencode.json"))
+
+	out, err := sandboxed(t, opts, "echo ok > main.go && cat .opencode.json")
+	require.NoError(t, err, out)
+	assert.Equal(t, "{}", out)
+}
+
+func TestSandboxMissingReadOnlyPaths(t *testing.T) {
+	writable := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(writable, "config.json"), []byte("{}"), 0o644))
+	opts := Options{Writable: DefaultWritable(writable), ReadOnly: DefaultReadOnly(writable)}
+
+	// The config of OpenCode can't be created by a command
+	for _, script := range []string{
+		"echo '{}' > .opencode.json",
+		"rmdir .opencode.json && echo '{}' > .opencode.json",
+		"mv config.json .opencode.json",
+		"ln -s config.json .opencode.json",
+		"mkdir -p .opencode/commands",
+	} {
+		_, err := sandboxed(t, opts, script)
+		assert.Error(t, err, script)
+	}
+	for _, name := range []string{".opencode.json", ".opencode"} {
+		entries, err := os.ReadDir(filepath.Join(writable, name))
+		require.NoError(t, err, name)
+		assert.Empty(t, entries, name)
+	}
+	assert.FileExists(t, filepath.Join(writable, "config.json"))
+}
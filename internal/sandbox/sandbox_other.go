@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sandbox
+
+import "os/exec"
+
+// Init does nothing outside Linux
+func Init() {}
+
+// Command fails outside Linux
+func Command(name string, args []string, opts Options) (*exec.Cmd, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/jobs"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+const keepAliveInterval = 15 * time.Second
+
+// Event is a single entry of the SSE stream. Kind names the resource, Type is
+// the pubsub event type (created, updated, deleted).
+type Event struct {
+	Kind      string           `json:"kind"`
+	Type      pubsub.EventType `json:"type"`
+	SessionID string           `json:"session_id,omitempty"`
+	Payload   any              `json:"payload"`
+}
+
+// agentEventPayload is the JSON form of an agent.AgentEvent.
+type agentEventPayload struct {
+	Type     agent.AgentEventType `json:"type"`
+	Message  *message.Message     `json:"message,omitempty"`
+	Error    string               `json:"error,omitempty"`
+	Progress string               `json:"progress,omitempty"`
+	Plan     *agent.Plan          `json:"plan,omitempty"`
+	Budget   *agent.BudgetStatus  `json:"budget,omitempty"`
+	Done     bool                 `json:"done"`
+	// ToolCallID is the tool call a tool_progress event reports output of
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+func newAgentEvent(event pubsub.Event[agent.AgentEvent]) Event {
+	e := event.Payload
+	payload := agentEventPayload{
+		Type:     e.Type,
+		Progress: e.Progress,
+		Plan:     e.Plan,
+		Budget:   e.Budget,
+		Done:     e.Done,
+
+		ToolCallID: e.ToolCallID,
+	}
+	sessionID := e.SessionID
+	if e.Message.ID != "" {
+		payload.Message = &e.Message
+		if sessionID == "" {
+			sessionID = e.Message.SessionID
+		}
+	}
+	if e.Error != nil {
+		payload.Error = e.Error.Error()
+	}
+	return Event{Kind: "agent", Type: event.Type, SessionID: sessionID, Payload: payload}
+}
+
+// forwardEvents fans all app brokers into the server broker until ctx is done.
+func (s *Server) forwardEvents(ctx context.Context) {
+	forward(ctx, "sessions", s.app.Sessions.Subscribe, func(e pubsub.Event[session.Session]) Event {
+		return Event{Kind: "session", Type: e.Type, SessionID: e.Payload.ID, Payload: e.Payload}
+	}, s.events)
+	forward(ctx, "messages", s.app.Messages.Subscribe, func(e pubsub.Event[message.Message]) Event {
+		return Event{Kind: "message", Type: e.Type, SessionID: e.Payload.SessionID, Payload: e.Payload}
+	}, s.events)
+	forward(ctx, "files", s.app.History.Subscribe, func(e pubsub.Event[history.File]) Event {
+		return Event{Kind: "file", Type: e.Type, SessionID: e.Payload.SessionID, Payload: e.Payload}
+	}, s.events)
+	forward(ctx, "coderAgent", s.app.CoderAgent.Subscribe, newAgentEvent, s.events)
+	forward(ctx, "permissions", s.app.Permissions.Subscribe, s.trackPermission, s.events)
+	forward(ctx, "jobs", s.app.Jobs.Subscribe, func(e pubsub.Event[jobs.Job]) Event {
+		return Event{Kind: "job", Type: e.Type, SessionID: e.Payload.SessionID, Payload: e.Payload}
+	}, s.events)
+}
+
+// trackPermission keeps track of the requests waiting for an answer, so that
+// clients can answer them later, until they are answered.
+func (s *Server) trackPermission(e pubsub.Event[permission.PermissionRequest]) Event {
+	s.pendingMu.Lock()
+	switch {
+	case e.Type == pubsub.CreatedEvent && !e.Payload.AutoApproved:
+		s.pending[e.Payload.ID] = e.Payload
+	case e.Type == pubsub.DeletedEvent:
+		delete(s.pending, e.Payload.ID)
+	}
+	s.pendingMu.Unlock()
+	return Event{Kind: "permission", Type: e.Type, SessionID: e.Payload.SessionID, Payload: e.Payload}
+}
+
+func forward[T any](
+	ctx context.Context,
+	name string,
+	subscribe func(context.Context) <-chan pubsub.Event[T],
+	convert func(pubsub.Event[T]) Event,
+	out *pubsub.Broker[Event],
+) {
+	ch := subscribe(ctx)
+	go func() {
+		defer logging.RecoverPanic(fmt.Sprintf("server-subscription-%s", name), nil)
+		for event := range ch {
+			converted := convert(event)
+			out.Publish(converted.Type, converted)
+		}
+	}()
+}
+
+// handleEvents streams events as Server-Sent Events. The optional session_id
+// query parameter limits the stream to one session.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+	sessionID := r.URL.Query().Get("session_id")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := s.events.Subscribe(r.Context())
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if sessionID != "" && event.Payload.SessionID != sessionID {
+				continue
+			}
+			data, err := json.Marshal(event.Payload)
+			if err != nil {
+				logging.Error("Failed to encode event", "kind", event.Payload.Kind, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Payload.Kind, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+type createSessionRequest struct {
+	Title string `json:"title"`
+}
+
+type branchSessionRequest struct {
+	// MessageID is the last message copied to the branch, empty for none
+	MessageID string `json:"message_id"`
+}
+
+type revertSessionRequest struct {
+	// MessageID is the prompt to revert to, empty for the last one
+	MessageID string `json:"message_id"`
+	// DryRun only returns the changes the revert would make
+	DryRun bool `json:"dry_run"`
+}
+
+type sessionModeRequest struct {
+	Mode agent.Mode `json:"mode"`
+}
+
+type sessionAgentRequest struct {
+	Agent config.AgentName `json:"agent"`
+}
+
+type attachmentRequest struct {
+	// Path of a file to attach, relative to the working directory.
+	Path string `json:"path,omitempty"`
+	// Inline file content, base64 encoded.
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
+type promptRequest struct {
+	Content     string              `json:"content"`
+	Attachments []attachmentRequest `json:"attachments,omitempty"`
+}
+
+type promptResponse struct {
+	SessionID string `json:"session_id"`
+	Status    string `json:"status"`
+}
+
+type permissionAction string
+
+const (
+	permissionAllow           permissionAction = "allow"
+	permissionAllowForSession permissionAction = "allow_session"
+	permissionAllowAlways     permissionAction = "allow_always"
+	permissionDeny            permissionAction = "deny"
+)
+
+type permissionResponseRequest struct {
+	Action permissionAction `json:"action"`
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.app.Sessions.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req createSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Title == "" {
+		req.Title = "New Session"
+	}
+	sess, err := s.app.Sessions.Create(r.Context(), req.Title)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+func (s *Server) handleBranchSession(w http.ResponseWriter, r *http.Request) {
+	var req branchSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	sess, err := s.app.Sessions.Branch(r.Context(), r.PathValue("id"), req.MessageID)
+	if errors.Is(err, session.ErrIncompleteTurn) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+func (s *Server) handleRevertSession(w http.ResponseWriter, r *http.Request) {
+	var req revertSessionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	plan, err := s.app.PlanRevert(r.Context(), r.PathValue("id"), req.MessageID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !req.DryRun {
+		if err := s.app.Revert(r.Context(), plan); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, agent.ErrSessionBusy) {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+func (s *Server) handleSetSessionMode(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req sessionModeRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if err := s.app.CoderAgent.SetMode(id, req.Mode); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, agent.ErrSessionBusy) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (s *Server) handleSetSessionAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req sessionAgentRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	if err := s.app.CoderAgent.SetAgent(r.Context(), id, req.Agent); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, agent.ErrSessionBusy) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
+		return
+	}
+	sess, err := s.app.Sessions.Get(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.app.Sessions.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.app.CoderAgent.IsSessionBusy(id) {
+		writeError(w, http.StatusConflict, agent.ErrSessionBusy)
+		return
+	}
+	if err := s.app.Sessions.Delete(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	msgs, err := s.app.Messages.List(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req promptRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Content == "" {
+		writeError(w, http.StatusBadRequest, errors.New("content is required"))
+		return
+	}
+	if _, err := s.app.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	attachments := make([]message.Attachment, 0, len(req.Attachments))
+	for _, a := range req.Attachments {
+		attachment, err := a.toAttachment()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	// The run outlives the request, progress is reported on the event stream.
+	done, err := s.app.CoderAgent.Run(context.Background(), id, req.Content, attachments...)
+	if err != nil {
+		if errors.Is(err, agent.ErrSessionBusy) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	go func() {
+		defer logging.RecoverPanic("server-prompt", nil)
+		// Drain the result, it is also published to subscribers of the agent.
+		<-done
+	}()
+
+	writeJSON(w, http.StatusAccepted, promptResponse{SessionID: id, Status: "running"})
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	s.app.CoderAgent.Cancel(r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	files, err := s.app.History.ListBySession(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, files)
+}
+
+func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	file, err := s.app.History.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, file)
+}
+
+func (s *Server) handleListPermissions(w http.ResponseWriter, r *http.Request) {
+	s.pendingMu.RLock()
+	requests := make([]permission.PermissionRequest, 0, len(s.pending))
+	for _, p := range s.pending {
+		requests = append(requests, p)
+	}
+	s.pendingMu.RUnlock()
+	writeJSON(w, http.StatusOK, requests)
+}
+
+func (s *Server) handleRespondPermission(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req permissionResponseRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.pendingMu.Lock()
+	p, ok := s.pending[id]
+	if ok {
+		delete(s.pending, id)
+	}
+	s.pendingMu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("permission request %s not found", id))
+		return
+	}
+
+	switch req.Action {
+	case permissionAllow:
+		s.app.Permissions.Grant(p)
+	case permissionAllowForSession:
+		s.app.Permissions.GrantPersistant(p)
+	case permissionAllowAlways:
+		if err := s.app.Permissions.GrantAlways(p); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	case permissionDeny:
+		s.app.Permissions.Deny(p)
+	default:
+		// Put it back, the request is still waiting for a valid answer
+		s.pendingMu.Lock()
+		s.pending[id] = p
+		s.pendingMu.Unlock()
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid action: %q", req.Action))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a attachmentRequest) toAttachment() (message.Attachment, error) {
+	if a.Path != "" {
+		path := a.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(config.WorkingDirectory(), path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return message.Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+		}
+		attachment := message.NewAttachment(path, content)
+		if a.MimeType != "" {
+			attachment.MimeType = a.MimeType
+		}
+		return attachment, nil
+	}
+
+	content, err := base64.StdEncoding.DecodeString(a.Data)
+	if err != nil {
+		return message.Attachment{}, fmt.Errorf("invalid attachment data: %w", err)
+	}
+	if len(content) == 0 {
+		return message.Attachment{}, errors.New("attachment requires a path or data")
+	}
+	mimeType := a.MimeType
+	if mimeType == "" {
+		mimeType = http.DetectContentType(content[:min(512, len(content))])
+	}
+	return message.Attachment{
+		FilePath: a.FileName,
+		FileName: a.FileName,
+		MimeType: mimeType,
+		Content:  content,
+	}, nil
+}
+
+func writeLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,209 @@
+// Package server exposes an App over a local HTTP API so that editor plugins
+// and scripts can drive a long-lived agent without the TUI.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/pubsub"
+)
+
+const (
+	DefaultHost = "127.0.0.1"
+	DefaultPort = 4096
+)
+
+// Server serves sessions, messages, permissions and agent events of an App.
+// Every request must carry the token of the server as a bearer token.
+type Server struct {
+	app    *app.App
+	http   *http.Server
+	events *pubsub.Broker[Event]
+	token  string
+
+	pending   map[string]permission.PermissionRequest
+	pendingMu sync.RWMutex
+}
+
+// New creates a server for the given app listening on addr, with a new
+// random token.
+func New(app *app.App, addr string) (*Server, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate server token: %w", err)
+	}
+	s := &Server{
+		app:     app,
+		events:  pubsub.NewBroker[Event](),
+		token:   token,
+		pending: make(map[string]permission.PermissionRequest),
+	}
+	s.http = &http.Server{
+		Addr:              addr,
+		Handler:           s.authorize(s.routes()),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// Token returns the bearer token the clients must send.
+func (s *Server) Token() string {
+	return s.token
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// authorize rejects the requests that don't come from a local client holding
+// the token. Browsers send an Origin header and can't set the token, and the
+// Host check stops DNS rebinding, so web pages can't reach the API.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed", r.Host))
+			return
+		}
+		if r.Header.Get("Origin") != "" {
+			writeError(w, http.StatusForbidden, errors.New("cross-origin requests are not allowed"))
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		if r.ContentLength != 0 && r.Body != nil && r.Body != http.NoBody {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, errors.New("content type must be application/json"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost reports whether the Host header names the local machine.
+func isLoopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /sessions", s.handleListSessions)
+	mux.HandleFunc("POST /sessions", s.handleCreateSession)
+	mux.HandleFunc("GET /sessions/{id}", s.handleGetSession)
+	mux.HandleFunc("DELETE /sessions/{id}", s.handleDeleteSession)
+	mux.HandleFunc("POST /sessions/{id}/branch", s.handleBranchSession)
+	mux.HandleFunc("POST /sessions/{id}/revert", s.handleRevertSession)
+	mux.HandleFunc("PUT /sessions/{id}/mode", s.handleSetSessionMode)
+	mux.HandleFunc("PUT /sessions/{id}/agent", s.handleSetSessionAgent)
+	mux.HandleFunc("GET /sessions/{id}/messages", s.handleListMessages)
+	mux.HandleFunc("POST /sessions/{id}/prompt", s.handlePrompt)
+	mux.HandleFunc("POST /sessions/{id}/cancel", s.handleCancel)
+	mux.HandleFunc("GET /sessions/{id}/files", s.handleListFiles)
+
+	mux.HandleFunc("GET /files/{id}", s.handleGetFile)
+
+	mux.HandleFunc("GET /permissions", s.handleListPermissions)
+	mux.HandleFunc("POST /permissions/{id}", s.handleRespondPermission)
+
+	mux.HandleFunc("GET /events", s.handleEvents)
+
+	return mux
+}
+
+// Run starts forwarding app events and serves HTTP until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.http.Addr, err)
+	}
+
+	subCtx, cancelSubs := context.WithCancel(ctx)
+	defer cancelSubs()
+	s.forwardEvents(subCtx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer logging.RecoverPanic("server", nil)
+		logging.Info("HTTP server listening", "addr", listener.Addr().String())
+		errCh <- s.http.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	// Close the event streams first, otherwise Shutdown waits for them forever
+	s.events.Shutdown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+	return nil
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Error("Failed to encode response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	if r.Body == nil || r.ContentLength == 0 {
+		return nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
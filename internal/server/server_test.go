@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/permission"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	s, err := New(&app.App{
+		Sessions:    session.NewService(q, conn),
+		Messages:    message.NewService(q),
+		History:     history.NewService(q, conn),
+		Permissions: permission.NewPermissionService(nil, ""),
+	}, "127.0.0.1:0")
+	require.NoError(t, err)
+	return s
+}
+
+// do serves a request with the token and a JSON body, the options can
+// change the request before it is served
+func (s *Server) do(method, path, body string, opts ...func(*http.Request)) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Host = "127.0.0.1:4096"
+	req.Header.Set("Authorization", "Bearer "+s.Token())
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	w := httptest.NewRecorder()
+	s.http.Handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthorize(t *testing.T) {
+	s := newTestServer(t)
+	other, err := New(s.app, "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.NotEqual(t, s.Token(), other.Token())
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		opt    func(*http.Request)
+		want   int
+	}{
+		{"authorized", http.MethodGet, "", nil, http.StatusOK},
+		{"localhost", http.MethodGet, "", func(r *http.Request) { r.Host = "localhost:4096" }, http.StatusOK},
+		{"ipv6 loopback", http.MethodGet, "", func(r *http.Request) { r.Host = "[::1]:4096" }, http.StatusOK},
+		{"json with charset", http.MethodPost, `{"title":"a"}`, func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		}, http.StatusCreated},
+		{"no token", http.MethodGet, "", func(r *http.Request) { r.Header.Del("Authorization") }, http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+other.Token())
+		}, http.StatusUnauthorized},
+		{"rebound host", http.MethodGet, "", func(r *http.Request) { r.Host = "attacker.example:4096" }, http.StatusForbidden},
+		{"lan host", http.MethodGet, "", func(r *http.Request) { r.Host = "192.168.1.2:4096" }, http.StatusForbidden},
+		{"origin", http.MethodGet, "", func(r *http.Request) { r.Header.Set("Origin", "http://127.0.0.1:4096") }, http.StatusForbidden},
+		{"form body", http.MethodPost, `{"title":"a"}`, func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}, http.StatusUnsupportedMediaType},
+		{"text body", http.MethodPost, `{"title":"a"}`, func(r *http.Request) {
+			r.Header.Set("Content-Type", "text/plain")
+		}, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []func(*http.Request)
+			if tt.opt != nil {
+				opts = append(opts, tt.opt)
+			}
+			w := s.do(tt.method, "/sessions", tt.body, opts...)
+			assert.Equal(t, tt.want, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestSessionHandlers(t *testing.T) {
+	s := newTestServer(t)
+
+	w := s.do(http.MethodPost, "/sessions", `{"title":"Fix the parser"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created session.Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Fix the parser", created.Title)
+
+	w = s.do(http.MethodPost, "/sessions", "")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = s.do(http.MethodGet, "/sessions/"+created.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var got session.Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, created.ID, got.ID)
+
+	w = s.do(http.MethodGet, "/sessions", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var sessions []session.Session
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	assert.Len(t, sessions, 2)
+
+	w = s.do(http.MethodGet, "/sessions/"+created.ID+"/messages", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodPost, "/sessions", `{"title":`).Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, "/sessions/missing", "").Code)
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodGet, "/sessions/missing/messages", "").Code)
+}
+
+func TestPermissionHandlers(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	events := s.app.Permissions.Subscribe(ctx)
+
+	// request asks for a permission and tracks its event like the server
+	request := func() (permission.PermissionRequest, <-chan bool) {
+		answered := make(chan bool, 1)
+		go func() {
+			answered <- s.app.Permissions.Request(permission.CreatePermissionRequest{
+				SessionID: "session",
+				ToolName:  "bash",
+				Command:   "make",
+				Path:      "Makefile",
+			})
+		}()
+		event := s.trackPermission(<-events)
+		return event.Payload.(permission.PermissionRequest), answered
+	}
+	pending := func() []permission.PermissionRequest {
+		w := s.do(http.MethodGet, "/permissions", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var requests []permission.PermissionRequest
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &requests))
+		return requests
+	}
+
+	p, answered := request()
+	require.Len(t, pending(), 1)
+	assert.Equal(t, p.ID, pending()[0].ID)
+
+	assert.Equal(t, http.StatusBadRequest, s.do(http.MethodPost, "/permissions/"+p.ID, `{"action":"maybe"}`).Code)
+	assert.Len(t, pending(), 1)
+	assert.Equal(t, http.StatusNoContent, s.do(http.MethodPost, "/permissions/"+p.ID, `{"action":"allow"}`).Code)
+	assert.True(t, <-answered)
+	s.trackPermission(<-events)
+	assert.Empty(t, pending())
+	assert.Equal(t, http.StatusNotFound, s.do(http.MethodPost, "/permissions/"+p.ID, `{"action":"allow"}`).Code)
+
+	// Requests answered by another client are no longer pending
+	p, answered = request()
+	require.Len(t, pending(), 1)
+	s.app.Permissions.Deny(p)
+	assert.False(t, <-answered)
+	s.trackPermission(<-events)
+	assert.Empty(t, pending())
+}
@@ -3,6 +3,11 @@ package session
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/opencode-ai/opencode/internal/db"
@@ -10,16 +15,25 @@ import (
 )
 
 type Session struct {
-	ID               string
-	ParentSessionID  string
-	Title            string
-	MessageCount     int64
-	PromptTokens     int64
-	CompletionTokens int64
-	SummaryMessageID string
-	Cost             float64
-	CreatedAt        int64
-	UpdatedAt        int64
+	ID               string  `json:"id"`
+	ParentSessionID  string  `json:"parent_session_id,omitempty"`
+	Title            string  `json:"title"`
+	MessageCount     int64   `json:"message_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	SummaryMessageID string  `json:"summary_message_id,omitempty"`
+	Cost             float64 `json:"cost"`
+	BranchParentID   string  `json:"branch_parent_id,omitempty"`
+	BranchMessageID  string  `json:"branch_message_id,omitempty"`
+	Agent            string  `json:"agent,omitempty"`
+	CreatedAt        int64   `json:"created_at"`
+	UpdatedAt        int64   `json:"updated_at"`
+}
+
+// Spending is what was spent on the models, in dollars and tokens
+type Spending struct {
+	Cost   float64 `json:"cost"`
+	Tokens int64   `json:"tokens"`
 }
 
 type Service interface {
@@ -27,15 +41,31 @@ type Service interface {
 	Create(ctx context.Context, title string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	Branch(ctx context.Context, sessionID, messageID string) (Session, error)
+	// Import creates a new session with the parent, title, usage, agent and
+	// creation date of an exported one
+	Import(ctx context.Context, session Session) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
+	// ListChildren lists the task and title sessions started by a session
+	ListChildren(ctx context.Context, parentSessionID string) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
 	Delete(ctx context.Context, id string) error
+	// Spending returns what a session and its sub-agents spent
+	Spending(ctx context.Context, sessionID string) (Spending, error)
+	// DailySpending returns what all the sessions spent on the day of t
+	DailySpending(ctx context.Context, t time.Time) (Spending, error)
+	// RecordUsage adds a request to the usage ledger, which is kept when
+	// sessions are deleted or summarized. The spending is totaled from it.
+	RecordUsage(ctx context.Context, usage Usage) error
+	// ListUsage returns the requests made from from up to to, oldest first
+	ListUsage(ctx context.Context, from, to time.Time) ([]Usage, error)
 }
 
 type service struct {
 	*pubsub.Broker[Session]
-	q db.Querier
+	db *sql.DB
+	q  *db.Queries
 }
 
 func (s *service) Create(ctx context.Context, title string) (Session, error) {
@@ -79,6 +109,125 @@ func (s *service) CreateTitleSession(ctx context.Context, parentSessionID string
 	return session, nil
 }
 
+// ErrIncompleteTurn is returned when branching at tool calls whose results
+// are missing
+var ErrIncompleteTurn = errors.New("the tool calls of the message have no results")
+
+// Branch creates a new session holding a copy of the messages of sessionID up
+// to and including messageID. An empty messageID creates a branch without any
+// messages. The original session is left untouched, and the branch is created
+// in a transaction so that a failed copy leaves nothing behind. A branch at
+// tool calls includes their results, the providers refuse calls without them.
+func (s *service) Branch(ctx context.Context, sessionID, messageID string) (Session, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := s.q.WithTx(tx)
+
+	dbParent, err := qtx.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	parent := s.fromDBItem(dbParent)
+	dbMessages, err := qtx.ListMessagesBySession(ctx, sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+	end := 0
+	if messageID != "" {
+		end = slices.IndexFunc(dbMessages, func(m db.Message) bool { return m.ID == messageID }) + 1
+		if end == 0 {
+			return Session{}, fmt.Errorf("message %s not found in session %s: %w", messageID, sessionID, sql.ErrNoRows)
+		}
+		if callsTools(dbMessages[end-1]) {
+			if end == len(dbMessages) || dbMessages[end].Role != "tool" {
+				return Session{}, fmt.Errorf("cannot branch at message %s: %w", messageID, ErrIncompleteTurn)
+			}
+			end++
+		}
+	}
+
+	dbSession, err := qtx.CreateSession(ctx, db.CreateSessionParams{
+		ID:              uuid.New().String(),
+		Title:           parent.Title,
+		BranchParentID:  sql.NullString{String: parent.ID, Valid: true},
+		BranchMessageID: sql.NullString{String: messageID, Valid: messageID != ""},
+		Agent:           parent.Agent,
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	summaryMessageID := ""
+	for _, m := range dbMessages[:end] {
+		id := uuid.New().String()
+		if m.ID == parent.SummaryMessageID {
+			summaryMessageID = id
+		}
+		err = qtx.CopyMessage(ctx, db.CopyMessageParams{
+			ID:         id,
+			SessionID:  dbSession.ID,
+			Role:       m.Role,
+			Parts:      m.Parts,
+			Model:      m.Model,
+			CreatedAt:  m.CreatedAt,
+			UpdatedAt:  m.UpdatedAt,
+			FinishedAt: m.FinishedAt,
+			RevertedAt: m.RevertedAt,
+		})
+		if err != nil {
+			return Session{}, err
+		}
+	}
+
+	// The copied history keeps the summary of the parent session, so the
+	// branch is cut at the same place when it is sent to the model
+	if summaryMessageID != "" {
+		dbSession, err = qtx.UpdateSession(ctx, db.UpdateSessionParams{
+			ID:               dbSession.ID,
+			Title:            dbSession.Title,
+			SummaryMessageID: sql.NullString{String: summaryMessageID, Valid: true},
+			Agent:            dbSession.Agent,
+		})
+	} else {
+		dbSession, err = qtx.GetSessionByID(ctx, dbSession.ID)
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Session{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
+func (s *service) Import(ctx context.Context, session Session) (Session, error) {
+	dbSession, err := s.q.ImportSession(ctx, db.ImportSessionParams{
+		ID: uuid.New().String(),
+		ParentSessionID: sql.NullString{
+			String: session.ParentSessionID,
+			Valid:  session.ParentSessionID != "",
+		},
+		Title:            session.Title,
+		PromptTokens:     session.PromptTokens,
+		CompletionTokens: session.CompletionTokens,
+		Cost:             session.Cost,
+		Agent:            session.Agent,
+		UpdatedAt:        session.UpdatedAt,
+		CreatedAt:        session.CreatedAt,
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	imported := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, imported)
+	return imported, nil
+}
+
 func (s *service) Delete(ctx context.Context, id string) error {
 	session, err := s.Get(ctx, id)
 	if err != nil {
@@ -110,7 +259,8 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 			String: session.SummaryMessageID,
 			Valid:  session.SummaryMessageID != "",
 		},
-		Cost: session.Cost,
+		Cost:  session.Cost,
+		Agent: session.Agent,
 	})
 	if err != nil {
 		return Session{}, err
@@ -120,6 +270,32 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 	return session, nil
 }
 
+func (s *service) Spending(ctx context.Context, sessionID string) (Spending, error) {
+	row, err := s.q.GetSessionSpending(ctx, sessionID)
+	if err != nil {
+		return Spending{}, err
+	}
+	return Spending{Cost: row.Cost, Tokens: row.Tokens}, nil
+}
+
+func (s *service) DailySpending(ctx context.Context, t time.Time) (Spending, error) {
+	t = t.Local()
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	row, err := s.q.GetDailySpending(ctx, db.GetDailySpendingParams{
+		FromTime: start.Unix(),
+		ToTime:   start.AddDate(0, 0, 1).Unix(),
+	})
+	if err != nil {
+		return Spending{}, err
+	}
+	return Spending{Cost: row.Cost, Tokens: row.Tokens}, nil
+}
+
+// spendingDay is the local day spending is accounted to
+func spendingDay(t time.Time) string {
+	return t.Local().Format(time.DateOnly)
+}
+
 func (s *service) List(ctx context.Context) ([]Session, error) {
 	dbSessions, err := s.q.ListSessions(ctx)
 	if err != nil {
@@ -132,6 +308,35 @@ func (s *service) List(ctx context.Context) ([]Session, error) {
 	return sessions, nil
 }
 
+// callsTools reports whether a message has tool calls, from the types of its
+// parts
+func callsTools(msg db.Message) bool {
+	var parts []struct {
+		Type string `json:"type"`
+	}
+	if msg.Role != "assistant" || json.Unmarshal([]byte(msg.Parts), &parts) != nil {
+		return false
+	}
+	for _, part := range parts {
+		if part.Type == "tool_call" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) ListChildren(ctx context.Context, parentSessionID string) ([]Session, error) {
+	dbSessions, err := s.q.ListChildSessions(ctx, sql.NullString{String: parentSessionID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, len(dbSessions))
+	for i, dbSession := range dbSessions {
+		sessions[i] = s.fromDBItem(dbSession)
+	}
+	return sessions, nil
+}
+
 func (s service) fromDBItem(item db.Session) Session {
 	return Session{
 		ID:               item.ID,
@@ -142,15 +347,19 @@ func (s service) fromDBItem(item db.Session) Session {
 		CompletionTokens: item.CompletionTokens,
 		SummaryMessageID: item.SummaryMessageID.String,
 		Cost:             item.Cost,
+		BranchParentID:   item.BranchParentID.String,
+		BranchMessageID:  item.BranchMessageID.String,
+		Agent:            item.Agent,
 		CreatedAt:        item.CreatedAt,
 		UpdatedAt:        item.UpdatedAt,
 	}
 }
 
-func NewService(q db.Querier) Service {
+func NewService(q *db.Queries, db *sql.DB) Service {
 	broker := pubsub.NewBroker[Session]()
 	return &service{
-		broker,
-		q,
+		Broker: broker,
+		db:     db,
+		q:      q,
 	}
 }
@@ -0,0 +1,121 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranch(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := NewService(q, conn)
+	messages := message.NewService(q)
+	ctx := context.Background()
+
+	parent, err := sessions.Create(ctx, "Parser")
+	require.NoError(t, err)
+	var ids []string
+	for _, text := range []string{"summary", "fix the parser", "done", "and the boom"} {
+		msg, err := messages.Create(ctx, parent.ID, message.CreateMessageParams{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: text}},
+		})
+		require.NoError(t, err)
+		ids = append(ids, msg.ID)
+	}
+	parent.SummaryMessageID = ids[0]
+	parent, err = sessions.Save(ctx, parent)
+	require.NoError(t, err)
+
+	branch, err := sessions.Branch(ctx, parent.ID, ids[2])
+	require.NoError(t, err)
+	assert.NotEqual(t, parent.ID, branch.ID)
+	assert.Equal(t, "Parser", branch.Title)
+	assert.Equal(t, parent.ID, branch.BranchParentID)
+	assert.Equal(t, ids[2], branch.BranchMessageID)
+	copied, err := messages.List(ctx, branch.ID)
+	require.NoError(t, err)
+	require.Len(t, copied, 3)
+	assert.Equal(t, "fix the parser", copied[1].Content().String())
+	// The branch is cut at its copy of the summary
+	assert.Equal(t, copied[0].ID, branch.SummaryMessageID)
+	original, err := messages.List(ctx, parent.ID)
+	require.NoError(t, err)
+	assert.Len(t, original, 4)
+
+	empty, err := sessions.Branch(ctx, parent.ID, "")
+	require.NoError(t, err)
+	copied, err = messages.List(ctx, empty.ID)
+	require.NoError(t, err)
+	assert.Empty(t, copied)
+	assert.Empty(t, empty.SummaryMessageID)
+
+	_, err = sessions.Branch(ctx, parent.ID, "missing")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// A copy failing half way leaves no branch behind
+	_, err = conn.Exec(`CREATE TRIGGER fail_copy BEFORE INSERT ON messages
+WHEN new.parts LIKE '%boom%' BEGIN SELECT RAISE(ABORT, 'boom'); END`)
+	require.NoError(t, err)
+	before, err := sessions.List(ctx)
+	require.NoError(t, err)
+	_, err = sessions.Branch(ctx, parent.ID, ids[3])
+	assert.ErrorContains(t, err, "boom")
+	after, err := sessions.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, after, len(before))
+}
+
+func TestBranchAtToolCalls(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	sessions := NewService(q, conn)
+	messages := message.NewService(q)
+	ctx := context.Background()
+
+	parent, err := sessions.Create(ctx, "Tools")
+	require.NoError(t, err)
+	create := func(role message.MessageRole, part message.ContentPart) message.Message {
+		t.Helper()
+		msg, err := messages.Create(ctx, parent.ID, message.CreateMessageParams{Role: role, Parts: []message.ContentPart{part}})
+		require.NoError(t, err)
+		return msg
+	}
+	create(message.User, message.TextContent{Text: "list the files"})
+	calls := create(message.Assistant, message.ToolCall{ID: "call-1", Name: "ls", Input: `{}`, Type: "tool_use", Finished: true})
+	create(message.Tool, message.ToolResult{ToolCallID: "call-1", Content: "main.go"})
+	create(message.Assistant, message.TextContent{Text: "there is main.go"})
+
+	// The branch keeps the results of the calls it ends with
+	branch, err := sessions.Branch(ctx, parent.ID, calls.ID)
+	require.NoError(t, err)
+	copied, err := messages.List(ctx, branch.ID)
+	require.NoError(t, err)
+	require.Len(t, copied, 3)
+	assert.Equal(t, message.Tool, copied[2].Role)
+	assert.Equal(t, "call-1", copied[2].ToolResults()[0].ToolCallID)
+
+	// Calls still running have no results to keep
+	pending := create(message.Assistant, message.ToolCall{ID: "call-2", Name: "ls", Input: `{}`, Type: "tool_use", Finished: true})
+	_, err = sessions.Branch(ctx, parent.ID, pending.ID)
+	assert.ErrorIs(t, err, ErrIncompleteTurn)
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/opencode-ai/opencode/internal/db"
+)
+
+// Usage is what a single request to a model used
+type Usage struct {
+	ID           string `json:"id"`
+	SessionID    string `json:"session_id"`
+	SessionTitle string `json:"session_title,omitempty"`
+	Agent        string `json:"agent"`
+	Model        string `json:"model"`
+	Provider     string `json:"provider"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+	// CacheReadTokens and CacheWriteTokens are the prompt tokens read from
+	// and written to the cache of the provider
+	CacheReadTokens  int64   `json:"cache_read_tokens"`
+	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	Cost             float64 `json:"cost"`
+	// LatencyMs is the time from sending the request to the end of the
+	// response, in milliseconds
+	LatencyMs int64 `json:"latency_ms"`
+	CreatedAt int64 `json:"created_at"`
+}
+
+func (s *service) RecordUsage(ctx context.Context, usage Usage) error {
+	if usage.ID == "" {
+		usage.ID = uuid.New().String()
+	}
+	if usage.CreatedAt == 0 {
+		usage.CreatedAt = time.Now().Unix()
+	}
+	return s.q.CreateUsage(ctx, db.CreateUsageParams{
+		ID:               usage.ID,
+		SessionID:        usage.SessionID,
+		Agent:            usage.Agent,
+		Model:            usage.Model,
+		Provider:         usage.Provider,
+		InputTokens:      usage.InputTokens,
+		OutputTokens:     usage.OutputTokens,
+		CacheReadTokens:  usage.CacheReadTokens,
+		CacheWriteTokens: usage.CacheWriteTokens,
+		Cost:             usage.Cost,
+		LatencyMs:        usage.LatencyMs,
+		CreatedAt:        usage.CreatedAt,
+	})
+}
+
+func (s *service) ListUsage(ctx context.Context, from, to time.Time) ([]Usage, error) {
+	rows, err := s.q.ListUsage(ctx, db.ListUsageParams{
+		FromTime: from.Unix(),
+		ToTime:   to.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]Usage, len(rows))
+	for i, row := range rows {
+		usage[i] = Usage{
+			ID:               row.ID,
+			SessionID:        row.SessionID,
+			SessionTitle:     row.SessionTitle,
+			Agent:            row.Agent,
+			Model:            row.Model,
+			Provider:         row.Provider,
+			InputTokens:      row.InputTokens,
+			OutputTokens:     row.OutputTokens,
+			CacheReadTokens:  row.CacheReadTokens,
+			CacheWriteTokens: row.CacheWriteTokens,
+			Cost:             row.Cost,
+			LatencyMs:        row.LatencyMs,
+			CreatedAt:        row.CreatedAt,
+		}
+	}
+	return usage, nil
+}
+
+// UsageGrouping is what usage is totaled by
+type UsageGrouping string
+
+const (
+	UsageByDay     UsageGrouping = "day"
+	UsageByModel   UsageGrouping = "model"
+	UsageBySession UsageGrouping = "session"
+	UsageByAgent   UsageGrouping = "agent"
+)
+
+// UsageGroupings are the supported groupings of usage
+var UsageGroupings = []UsageGrouping{UsageByDay, UsageByModel, UsageBySession, UsageByAgent}
+
+// UsageTotal is the usage of the requests sharing a day, model, session or
+// agent
+type UsageTotal struct {
+	// Key is the local day, the model, the session ID or the agent
+	Key              string  `json:"key"`
+	SessionTitle     string  `json:"session_title,omitempty"`
+	Requests         int64   `json:"requests"`
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CacheReadTokens  int64   `json:"cache_read_tokens"`
+	CacheWriteTokens int64   `json:"cache_write_tokens"`
+	Cost             float64 `json:"cost"`
+	// AverageLatencyMs is the mean latency of the requests, in milliseconds
+	AverageLatencyMs int64 `json:"average_latency_ms"`
+}
+
+// TotalUsage totals usage by a grouping. Days are in chronological order,
+// the other groups are sorted by decreasing cost.
+func TotalUsage(usage []Usage, by UsageGrouping) ([]UsageTotal, error) {
+	var key func(Usage) string
+	switch by {
+	case UsageByDay:
+		key = func(u Usage) string { return spendingDay(time.Unix(u.CreatedAt, 0)) }
+	case UsageByModel:
+		key = func(u Usage) string { return u.Model }
+	case UsageBySession:
+		key = func(u Usage) string { return u.SessionID }
+	case UsageByAgent:
+		key = func(u Usage) string { return u.Agent }
+	default:
+		return nil, fmt.Errorf("unknown usage grouping %q", by)
+	}
+
+	var totals []UsageTotal
+	index := make(map[string]int)
+	latency := make(map[string]int64)
+	for _, u := range usage {
+		k := key(u)
+		i, ok := index[k]
+		if !ok {
+			i = len(totals)
+			index[k] = i
+			totals = append(totals, UsageTotal{Key: k})
+		}
+		total := &totals[i]
+		if by == UsageBySession {
+			total.SessionTitle = u.SessionTitle
+		}
+		total.Requests++
+		total.InputTokens += u.InputTokens
+		total.OutputTokens += u.OutputTokens
+		total.CacheReadTokens += u.CacheReadTokens
+		total.CacheWriteTokens += u.CacheWriteTokens
+		total.Cost += u.Cost
+		latency[k] += u.LatencyMs
+	}
+	for i := range totals {
+		totals[i].AverageLatencyMs = latency[totals[i].Key] / totals[i].Requests
+	}
+
+	if by == UsageByDay {
+		slices.SortFunc(totals, func(a, b UsageTotal) int { return strings.Compare(a.Key, b.Key) })
+	} else {
+		slices.SortStableFunc(totals, func(a, b UsageTotal) int { return cmp.Compare(b.Cost, a.Cost) })
+	}
+	return totals, nil
+}
@@ -0,0 +1,83 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsage(t *testing.T) {
+	dir := t.TempDir()
+	_, err := config.Load(dir, false)
+	require.NoError(t, err)
+	config.Get().Data.Directory = dir
+	conn, err := db.Connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	sessions := NewService(db.New(conn), conn)
+	ctx := context.Background()
+
+	sess, err := sessions.Create(ctx, "Parser")
+	require.NoError(t, err)
+	day := time.Date(2025, 6, 1, 10, 0, 0, 0, time.Local)
+	record := func(agent, model string, at time.Time, cost float64, latency int64) {
+		require.NoError(t, sessions.RecordUsage(ctx, Usage{
+			SessionID:    sess.ID,
+			Agent:        agent,
+			Model:        model,
+			Provider:     "anthropic",
+			InputTokens:  100,
+			OutputTokens: 10,
+			Cost:         cost,
+			LatencyMs:    latency,
+			CreatedAt:    at.Unix(),
+		}))
+	}
+	record("coder", "claude-4-sonnet", day, 0.5, 1000)
+	record("coder", "claude-4-sonnet", day.Add(time.Hour), 0.25, 3000)
+	record("summarizer", "gpt-4.1-mini", day.AddDate(0, 0, 1), 1, 500)
+	record("coder", "claude-4-sonnet", day.AddDate(0, 0, 10), 2, 500)
+
+	// The spending is totaled from the ledger
+	spent, err := sessions.Spending(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 3.75, spent.Cost, 1e-9)
+	assert.Equal(t, int64(440), spent.Tokens)
+	spent, err = sessions.DailySpending(ctx, day.Add(12*time.Hour))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.75, spent.Cost, 1e-9)
+	assert.Equal(t, int64(220), spent.Tokens)
+
+	// The ledger outlives the session
+	require.NoError(t, sessions.Delete(ctx, sess.ID))
+	usage, err := sessions.ListUsage(ctx, day, day.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	require.Len(t, usage, 3)
+	assert.Equal(t, "coder", usage[0].Agent)
+
+	byDay, err := TotalUsage(usage, UsageByDay)
+	require.NoError(t, err)
+	require.Len(t, byDay, 2)
+	assert.Equal(t, "2025-06-01", byDay[0].Key)
+	assert.Equal(t, int64(2), byDay[0].Requests)
+	assert.Equal(t, int64(200), byDay[0].InputTokens)
+	assert.InDelta(t, 0.75, byDay[0].Cost, 1e-9)
+	assert.Equal(t, int64(2000), byDay[0].AverageLatencyMs)
+
+	byModel, err := TotalUsage(usage, UsageByModel)
+	require.NoError(t, err)
+	require.Len(t, byModel, 2)
+	assert.Equal(t, "gpt-4.1-mini", byModel[0].Key)
+
+	_, err = TotalUsage(usage, "week")
+	assert.Error(t, err)
+
+	spent, err = sessions.DailySpending(ctx, day)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.75, spent.Cost, 1e-9)
+}
@@ -0,0 +1,189 @@
+package transcript
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/opencode-ai/opencode/internal/diff"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/opencode-ai/opencode/internal/tui/theme"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdownToHTML converts the text of the messages. Raw HTML in them is left
+// out, the page must not run what a model wrote.
+var markdownToHTML = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+	),
+)
+
+// codeBlockRenderer highlights the fenced code blocks of the messages
+type codeBlockRenderer struct{}
+
+func (codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderCodeBlock)
+}
+
+func renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	block := node.(*ast.FencedCodeBlock)
+	var code strings.Builder
+	for i := 0; i < block.Lines().Len(); i++ {
+		line := block.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+	writeCode(w, code.String(), string(block.Language(source)))
+	return ast.WalkSkipChildren, nil
+}
+
+// writeCode writes highlighted code, lang is a language or a file name
+func writeCode(w io.Writer, code, lang string) {
+	if err := diff.HighlightHTML(w, code, lang); err != nil {
+		fmt.Fprintf(w, "<pre>%s</pre>", html.EscapeString(code))
+	}
+}
+
+func renderHTML(w io.Writer, sess session.Session, turns []turn, opts Options) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(b, "<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(b, "<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n<main>\n", html.EscapeString(sess.Title), stylesheet())
+
+	fmt.Fprintf(b, "<h1>%s</h1>\n<ul class=\"meta\">\n", html.EscapeString(sess.Title))
+	fmt.Fprintf(b, "<li>Session <code>%s</code></li>\n", html.EscapeString(sess.ID))
+	fmt.Fprintf(b, "<li>Started %s</li>\n", formatTime(sess.CreatedAt))
+	fmt.Fprintf(b, "<li>$%.2f, %d prompt and %d completion tokens</li>\n</ul>\n", sess.Cost, sess.PromptTokens, sess.CompletionTokens)
+
+	for _, turn := range turns {
+		if turn.prompt != nil {
+			writeHTMLMessage(b, *turn.prompt, turn, opts)
+		}
+		for _, msg := range turn.responses {
+			writeHTMLMessage(b, msg, turn, opts)
+		}
+		if len(turn.changes) > 0 {
+			fmt.Fprintf(b, "<section class=\"changes\">\n<h3>Changes</h3>\n")
+			for _, change := range turn.changes {
+				fmt.Fprintf(b, "<h4><code>%s</code> <span class=\"added\">+%d</span> <span class=\"removed\">-%d</span></h4>\n",
+					html.EscapeString(change.path), change.additions, change.removals)
+				writeCode(b, change.diff, "diff")
+			}
+			fmt.Fprintf(b, "</section>\n")
+		}
+	}
+	fmt.Fprintf(b, "</main>\n</body>\n</html>\n")
+	return b.Flush()
+}
+
+func writeHTMLMessage(b *bufio.Writer, msg message.Message, turn turn, opts Options) {
+	class := string(msg.Role)
+	if msg.RevertedAt != 0 {
+		class += " reverted"
+	}
+	fmt.Fprintf(b, "<section class=\"%s\">\n<h2>%s</h2>\n<time>%s</time>\n", class, html.EscapeString(title(msg)), formatTime(msg.CreatedAt))
+
+	if thinking := strings.TrimSpace(msg.ReasoningContent().Thinking); opts.Reasoning && thinking != "" {
+		fmt.Fprintf(b, "<details class=\"reasoning\">\n<summary>Reasoning</summary>\n")
+		writeMarkdownHTML(b, thinking)
+		fmt.Fprintf(b, "</details>\n")
+	}
+	if text := strings.TrimSpace(msg.Content().String()); text != "" {
+		writeMarkdownHTML(b, text)
+	}
+	for _, attachment := range msg.BinaryContent() {
+		if strings.HasPrefix(attachment.MIMEType, "image/") && len(attachment.Data) > 0 {
+			fmt.Fprintf(b, "<figure><img src=\"data:%s;base64,%s\" alt=\"%s\"><figcaption>%s</figcaption></figure>\n",
+				attachment.MIMEType,
+				base64.StdEncoding.EncodeToString(attachment.Data),
+				html.EscapeString(attachment.Path),
+				html.EscapeString(attachment.Path),
+			)
+			continue
+		}
+		fmt.Fprintf(b, "<p class=\"attachment\">Attached <code>%s</code></p>\n", html.EscapeString(attachment.Path))
+	}
+	for _, call := range msg.ToolCalls() {
+		result, ok := turn.results[call.ID]
+		class := "tool"
+		if result.IsError {
+			class += " error"
+		}
+		fmt.Fprintf(b, "<details class=\"%s\">\n<summary>%s</summary>\n<h5>Input</h5>\n", class, html.EscapeString(call.Name))
+		writeCode(b, toolInput(call), "json")
+		if ok {
+			fmt.Fprintf(b, "<h5>Output</h5>\n")
+			writeCode(b, result.Content, "plaintext")
+		}
+		fmt.Fprintf(b, "</details>\n")
+	}
+	fmt.Fprintf(b, "</section>\n")
+}
+
+func writeMarkdownHTML(b *bufio.Writer, text string) {
+	fmt.Fprintf(b, "<div class=\"content\">\n")
+	if err := markdownToHTML.Convert([]byte(text), b); err != nil {
+		fmt.Fprintf(b, "<pre>%s</pre>\n", html.EscapeString(text))
+	}
+	fmt.Fprintf(b, "</div>\n")
+}
+
+// stylesheet styles the page with the colors of the current theme
+func stylesheet() string {
+	t := theme.CurrentTheme()
+	return fmt.Sprintf(`body { margin: 0; background: %s; color: %s; font: 15px/1.6 system-ui, sans-serif; }
+main { max-width: 960px; margin: 0 auto; padding: 24px; }
+h1, h2 { color: %s; }
+h2 { font-size: 1.1em; margin: 0; }
+time, .meta, h5 { color: %s; font-size: 0.85em; }
+h5 { margin: 8px 0 4px; }
+section { border-left: 3px solid %s; padding: 8px 16px; margin: 16px 0; }
+section.user { border-color: %s; }
+section.reverted { opacity: 0.6; }
+section.changes { border-color: %s; }
+details { margin: 8px 0; }
+summary { cursor: pointer; color: %s; font-family: ui-monospace, monospace; }
+details.error summary { color: %s; }
+pre { padding: 8px 12px; overflow-x: auto; border-radius: 4px; font: 13px/1.4 ui-monospace, monospace; }
+code { font-family: ui-monospace, monospace; }
+.added { color: %s; }
+.removed { color: %s; }
+img { max-width: 100%%; }
+a { color: %s; }
+`,
+		color(t.Background()),
+		color(t.Text()),
+		color(t.Primary()),
+		color(t.TextMuted()),
+		color(t.BorderNormal()),
+		color(t.Secondary()),
+		color(t.Accent()),
+		color(t.Info()),
+		color(t.Error()),
+		color(t.Success()),
+		color(t.Error()),
+		color(t.Primary()),
+	)
+}
+
+// color picks the variant of a theme color for the terminal background, like
+// the syntax highlighting does
+func color(c lipgloss.AdaptiveColor) string {
+	if lipgloss.HasDarkBackground() {
+		return c.Dark
+	}
+	return c.Light
+}
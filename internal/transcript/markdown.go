@@ -0,0 +1,85 @@
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+func renderMarkdown(w io.Writer, sess session.Session, turns []turn, opts Options) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# %s\n\n", sess.Title)
+	fmt.Fprintf(b, "- **Session:** `%s`\n", sess.ID)
+	fmt.Fprintf(b, "- **Started:** %s\n", formatTime(sess.CreatedAt))
+	fmt.Fprintf(b, "- **Cost:** $%.2f (%d prompt and %d completion tokens)\n", sess.Cost, sess.PromptTokens, sess.CompletionTokens)
+
+	for _, turn := range turns {
+		if turn.prompt != nil {
+			writeMarkdownMessage(b, *turn.prompt, turn, opts)
+		}
+		for _, msg := range turn.responses {
+			writeMarkdownMessage(b, msg, turn, opts)
+		}
+		if len(turn.changes) > 0 {
+			fmt.Fprintf(b, "\n### Changes\n")
+			for _, change := range turn.changes {
+				fmt.Fprintf(b, "\n#### `%s` (+%d -%d)\n\n", change.path, change.additions, change.removals)
+				writeFence(b, change.diff, "diff")
+			}
+		}
+	}
+	return b.Flush()
+}
+
+func writeMarkdownMessage(b *bufio.Writer, msg message.Message, turn turn, opts Options) {
+	fmt.Fprintf(b, "\n## %s\n\n_%s_\n", title(msg), formatTime(msg.CreatedAt))
+
+	if thinking := strings.TrimSpace(msg.ReasoningContent().Thinking); opts.Reasoning && thinking != "" {
+		fmt.Fprintf(b, "\n<details>\n<summary>Reasoning</summary>\n\n%s\n\n</details>\n", thinking)
+	}
+	if text := strings.TrimSpace(msg.Content().String()); text != "" {
+		fmt.Fprintf(b, "\n%s\n", text)
+	}
+	for _, attachment := range msg.BinaryContent() {
+		fmt.Fprintf(b, "\n_Attached `%s` (%s)_\n", attachment.Path, attachment.MIMEType)
+	}
+	for _, call := range msg.ToolCalls() {
+		result, ok := turn.results[call.ID]
+		summary := call.Name
+		if result.IsError {
+			summary += " (error)"
+		}
+		fmt.Fprintf(b, "\n<details>\n<summary>%s</summary>\n\n**Input**\n\n", summary)
+		writeFence(b, toolInput(call), "json")
+		if ok {
+			fmt.Fprintf(b, "\n**Output**\n\n")
+			writeFence(b, result.Content, "")
+		}
+		fmt.Fprintf(b, "\n</details>\n")
+	}
+}
+
+// writeFence writes a fenced code block, with a fence longer than any run of
+// backticks in the code
+func writeFence(b *bufio.Writer, code, lang string) {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n", fence, lang, strings.TrimRight(code, "\n"), fence)
+}
+
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,228 @@
+// Package transcript renders sessions as documents that can be read outside
+// of opencode, to attach them to pull requests or incident write-ups.
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/opencode-ai/opencode/internal/diff"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/llm/models"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+)
+
+// Format is the document format of a transcript
+type Format string
+
+const (
+	// Markdown renders the transcript as GitHub flavored Markdown, tool calls
+	// are collapsed in <details> blocks.
+	Markdown Format = "markdown"
+
+	// HTML renders the transcript as a standalone HTML page, highlighted with
+	// the colors of the current theme.
+	HTML Format = "html"
+)
+
+// SupportedFormats lists the formats a transcript can be rendered to
+var SupportedFormats = []string{
+	string(Markdown),
+	string(HTML),
+}
+
+// Transcript is a session with everything needed to render it
+type Transcript struct {
+	Session     session.Session
+	Messages    []message.Message
+	Files       []history.File
+	Checkpoints []history.Checkpoint
+}
+
+// Options changes what a transcript shows
+type Options struct {
+	// Reasoning includes the thinking of the models
+	Reasoning bool
+}
+
+// Render writes the transcript in the given format
+func Render(w io.Writer, t Transcript, format Format, opts Options) error {
+	turns := t.turns()
+	switch format {
+	case Markdown:
+		return renderMarkdown(w, t.Session, turns, opts)
+	case HTML:
+		return renderHTML(w, t.Session, turns, opts)
+	default:
+		return fmt.Errorf("unsupported transcript format %q, expected one of %s", format, strings.Join(SupportedFormats, ", "))
+	}
+}
+
+// turn is a prompt of the user with the responses of the agent and the files
+// they changed
+type turn struct {
+	// prompt is nil for the messages before the first prompt
+	prompt    *message.Message
+	responses []message.Message
+	results   map[string]message.ToolResult
+	changes   []fileChange
+}
+
+type fileChange struct {
+	path      string
+	diff      string
+	additions int
+	removals  int
+}
+
+// turns groups the messages by prompt. The tool results are attached to the
+// calls that produced them instead of being messages of their own.
+func (t Transcript) turns() []turn {
+	results := make(map[string]message.ToolResult)
+	for _, msg := range t.Messages {
+		for _, result := range msg.ToolResults() {
+			results[result.ToolCallID] = result
+		}
+	}
+
+	var turns []turn
+	var prompts []message.Message
+	for i := range t.Messages {
+		msg := &t.Messages[i]
+		switch {
+		case msg.Role == message.User:
+			prompts = append(prompts, *msg)
+			turns = append(turns, turn{prompt: msg, results: results})
+		case msg.Role == message.Tool:
+			continue
+		case len(turns) == 0:
+			turns = append(turns, turn{results: results})
+			fallthrough
+		default:
+			turns[len(turns)-1].responses = append(turns[len(turns)-1].responses, *msg)
+		}
+	}
+
+	changes := t.changes(prompts)
+	prompt := 0
+	for i := range turns {
+		if turns[i].prompt != nil {
+			turns[i].changes = changes[prompt]
+			prompt++
+		}
+	}
+	return turns
+}
+
+// changes diffs the files changed by each prompt, between the checkpoint of
+// the prompt and the one of the next prompt. Prompts without a checkpoint,
+// like the ones of sessions created before checkpoints existed, have their
+// changes shown with the next prompt that has one.
+func (t Transcript) changes(prompts []message.Message) [][]fileChange {
+	versions := make(map[string]history.File, len(t.Files))
+	initial := make(map[string]history.File)
+	for _, file := range t.Files {
+		versions[file.ID] = file
+		if file.Version == history.InitialVersion {
+			initial[file.Path] = file
+		}
+	}
+	checkpoints := make(map[string]history.Checkpoint, len(t.Checkpoints))
+	for _, checkpoint := range t.Checkpoints {
+		checkpoints[checkpoint.MessageID] = checkpoint
+	}
+	latest := make(map[string]string)
+	for path, file := range history.LatestVersions(t.Files) {
+		latest[path] = file.ID
+	}
+
+	changes := make([][]fileChange, len(prompts))
+	before := map[string]string{}
+	for i, prompt := range prompts {
+		if checkpoint, ok := checkpoints[prompt.ID]; ok {
+			before = checkpoint.Files
+		}
+		after := latest
+		if i+1 < len(prompts) {
+			checkpoint, ok := checkpoints[prompts[i+1].ID]
+			if !ok {
+				continue
+			}
+			after = checkpoint.Files
+		}
+
+		paths := make([]string, 0, len(after))
+		for path := range after {
+			paths = append(paths, path)
+		}
+		slices.Sort(paths)
+		for _, path := range paths {
+			if before[path] == after[path] {
+				continue
+			}
+			// Files first changed by this prompt start from their content
+			// before the first change
+			var old string
+			if file, ok := versions[before[path]]; ok {
+				old = file.Content
+			} else {
+				old = initial[path].Content
+			}
+			current := versions[after[path]].Content
+			if old == current {
+				continue
+			}
+			unified, additions, removals := diff.GenerateDiff(old, current, path)
+			changes[i] = append(changes[i], fileChange{
+				path:      path,
+				diff:      unified,
+				additions: additions,
+				removals:  removals,
+			})
+		}
+		before = after
+	}
+	return changes
+}
+
+// modelName returns the display name of the model that wrote a message
+func modelName(msg message.Message) string {
+	if model, ok := models.SupportedModels[msg.Model]; ok {
+		return model.Name
+	}
+	return string(msg.Model)
+}
+
+// title is the heading of a message
+func title(msg message.Message) string {
+	var heading string
+	switch {
+	case msg.SummaryPart() != nil:
+		heading = "Summary"
+	case msg.Role == message.User:
+		heading = "User"
+	default:
+		heading = "Assistant"
+		if name := modelName(msg); name != "" {
+			heading += " · " + name
+		}
+	}
+	if msg.RevertedAt != 0 {
+		heading += " (reverted)"
+	}
+	return heading
+}
+
+// toolInput indents the JSON input of a tool call
+func toolInput(call message.ToolCall) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(call.Input), "", "  "); err != nil {
+		return call.Input
+	}
+	return buf.String()
+}
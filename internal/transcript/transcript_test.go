@@ -0,0 +1,91 @@
+package transcript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTranscript(t *testing.T) Transcript {
+	_, err := config.Load(t.TempDir(), false)
+	require.NoError(t, err)
+	call := message.ToolCall{ID: "c1", Name: "edit", Input: `{"file_path":"a.go"}`, Finished: true}
+	return Transcript{
+		Session: session.Session{ID: "s1", Title: "Fix <parser>"},
+		Messages: []message.Message{
+			{ID: "p1", Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "fix a.go"}}},
+			{ID: "m1", Role: message.Assistant, Parts: []message.ContentPart{
+				message.ReasoningContent{Thinking: "the bug is in a.go"},
+				message.TextContent{Text: "Fixing it"},
+				call,
+			}},
+			{ID: "m2", Role: message.Tool, Parts: []message.ContentPart{
+				message.ToolResult{ToolCallID: "c1", Content: "```\nedited\n```"},
+			}},
+			{ID: "p2", Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "<script>alert(1)</script> and b.go"}}},
+			{ID: "m3", Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "Done"}}},
+		},
+		Files: []history.File{
+			{ID: "a0", Path: "a.go", Content: "package a\n", Version: history.InitialVersion},
+			{ID: "a1", Path: "a.go", Content: "package a\n\nfunc A() {}\n", Version: "v1"},
+			{ID: "b0", Path: "b.go", Content: "", Version: history.InitialVersion},
+			{ID: "b1", Path: "b.go", Content: "package b\n", Version: "v1"},
+		},
+		Checkpoints: []history.Checkpoint{
+			{MessageID: "p1", Files: map[string]string{}},
+			{MessageID: "p2", Files: map[string]string{"a.go": "a1"}},
+		},
+	}
+}
+
+func TestTurns(t *testing.T) {
+	turns := testTranscript(t).turns()
+	require.Len(t, turns, 2)
+
+	assert.Equal(t, "p1", turns[0].prompt.ID)
+	require.Len(t, turns[0].responses, 1)
+	assert.Equal(t, "```\nedited\n```", turns[0].results["c1"].Content)
+	require.Len(t, turns[0].changes, 1)
+	assert.Equal(t, "a.go", turns[0].changes[0].path)
+	assert.Equal(t, 2, turns[0].changes[0].additions)
+
+	require.Len(t, turns[1].changes, 1)
+	assert.Equal(t, "b.go", turns[1].changes[0].path)
+	assert.Contains(t, turns[1].changes[0].diff, "+package b")
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, testTranscript(t), Markdown, Options{}))
+	out := buf.String()
+
+	assert.Contains(t, out, "# Fix <parser>")
+	assert.Contains(t, out, "<summary>edit</summary>")
+	// The output contains a fence, so it needs a longer one
+	assert.Contains(t, out, "````\n```\nedited\n```\n````")
+	assert.Contains(t, out, "#### `b.go` (+1 -0)")
+	assert.NotContains(t, out, "the bug is in a.go")
+
+	buf.Reset()
+	require.NoError(t, Render(&buf, testTranscript(t), Markdown, Options{Reasoning: true}))
+	assert.Contains(t, buf.String(), "the bug is in a.go")
+}
+
+func TestRenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Render(&buf, testTranscript(t), HTML, Options{}))
+	out := buf.String()
+
+	assert.Contains(t, out, "<title>Fix &lt;parser&gt;</title>")
+	assert.Contains(t, out, "<summary>edit</summary>")
+	assert.NotContains(t, out, "<script>")
+	assert.Contains(t, out, "<pre")
+
+	assert.Error(t, Render(&buf, testTranscript(t), Format("pdf"), Options{}))
+}
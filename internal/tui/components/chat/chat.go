@@ -23,8 +23,16 @@ type SessionSelectedMsg = session.Session
 
 type SessionClearedMsg struct{}
 
+// MessageFocusedMsg scrolls the messages of the session to one of them
+type MessageFocusedMsg struct {
+	ID string
+}
+
 type EditorFocusMsg bool
 
+// EditorSetValueMsg replaces the text of the editor
+type EditorSetValueMsg string
+
 func header(width int) string {
 	return lipgloss.JoinVertical(
 		lipgloss.Top,
@@ -13,6 +13,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/logging"
 	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/session"
@@ -156,6 +157,9 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.session = msg
 		}
 		return m, nil
+	case EditorSetValueMsg:
+		m.textarea.SetValue(string(msg))
+		return m, nil
 	case dialog.AttachmentAddedMsg:
 		if len(m.attachments) >= maxAttachments {
 			logging.ErrorPersist(fmt.Sprintf("cannot add more than %d images", maxAttachments))
@@ -224,6 +228,10 @@ func (m *editorCmp) View() string {
 		Padding(0, 0, 0, 1).
 		Bold(true).
 		Foreground(t.Primary())
+	// The prompt changes color while the agent can only read files and plan
+	if m.session.ID != "" && m.app.CoderAgent.Mode(m.session.ID) == agent.ModePlan {
+		style = style.Foreground(t.Warning())
+	}
 
 	if len(m.attachments) == 0 {
 		return lipgloss.JoinHorizontal(lipgloss.Top, style.Render(">"), m.textarea.View())
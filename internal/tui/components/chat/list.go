@@ -11,6 +11,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/pubsub"
 	"github.com/opencode-ai/opencode/internal/session"
@@ -36,6 +37,10 @@ type messagesCmp struct {
 	spinner       spinner.Model
 	rendering     bool
 	attachments   viewport.Model
+	// focusedMsgID is the message to scroll to once rendered
+	focusedMsgID string
+	// toolOutput is the tail of the output of the running tool calls
+	toolOutput map[string]string
 }
 type renderFinishedMsg struct{}
 
@@ -84,6 +89,7 @@ func (m *messagesCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case SessionClearedMsg:
 		m.session = session.Session{}
 		m.messages = make([]message.Message, 0)
+		clear(m.toolOutput)
 		m.currentMsgID = ""
 		m.rendering = false
 		return m, nil
@@ -96,9 +102,17 @@ func (m *messagesCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 		}
 
+	case MessageFocusedMsg:
+		m.focusedMsgID = msg.ID
+		if !m.rendering {
+			m.scrollToFocused()
+		}
+		return m, nil
+
 	case renderFinishedMsg:
 		m.rendering = false
 		m.viewport.GotoBottom()
+		m.scrollToFocused()
 	case pubsub.Event[session.Session]:
 		if msg.Type == pubsub.UpdatedEvent && msg.Payload.ID == m.session.ID {
 			m.session = msg.Payload
@@ -107,6 +121,10 @@ func (m *messagesCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.renderView()
 			}
 		}
+	case pubsub.Event[agent.AgentEvent]:
+		if msg.Payload.Type == agent.AgentEventTypeToolProgress && msg.Payload.SessionID == m.session.ID {
+			m.updateToolOutput(msg.Payload.ToolCallID, msg.Payload.Progress)
+		}
 	case pubsub.Event[message.Message]:
 		needsRerender := false
 		if msg.Type == pubsub.CreatedEvent {
@@ -168,6 +186,25 @@ func (m *messagesCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// updateToolOutput adds output to the tail of a running tool call, and shows
+// it when the call is in the session
+func (m *messagesCmp) updateToolOutput(toolCallID, output string) {
+	for i, msg := range m.messages {
+		for _, call := range msg.ToolCalls() {
+			if call.ID != toolCallID {
+				continue
+			}
+			m.toolOutput[toolCallID] = outputTail(m.toolOutput[toolCallID], output)
+			delete(m.cachedContent, msg.ID)
+			m.renderView()
+			if i == len(m.messages)-1 {
+				m.viewport.GotoBottom()
+			}
+			return
+		}
+	}
+}
+
 func (m *messagesCmp) IsAgentWorking() bool {
 	return m.app.CoderAgent.IsSessionBusy(m.session.ID)
 }
@@ -223,6 +260,7 @@ func (m *messagesCmp) renderView() {
 				inx,
 				m.messages,
 				m.app.Messages,
+				m.toolOutput,
 				m.currentMsgID,
 				isSummary,
 				m.width,
@@ -262,6 +300,31 @@ func (m *messagesCmp) renderView() {
 	)
 }
 
+// scrollToFocused scrolls to the top of the focused message. Tool results
+// are shown with their call, so the call is shown for a tool message.
+func (m *messagesCmp) scrollToFocused() {
+	if m.focusedMsgID == "" {
+		return
+	}
+	ids := map[string]bool{m.focusedMsgID: true}
+	for _, msg := range m.messages {
+		if msg.ID == m.focusedMsgID {
+			for _, result := range msg.ToolResults() {
+				ids[result.ToolCallID] = true
+			}
+		}
+	}
+	offset := 0
+	for _, msg := range m.uiMessages {
+		if ids[msg.ID] {
+			m.viewport.SetYOffset(offset)
+			break
+		}
+		offset += msg.height + 1 // + 1 for spacing
+	}
+	m.focusedMsgID = ""
+}
+
 func (m *messagesCmp) View() string {
 	baseStyle := styles.BaseStyle()
 
@@ -443,6 +506,7 @@ func (m *messagesCmp) SetSession(session session.Session) tea.Cmd {
 		return nil
 	}
 	m.session = session
+	clear(m.toolOutput)
 	messages, err := m.app.Messages.List(context.Background(), session.ID)
 	if err != nil {
 		return util.ReportError(err)
@@ -480,6 +544,7 @@ func NewMessagesCmp(app *app.App) tea.Model {
 	return &messagesCmp{
 		app:           app,
 		cachedContent: make(map[string]cacheItem),
+		toolOutput:    make(map[string]string),
 		viewport:      vp,
 		spinner:       s,
 		attachments:   attachmets,
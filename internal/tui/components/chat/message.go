@@ -96,13 +96,14 @@ func renderUserMessage(msg message.Message, isFocused bool, width int, position
 		}
 		styledAttachments = append(styledAttachments, attachmentStyles.Render(filename))
 	}
-	content := ""
+	info := []string{}
 	if len(styledAttachments) > 0 {
-		attachmentContent := styles.BaseStyle().Width(width).Render(lipgloss.JoinHorizontal(lipgloss.Left, styledAttachments...))
-		content = renderMessage(msg.Content().String(), true, isFocused, width, attachmentContent)
-	} else {
-		content = renderMessage(msg.Content().String(), true, isFocused, width)
+		info = append(info, styles.BaseStyle().Width(width).Render(lipgloss.JoinHorizontal(lipgloss.Left, styledAttachments...)))
+	}
+	if msg.RevertedAt != 0 {
+		info = append(info, styles.BaseStyle().Width(width-1).Foreground(t.TextMuted()).Render(" (reverted)"))
 	}
+	content := renderMessage(msg.Content().String(), true, isFocused, width, info...)
 	userMsg := uiMessage{
 		ID:          msg.ID,
 		messageType: userMessageType,
@@ -119,6 +120,7 @@ func renderAssistantMessage(
 	msgIndex int,
 	allMessages []message.Message, // we need this to get tool results and the user message
 	messagesService message.Service, // We need this to get the task tool messages
+	toolOutput map[string]string, // the output of the running tool calls
 	focusedUIMessageId string,
 	isSummary bool,
 	width int,
@@ -163,6 +165,12 @@ func renderAssistantMessage(
 				Foreground(t.TextMuted()).
 				Render(fmt.Sprintf(" %s (%s)", models.SupportedModels[msg.Model].Name, "permission denied")),
 			)
+		case message.FinishReasonBudgetExceeded:
+			info = append(info, baseStyle.
+				Width(width-1).
+				Foreground(t.TextMuted()).
+				Render(fmt.Sprintf(" %s (%s)", models.SupportedModels[msg.Model].Name, "budget exceeded")),
+			)
 		}
 	}
 	if content != "" || (finished && finishData.Reason == message.FinishReasonEndTurn) {
@@ -172,6 +180,9 @@ func renderAssistantMessage(
 		if isSummary {
 			info = append(info, baseStyle.Width(width-1).Foreground(t.TextMuted()).Render(" (summary)"))
 		}
+		if msg.RevertedAt != 0 {
+			info = append(info, baseStyle.Width(width-1).Foreground(t.TextMuted()).Render(" (reverted)"))
+		}
 
 		content = renderMessage(content, false, true, width, info...)
 		messages = append(messages, uiMessage{
@@ -193,6 +204,7 @@ func renderAssistantMessage(
 			toolCall,
 			allMessages,
 			messagesService,
+			toolOutput[toolCall.ID],
 			focusedUIMessageId,
 			false,
 			width,
@@ -240,6 +252,32 @@ func toolName(name string) string {
 		return "Write"
 	case tools.PatchToolName:
 		return "Patch"
+	case agent.PlanToolName:
+		return "Plan"
+	case tools.JobOutputToolName:
+		return "Job Output"
+	case tools.JobInputToolName:
+		return "Job Input"
+	case tools.JobListToolName:
+		return "Jobs"
+	case tools.JobKillToolName:
+		return "Kill Job"
+	case tools.LSPDefinitionToolName:
+		return "Definition"
+	case tools.LSPImplementationToolName:
+		return "Implementations"
+	case tools.LSPReferencesToolName:
+		return "References"
+	case tools.LSPHoverToolName:
+		return "Hover"
+	case tools.LSPSymbolsToolName:
+		return "Symbols"
+	case tools.LSPCallsToolName:
+		return "Calls"
+	case tools.LSPRenameToolName:
+		return "Rename"
+	case tools.LSPCodeActionToolName:
+		return "Code Action"
 	}
 	return name
 }
@@ -268,10 +306,48 @@ func getToolAction(name string) string {
 		return "Preparing write..."
 	case tools.PatchToolName:
 		return "Preparing patch..."
+	case agent.PlanToolName:
+		return "Writing plan..."
+	case tools.JobOutputToolName:
+		return "Reading job output..."
+	case tools.JobInputToolName:
+		return "Sending input..."
+	case tools.JobListToolName:
+		return "Listing jobs..."
+	case tools.JobKillToolName:
+		return "Killing job..."
+	case tools.LSPDefinitionToolName, tools.LSPImplementationToolName, tools.LSPReferencesToolName,
+		tools.LSPHoverToolName, tools.LSPSymbolsToolName, tools.LSPCallsToolName:
+		return "Asking the language server..."
+	case tools.LSPRenameToolName:
+		return "Preparing rename..."
+	case tools.LSPCodeActionToolName:
+		return "Preparing code action..."
 	}
 	return "Working..."
 }
 
+// maxToolOutputLines is the number of lines of output shown for a running
+// tool call
+const maxToolOutputLines = 10
+
+// outputTail appends output to the tail of the output of a tool call, and
+// returns its last maxToolOutputLines lines. Colors are removed and lines
+// rewritten with carriage returns, like progress bars, keep their last text.
+func outputTail(tail, output string) string {
+	output = strings.ReplaceAll(ansi.Strip(output), "\r\n", "\n")
+	lines := strings.Split(tail+output, "\n")
+	for i, line := range lines {
+		if j := strings.LastIndex(line, "\r"); j >= 0 {
+			lines[i] = line[j+1:]
+		}
+	}
+	if len(lines) > maxToolOutputLines {
+		lines = lines[len(lines)-maxToolOutputLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
 // renders params, params[0] (params[1]=params[2] ....)
 func renderParams(paramsWidth int, params ...string) string {
 	if len(params) == 0 {
@@ -340,11 +416,33 @@ func renderToolParams(paramWidth int, toolCall message.ToolCall) string {
 		json.Unmarshal([]byte(toolCall.Input), &params)
 		prompt := strings.ReplaceAll(params.Prompt, "\n", " ")
 		return renderParams(paramWidth, prompt)
+	case agent.PlanToolName:
+		var plan agent.Plan
+		json.Unmarshal([]byte(toolCall.Input), &plan)
+		return renderParams(paramWidth, plan.Title, "steps", fmt.Sprintf("%d", len(plan.Steps)))
 	case tools.BashToolName:
 		var params tools.BashParams
 		json.Unmarshal([]byte(toolCall.Input), &params)
 		command := strings.ReplaceAll(params.Command, "\n", " ")
+		if params.Background {
+			return renderParams(paramWidth, command, "background", "true")
+		}
 		return renderParams(paramWidth, command)
+	case tools.JobOutputToolName:
+		var params tools.JobOutputParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		return renderParams(paramWidth, params.ID)
+	case tools.JobInputToolName:
+		var params tools.JobInputParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		if params.Signal != "" {
+			return renderParams(paramWidth, params.ID, "signal", params.Signal)
+		}
+		return renderParams(paramWidth, params.ID)
+	case tools.JobKillToolName:
+		var params tools.JobKillParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		return renderParams(paramWidth, params.ID)
 	case tools.EditToolName:
 		var params tools.EditParams
 		json.Unmarshal([]byte(toolCall.Input), &params)
@@ -423,6 +521,33 @@ func renderToolParams(paramWidth int, toolCall message.ToolCall) string {
 		json.Unmarshal([]byte(toolCall.Input), &params)
 		filePath := removeWorkingDirPrefix(params.FilePath)
 		return renderParams(paramWidth, filePath)
+	case tools.LSPDefinitionToolName, tools.LSPImplementationToolName, tools.LSPReferencesToolName, tools.LSPHoverToolName:
+		var params tools.LSPPositionParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		return renderParams(paramWidth, lspPosition(params))
+	case tools.LSPCallsToolName:
+		var params tools.LSPCallsParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		return renderParams(paramWidth, lspPosition(params.LSPPositionParams), "direction", params.Direction)
+	case tools.LSPRenameToolName:
+		var params tools.LSPRenameParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		return renderParams(paramWidth, lspPosition(params.LSPPositionParams), "new_name", params.NewName)
+	case tools.LSPCodeActionToolName:
+		var params tools.LSPCodeActionParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		position := fmt.Sprintf("%s:%d", removeWorkingDirPrefix(params.FilePath), params.Line)
+		if params.EndLine > params.Line {
+			position += fmt.Sprintf("-%d", params.EndLine)
+		}
+		return renderParams(paramWidth, position, "action", params.Action)
+	case tools.LSPSymbolsToolName:
+		var params tools.LSPSymbolsParams
+		json.Unmarshal([]byte(toolCall.Input), &params)
+		if params.FilePath == "" {
+			return renderParams(paramWidth, params.Query)
+		}
+		return renderParams(paramWidth, removeWorkingDirPrefix(params.FilePath), "query", params.Query)
 	default:
 		input := strings.ReplaceAll(toolCall.Input, "\n", " ")
 		params = renderParams(paramWidth, input)
@@ -430,6 +555,15 @@ func renderToolParams(paramWidth int, toolCall message.ToolCall) string {
 	return params
 }
 
+// lspPosition renders the symbol of an LSP tool call as path:line symbol
+func lspPosition(params tools.LSPPositionParams) string {
+	position := fmt.Sprintf("%s:%d", removeWorkingDirPrefix(params.FilePath), params.Line)
+	if params.Symbol != "" {
+		return position + " " + params.Symbol
+	}
+	return position
+}
+
 func truncateHeight(content string, height int) string {
 	lines := strings.Split(content, "\n")
 	if len(lines) > height {
@@ -458,7 +592,14 @@ func renderToolResponse(toolCall message.ToolCall, response message.ToolResult,
 			toMarkdown(resultContent, false, width),
 			t.Background(),
 		)
-	case tools.BashToolName:
+	case agent.PlanToolName:
+		var plan agent.Plan
+		json.Unmarshal([]byte(toolCall.Input), &plan)
+		return styles.ForceReplaceBackgroundWithLipgloss(
+			toMarkdown(plan.Markdown(), false, width),
+			t.Background(),
+		)
+	case tools.BashToolName, tools.JobOutputToolName:
 		resultContent = fmt.Sprintf("```bash\n%s\n```", resultContent)
 		return styles.ForceReplaceBackgroundWithLipgloss(
 			toMarkdown(resultContent, true, width),
@@ -536,6 +677,7 @@ func renderToolMessage(
 	toolCall message.ToolCall,
 	allMessages []message.Message,
 	messagesService message.Service,
+	output string,
 	focusedUIMessageId string,
 	nested bool,
 	width int,
@@ -570,6 +712,7 @@ func renderToolMessage(
 
 		content := style.Render(lipgloss.JoinHorizontal(lipgloss.Left, toolNameText, progressText))
 		toolMsg := uiMessage{
+			ID:          toolCall.ID,
 			messageType: toolMessageType,
 			position:    position,
 			height:      lipgloss.Height(content),
@@ -583,6 +726,16 @@ func renderToolMessage(
 	if response != nil {
 		responseContent = renderToolResponse(toolCall, *response, width-2)
 		responseContent = strings.TrimSuffix(responseContent, "\n")
+	} else if output != "" {
+		// The output so far, the response replaces it once the tool is done
+		lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		for i, line := range lines {
+			lines[i] = ansi.Truncate(line, width-2, "…")
+		}
+		responseContent = baseStyle.
+			Width(width - 2).
+			Foreground(t.TextMuted()).
+			Render(strings.Join(lines, "\n"))
 	} else {
 		responseContent = baseStyle.
 			Italic(true).
@@ -617,7 +770,7 @@ func renderToolMessage(
 			toolCalls = append(toolCalls, v.ToolCalls()...)
 		}
 		for _, call := range toolCalls {
-			rendered := renderToolMessage(call, []message.Message{}, messagesService, focusedUIMessageId, true, width, 0)
+			rendered := renderToolMessage(call, []message.Message{}, messagesService, "", focusedUIMessageId, true, width, 0)
 			parts = append(parts, rendered.content)
 		}
 	}
@@ -638,6 +791,7 @@ func renderToolMessage(
 		)
 	}
 	toolMsg := uiMessage{
+		ID:          toolCall.ID,
 		messageType: toolMessageType,
 		position:    position,
 		height:      lipgloss.Height(content),
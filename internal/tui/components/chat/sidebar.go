@@ -8,9 +8,11 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/opencode-ai/opencode/internal/config"
 	"github.com/opencode-ai/opencode/internal/diff"
 	"github.com/opencode-ai/opencode/internal/history"
+	"github.com/opencode-ai/opencode/internal/jobs"
 	"github.com/opencode-ai/opencode/internal/pubsub"
 	"github.com/opencode-ai/opencode/internal/session"
 	"github.com/opencode-ai/opencode/internal/tui/styles"
@@ -21,7 +23,10 @@ type sidebarCmp struct {
 	width, height int
 	session       session.Session
 	history       history.Service
-	modFiles      map[string]struct {
+	jobs          jobs.Service
+	// sessionJobs are the background jobs of the session, oldest first
+	sessionJobs []jobs.Job
+	modFiles    map[string]struct {
 		additions int
 		removals  int
 	}
@@ -57,6 +62,7 @@ func (m *sidebarCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.session = msg
 			ctx := context.Background()
 			m.loadModifiedFiles(ctx)
+			m.loadJobs()
 		}
 	case pubsub.Event[session.Session]:
 		if msg.Type == pubsub.UpdatedEvent {
@@ -64,6 +70,10 @@ func (m *sidebarCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.session = msg.Payload
 			}
 		}
+	case pubsub.Event[jobs.Job]:
+		if msg.Payload.SessionID == m.session.ID {
+			m.updateJob(msg.Payload)
+		}
 	case pubsub.Event[history.File]:
 		if msg.Payload.SessionID == m.session.ID {
 			// Process the individual file change instead of reloading all files
@@ -99,6 +109,57 @@ func (m *sidebarCmp) View() string {
 				lspsConfigured(m.width),
 				" ",
 				m.modifiedFiles(),
+				m.jobsSection(),
+			),
+		)
+}
+
+// jobsSection lists the background jobs of the session, nothing when there
+// are none
+func (m *sidebarCmp) jobsSection() string {
+	if len(m.sessionJobs) == 0 {
+		return ""
+	}
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+
+	title := baseStyle.
+		Width(m.width).
+		Foreground(t.Primary()).
+		Bold(true).
+		Render("Jobs:")
+
+	var jobViews []string
+	for _, job := range m.sessionJobs {
+		status := baseStyle.Foreground(t.Success()).Render("running")
+		switch {
+		case job.Status == jobs.StatusKilled:
+			status = baseStyle.Foreground(t.Error()).Render("killed")
+		case job.Status == jobs.StatusExited && job.ExitCode != 0:
+			status = baseStyle.Foreground(t.Error()).Render(fmt.Sprintf("exit %d", job.ExitCode))
+		case job.Status == jobs.StatusExited:
+			status = baseStyle.Foreground(t.TextMuted()).Render("done")
+		}
+		id := baseStyle.Foreground(t.Text()).Render(fmt.Sprintf("• %s ", job.ID))
+		command := ansi.Truncate(job.Command, m.width-lipgloss.Width(id)-lipgloss.Width(status)-1, "…")
+		jobViews = append(jobViews, baseStyle.Width(m.width).Render(
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				id,
+				baseStyle.Foreground(t.TextMuted()).Render(command+" "),
+				status,
+			),
+		))
+	}
+
+	return baseStyle.
+		Width(m.width).
+		Render(
+			lipgloss.JoinVertical(
+				lipgloss.Top,
+				" ",
+				title,
+				lipgloss.JoinVertical(lipgloss.Left, jobViews...),
 			),
 		)
 }
@@ -235,11 +296,33 @@ func (m *sidebarCmp) GetSize() (int, int) {
 	return m.width, m.height
 }
 
-func NewSidebarCmp(session session.Session, history history.Service) tea.Model {
-	return &sidebarCmp{
+func NewSidebarCmp(session session.Session, history history.Service, jobs jobs.Service) tea.Model {
+	m := &sidebarCmp{
 		session: session,
 		history: history,
+		jobs:    jobs,
+	}
+	m.loadJobs()
+	return m
+}
+
+func (m *sidebarCmp) loadJobs() {
+	m.sessionJobs = nil
+	if m.jobs == nil || m.session.ID == "" {
+		return
+	}
+	m.sessionJobs = m.jobs.List(m.session.ID)
+}
+
+// updateJob adds a job that started or updates one that ended
+func (m *sidebarCmp) updateJob(job jobs.Job) {
+	for i, j := range m.sessionJobs {
+		if j.ID == job.ID {
+			m.sessionJobs[i] = job
+			return
+		}
 	}
+	m.sessionJobs = append(m.sessionJobs, job)
 }
 
 func (m *sidebarCmp) loadModifiedFiles(ctx context.Context) {
@@ -2,12 +2,15 @@ package core
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/opencode-ai/opencode/internal/config"
+	"github.com/opencode-ai/opencode/internal/llm/agent"
 	"github.com/opencode-ai/opencode/internal/llm/models"
 	"github.com/opencode-ai/opencode/internal/lsp"
 	"github.com/opencode-ai/opencode/internal/lsp/protocol"
@@ -27,8 +30,9 @@ type statusCmp struct {
 	info       util.InfoMsg
 	width      int
 	messageTTL time.Duration
-	lspClients map[string]*lsp.Client
+	lspClients *lsp.Clients
 	session    session.Session
+	budget     agent.BudgetStatus
 }
 
 // clearMessageCmd is a command that clears status messages after a timeout
@@ -48,9 +52,17 @@ func (m statusCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		return m, nil
 	case chat.SessionSelectedMsg:
+		if m.session.ID != msg.ID {
+			m.budget = agent.BudgetStatus{}
+		}
 		m.session = msg
 	case chat.SessionClearedMsg:
 		m.session = session.Session{}
+		m.budget = agent.BudgetStatus{}
+	case pubsub.Event[agent.AgentEvent]:
+		if msg.Payload.Budget != nil && msg.Payload.SessionID == m.session.ID {
+			m.budget = *msg.Payload.Budget
+		}
 	case pubsub.Event[session.Session]:
 		if msg.Type == pubsub.UpdatedEvent {
 			if m.session.ID == msg.Payload.ID {
@@ -84,8 +96,8 @@ func getHelpWidget() string {
 		Render(helpText)
 }
 
-func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
-	// Format tokens in human-readable format (e.g., 110K, 1.2M)
+// formatTokens formats tokens in human-readable format (e.g., 110K, 1.2M)
+func formatTokens(tokens int64) string {
 	var formattedTokens string
 	switch {
 	case tokens >= 1_000_000:
@@ -103,6 +115,11 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 	if strings.HasSuffix(formattedTokens, ".0M") {
 		formattedTokens = strings.Replace(formattedTokens, ".0M", "M", 1)
 	}
+	return formattedTokens
+}
+
+func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
+	formattedTokens := formatTokens(tokens)
 
 	// Format cost with $ symbol and 2 decimal places
 	formattedCost := fmt.Sprintf("$%.2f", cost)
@@ -116,9 +133,25 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 	return fmt.Sprintf("Context: %s, Cost: %s", formattedTokens, formattedCost)
 }
 
+// formatBudget shows what is left of the limit that is the closest to be
+// exhausted, in dollars or tokens.
+func formatBudget(b agent.BudgetStatus) string {
+	costUsed, tokensUsed := -1.0, -1.0
+	if b.Limit.Cost > 0 {
+		costUsed = b.Spent.Cost / b.Limit.Cost
+	}
+	if b.Limit.Tokens > 0 {
+		tokensUsed = float64(b.Spent.Tokens) / float64(b.Limit.Tokens)
+	}
+	if costUsed >= tokensUsed {
+		return fmt.Sprintf("Budget (%s): $%.2f left", b.Scope, max(0, b.Limit.Cost-b.Spent.Cost))
+	}
+	return fmt.Sprintf("Budget (%s): %s tokens left", b.Scope, formatTokens(max(0, b.Limit.Tokens-b.Spent.Tokens)))
+}
+
 func (m statusCmp) View() string {
 	t := theme.CurrentTheme()
-	modelID := config.Get().Agents[config.AgentCoder].Model
+	modelID := config.Get().Agents[m.agentName()].Model
 	model := models.SupportedModels[modelID]
 
 	// Initialize the help widget
@@ -138,10 +171,28 @@ func (m statusCmp) View() string {
 		tokenInfoWidth = lipgloss.Width(tokens) + 2
 		status += tokensStyle.Render(tokens)
 	}
+	if !m.budget.Limit.IsZero() {
+		budget := formatBudget(m.budget)
+		budgetStyle := styles.Padded().
+			Background(t.TextMuted()).
+			Foreground(t.BackgroundSecondary())
+		if m.budget.Warning() {
+			budgetStyle = budgetStyle.Background(t.Warning())
+		}
+		if m.budget.Exceeded() {
+			budgetStyle = budgetStyle.Background(t.Error())
+		}
+		tokenInfoWidth += lipgloss.Width(budget) + 2
+		status += budgetStyle.Render(budget)
+	}
 
+	diagnosticsInfo := m.projectDiagnostics()
+	if servers := m.lspServers(); servers != "" {
+		diagnosticsInfo = servers + " " + diagnosticsInfo
+	}
 	diagnostics := styles.Padded().
 		Background(t.BackgroundDarker()).
-		Render(m.projectDiagnostics())
+		Render(diagnosticsInfo)
 
 	availableWidht := max(0, m.width-lipgloss.Width(helpWidget)-lipgloss.Width(m.model())-lipgloss.Width(diagnostics)-tokenInfoWidth)
 
@@ -182,28 +233,11 @@ func (m statusCmp) View() string {
 func (m *statusCmp) projectDiagnostics() string {
 	t := theme.CurrentTheme()
 
-	// Check if any LSP server is still initializing
-	initializing := false
-	for _, client := range m.lspClients {
-		if client.GetServerState() == lsp.StateStarting {
-			initializing = true
-			break
-		}
-	}
-
-	// If any server is initializing, show that status
-	if initializing {
-		return lipgloss.NewStyle().
-			Background(t.BackgroundDarker()).
-			Foreground(t.Warning()).
-			Render(fmt.Sprintf("%s Initializing LSP...", styles.SpinnerIcon))
-	}
-
 	errorDiagnostics := []protocol.Diagnostic{}
 	warnDiagnostics := []protocol.Diagnostic{}
 	hintDiagnostics := []protocol.Diagnostic{}
 	infoDiagnostics := []protocol.Diagnostic{}
-	for _, client := range m.lspClients {
+	for _, client := range m.lspClients.All() {
 		for _, d := range client.GetDiagnostics() {
 			for _, diag := range d {
 				switch diag.Severity {
@@ -258,6 +292,30 @@ func (m *statusCmp) projectDiagnostics() string {
 	return strings.Join(diagnostics, " ")
 }
 
+// lspServers shows the state of each language server
+func (m statusCmp) lspServers() string {
+	t := theme.CurrentTheme()
+
+	servers := []string{}
+	clients := m.lspClients.All()
+	for _, name := range slices.Sorted(maps.Keys(clients)) {
+		icon, color := styles.CheckIcon, t.Success()
+		switch clients[name].GetServerState() {
+		case lsp.StateStarting:
+			icon, color = styles.LoadingIcon, t.Warning()
+		case lsp.StateError:
+			icon, color = styles.WarningIcon, t.Warning()
+		case lsp.StateCrashed:
+			icon, color = styles.ErrorIcon, t.Error()
+		}
+		servers = append(servers, lipgloss.NewStyle().
+			Background(t.BackgroundDarker()).
+			Foreground(color).
+			Render(fmt.Sprintf("%s %s", name, icon)))
+	}
+	return strings.Join(servers, " ")
+}
+
 func (m statusCmp) availableFooterMsgWidth(diagnostics, tokenInfo string) int {
 	tokensWidth := 0
 	if m.session.ID != "" {
@@ -271,19 +329,34 @@ func (m statusCmp) model() string {
 
 	cfg := config.Get()
 
-	coder, ok := cfg.Agents[config.AgentCoder]
+	agentName := m.agentName()
+	agent, ok := cfg.Agents[agentName]
 	if !ok {
 		return "Unknown"
 	}
-	model := models.SupportedModels[coder.Model]
+	model := models.SupportedModels[agent.Model]
 
+	name := model.Name
+	if agentName != config.AgentCoder {
+		name = fmt.Sprintf("%s · %s", agentName, model.Name)
+	}
 	return styles.Padded().
 		Background(t.Secondary()).
 		Foreground(t.Background()).
-		Render(model.Name)
+		Render(name)
+}
+
+// agentName is the agent answering in the current session, sessions fall
+// back to the coder when their agent is no longer configured
+func (m statusCmp) agentName() config.AgentName {
+	name := config.AgentName(m.session.Agent)
+	if _, ok := config.Get().Agents[name]; ok && !config.IsBuiltinAgent(name) {
+		return name
+	}
+	return config.AgentCoder
 }
 
-func NewStatusCmp(lspClients map[string]*lsp.Client) StatusCmp {
+func NewStatusCmp(lspClients *lsp.Clients) StatusCmp {
 	helpWidget = getHelpWidget()
 
 	return &statusCmp{
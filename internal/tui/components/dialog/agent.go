@@ -0,0 +1,152 @@
+package dialog
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/opencode-ai/opencode/internal/config"
+	utilComponents "github.com/opencode-ai/opencode/internal/tui/components/util"
+	"github.com/opencode-ai/opencode/internal/tui/layout"
+	"github.com/opencode-ai/opencode/internal/tui/styles"
+	"github.com/opencode-ai/opencode/internal/tui/theme"
+	"github.com/opencode-ai/opencode/internal/tui/util"
+)
+
+// AgentSelectedMsg is sent when an agent is selected for the current session
+type AgentSelectedMsg struct {
+	Name config.AgentName
+}
+
+// CloseAgentDialogMsg is sent when the agent dialog is closed
+type CloseAgentDialogMsg struct{}
+
+type agentItem struct {
+	name        config.AgentName
+	description string
+	current     bool
+}
+
+func (a agentItem) Render(selected bool, width int) string {
+	t := theme.CurrentTheme()
+	itemStyle := styles.BaseStyle().Width(width).Padding(0, 1)
+	descStyle := styles.BaseStyle().Foreground(t.TextMuted())
+	if selected {
+		itemStyle = itemStyle.
+			Background(t.Primary()).
+			Foreground(t.Background()).
+			Bold(true)
+		descStyle = descStyle.
+			Background(t.Primary()).
+			Foreground(t.Background())
+	}
+	title := string(a.name)
+	if a.current {
+		title += " (current)"
+	}
+	if a.description != "" {
+		title += " " + descStyle.Render("· "+a.description)
+	}
+	return itemStyle.Render(ansi.Truncate(title, width-2, "…"))
+}
+
+// AgentDialog lists the agents a session can switch to
+type AgentDialog interface {
+	tea.Model
+	layout.Bindings
+	SetAgents(current config.AgentName)
+}
+
+type agentDialogCmp struct {
+	listView utilComponents.SimpleList[agentItem]
+	width    int
+	height   int
+}
+
+func (a *agentDialogCmp) Init() tea.Cmd {
+	return a.listView.Init()
+}
+
+func (a *agentDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, branchKeys.Enter):
+			item, idx := a.listView.GetSelectedItem()
+			if idx != -1 {
+				return a, util.CmdHandler(AgentSelectedMsg{Name: item.name})
+			}
+		case key.Matches(msg, branchKeys.Escape):
+			return a, util.CmdHandler(CloseAgentDialogMsg{})
+		}
+	case tea.WindowSizeMsg:
+		a.width = msg.Width
+		a.height = msg.Height
+	}
+
+	u, cmd := a.listView.Update(msg)
+	a.listView = u.(utilComponents.SimpleList[agentItem])
+	return a, cmd
+}
+
+func (a *agentDialogCmp) View() string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+
+	maxWidth := max(40, min(80, a.width-15))
+	a.listView.SetMaxWidth(maxWidth)
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		baseStyle.
+			Foreground(t.Primary()).
+			Bold(true).
+			Width(maxWidth).
+			Padding(0, 1).
+			Render("Switch Agent"),
+		baseStyle.Width(maxWidth).Render(""),
+		baseStyle.Width(maxWidth).Render(a.listView.View()),
+		baseStyle.Width(maxWidth).Render(""),
+	)
+
+	return baseStyle.Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(t.Background()).
+		BorderForeground(t.TextMuted()).
+		Width(lipgloss.Width(content) + 4).
+		Render(content)
+}
+
+func (a *agentDialogCmp) BindingKeys() []key.Binding {
+	return append(layout.KeyMapToSlice(branchKeys), a.listView.BindingKeys()...)
+}
+
+// SetAgents lists the coder and the user-defined agents of the configuration
+func (a *agentDialogCmp) SetAgents(current config.AgentName) {
+	agents := config.Get().Agents
+	items := []agentItem{{
+		name:        config.AgentCoder,
+		description: "The default coding agent",
+		current:     current == config.AgentCoder,
+	}}
+	for _, name := range config.CustomAgents() {
+		items = append(items, agentItem{
+			name:        name,
+			description: agents[name].Description,
+			current:     current == name,
+		})
+	}
+	a.listView.SetItems(items)
+}
+
+// NewAgentDialogCmp creates a new dialog to switch the agent of a session
+func NewAgentDialogCmp() AgentDialog {
+	return &agentDialogCmp{
+		listView: utilComponents.NewSimpleList[agentItem](
+			[]agentItem{},
+			10,
+			"No agents available",
+			true,
+		),
+	}
+}
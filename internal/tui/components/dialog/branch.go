@@ -0,0 +1,307 @@
+package dialog
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/opencode-ai/opencode/internal/message"
+	"github.com/opencode-ai/opencode/internal/session"
+	utilComponents "github.com/opencode-ai/opencode/internal/tui/components/util"
+	"github.com/opencode-ai/opencode/internal/tui/layout"
+	"github.com/opencode-ai/opencode/internal/tui/styles"
+	"github.com/opencode-ai/opencode/internal/tui/theme"
+	"github.com/opencode-ai/opencode/internal/tui/util"
+)
+
+// BranchSelectedMsg is sent when a prompt to branch from is selected. The new
+// session gets the messages up to and including MessageID, and Prompt is the
+// text of the selected prompt so it can be edited before sending it again.
+type BranchSelectedMsg struct {
+	MessageID string
+	Prompt    string
+}
+
+// CloseBranchDialogMsg is sent when the branch dialogs are closed
+type CloseBranchDialogMsg struct{}
+
+type branchKeyMap struct {
+	Enter  key.Binding
+	Escape key.Binding
+}
+
+var branchKeys = branchKeyMap{
+	Enter: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "select"),
+	),
+	Escape: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "close"),
+	),
+}
+
+type branchPointItem struct {
+	// previousID is the message right before the prompt, empty for the first one
+	previousID string
+	prompt     string
+	index      int
+}
+
+func (b branchPointItem) Render(selected bool, width int) string {
+	t := theme.CurrentTheme()
+	itemStyle := styles.BaseStyle().Width(width).Padding(0, 1)
+	if selected {
+		itemStyle = itemStyle.
+			Background(t.Primary()).
+			Foreground(t.Background()).
+			Bold(true)
+	}
+	text := strings.Join(strings.Fields(b.prompt), " ")
+	return itemStyle.Render(ansi.Truncate(fmt.Sprintf("%d. %s", b.index, text), width-2, "…"))
+}
+
+// BranchDialog lists the prompts of a session to branch from
+type BranchDialog interface {
+	tea.Model
+	layout.Bindings
+	SetMessages(messages []message.Message)
+}
+
+type branchDialogCmp struct {
+	listView utilComponents.SimpleList[branchPointItem]
+	width    int
+	height   int
+}
+
+func (b *branchDialogCmp) Init() tea.Cmd {
+	return b.listView.Init()
+}
+
+func (b *branchDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, branchKeys.Enter):
+			item, idx := b.listView.GetSelectedItem()
+			if idx != -1 {
+				return b, util.CmdHandler(BranchSelectedMsg{
+					MessageID: item.previousID,
+					Prompt:    item.prompt,
+				})
+			}
+		case key.Matches(msg, branchKeys.Escape):
+			return b, util.CmdHandler(CloseBranchDialogMsg{})
+		}
+	case tea.WindowSizeMsg:
+		b.width = msg.Width
+		b.height = msg.Height
+	}
+
+	u, cmd := b.listView.Update(msg)
+	b.listView = u.(utilComponents.SimpleList[branchPointItem])
+	return b, cmd
+}
+
+func (b *branchDialogCmp) View() string {
+	maxWidth := max(40, min(80, b.width-15))
+	b.listView.SetMaxWidth(maxWidth)
+	return renderBranchDialog("Branch From Prompt", b.listView.View(), maxWidth)
+}
+
+func (b *branchDialogCmp) BindingKeys() []key.Binding {
+	return append(layout.KeyMapToSlice(branchKeys), b.listView.BindingKeys()...)
+}
+
+func (b *branchDialogCmp) SetMessages(messages []message.Message) {
+	items := []branchPointItem{}
+	for i, msg := range messages {
+		if msg.Role != message.User {
+			continue
+		}
+		item := branchPointItem{
+			prompt: msg.Content().String(),
+			index:  len(items) + 1,
+		}
+		if i > 0 {
+			item.previousID = messages[i-1].ID
+		}
+		items = append(items, item)
+	}
+	// Most recent prompts first, they are the usual place to branch from
+	slices.Reverse(items)
+	b.listView.SetItems(items)
+}
+
+// NewBranchDialogCmp creates a new dialog to pick the prompt to branch from
+func NewBranchDialogCmp() BranchDialog {
+	return &branchDialogCmp{
+		listView: utilComponents.NewSimpleList[branchPointItem](
+			[]branchPointItem{},
+			10,
+			"No prompts to branch from",
+			true,
+		),
+	}
+}
+
+type branchTreeItem struct {
+	session session.Session
+	depth   int
+	current bool
+}
+
+func (b branchTreeItem) Render(selected bool, width int) string {
+	t := theme.CurrentTheme()
+	itemStyle := styles.BaseStyle().Width(width).Padding(0, 1)
+	if selected {
+		itemStyle = itemStyle.
+			Background(t.Primary()).
+			Foreground(t.Background()).
+			Bold(true)
+	}
+	prefix := ""
+	if b.depth > 0 {
+		prefix = strings.Repeat("  ", b.depth-1) + "└─ "
+	}
+	title := b.session.Title
+	if b.current {
+		title += " (current)"
+	}
+	return itemStyle.Render(ansi.Truncate(fmt.Sprintf("%s%s · %d messages", prefix, title, b.session.MessageCount), width-2, "…"))
+}
+
+// BranchTreeDialog shows the branches related to a session
+type BranchTreeDialog interface {
+	tea.Model
+	layout.Bindings
+	SetSessions(sessions []session.Session, currentID string)
+}
+
+type branchTreeDialogCmp struct {
+	listView utilComponents.SimpleList[branchTreeItem]
+	width    int
+	height   int
+}
+
+func (b *branchTreeDialogCmp) Init() tea.Cmd {
+	return b.listView.Init()
+}
+
+func (b *branchTreeDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, branchKeys.Enter):
+			item, idx := b.listView.GetSelectedItem()
+			if idx != -1 {
+				return b, util.CmdHandler(SessionSelectedMsg{
+					Session: item.session,
+				})
+			}
+		case key.Matches(msg, branchKeys.Escape):
+			return b, util.CmdHandler(CloseBranchDialogMsg{})
+		}
+	case tea.WindowSizeMsg:
+		b.width = msg.Width
+		b.height = msg.Height
+	}
+
+	u, cmd := b.listView.Update(msg)
+	b.listView = u.(utilComponents.SimpleList[branchTreeItem])
+	return b, cmd
+}
+
+func (b *branchTreeDialogCmp) View() string {
+	maxWidth := max(40, min(80, b.width-15))
+	b.listView.SetMaxWidth(maxWidth)
+	return renderBranchDialog("Session Branches", b.listView.View(), maxWidth)
+}
+
+func (b *branchTreeDialogCmp) BindingKeys() []key.Binding {
+	return append(layout.KeyMapToSlice(branchKeys), b.listView.BindingKeys()...)
+}
+
+// SetSessions shows the whole tree the current session belongs to, starting
+// from its oldest ancestor that still exists.
+func (b *branchTreeDialogCmp) SetSessions(sessions []session.Session, currentID string) {
+	byID := make(map[string]session.Session, len(sessions))
+	children := make(map[string][]session.Session)
+	for _, s := range sessions {
+		byID[s.ID] = s
+	}
+	for _, s := range sessions {
+		if _, ok := byID[s.BranchParentID]; ok {
+			children[s.BranchParentID] = append(children[s.BranchParentID], s)
+		}
+	}
+
+	root, ok := byID[currentID]
+	if !ok {
+		b.listView.SetItems([]branchTreeItem{})
+		return
+	}
+	for {
+		parent, ok := byID[root.BranchParentID]
+		if !ok {
+			break
+		}
+		root = parent
+	}
+
+	items := []branchTreeItem{}
+	var walk func(s session.Session, depth int)
+	walk = func(s session.Session, depth int) {
+		items = append(items, branchTreeItem{session: s, depth: depth, current: s.ID == currentID})
+		branches := children[s.ID]
+		slices.SortFunc(branches, func(a, b session.Session) int {
+			return int(a.CreatedAt - b.CreatedAt)
+		})
+		for _, branch := range branches {
+			walk(branch, depth+1)
+		}
+	}
+	walk(root, 0)
+	b.listView.SetItems(items)
+}
+
+// NewBranchTreeDialogCmp creates a new dialog to browse the branches of a session
+func NewBranchTreeDialogCmp() BranchTreeDialog {
+	return &branchTreeDialogCmp{
+		listView: utilComponents.NewSimpleList[branchTreeItem](
+			[]branchTreeItem{},
+			10,
+			"No branches available",
+			true,
+		),
+	}
+}
+
+func renderBranchDialog(title, list string, width int) string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		baseStyle.
+			Foreground(t.Primary()).
+			Bold(true).
+			Width(width).
+			Padding(0, 1).
+			Render(title),
+		baseStyle.Width(width).Render(""),
+		baseStyle.Width(width).Render(list),
+		baseStyle.Width(width).Render(""),
+	)
+
+	return baseStyle.Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(t.Background()).
+		BorderForeground(t.TextMuted()).
+		Width(lipgloss.Width(content) + 4).
+		Render(content)
+}
@@ -23,6 +23,7 @@ type PermissionAction string
 const (
 	PermissionAllow           PermissionAction = "allow"
 	PermissionAllowForSession PermissionAction = "allow_session"
+	PermissionAllowAlways     PermissionAction = "allow_always"
 	PermissionDeny            PermissionAction = "deny"
 )
 
@@ -45,6 +46,7 @@ type permissionsMapping struct {
 	EnterSpace   key.Binding
 	Allow        key.Binding
 	AllowSession key.Binding
+	AllowAlways  key.Binding
 	Deny         key.Binding
 	Tab          key.Binding
 }
@@ -70,6 +72,10 @@ var permissionsKeys = permissionsMapping{
 		key.WithKeys("s"),
 		key.WithHelp("s", "allow for session"),
 	),
+	AllowAlways: key.NewBinding(
+		key.WithKeys("A"),
+		key.WithHelp("A", "allow always"),
+	),
 	Deny: key.NewBinding(
 		key.WithKeys("d"),
 		key.WithHelp("d", "deny"),
@@ -80,6 +86,9 @@ var permissionsKeys = permissionsMapping{
 	),
 }
 
+// minPermissionDialogWidth fits the buttons of the dialog
+const minPermissionDialogWidth = 72
+
 // permissionDialogCmp is the implementation of PermissionDialog
 type permissionDialogCmp struct {
 	width           int
@@ -87,7 +96,7 @@ type permissionDialogCmp struct {
 	permission      permission.PermissionRequest
 	windowSize      tea.WindowSizeMsg
 	contentViewPort viewport.Model
-	selectedOption  int // 0: Allow, 1: Allow for session, 2: Deny
+	selectedOption  int // 0: Allow, 1: Allow for session, 2: Allow always, 3: Deny
 
 	diffCache     map[string]string
 	markdownCache map[string]string
@@ -110,16 +119,18 @@ func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, permissionsKeys.Right) || key.Matches(msg, permissionsKeys.Tab):
-			p.selectedOption = (p.selectedOption + 1) % 3
+			p.selectedOption = (p.selectedOption + 1) % 4
 			return p, nil
 		case key.Matches(msg, permissionsKeys.Left):
-			p.selectedOption = (p.selectedOption + 2) % 3
+			p.selectedOption = (p.selectedOption + 3) % 4
 		case key.Matches(msg, permissionsKeys.EnterSpace):
 			return p, p.selectCurrentOption()
 		case key.Matches(msg, permissionsKeys.Allow):
 			return p, util.CmdHandler(PermissionResponseMsg{Action: PermissionAllow, Permission: p.permission})
 		case key.Matches(msg, permissionsKeys.AllowSession):
 			return p, util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowForSession, Permission: p.permission})
+		case key.Matches(msg, permissionsKeys.AllowAlways):
+			return p, util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowAlways, Permission: p.permission})
 		case key.Matches(msg, permissionsKeys.Deny):
 			return p, util.CmdHandler(PermissionResponseMsg{Action: PermissionDeny, Permission: p.permission})
 		default:
@@ -142,6 +153,8 @@ func (p *permissionDialogCmp) selectCurrentOption() tea.Cmd {
 	case 1:
 		action = PermissionAllowForSession
 	case 2:
+		action = PermissionAllowAlways
+	case 3:
 		action = PermissionDeny
 	}
 
@@ -152,40 +165,20 @@ func (p *permissionDialogCmp) renderButtons() string {
 	t := theme.CurrentTheme()
 	baseStyle := styles.BaseStyle()
 
-	allowStyle := baseStyle
-	allowSessionStyle := baseStyle
-	denyStyle := baseStyle
 	spacerStyle := baseStyle.Background(t.Background())
 
-	// Style the selected button
-	switch p.selectedOption {
-	case 0:
-		allowStyle = allowStyle.Background(t.Primary()).Foreground(t.Background())
-		allowSessionStyle = allowSessionStyle.Background(t.Background()).Foreground(t.Primary())
-		denyStyle = denyStyle.Background(t.Background()).Foreground(t.Primary())
-	case 1:
-		allowStyle = allowStyle.Background(t.Background()).Foreground(t.Primary())
-		allowSessionStyle = allowSessionStyle.Background(t.Primary()).Foreground(t.Background())
-		denyStyle = denyStyle.Background(t.Background()).Foreground(t.Primary())
-	case 2:
-		allowStyle = allowStyle.Background(t.Background()).Foreground(t.Primary())
-		allowSessionStyle = allowSessionStyle.Background(t.Background()).Foreground(t.Primary())
-		denyStyle = denyStyle.Background(t.Primary()).Foreground(t.Background())
+	// The selected button is highlighted
+	labels := []string{"Allow (a)", "Allow for session (s)", "Allow always (A)", "Deny (d)"}
+	buttons := make([]string, 0, 2*len(labels))
+	for i, label := range labels {
+		style := baseStyle.Background(t.Background()).Foreground(t.Primary())
+		if i == p.selectedOption {
+			style = baseStyle.Background(t.Primary()).Foreground(t.Background())
+		}
+		buttons = append(buttons, style.Padding(0, 1).Render(label), spacerStyle.Render("  "))
 	}
 
-	allowButton := allowStyle.Padding(0, 1).Render("Allow (a)")
-	allowSessionButton := allowSessionStyle.Padding(0, 1).Render("Allow for session (s)")
-	denyButton := denyStyle.Padding(0, 1).Render("Deny (d)")
-
-	content := lipgloss.JoinHorizontal(
-		lipgloss.Left,
-		allowButton,
-		spacerStyle.Render("  "),
-		allowSessionButton,
-		spacerStyle.Render("  "),
-		denyButton,
-		spacerStyle.Render("  "),
-	)
+	content := lipgloss.JoinHorizontal(lipgloss.Left, buttons...)
 
 	remainingWidth := p.width - lipgloss.Width(content)
 	if remainingWidth > 0 {
@@ -262,6 +255,20 @@ func (p *permissionDialogCmp) renderHeader() string {
 		)
 	case tools.FetchToolName:
 		headerParts = append(headerParts, baseStyle.Foreground(t.TextMuted()).Width(p.width).Bold(true).Render("URL"))
+	case tools.LSPRenameToolName, tools.LSPCodeActionToolName:
+		changeKey := baseStyle.Foreground(t.TextMuted()).Bold(true).Render("Change")
+		changeValue := baseStyle.
+			Foreground(t.Text()).
+			Width(p.width - lipgloss.Width(changeKey)).
+			Render(fmt.Sprintf(": %s", p.permission.Description))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				changeKey,
+				changeValue,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
 	}
 
 	return lipgloss.NewStyle().Background(t.Background()).Render(lipgloss.JoinVertical(lipgloss.Left, headerParts...))
@@ -327,6 +334,34 @@ func (p *permissionDialogCmp) renderWriteContent() string {
 	return ""
 }
 
+// renderWorkspaceEditContent renders the diffs of the files changed together
+// by a language server, one after the other
+func (p *permissionDialogCmp) renderWorkspaceEditContent() string {
+	if pr, ok := p.permission.Params.(tools.WorkspaceEditPermissionsParams); ok {
+		t := theme.CurrentTheme()
+		diffs := p.GetOrSetDiff(p.permission.ID, func() (string, error) {
+			parts := make([]string, 0, 2*len(pr.Files))
+			for _, file := range pr.Files {
+				fileDiff, err := diff.FormatDiff(file.Diff, diff.WithTotalWidth(p.contentViewPort.Width))
+				if err != nil {
+					return "", err
+				}
+				fileName := styles.BaseStyle().
+					Foreground(t.TextMuted()).
+					Bold(true).
+					Width(p.contentViewPort.Width).
+					Render(file.FilePath)
+				parts = append(parts, fileName, fileDiff)
+			}
+			return lipgloss.JoinVertical(lipgloss.Left, parts...), nil
+		})
+
+		p.contentViewPort.SetContent(diffs)
+		return p.styleViewport()
+	}
+	return ""
+}
+
 func (p *permissionDialogCmp) renderFetchContent() string {
 	t := theme.CurrentTheme()
 	baseStyle := styles.BaseStyle()
@@ -414,6 +449,12 @@ func (p *permissionDialogCmp) render() string {
 		contentFinal = p.renderWriteContent()
 	case tools.FetchToolName:
 		contentFinal = p.renderFetchContent()
+	case tools.LSPRenameToolName, tools.LSPCodeActionToolName:
+		contentFinal = p.renderWorkspaceEditContent()
+		if contentFinal == "" {
+			// The command of a code action, asked before it runs
+			contentFinal = p.renderDefaultContent()
+		}
 	default:
 		contentFinal = p.renderDefaultContent()
 	}
@@ -459,7 +500,7 @@ func (p *permissionDialogCmp) SetSize() tea.Cmd {
 	case tools.EditToolName:
 		p.width = int(float64(p.windowSize.Width) * 0.8)
 		p.height = int(float64(p.windowSize.Height) * 0.8)
-	case tools.WriteToolName:
+	case tools.WriteToolName, tools.LSPRenameToolName, tools.LSPCodeActionToolName:
 		p.width = int(float64(p.windowSize.Width) * 0.8)
 		p.height = int(float64(p.windowSize.Height) * 0.8)
 	case tools.FetchToolName:
@@ -469,6 +510,8 @@ func (p *permissionDialogCmp) SetSize() tea.Cmd {
 		p.width = int(float64(p.windowSize.Width) * 0.7)
 		p.height = int(float64(p.windowSize.Height) * 0.5)
 	}
+	// Leave room for the buttons
+	p.width = min(max(p.width, minPermissionDialogWidth), p.windowSize.Width)
 	return nil
 }
 
@@ -0,0 +1,168 @@
+package dialog
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/opencode-ai/opencode/internal/llm/agent"
+	"github.com/opencode-ai/opencode/internal/tui/styles"
+	"github.com/opencode-ai/opencode/internal/tui/theme"
+	"github.com/opencode-ai/opencode/internal/tui/util"
+)
+
+// PlanDecision is the answer of the user to a plan
+type PlanDecision int
+
+const (
+	PlanApprove PlanDecision = iota
+	PlanEdit
+	PlanReject
+)
+
+var planButtons = []struct {
+	decision PlanDecision
+	label    string
+}{
+	{PlanApprove, "Approve"},
+	{PlanEdit, "Edit"},
+	{PlanReject, "Reject"},
+}
+
+// PlanDialogCmp shows the plan submitted in plan mode and asks what to do with it
+type PlanDialogCmp struct {
+	width, height int
+	selected      int
+	sessionID     string
+	plan          agent.Plan
+}
+
+// NewPlanDialogCmp creates a new PlanDialogCmp.
+func NewPlanDialogCmp() PlanDialogCmp {
+	return PlanDialogCmp{}
+}
+
+// SetPlan sets the plan to review, "Approve" is selected by default
+func (m *PlanDialogCmp) SetPlan(sessionID string, plan agent.Plan) {
+	m.sessionID = sessionID
+	m.plan = plan
+	m.selected = 0
+}
+
+// Init implements tea.Model.
+func (m PlanDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (m PlanDialogCmp) decide(decision PlanDecision) tea.Cmd {
+	return util.CmdHandler(ClosePlanDialogMsg{
+		SessionID: m.sessionID,
+		Plan:      m.plan,
+		Decision:  decision,
+	})
+}
+
+// Update implements tea.Model.
+func (m PlanDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, key.NewBinding(key.WithKeys("esc", "r"))):
+			return m, m.decide(PlanReject)
+		case key.Matches(msg, key.NewBinding(key.WithKeys("a"))):
+			return m, m.decide(PlanApprove)
+		case key.Matches(msg, key.NewBinding(key.WithKeys("e"))):
+			return m, m.decide(PlanEdit)
+		case key.Matches(msg, key.NewBinding(key.WithKeys("tab", "right", "l"))):
+			m.selected = (m.selected + 1) % len(planButtons)
+			return m, nil
+		case key.Matches(msg, key.NewBinding(key.WithKeys("shift+tab", "left", "h"))):
+			m.selected = (m.selected + len(planButtons) - 1) % len(planButtons)
+			return m, nil
+		case key.Matches(msg, key.NewBinding(key.WithKeys("enter"))):
+			return m, m.decide(planButtons[m.selected].decision)
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	}
+	return m, nil
+}
+
+// View implements tea.Model.
+func (m PlanDialogCmp) View() string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+
+	maxWidth := max(40, min(100, m.width-10))
+
+	title := baseStyle.
+		Foreground(t.Primary()).
+		Bold(true).
+		Width(maxWidth).
+		Padding(0, 1).
+		Render("Review Plan")
+
+	// Keep room for the title, the buttons and the border
+	lines := strings.Split(m.plan.Markdown(), "\n")
+	if maxLines := max(5, m.height-16); len(lines) > maxLines {
+		lines = append(lines[:maxLines-1], "…")
+	}
+	body := baseStyle.
+		Foreground(t.Text()).
+		Width(maxWidth).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+
+	help := baseStyle.
+		Foreground(t.TextMuted()).
+		Width(maxWidth).
+		Padding(1, 1, 0, 1).
+		Render("Approve runs the plan with all tools, Edit puts it in the editor, Reject keeps planning.")
+
+	buttons := make([]string, 0, len(planButtons)*2)
+	for i, button := range planButtons {
+		style := baseStyle.
+			Background(t.Background()).
+			Foreground(t.Primary())
+		if i == m.selected {
+			style = baseStyle.
+				Background(t.Primary()).
+				Foreground(t.Background()).
+				Bold(true)
+		}
+		if i > 0 {
+			buttons = append(buttons, baseStyle.Render("  "))
+		}
+		buttons = append(buttons, style.Padding(0, 3).Render(button.label))
+	}
+	buttonRow := baseStyle.
+		Width(maxWidth).
+		Padding(1, 0).
+		Render(lipgloss.JoinHorizontal(lipgloss.Center, buttons...))
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		baseStyle.Width(maxWidth).Render(""),
+		body,
+		help,
+		buttonRow,
+	)
+
+	return baseStyle.Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(t.Background()).
+		BorderForeground(t.TextMuted()).
+		Width(lipgloss.Width(content) + 4).
+		Render(content)
+}
+
+// ClosePlanDialogMsg is sent when the plan dialog is closed with a decision
+type ClosePlanDialogMsg struct {
+	SessionID string
+	Plan      agent.Plan
+	Decision  PlanDecision
+}
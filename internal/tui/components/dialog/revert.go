@@ -0,0 +1,137 @@
+package dialog
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/opencode-ai/opencode/internal/app"
+	"github.com/opencode-ai/opencode/internal/tui/styles"
+	"github.com/opencode-ai/opencode/internal/tui/theme"
+	"github.com/opencode-ai/opencode/internal/tui/util"
+)
+
+// RevertDialogCmp shows what a revert changes and asks for confirmation
+type RevertDialogCmp struct {
+	width, height int
+	selected      int
+	plan          app.RevertPlan
+}
+
+// NewRevertDialogCmp creates a new RevertDialogCmp.
+func NewRevertDialogCmp() RevertDialogCmp {
+	return RevertDialogCmp{}
+}
+
+// SetPlan sets the revert to confirm, "No" is selected by default
+func (m *RevertDialogCmp) SetPlan(plan app.RevertPlan) {
+	m.plan = plan
+	m.selected = 1
+}
+
+// Init implements tea.Model.
+func (m RevertDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model.
+func (m RevertDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, key.NewBinding(key.WithKeys("esc"))):
+			return m, util.CmdHandler(CloseRevertDialogMsg{Plan: m.plan})
+		case key.Matches(msg, key.NewBinding(key.WithKeys("tab", "left", "right", "h", "l"))):
+			m.selected = (m.selected + 1) % 2
+			return m, nil
+		case key.Matches(msg, key.NewBinding(key.WithKeys("enter"))):
+			return m, util.CmdHandler(CloseRevertDialogMsg{Plan: m.plan, Confirm: m.selected == 0})
+		case key.Matches(msg, key.NewBinding(key.WithKeys("y"))):
+			return m, util.CmdHandler(CloseRevertDialogMsg{Plan: m.plan, Confirm: true})
+		case key.Matches(msg, key.NewBinding(key.WithKeys("n"))):
+			return m, util.CmdHandler(CloseRevertDialogMsg{Plan: m.plan})
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	}
+	return m, nil
+}
+
+// View implements tea.Model.
+func (m RevertDialogCmp) View() string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+
+	maxWidth := max(40, min(80, m.width-10))
+
+	title := baseStyle.
+		Foreground(t.Primary()).
+		Bold(true).
+		Width(maxWidth).
+		Padding(0, 1).
+		Render("Undo")
+
+	summary := baseStyle.
+		Foreground(t.Text()).
+		Width(maxWidth).
+		Padding(0, 1).
+		Render(m.plan.Summary())
+
+	question := baseStyle.
+		Foreground(t.Text()).
+		Width(maxWidth).
+		Padding(1, 1).
+		Render("Restore these files and revert the messages?")
+
+	yesStyle := baseStyle
+	noStyle := baseStyle
+	if m.selected == 0 {
+		yesStyle = yesStyle.
+			Background(t.Primary()).
+			Foreground(t.Background()).
+			Bold(true)
+		noStyle = noStyle.
+			Background(t.Background()).
+			Foreground(t.Primary())
+	} else {
+		noStyle = noStyle.
+			Background(t.Primary()).
+			Foreground(t.Background()).
+			Bold(true)
+		yesStyle = yesStyle.
+			Background(t.Background()).
+			Foreground(t.Primary())
+	}
+
+	yes := yesStyle.Padding(0, 3).Render("Yes")
+	no := noStyle.Padding(0, 3).Render("No")
+
+	buttons := lipgloss.JoinHorizontal(lipgloss.Center, yes, baseStyle.Render("  "), no)
+	buttons = baseStyle.
+		Width(maxWidth).
+		Padding(1, 0).
+		Render(buttons)
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		baseStyle.Width(maxWidth).Render(""),
+		summary,
+		question,
+		buttons,
+	)
+
+	return baseStyle.Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(t.Background()).
+		BorderForeground(t.TextMuted()).
+		Width(lipgloss.Width(content) + 4).
+		Render(content)
+}
+
+// CloseRevertDialogMsg is sent when the revert dialog is closed
+type CloseRevertDialogMsg struct {
+	Plan    app.RevertPlan
+	Confirm bool
+}
@@ -1,9 +1,14 @@
 package dialog
 
 import (
+	"strings"
+
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/opencode-ai/opencode/internal/message"
 	"github.com/opencode-ai/opencode/internal/session"
 	"github.com/opencode-ai/opencode/internal/tui/layout"
 	"github.com/opencode-ai/opencode/internal/tui/styles"
@@ -19,12 +24,24 @@ type SessionSelectedMsg struct {
 // CloseSessionDialogMsg is sent when the session dialog is closed
 type CloseSessionDialogMsg struct{}
 
+// SessionSearchMsg asks for the messages matching the query typed in the
+// session dialog, they are given back with SetSearchResults
+type SessionSearchMsg struct {
+	Query string
+}
+
+// SearchResultSelectedMsg is sent when a message found by a search is selected
+type SearchResultSelectedMsg struct {
+	Result message.SearchResult
+}
+
 // SessionDialog interface for the session switching dialog
 type SessionDialog interface {
 	tea.Model
 	layout.Bindings
 	SetSessions(sessions []session.Session)
 	SetSelectedSession(sessionID string)
+	SetSearchResults(query string, results []message.SearchResult)
 }
 
 type sessionDialogCmp struct {
@@ -33,6 +50,13 @@ type sessionDialogCmp struct {
 	width             int
 	height            int
 	selectedSessionID string
+
+	// searching is true while searching the messages instead of picking a
+	// session
+	searching bool
+	input     textinput.Model
+	results   []message.SearchResult
+	resultIdx int
 }
 
 type sessionKeyMap struct {
@@ -42,6 +66,7 @@ type sessionKeyMap struct {
 	Escape key.Binding
 	J      key.Binding
 	K      key.Binding
+	Search key.Binding
 }
 
 var sessionKeys = sessionKeyMap{
@@ -69,6 +94,10 @@ var sessionKeys = sessionKeyMap{
 		key.WithKeys("k"),
 		key.WithHelp("k", "previous session"),
 	),
+	Search: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("/", "search messages"),
+	),
 }
 
 func (s *sessionDialogCmp) Init() tea.Cmd {
@@ -76,9 +105,18 @@ func (s *sessionDialogCmp) Init() tea.Cmd {
 }
 
 func (s *sessionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && s.searching {
+		return s.updateSearch(keyMsg)
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
+		case key.Matches(msg, sessionKeys.Search):
+			s.searching = true
+			s.input.Reset()
+			s.results = nil
+			s.resultIdx = 0
+			return s, s.input.Focus()
 		case key.Matches(msg, sessionKeys.Up) || key.Matches(msg, sessionKeys.K):
 			if s.selectedIdx > 0 {
 				s.selectedIdx--
@@ -105,10 +143,53 @@ func (s *sessionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return s, nil
 }
 
+// updateSearch handles the keys while searching, the arrows move through the
+// results and the other keys edit the query
+func (s *sessionDialogCmp) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, sessionKeys.Up):
+		if s.resultIdx > 0 {
+			s.resultIdx--
+		}
+		return s, nil
+	case key.Matches(msg, sessionKeys.Down):
+		if s.resultIdx < len(s.results)-1 {
+			s.resultIdx++
+		}
+		return s, nil
+	case key.Matches(msg, sessionKeys.Enter):
+		if len(s.results) > 0 {
+			return s, util.CmdHandler(SearchResultSelectedMsg{
+				Result: s.results[s.resultIdx],
+			})
+		}
+		return s, nil
+	case key.Matches(msg, sessionKeys.Escape):
+		s.searching = false
+		s.input.Blur()
+		return s, nil
+	}
+
+	query := s.input.Value()
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	if s.input.Value() == query {
+		return s, cmd
+	}
+	if strings.TrimSpace(s.input.Value()) == "" {
+		s.results = nil
+		return s, cmd
+	}
+	return s, tea.Batch(cmd, util.CmdHandler(SessionSearchMsg{Query: s.input.Value()}))
+}
+
 func (s *sessionDialogCmp) View() string {
 	t := theme.CurrentTheme()
 	baseStyle := styles.BaseStyle()
-	
+
+	if s.searching {
+		return s.searchView()
+	}
 	if len(s.sessions) == 0 {
 		return baseStyle.Padding(1, 2).
 			Border(lipgloss.RoundedBorder()).
@@ -185,12 +266,93 @@ func (s *sessionDialogCmp) View() string {
 		Render(content)
 }
 
+// searchView shows the query and the matching messages, with the session
+// they belong to
+func (s *sessionDialogCmp) searchView() string {
+	t := theme.CurrentTheme()
+	baseStyle := styles.BaseStyle()
+	width := max(30, min(70, s.width-15))
+	const maxVisibleResults = 5
+
+	title := baseStyle.
+		Foreground(t.Primary()).
+		Bold(true).
+		Width(width).
+		Padding(0, 1).
+		Render("Search Messages")
+	s.input.Width = width - 4
+	input := baseStyle.Width(width).Padding(0, 1).Render(s.input.View())
+
+	var items []string
+	switch {
+	case strings.TrimSpace(s.input.Value()) == "":
+		items = append(items, baseStyle.Width(width).Padding(0, 1).Foreground(t.TextMuted()).Render("Type to search every session"))
+	case len(s.results) == 0:
+		items = append(items, baseStyle.Width(width).Padding(0, 1).Foreground(t.TextMuted()).Render("No messages found"))
+	}
+	startIdx := max(0, min(s.resultIdx-maxVisibleResults/2, len(s.results)-maxVisibleResults))
+	endIdx := min(startIdx+maxVisibleResults, len(s.results))
+	for i := startIdx; i < endIdx; i++ {
+		result := s.results[i]
+		titleStyle := baseStyle.Width(width).Padding(0, 1).Bold(true)
+		snippetStyle := baseStyle.Width(width).Padding(0, 1).Foreground(t.TextMuted())
+		matchStyle := lipgloss.NewStyle().Background(t.Background()).Foreground(t.Accent()).Bold(true)
+		if i == s.resultIdx {
+			titleStyle = titleStyle.Background(t.Primary()).Foreground(t.Background())
+			snippetStyle = snippetStyle.Background(t.Primary()).Foreground(t.Background())
+			matchStyle = matchStyle.Background(t.Primary()).Foreground(t.Background()).Underline(true)
+		}
+		snippet := highlightSnippet(result.Snippet, matchStyle, snippetStyle.UnsetWidth().UnsetPadding())
+		items = append(items,
+			titleStyle.Render(ansi.Truncate(result.SessionTitle, width-2, "…")),
+			snippetStyle.Render(ansi.Truncate(snippet, width-2, "…")),
+		)
+	}
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		baseStyle.Width(width).Render(""),
+		input,
+		baseStyle.Width(width).Render(""),
+		lipgloss.JoinVertical(lipgloss.Left, items...),
+	)
+
+	return baseStyle.Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(t.Background()).
+		BorderForeground(t.TextMuted()).
+		Width(lipgloss.Width(content) + 4).
+		Render(content)
+}
+
+// highlightSnippet renders a snippet of a search result on one line, with
+// the matched terms in matchStyle
+func highlightSnippet(snippet string, matchStyle, textStyle lipgloss.Style) string {
+	snippet = strings.Join(strings.Fields(snippet), " ")
+	var b strings.Builder
+	for {
+		start := strings.Index(snippet, message.MatchStart)
+		end := strings.Index(snippet, message.MatchEnd)
+		if start == -1 || end < start {
+			break
+		}
+		b.WriteString(textStyle.Render(snippet[:start]))
+		b.WriteString(matchStyle.Render(snippet[start+len(message.MatchStart) : end]))
+		snippet = snippet[end+len(message.MatchEnd):]
+	}
+	b.WriteString(textStyle.Render(snippet))
+	return b.String()
+}
+
 func (s *sessionDialogCmp) BindingKeys() []key.Binding {
 	return layout.KeyMapToSlice(sessionKeys)
 }
 
 func (s *sessionDialogCmp) SetSessions(sessions []session.Session) {
 	s.sessions = sessions
+	s.searching = false
+	s.input.Blur()
 
 	// If we have a selected session ID, find its index
 	if s.selectedSessionID != "" {
@@ -220,11 +382,30 @@ func (s *sessionDialogCmp) SetSelectedSession(sessionID string) {
 	}
 }
 
+// SetSearchResults shows the messages found for a query, unless the query
+// changed since
+func (s *sessionDialogCmp) SetSearchResults(query string, results []message.SearchResult) {
+	if !s.searching || query != s.input.Value() {
+		return
+	}
+	s.results = results
+	s.resultIdx = 0
+}
+
 // NewSessionDialogCmp creates a new session switching dialog
 func NewSessionDialogCmp() SessionDialog {
+	t := theme.CurrentTheme()
+	input := textinput.New()
+	input.Placeholder = "Search messages..."
+	input.Prompt = "/ "
+	input.PlaceholderStyle = input.PlaceholderStyle.Background(t.Background())
+	input.PromptStyle = input.PromptStyle.Background(t.Background()).Foreground(t.Primary())
+	input.TextStyle = input.TextStyle.Background(t.Background())
+
 	return &sessionDialogCmp{
 		sessions:          []session.Session{},
 		selectedIdx:       0,
 		selectedSessionID: "",
+		input:             input,
 	}
 }
@@ -55,8 +55,28 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.Dialogs.Filepicker = filepicker.(dialog.FilepickerCmp)
 		cmds = append(cmds, filepickerCmd)
 
+		branch, branchCmd := a.Dialogs.Branch.Update(msg)
+		a.Dialogs.Branch = branch.(dialog.BranchDialog)
+		cmds = append(cmds, branchCmd)
+
+		branchTree, branchTreeCmd := a.Dialogs.BranchTree.Update(msg)
+		a.Dialogs.BranchTree = branchTree.(dialog.BranchTreeDialog)
+		cmds = append(cmds, branchTreeCmd)
+
+		agentDialog, agentCmd := a.Dialogs.Agent.Update(msg)
+		a.Dialogs.Agent = agentDialog.(dialog.AgentDialog)
+		cmds = append(cmds, agentCmd)
+
 		a.Dialogs.Init.SetSize(msg.Width, msg.Height)
 
+		revert, revertCmd := a.Dialogs.Revert.Update(msg)
+		a.Dialogs.Revert = revert.(dialog.RevertDialogCmp)
+		cmds = append(cmds, revertCmd)
+
+		plan, planCmd := a.Dialogs.Plan.Update(msg)
+		a.Dialogs.Plan = plan.(dialog.PlanDialogCmp)
+		cmds = append(cmds, planCmd)
+
 		if a.ShowMultiArguments {
 			a.Dialogs.MultiArguments.SetSize(msg.Width, msg.Height)
 			args, argsCmd := a.Dialogs.MultiArguments.Update(msg)
@@ -116,6 +136,10 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Permission
 	case pubsub.Event[permission.PermissionRequest]:
+		// Answered requests are published again as deleted
+		if msg.Type != pubsub.CreatedEvent || msg.Payload.AutoApproved {
+			return a, nil
+		}
 		a.ShowPermissions = true
 		return a, a.Dialogs.Permissions.SetPermissions(msg.Payload)
 	case dialog.PermissionResponseMsg:
@@ -125,6 +149,10 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.App.Permissions.Grant(msg.Permission)
 		case dialog.PermissionAllowForSession:
 			a.App.Permissions.GrantPersistant(msg.Permission)
+		case dialog.PermissionAllowAlways:
+			if err := a.App.Permissions.GrantAlways(msg.Permission); err != nil {
+				cmd = util.ReportError(err)
+			}
 		case dialog.PermissionDeny:
 			a.App.Permissions.Deny(msg.Permission)
 		}
@@ -142,10 +170,169 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.ShowSession = false
 		return a, nil
 
+	case dialog.SessionSearchMsg:
+		results, err := a.App.Messages.Search(context.Background(), msg.Query, 50)
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		a.Dialogs.Session.SetSearchResults(msg.Query, results)
+		return a, nil
+
+	case dialog.SearchResultSelectedMsg:
+		a.ShowSession = false
+		if a.CurrentPage != page.ChatPage {
+			return a, nil
+		}
+		sess, err := a.App.Sessions.Get(context.Background(), msg.Result.SessionID)
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		// The session is rendered before scrolling to the message
+		return a, tea.Sequence(
+			util.CmdHandler(chat.SessionSelectedMsg(sess)),
+			util.CmdHandler(chat.MessageFocusedMsg{ID: msg.Result.MessageID}),
+		)
+
 	case dialog.CloseCommandDialogMsg:
 		a.ShowCommand = false
 		return a, nil
 
+	case showBranchDialogMsg:
+		if a.SelectedSession.ID == "" {
+			return a, util.ReportWarn("No active session to branch")
+		}
+		messages, err := a.App.Messages.List(context.Background(), a.SelectedSession.ID)
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		a.Dialogs.Branch.SetMessages(messages)
+		a.ShowBranch = true
+		return a, nil
+
+	case showBranchTreeDialogMsg:
+		if a.SelectedSession.ID == "" {
+			return a, util.ReportWarn("No active session")
+		}
+		sessions, err := a.App.Sessions.List(context.Background())
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		a.Dialogs.BranchTree.SetSessions(sessions, a.SelectedSession.ID)
+		a.ShowBranchTree = true
+		return a, nil
+
+	case startUndoMsg:
+		if a.SelectedSession.ID == "" {
+			return a, util.ReportWarn("No active session to undo")
+		}
+		if a.App.CoderAgent.IsSessionBusy(a.SelectedSession.ID) {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		plan, err := a.App.PlanRevert(context.Background(), a.SelectedSession.ID, "")
+		if err != nil {
+			return a, util.ReportWarn(err.Error())
+		}
+		a.Dialogs.Revert.SetPlan(plan)
+		a.ShowRevert = true
+		return a, nil
+
+	case dialog.CloseRevertDialogMsg:
+		a.ShowRevert = false
+		if !msg.Confirm {
+			return a, nil
+		}
+		if err := a.App.Revert(context.Background(), msg.Plan); err != nil {
+			return a, util.ReportError(err)
+		}
+		return a, tea.Batch(
+			util.CmdHandler(chat.EditorSetValueMsg(msg.Plan.Prompt)),
+			util.ReportInfo(fmt.Sprintf("Reverted %d message(s) and %d file(s)", len(msg.Plan.Messages), len(msg.Plan.Changes))),
+		)
+
+	case togglePlanModeMsg:
+		// Plan mode is set per session, start one for the first prompt
+		sessionCmd, err := a.ensureSession()
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		mode := agent.ModePlan
+		info := "Plan mode on: the agent only reads files and submits a plan for approval"
+		if a.App.CoderAgent.Mode(a.SelectedSession.ID) == agent.ModePlan {
+			mode = agent.ModeBuild
+			info = "Plan mode off: the agent can use all of its tools"
+		}
+		if err := a.App.CoderAgent.SetMode(a.SelectedSession.ID, mode); err != nil {
+			return a, util.ReportWarn(err.Error())
+		}
+		return a, tea.Batch(sessionCmd, util.ReportInfo(info))
+
+	case showAgentDialogMsg:
+		current := config.AgentCoder
+		if a.SelectedSession.Agent != "" {
+			current = config.AgentName(a.SelectedSession.Agent)
+		}
+		a.Dialogs.Agent.SetAgents(current)
+		a.ShowAgent = true
+		return a, nil
+
+	case dialog.CloseAgentDialogMsg:
+		a.ShowAgent = false
+		return a, nil
+
+	case dialog.AgentSelectedMsg:
+		a.ShowAgent = false
+		sessionCmd, err := a.ensureSession()
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		if err := a.App.CoderAgent.SetAgent(context.Background(), a.SelectedSession.ID, msg.Name); err != nil {
+			return a, util.ReportWarn(err.Error())
+		}
+		return a, tea.Batch(sessionCmd, util.ReportInfo(fmt.Sprintf("Switched to the %s agent", msg.Name)))
+
+	case dialog.ClosePlanDialogMsg:
+		a.ShowPlan = false
+		switch msg.Decision {
+		case dialog.PlanApprove, dialog.PlanEdit:
+			if err := a.App.CoderAgent.SetMode(msg.SessionID, agent.ModeBuild); err != nil {
+				return a, util.ReportError(err)
+			}
+			if msg.Decision == dialog.PlanEdit {
+				return a, util.CmdHandler(chat.EditorSetValueMsg(msg.Plan.ExecutePrompt()))
+			}
+			return a, util.CmdHandler(chat.SendMsg{Text: msg.Plan.ExecutePrompt()})
+		default:
+			return a, util.ReportInfo("Plan rejected, tell the agent what to change")
+		}
+
+	case chat.SendMsg:
+		// Prompts like /undo run the command palette entry with that ID
+		if id, ok := strings.CutPrefix(strings.TrimSpace(msg.Text), "/"); ok {
+			if command, found := a.findCommand(id); found && command.Handler != nil {
+				return a, command.Handler(command)
+			}
+		}
+
+	case dialog.CloseBranchDialogMsg:
+		a.ShowBranch = false
+		a.ShowBranchTree = false
+		return a, nil
+
+	case dialog.BranchSelectedMsg:
+		a.ShowBranch = false
+		if a.App.CoderAgent.IsSessionBusy(a.SelectedSession.ID) {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		branch, err := a.App.Sessions.Branch(context.Background(), a.SelectedSession.ID, msg.MessageID)
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		return a, tea.Batch(
+			util.CmdHandler(chat.SessionSelectedMsg(branch)),
+			util.CmdHandler(chat.EditorSetValueMsg(msg.Prompt)),
+			util.ReportInfo("Branched session, edit the prompt and send it again"),
+		)
+
 	case startCompactSessionMsg:
 		// Start compacting the current session
 		a.IsCompacting = true
@@ -165,23 +352,41 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case pubsub.Event[agent.AgentEvent]:
 		payload := msg.Payload
+		// The chat shows the output of the running tools
+		if payload.Type == agent.AgentEventTypeToolProgress {
+			a.Pages[a.CurrentPage], cmd = a.Pages[a.CurrentPage].Update(msg)
+			return a, cmd
+		}
 		if payload.Error != nil {
 			a.IsCompacting = false
 			return a, util.ReportError(payload.Error)
 		}
 
+		if payload.Type == agent.AgentEventTypeBudget || payload.Type == agent.AgentEventTypeBudgetWarning {
+			s, _ := a.Status.Update(msg)
+			a.Status = s.(core.StatusCmp)
+			if payload.Type == agent.AgentEventTypeBudgetWarning && payload.SessionID == a.SelectedSession.ID {
+				return a, util.ReportWarn("Approaching the " + payload.Budget.String())
+			}
+			return a, nil
+		}
+
 		a.CompactingMessage = payload.Progress
 
+		if payload.Done && payload.Budget != nil && payload.Message.SessionID == a.SelectedSession.ID {
+			return a, util.ReportError(fmt.Errorf("%w, %s", agent.ErrBudgetExceeded, payload.Budget))
+		}
+
+		if payload.Done && payload.Plan != nil && payload.Message.SessionID == a.SelectedSession.ID {
+			a.Dialogs.Plan.SetPlan(payload.Message.SessionID, *payload.Plan)
+			a.ShowPlan = true
+			return a, nil
+		}
+
+		// The agent summarizes sessions by itself with AutoCompact
 		if payload.Done && payload.Type == agent.AgentEventTypeSummarize {
 			a.IsCompacting = false
 			return a, util.ReportInfo("Session summarization complete")
-		} else if payload.Done && payload.Type == agent.AgentEventTypeResponse && a.SelectedSession.ID != "" {
-			model := a.App.CoderAgent.Model()
-			contextWindow := model.ContextWindow
-			tokens := a.SelectedSession.CompletionTokens + a.SelectedSession.PromptTokens
-			if (tokens >= int64(float64(contextWindow)*0.95)) && config.Get().AutoCompact {
-				return a, util.CmdHandler(startCompactSessionMsg{})
-			}
 		}
 		// Continue listening for events
 		return a, nil
@@ -244,6 +449,7 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	case dialog.SessionSelectedMsg:
 		a.ShowSession = false
+		a.ShowBranchTree = false
 		if a.CurrentPage == page.ChatPage {
 			return a, util.CmdHandler(chat.SessionSelectedMsg(msg.Session))
 		}
@@ -326,6 +532,21 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if a.ShowMultiArguments {
 				a.ShowMultiArguments = false
 			}
+			if a.ShowBranch {
+				a.ShowBranch = false
+			}
+			if a.ShowBranchTree {
+				a.ShowBranchTree = false
+			}
+			if a.ShowRevert {
+				a.ShowRevert = false
+			}
+			if a.ShowPlan {
+				a.ShowPlan = false
+			}
+			if a.ShowAgent {
+				a.ShowAgent = false
+			}
 			return a, nil
 		case key.Matches(msg, keys.SwitchSession):
 			if a.CurrentPage == page.ChatPage && !a.ShowQuit && !a.ShowPermissions && !a.ShowCommand {
@@ -499,6 +720,56 @@ func (a *state.AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	if a.ShowBranch {
+		d, branchCmd := a.Dialogs.Branch.Update(msg)
+		a.Dialogs.Branch = d.(dialog.BranchDialog)
+		cmds = append(cmds, branchCmd)
+		// Only block key messages send all other messages down
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return a, tea.Batch(cmds...)
+		}
+	}
+
+	if a.ShowBranchTree {
+		d, branchTreeCmd := a.Dialogs.BranchTree.Update(msg)
+		a.Dialogs.BranchTree = d.(dialog.BranchTreeDialog)
+		cmds = append(cmds, branchTreeCmd)
+		// Only block key messages send all other messages down
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return a, tea.Batch(cmds...)
+		}
+	}
+
+	if a.ShowAgent {
+		d, agentCmd := a.Dialogs.Agent.Update(msg)
+		a.Dialogs.Agent = d.(dialog.AgentDialog)
+		cmds = append(cmds, agentCmd)
+		// Only block key messages send all other messages down
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return a, tea.Batch(cmds...)
+		}
+	}
+
+	if a.ShowPlan {
+		d, planCmd := a.Dialogs.Plan.Update(msg)
+		a.Dialogs.Plan = d.(dialog.PlanDialogCmp)
+		cmds = append(cmds, planCmd)
+		// Only block key messages send all other messages down
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return a, tea.Batch(cmds...)
+		}
+	}
+
+	if a.ShowRevert {
+		d, revertCmd := a.Dialogs.Revert.Update(msg)
+		a.Dialogs.Revert = d.(dialog.RevertDialogCmp)
+		cmds = append(cmds, revertCmd)
+		// Only block key messages send all other messages down
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return a, tea.Batch(cmds...)
+		}
+	}
+
 	if a.ShowTheme {
 		d, themeCmd := a.Dialogs.Theme.Update(msg)
 		a.Dialogs.Theme = d.(dialog.ThemeDialog)
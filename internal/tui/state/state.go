@@ -20,6 +20,11 @@ type Dialogs struct {
 	Filepicker           dialog.FilepickerCmp
 	Theme                dialog.ThemeDialog
 	MultiArguments       dialog.MultiArgumentsDialogCmp
+	Branch               dialog.BranchDialog
+	BranchTree           dialog.BranchTreeDialog
+	Revert               dialog.RevertDialogCmp
+	Plan                 dialog.PlanDialogCmp
+	Agent                dialog.AgentDialog
 }
 
 type AppModel struct {
@@ -41,6 +46,11 @@ type AppModel struct {
 	ShowFilepicker  bool
 	ShowTheme       bool
 	ShowMultiArguments bool
+	ShowBranch        bool
+	ShowBranchTree    bool
+	ShowRevert        bool
+	ShowPlan          bool
+	ShowAgent         bool
 	IsCompacting      bool
 	CompactingMessage string
 }
@@ -37,6 +37,14 @@ type keyMap struct {
 
 type startCompactSessionMsg struct{}
 
+type (
+	showBranchDialogMsg     struct{}
+	showBranchTreeDialogMsg struct{}
+	startUndoMsg            struct{}
+	togglePlanModeMsg       struct{}
+	showAgentDialogMsg      struct{}
+)
+
 const (
 	quitKey = "q"
 )
@@ -128,6 +136,11 @@ func New(app *app.App) tea.Model {
 				Init:        dialog.NewInitDialogCmp(),
 				Theme:       dialog.NewThemeDialogCmp(),
 				Filepicker:  dialog.NewFilepickerCmp(app),
+				Branch:      dialog.NewBranchDialogCmp(),
+				BranchTree:  dialog.NewBranchTreeDialogCmp(),
+				Revert:      dialog.NewRevertDialogCmp(),
+				Plan:        dialog.NewPlanDialogCmp(),
+				Agent:       dialog.NewAgentDialogCmp(),
 			},
 			App: app,
 			Pages: map[page.PageID]tea.Model{
@@ -198,6 +211,64 @@ If there are Cursor rules (in .cursor/rules/ or .cursorrules) or Copilot rules (
 		},
 	})
 
+	model.RegisterCommand(dialog.Command{
+		ID:          "undo",
+		Title:       "Undo",
+		Description: "Restore the files changed since your last prompt and revert that turn",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			return util.CmdHandler(startUndoMsg{})
+		},
+	})
+
+	model.RegisterCommand(dialog.Command{
+		ID:          "plan",
+		Title:       "Toggle Plan Mode",
+		Description: "Limit the agent to read-only tools until you approve its plan",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			return util.CmdHandler(togglePlanModeMsg{})
+		},
+	})
+
+	model.RegisterCommand(dialog.Command{
+		ID:          "agent",
+		Title:       "Switch Agent",
+		Description: "Choose the agent answering in the current session",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			return util.CmdHandler(showAgentDialogMsg{})
+		},
+	})
+
+	model.RegisterCommand(dialog.Command{
+		ID:          "branch",
+		Title:       "Branch Session",
+		Description: "Start a new session from an earlier prompt of the current one",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			return util.CmdHandler(showBranchDialogMsg{})
+		},
+	})
+
+	model.RegisterCommand(dialog.Command{
+		ID:          "branch-tree",
+		Title:       "Session Branches",
+		Description: "Browse the branches of the current session",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			return util.CmdHandler(showBranchTreeDialogMsg{})
+		},
+	})
+
+	model.RegisterCommand(dialog.Command{
+		ID:          "restart-lsp",
+		Title:       "Restart Language Servers",
+		Description: "Restart the language servers, including the crashed ones",
+		Handler: func(cmd dialog.Command) tea.Cmd {
+			names := app.RestartLSPClients()
+			if len(names) == 0 {
+				return util.ReportWarn("No language server is configured")
+			}
+			return util.ReportInfo("Restarting language servers: " + strings.Join(names, ", "))
+		},
+	})
+
 	model.RegisterCommand(dialog.Command{
 		ID:          "setup-agent-os",
 		Title:       "Setup Agent OS",
@@ -281,3 +352,17 @@ func (a *state.AppModel) findCommand(id string) (dialog.Command, bool) {
 	}
 	return dialog.Command{}, false
 }
+
+// ensureSession creates a session when none is selected, for settings that
+// are made per session before the first prompt is sent.
+func (a *state.AppModel) ensureSession() (tea.Cmd, error) {
+	if a.SelectedSession.ID != "" {
+		return nil, nil
+	}
+	session, err := a.App.Sessions.Create(context.Background(), "New Session")
+	if err != nil {
+		return nil, err
+	}
+	a.SelectedSession = session
+	return util.CmdHandler(chat.SessionSelectedMsg(session)), nil
+}
@@ -3,9 +3,13 @@ package main
 import (
 	"github.com/opencode-ai/opencode/cmd"
 	"github.com/opencode-ai/opencode/internal/logging"
+	"github.com/opencode-ai/opencode/internal/sandbox"
 )
 
 func main() {
+	// Runs the sandboxed commands when OpenCode was started as a sandbox
+	sandbox.Init()
+
 	defer logging.RecoverPanic("main", func() {
 		logging.ErrorPersist("Application terminated due to unhandled panic")
 	})